@@ -0,0 +1,277 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package aggregator merges snapshots pushed by several processes, such as
+// the workers of a pre-forking server, into one registry so they can be
+// reported under a single host identity.
+package aggregator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/internal/listen"
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/metricspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrServerClosed is returned by ServePacket after Close.
+var ErrServerClosed = errors.New("aggregator: server closed")
+
+// Server merges metricspb snapshots into a registry. Snapshots are received
+// over gRPC by registering the Server as a metricspb.CollectorServer (the
+// processes use reporter.NewGRPCReporter) or as UDP datagrams by ServePacket
+// (the processes use reporter.NewUDPSnapshotReporter).
+//
+// Counters must be sent as the change since the previous snapshot, which is
+// the default, and are added to a *metrics.Counter. Distributions sent with
+// buckets are merged bucket by bucket into a histogram from
+// metrics.NewDefaultBucketedHistogram, whose percentiles are then computed
+// over the values of every source to within the width of its buckets.
+// Other distributions, including those of sampled histograms and timers, are
+// merged into a *metrics.Distribution. Other values, such as gauges and
+// meter rates, are kept per source and reported as their sum, except for
+// the percentiles of histograms sent without buckets, which are reported as
+// the largest of any source. Those are only an approximation since
+// percentiles can't be combined.
+// Values and distributions with tags, including the tags of the snapshot,
+// are registered under metricspb.TaggedName so that the points of a family
+// and of differently tagged sources are kept apart.
+type Server struct {
+	metricspb.UnimplementedCollectorServer
+
+	registry metrics.Registry
+
+	// Expiry is how long the gauges of a source are kept after its last
+	// snapshot. Zero keeps them until the server is discarded.
+	Expiry time.Duration
+	// ErrorLog logs snapshots that can not be merged. If nil the standard
+	// logger is used.
+	ErrorLog *log.Logger
+
+	mu     sync.Mutex
+	gauges map[string]*gauge
+	server listen.Server
+}
+
+type sourceValue struct {
+	value   float64
+	updated time.Time
+}
+
+type gauge struct {
+	max     bool
+	sources map[string]sourceValue
+}
+
+// NewServer returns a Server merging snapshots into registry.
+func NewServer(registry metrics.Registry) *Server {
+	return &Server{
+		registry: registry,
+		gauges:   make(map[string]*gauge),
+		server:   listen.Server{ErrClosed: ErrServerClosed},
+	}
+}
+
+// Merge adds a snapshot to the registry. It returns an error if a metric is
+// already registered with a different type but merges the rest. The
+// percentiles of a histogram are recognised by the distribution sent in the
+// same snapshot, which reporter.NewUDPSnapshotReporter keeps together when
+// it splits a snapshot across packets.
+func (s *Server) Merge(snapshot *metricspb.Snapshot) error {
+	var errs []string
+	// Whether each distribution was merged bucket by bucket, in which case
+	// the histogram computes its own percentiles.
+	distributions := make(map[string]bool, len(snapshot.Distributions))
+	for _, d := range snapshot.Distributions {
+		name := metricspb.TaggedName(d.Name, withTags(snapshot.Tags, d.Tags))
+		bucketed, err := s.mergeDistribution(name, d)
+		if err != "" {
+			errs = append(errs, err)
+		}
+		distributions[d.Name] = distributions[d.Name] || bucketed
+	}
+	now := time.Now()
+	for _, v := range snapshot.Values {
+		name := metricspb.TaggedName(v.Name, withTags(snapshot.Tags, v.Tags))
+		if v.Counter {
+			c, ok := s.registry.GetOrAdd(name, func() interface{} { return metrics.NewCounter() }).(*metrics.Counter)
+			if !ok {
+				errs = append(errs, s.conflict(name, "counter"))
+			} else if v.Value > 0 {
+				c.Inc(uint64(math.Round(v.Value)))
+			}
+			continue
+		}
+		max := false
+		if i := strings.LastIndexByte(v.Name, '/'); i > 0 {
+			bucketed, ok := distributions[v.Name[:i]]
+			if bucketed {
+				// Computed from the merged buckets instead
+				continue
+			}
+			max = ok
+		}
+		if err := s.setGauge(name, snapshot.Source, v.Value, max, now); err != "" {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("aggregator: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// mergeDistribution merges d into the metric registered as name, returning
+// whether it was merged bucket by bucket.
+func (s *Server) mergeDistribution(name string, d *metricspb.Distribution) (bool, string) {
+	if len(d.Buckets) == 0 {
+		dist, ok := s.registry.GetOrAdd(name, func() interface{} { return metrics.NewDistribution() }).(*metrics.Distribution)
+		if !ok {
+			return false, s.conflict(name, "distribution")
+		}
+		dist.Merge(d.DistributionValue())
+		return false, ""
+	}
+	m := s.registry.GetOrAdd(name, func() interface{} { return metrics.NewDefaultBucketedHistogram() })
+	switch m := m.(type) {
+	case metrics.BucketHistogram:
+		data := &metrics.HistogramData{
+			Count:   d.Count,
+			Sum:     int64(math.Round(d.Sum)),
+			Min:     int64(d.Min),
+			Max:     int64(d.Max),
+			Buckets: d.HistogramBuckets(),
+		}
+		if err := data.MergeInto(m); err != nil {
+			return false, err.Error()
+		}
+		return true, ""
+	case *metrics.Distribution:
+		// Registered before the source sent buckets
+		m.Merge(d.DistributionValue())
+		return false, ""
+	}
+	return false, s.conflict(name, "histogram")
+}
+
+// withTags returns the tags of a point combined with those of its snapshot.
+func withTags(snapshotTags, tags map[string]string) map[string]string {
+	if len(snapshotTags) == 0 {
+		return tags
+	}
+	out := make(map[string]string, len(snapshotTags)+len(tags))
+	for k, v := range snapshotTags {
+		out[k] = v
+	}
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *Server) setGauge(name, source string, value float64, max bool, now time.Time) string {
+	s.mu.Lock()
+	g := s.gauges[name]
+	if g == nil {
+		g = &gauge{sources: make(map[string]sourceValue)}
+		s.gauges[name] = g
+	}
+	g.max = max
+	g.sources[source] = sourceValue{value: value, updated: now}
+	s.mu.Unlock()
+
+	f := s.registry.GetOrAdd(name, func() interface{} {
+		return metrics.GaugeFunc(func() float64 { return s.gaugeValue(name) })
+	})
+	if _, ok := f.(metrics.GaugeFunc); !ok {
+		return s.conflict(name, "gauge")
+	}
+	return ""
+}
+
+// gaugeValue combines the values of a gauge from all sources, dropping
+// those that have expired.
+func (s *Server) gaugeValue(name string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.gauges[name]
+	if g == nil {
+		return 0
+	}
+	value := 0.0
+	first := true
+	for source, v := range g.sources {
+		if s.Expiry > 0 && time.Since(v.updated) > s.Expiry {
+			delete(g.sources, source)
+			continue
+		}
+		if g.max {
+			if first || v.value > value {
+				value = v.value
+			}
+		} else {
+			value += v.value
+		}
+		first = false
+	}
+	return value
+}
+
+func (s *Server) conflict(name, typ string) string {
+	return fmt.Sprintf("%s is already registered as %T not a %s", name, s.registry.Get(name), typ)
+}
+
+// StreamMetrics implements metricspb.CollectorServer.
+func (s *Server) StreamMetrics(stream metricspb.Collector_StreamMetricsServer) error {
+	var n uint64
+	for {
+		snapshot, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&metricspb.StreamMetricsResponse{Received: n})
+		} else if err != nil {
+			return err
+		}
+		n++
+		if err := s.Merge(snapshot); err != nil {
+			s.logf("%s", err.Error())
+		}
+	}
+}
+
+// ServePacket reads datagrams, each holding a marshaled metricspb.Snapshot,
+// from conn until it's closed.
+func (s *Server) ServePacket(conn net.PacketConn) error {
+	return s.server.ServePacket(conn, func(packet []byte, addr net.Addr) {
+		snapshot := &metricspb.Snapshot{}
+		if err := proto.Unmarshal(packet, snapshot); err != nil {
+			s.logf("aggregator: invalid snapshot from %s: %s", addr, err.Error())
+			return
+		}
+		if err := s.Merge(snapshot); err != nil {
+			s.logf("%s", err.Error())
+		}
+	})
+}
+
+// Close stops ServePacket. Streams are ended by stopping the gRPC server.
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/metricspb"
+	"github.com/samuel/go-metrics/reporter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func workerSnapshot(source string, requests, conns, p99 float64, latency float64) *metricspb.Snapshot {
+	return &metricspb.Snapshot{
+		Source: source,
+		Values: []*metricspb.Value{
+			{Name: "requests", Value: requests, Counter: true},
+			{Name: "conns", Value: conns},
+			{Name: "latency/p99", Value: p99},
+		},
+		Distributions: []*metricspb.Distribution{
+			{Name: "latency", Count: 1, Sum: latency, Min: latency, Max: latency},
+		},
+	}
+}
+
+func TestServerMerge(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	for i := 0; i < 2; i++ {
+		if err := s.Merge(workerSnapshot("a", 2, 3, 10, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Merge(workerSnapshot("b", 5, 4, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := registry.Counter("requests"); c == nil || c.Count() != 9 {
+		t.Fatalf("Expected requests counter of 9. Got %+v", registry.Get("requests"))
+	}
+	if g := registry.Get("conns").(metrics.GaugeFunc); g.Value() != 7 {
+		t.Fatalf("Expected the sum of conns of 7. Got %f", g.Value())
+	}
+	if g := registry.Get("latency/p99").(metrics.GaugeFunc); g.Value() != 30 {
+		t.Fatalf("Expected the largest p99 of 30. Got %f", g.Value())
+	}
+	if d := registry.Get("latency").(*metrics.Distribution).Value(); d.Count != 3 || d.Sum != 50 || d.Min != 10 || d.Max != 30 {
+		t.Fatalf("Expected 3 latencies from 10 to 30. Got %+v", d)
+	}
+
+	registry.Add("taken", metrics.NewIntegerGauge())
+	err := s.Merge(&metricspb.Snapshot{Values: []*metricspb.Value{{Name: "taken", Value: 1, Counter: true}, {Name: "other", Value: 1}}})
+	if err == nil {
+		t.Fatal("Expected an error for a metric of another type")
+	}
+	if registry.Get("other") == nil {
+		t.Fatal("Expected the rest of the snapshot to be merged")
+	}
+}
+
+func TestServerMergeTags(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	err := s.Merge(&metricspb.Snapshot{
+		Values: []*metricspb.Value{
+			{Name: "requests", Value: 2, Counter: true, Tags: map[string]string{"route": "/a"}},
+			{Name: "requests", Value: 5, Counter: true, Tags: map[string]string{"route": "/b"}},
+		},
+		Distributions: []*metricspb.Distribution{
+			{Name: "latency", Count: 1, Sum: 10, Min: 10, Max: 10, Tags: map[string]string{"route": "/a", "code": "200"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := registry.Counter("requests;route=/b"); c == nil || c.Count() != 5 {
+		t.Fatalf("Expected requests of 5 for /b. Got %+v", registry.Get("requests;route=/b"))
+	}
+	if _, ok := registry.Get("latency;code=200;route=/a").(*metrics.Distribution); !ok {
+		t.Fatalf("Expected a tagged distribution. Got %+v", registry.Names())
+	}
+}
+
+func TestServerMergeSnapshotTags(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	for _, env := range []string{"prod", "staging"} {
+		err := s.Merge(&metricspb.Snapshot{
+			Tags:   map[string]string{"env": env},
+			Values: []*metricspb.Value{{Name: "requests", Value: 2, Counter: true, Tags: map[string]string{"route": "/a"}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"requests;env=prod;route=/a", "requests;env=staging;route=/a"} {
+		if c := registry.Counter(name); c == nil || c.Count() != 2 {
+			t.Fatalf("Expected %s of 2. Got %+v", name, registry.Names())
+		}
+	}
+}
+
+func TestServerMergeBuckets(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	// The largest p50 of any source is high but most values are low
+	for i, source := range []string{"a", "b", "c"} {
+		h := metrics.NewDefaultBucketedHistogram()
+		for j := 0; j < 100; j++ {
+			if i == 0 {
+				h.Update(1000)
+			} else {
+				h.Update(10)
+			}
+		}
+		p := h.Percentiles([]float64{0.5})[0]
+		err := s.Merge(&metricspb.Snapshot{
+			Source: source,
+			Values: []*metricspb.Value{{Name: "latency/p50", Value: float64(p)}},
+			Distributions: []*metricspb.Distribution{{
+				Name:    "latency",
+				Count:   100,
+				Sum:     float64(h.Distribution().Sum),
+				Min:     h.Distribution().Min,
+				Max:     h.Distribution().Max,
+				Buckets: bucketMessages(h.(metrics.BucketHistogram).Buckets()),
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, ok := registry.Get("latency").(metrics.BucketHistogram)
+	if !ok {
+		t.Fatalf("Expected a bucketed histogram. Got %T", registry.Get("latency"))
+	}
+	if d := h.Distribution(); d.Count != 300 || d.Min != 10 || d.Max != 1000 {
+		t.Fatalf("Expected 300 values from 10 to 1000. Got %+v", d)
+	}
+	if p := h.Percentiles([]float64{0.5})[0]; p < 10 || p > 11 {
+		t.Fatalf("Expected a merged p50 of about 10. Got %d", p)
+	}
+	if registry.Get("latency/p50") != nil {
+		t.Fatal("Expected percentiles to come from the merged histogram")
+	}
+}
+
+func bucketMessages(buckets []metrics.HistogramBucket) []*metricspb.Bucket {
+	out := make([]*metricspb.Bucket, len(buckets))
+	for i, b := range buckets {
+		out[i] = &metricspb.Bucket{UpperBound: float64(b.UpperBound), Count: b.Count}
+	}
+	return out
+}
+
+func TestServerExpiry(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	s.Expiry = 50 * time.Millisecond
+	s.Merge(workerSnapshot("a", 0, 3, 0, 1))
+	time.Sleep(100 * time.Millisecond)
+	s.Merge(workerSnapshot("b", 0, 4, 0, 1))
+	if g := registry.Get("conns").(metrics.GaugeFunc); g.Value() != 4 {
+		t.Fatalf("Expected the expired source to be dropped. Got %f", g.Value())
+	}
+}
+
+func TestServerPacket(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	s.ErrorLog = log.New(ioutil.Discard, "", 0)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.ServePacket(pc) }()
+
+	worker := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	worker.Add("requests", counter)
+	counter.Inc(3)
+	r := reporter.NewUDPSnapshotReporter(worker, time.Minute, false, pc.LocalAddr().String(), "worker1")
+	r.Flush()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c := registry.Counter("requests"); c == nil || c.Count() != 3; c = registry.Counter("requests") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected requests counter of 3. Got %+v", c)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Close()
+	if err := <-errc; err != ErrServerClosed {
+		t.Fatalf("Expected %v. Got %v", ErrServerClosed, err)
+	}
+}
+
+func TestServerGRPC(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := NewServer(registry)
+	ln := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	metricspb.RegisterCollectorServer(server, s)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, source := range []string{"worker1", "worker2"} {
+		worker := metrics.NewRegistry()
+		counter := metrics.NewCounter()
+		worker.Add("requests", counter)
+		counter.Inc(2)
+		r := reporter.NewGRPCReporter(worker, time.Minute, false, conn, source)
+		r.Start()
+		r.Stop()
+	}
+	if c := registry.Counter("requests"); c == nil || c.Count() != 4 {
+		t.Fatalf("Expected requests counter of 4. Got %+v", c)
+	}
+}
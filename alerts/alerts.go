@@ -0,0 +1,306 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package alerts evaluates threshold rules against the metrics of a
+// registry and notifies handlers when a rule starts or stops firing, giving
+// small deployments basic alerting without a monitoring stack.
+//
+//	engine := alerts.NewEngine(metrics.DefaultRegistry, 10*time.Second)
+//	engine.AddRule(alerts.Rule{Name: "slow", Metric: "http/*/latency/p99", Op: alerts.Above, Threshold: 500000, For: time.Minute})
+//	engine.OnChange(alerts.WebhookHandler("https://hooks.example.com/alerts", nil))
+//	engine.Start()
+//
+// A Detector flags values that stray from a metric's recent behaviour,
+// catching regressions that fixed thresholds miss. Rules and detectors look
+// at these values of each metric, none of which resets the metric:
+//
+//	counters       the change per second since the previous evaluation
+//	gauges         the value
+//	meters         the one minute rate
+//	EWMAs          the rate, or the mean of an EWMAGauge
+//	histograms     name/p50, name/p75, name/p90, name/p99 and name/p999
+//	distributions  name/mean
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// Op compares a metric's value with a rule's threshold.
+type Op int
+
+const (
+	Above Op = iota
+	AboveOrEqual
+	Below
+	BelowOrEqual
+)
+
+func (op Op) String() string {
+	switch op {
+	case Above:
+		return ">"
+	case AboveOrEqual:
+		return ">="
+	case Below:
+		return "<"
+	case BelowOrEqual:
+		return "<="
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+func (op Op) compare(value, threshold float64) bool {
+	switch op {
+	case Above:
+		return value > threshold
+	case AboveOrEqual:
+		return value >= threshold
+	case Below:
+		return value < threshold
+	case BelowOrEqual:
+		return value <= threshold
+	}
+	return false
+}
+
+// Rule fires for a metric when its value compares with Threshold by Op for
+// at least For.
+type Rule struct {
+	Name string
+	// Metric is a metric name or a pattern of names as used by path.Match,
+	// such as "http/*/errors", in which case the rule is evaluated for each
+	// matching metric separately.
+	Metric    string
+	Op        Op
+	Threshold float64
+	// For is how long the condition must hold before the rule fires. Zero
+	// fires at the first evaluation that finds it true.
+	For time.Duration
+}
+
+// State is whether a rule is firing for a metric.
+type State int
+
+const (
+	OK State = iota
+	Alert
+)
+
+func (s State) String() string {
+	if s == Alert {
+		return "alert"
+	}
+	return "ok"
+}
+
+// MarshalText encodes the state as "ok" or "alert".
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Event is a change in the state of a rule for a metric.
+type Event struct {
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	State     State     `json:"state"`
+	Value     float64   `json:"value"`
+	Op        string    `json:"op"`
+	Threshold float64   `json:"threshold"`
+	Time      time.Time `json:"time"`
+}
+
+func (e Event) String() string {
+	if e.State == Alert {
+		return fmt.Sprintf("%s: %s is %g %s %g", e.Rule, e.Metric, e.Value, e.Op, e.Threshold)
+	}
+	return fmt.Sprintf("%s: %s is ok at %g", e.Rule, e.Metric, e.Value)
+}
+
+// Handler is called with each change of state.
+type Handler func(Event)
+
+// state is the state of a rule for one metric.
+type state struct {
+	since time.Time // when the condition became true, zero if it's false
+	state State
+	value float64
+}
+
+// Engine evaluates rules against a registry every interval.
+type Engine struct {
+	registry metrics.Registry
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	rules    []Rule
+	handlers []Handler
+	states   map[[2]string]*state // by rule and metric name
+	sampler  sampler
+
+	runner runner
+}
+
+// NewEngine returns an engine that evaluates rules against registry every
+// interval once started.
+func NewEngine(registry metrics.Registry, interval time.Duration) *Engine {
+	return &Engine{
+		registry: registry,
+		interval: interval,
+		now:      time.Now,
+		states:   make(map[[2]string]*state),
+	}
+}
+
+// AddRule adds a rule. It returns an error if the rule has no name, its
+// name is already used, or its metric pattern is malformed.
+func (e *Engine) AddRule(rule Rule) error {
+	if rule.Name == "" {
+		return errors.New("alerts: rule has no name")
+	}
+	if _, err := path.Match(rule.Metric, ""); err != nil {
+		return fmt.Errorf("alerts: invalid metric pattern %q: %w", rule.Metric, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Name == rule.Name {
+			return fmt.Errorf("alerts: rule %s already exists", rule.Name)
+		}
+	}
+	e.rules = append(e.rules, rule)
+	return nil
+}
+
+// OnChange adds a handler to call whenever a rule starts or stops firing
+// for a metric. Handlers are called in order from the goroutine evaluating
+// the rules, so they should not block for long.
+func (e *Engine) OnChange(handler Handler) {
+	e.mu.Lock()
+	e.handlers = append(e.handlers, handler)
+	e.mu.Unlock()
+}
+
+// Alerts returns an event for each rule and metric currently firing,
+// sorted by rule and metric.
+func (e *Engine) Alerts() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events []Event
+	for _, rule := range e.rules {
+		for key, s := range e.states {
+			if key[0] == rule.Name && s.state == Alert {
+				events = append(events, event(rule, key[1], s, s.since))
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Rule != events[j].Rule {
+			return events[i].Rule < events[j].Rule
+		}
+		return events[i].Metric < events[j].Metric
+	})
+	return events
+}
+
+func event(rule Rule, metric string, s *state, t time.Time) Event {
+	return Event{
+		Rule:      rule.Name,
+		Metric:    metric,
+		State:     s.state,
+		Value:     s.value,
+		Op:        rule.Op.String(),
+		Threshold: rule.Threshold,
+		Time:      t,
+	}
+}
+
+// Evaluate evaluates every rule once and calls the handlers with any
+// changes. It's called every interval once the engine is started.
+func (e *Engine) Evaluate() {
+	e.mu.Lock()
+	now := e.now()
+	values := e.sampler.sample(e.registry, now)
+	var events []Event
+	seen := make(map[[2]string]bool)
+	for _, rule := range e.rules {
+		for name, value := range values {
+			if ok, _ := path.Match(rule.Metric, name); !ok {
+				continue
+			}
+			key := [2]string{rule.Name, name}
+			seen[key] = true
+			s := e.states[key]
+			if s == nil {
+				s = &state{}
+				e.states[key] = s
+			}
+			s.value = value
+			if !rule.Op.compare(value, rule.Threshold) {
+				s.since = time.Time{}
+				if s.state == Alert {
+					s.state = OK
+					events = append(events, event(rule, name, s, now))
+				}
+				continue
+			}
+			if s.since.IsZero() {
+				s.since = now
+			}
+			if s.state == OK && now.Sub(s.since) >= rule.For {
+				s.state = Alert
+				events = append(events, event(rule, name, s, now))
+			}
+		}
+	}
+	// Resolve alerts of metrics that have been unregistered. Metrics that
+	// are registered but have no value this time, such as a histogram with
+	// no updates, keep their state.
+	for key, s := range e.states {
+		if seen[key] || e.sampler.registered[e.sampler.source(key[1])] {
+			continue
+		}
+		if s.state == Alert {
+			s.state = OK
+			for _, rule := range e.rules {
+				if rule.Name == key[0] {
+					events = append(events, event(rule, key[1], s, now))
+				}
+			}
+		}
+		delete(e.states, key)
+	}
+	handlers := e.handlers
+	e.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Rule != events[j].Rule {
+			return events[i].Rule < events[j].Rule
+		}
+		return events[i].Metric < events[j].Metric
+	})
+	for _, ev := range events {
+		for _, h := range handlers {
+			h(ev)
+		}
+	}
+}
+
+// Start evaluates the rules every interval in the background.
+func (e *Engine) Start() {
+	e.runner.start(e.interval, e.Evaluate)
+}
+
+// Stop stops evaluating the rules.
+func (e *Engine) Stop() {
+	e.runner.stop()
+}
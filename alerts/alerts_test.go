@@ -0,0 +1,176 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package alerts
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestEngine(t *testing.T) {
+	reg := metrics.NewRegistry()
+	g := metrics.NewIntegerGauge()
+	reg.Add("queue/depth", g)
+	errs := metrics.NewCounter()
+	reg.Add("http/api/errors", errs)
+	reg.Add("http/web/errors", metrics.NewCounter())
+
+	now := time.Unix(1000, 0)
+	e := NewEngine(reg, time.Second)
+	e.now = func() time.Time { return now }
+	if err := e.AddRule(Rule{Name: "backlog", Metric: "queue/depth", Op: Above, Threshold: 10, For: 2 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRule(Rule{Name: "errors", Metric: "http/*/errors", Op: AboveOrEqual, Threshold: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRule(Rule{Name: "errors", Metric: "x"}); err == nil {
+		t.Fatal("Expected an error for a duplicate rule")
+	}
+	var events []string
+	e.OnChange(func(ev Event) { events = append(events, ev.String()) })
+
+	step := func(d time.Duration) {
+		now = now.Add(d)
+		e.Evaluate()
+	}
+	g.Set(20)
+	step(0)
+	step(time.Second)
+	if len(events) != 0 {
+		t.Fatalf("Expected no events before the rule is sustained. Got %+v", events)
+	}
+	errs.Inc(10)
+	step(time.Second)
+	exp := []string{
+		"backlog: queue/depth is 20 > 10",
+		"errors: http/api/errors is 10 >= 5",
+	}
+	if !reflect.DeepEqual(events, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, events)
+	}
+	if alerts := e.Alerts(); len(alerts) != 2 || alerts[1].Metric != "http/api/errors" || alerts[1].State != Alert {
+		t.Fatalf("Expected two alerts. Got %+v", alerts)
+	}
+
+	events = nil
+	step(time.Second)
+	g.Set(20)
+	step(time.Second)
+	exp = []string{"errors: http/api/errors is ok at 0"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, events)
+	}
+
+	events = nil
+	reg.Remove("queue/depth")
+	step(time.Second)
+	exp = []string{"backlog: queue/depth is ok at 20"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, events)
+	}
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Fatalf("Expected no alerts. Got %+v", alerts)
+	}
+}
+
+func TestEngineAbsentValue(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := metrics.NewUnbiasedHistogram()
+	reg.Add("latency", h)
+
+	now := time.Unix(1000, 0)
+	e := NewEngine(reg, time.Second)
+	e.now = func() time.Time { return now }
+	if err := e.AddRule(Rule{Name: "slow", Metric: "latency/p99", Op: Above, Threshold: 100, For: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	e.OnChange(func(ev Event) { events = append(events, ev.String()) })
+	step := func(d time.Duration) {
+		now = now.Add(d)
+		e.Evaluate()
+	}
+
+	h.Update(1000)
+	step(0)
+	h.Clear()
+	step(time.Second)
+	if len(events) != 0 {
+		t.Fatalf("Expected no events while the histogram is empty. Got %+v", events)
+	}
+	h.Update(1000)
+	step(0)
+	exp := []string{"slow: latency/p99 is 1000 > 100"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Fatalf("Expected the For timer to have kept running. Got %+v", events)
+	}
+
+	events = nil
+	h.Clear()
+	step(time.Second)
+	if alerts := e.Alerts(); len(events) != 0 || len(alerts) != 1 {
+		t.Fatalf("Expected the alert to keep firing. Got %+v and %+v", events, alerts)
+	}
+	reg.Remove("latency")
+	step(time.Second)
+	exp = []string{"slow: latency/p99 is ok at 1000"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, events)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		bodies <- b
+	}))
+	defer server.Close()
+
+	WebhookHandler(server.URL, nil)(Event{Rule: "r", Metric: "m", State: Alert, Value: 2, Op: ">", Threshold: 1, Time: time.Unix(0, 0).UTC()})
+	var got map[string]interface{}
+	if err := json.Unmarshal(<-bodies, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["rule"] != "r" || got["state"] != "alert" || got["value"] != 2.0 || got["time"] != "1970-01-01T00:00:00Z" {
+		t.Fatalf("Unexpected event %+v", got)
+	}
+
+	WebhookHandler(server.URL, nil)(Event{Rule: "r", Metric: "m", State: Alert, Value: math.NaN(), Op: ">", Threshold: 1})
+	got = nil
+	if err := json.Unmarshal(<-bodies, &got); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := got["value"]; !ok || v != nil || got["threshold"] != 1.0 {
+		t.Fatalf("Expected a null value. Got %+v", got)
+	}
+}
+
+func TestWebhookHandlerSlowEndpoint(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	handler := WebhookHandler(server.URL, nil)
+	start := time.Now()
+	for i := 0; i < 2*webhookQueueSize; i++ {
+		handler(Event{Rule: "r", Metric: "m", State: Alert, Value: float64(i)})
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected the handler not to wait for the endpoint. Took %s", d)
+	}
+}
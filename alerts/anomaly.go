@@ -0,0 +1,207 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// DetectorConfig configures a Detector. Zero fields take the defaults.
+type DetectorConfig struct {
+	// Metric limits the detector to metrics whose names match the pattern
+	// (as for path.Match). Empty matches every metric.
+	Metric string
+	// Alpha is the weight of each new value in the moving mean and variance.
+	// Smaller values give a longer memory. Default 0.1.
+	Alpha float64
+	// ZScore is how many standard deviations from the mean a value must be
+	// to be an anomaly. Default 3.
+	ZScore float64
+	// Warmup is the number of values seen for a metric before it can be
+	// flagged. Default 10.
+	Warmup int
+	// MinStdDev is a floor on the standard deviation, which stops tiny
+	// changes to a metric that has been flat from being anomalies. With the
+	// default of 0 a metric that has not varied is never flagged.
+	MinStdDev float64
+}
+
+// Anomaly is a value that was ZScore standard deviations from the mean.
+type Anomaly struct {
+	Metric string    `json:"metric"`
+	Value  float64   `json:"value"`
+	Mean   float64   `json:"mean"`
+	StdDev float64   `json:"stddev"`
+	ZScore float64   `json:"zscore"`
+	Time   time.Time `json:"time"`
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s = %g is %.1f standard deviations from mean %g", a.Metric, a.Value, a.ZScore, a.Mean)
+}
+
+// band is the exponentially weighted mean and variance of a metric.
+type band struct {
+	n        int
+	mean     float64
+	variance float64
+}
+
+func (b *band) update(value, alpha float64) {
+	b.n++
+	if b.n == 1 {
+		b.mean = value
+		return
+	}
+	diff := value - b.mean
+	b.mean += alpha * diff
+	b.variance = (1 - alpha) * (b.variance + alpha*diff*diff)
+}
+
+// Detector keeps a moving mean and standard deviation of the metrics in a
+// registry and calls handlers for values that stray from them. It is a
+// metrics.Collection of the number of anomalies seen, in total and by
+// metric, which can be added to a registry other than the one it watches.
+type Detector struct {
+	registry metrics.Registry
+	interval time.Duration
+	config   DetectorConfig
+	now      func() time.Time
+
+	mu       sync.Mutex
+	handlers []func(Anomaly)
+	bands    map[string]*band
+	total    uint64
+	counts   map[string]uint64 // anomalies by metric
+	sampler  sampler
+
+	runner runner
+}
+
+// NewDetector returns a detector that samples registry every interval once
+// started.
+func NewDetector(registry metrics.Registry, interval time.Duration, config DetectorConfig) (*Detector, error) {
+	if config.Metric != "" {
+		if _, err := path.Match(config.Metric, ""); err != nil {
+			return nil, fmt.Errorf("alerts: invalid metric pattern %q: %w", config.Metric, err)
+		}
+	}
+	if config.Alpha == 0 {
+		config.Alpha = 0.1
+	} else if config.Alpha < 0 || config.Alpha > 1 {
+		return nil, fmt.Errorf("alerts: alpha %g outside (0, 1]", config.Alpha)
+	}
+	if config.ZScore == 0 {
+		config.ZScore = 3
+	}
+	if config.Warmup == 0 {
+		config.Warmup = 10
+	}
+	return &Detector{
+		registry: registry,
+		interval: interval,
+		config:   config,
+		now:      time.Now,
+		bands:    make(map[string]*band),
+		counts:   make(map[string]uint64),
+	}, nil
+}
+
+// OnAnomaly adds a function to call for each anomaly. Handlers are called
+// after sampling, in the order they were added, and must not call back into
+// the detector.
+func (d *Detector) OnAnomaly(handler func(Anomaly)) {
+	d.mu.Lock()
+	d.handlers = append(d.handlers, handler)
+	d.mu.Unlock()
+}
+
+// Evaluate samples the registry once, compares each value with the metric's
+// band from before the value, and then adds the value to the band so that a
+// lasting change in level soon stops being an anomaly.
+func (d *Detector) Evaluate() {
+	d.mu.Lock()
+	now := d.now()
+	values := d.sampler.sample(d.registry, now)
+	var anomalies []Anomaly
+	for name, value := range values {
+		if d.config.Metric != "" {
+			if ok, _ := path.Match(d.config.Metric, name); !ok {
+				continue
+			}
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		b := d.bands[name]
+		if b == nil {
+			b = &band{}
+			d.bands[name] = b
+		}
+		if b.n >= d.config.Warmup {
+			stddev := math.Max(math.Sqrt(b.variance), d.config.MinStdDev)
+			if stddev > 0 {
+				if z := math.Abs(value-b.mean) / stddev; z >= d.config.ZScore {
+					anomalies = append(anomalies, Anomaly{
+						Metric: name,
+						Value:  value,
+						Mean:   b.mean,
+						StdDev: stddev,
+						ZScore: z,
+						Time:   now,
+					})
+					d.total++
+					d.counts[name]++
+				}
+			}
+		}
+		b.update(value, d.config.Alpha)
+	}
+	// Forget metrics that went away so they warm up again if they return
+	for name := range d.bands {
+		if _, ok := values[name]; !ok {
+			delete(d.bands, name)
+		}
+	}
+	handlers := d.handlers
+	d.mu.Unlock()
+
+	sort.Slice(anomalies, func(i, j int) bool { return anomalies[i].Metric < anomalies[j].Metric })
+	for _, a := range anomalies {
+		for _, h := range handlers {
+			h(a)
+		}
+	}
+}
+
+// Start samples the registry every interval in the background.
+func (d *Detector) Start() {
+	d.runner.start(d.interval, d.Evaluate)
+}
+
+// Stop stops sampling the registry.
+func (d *Detector) Stop() {
+	d.runner.stop()
+}
+
+// Metrics returns the "total" anomaly counter and one counter per metric
+// that has had an anomaly, named after the metric.
+func (d *Detector) Metrics() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m := make(map[string]interface{}, len(d.counts)+1)
+	m["total"] = metrics.CounterValue(d.total)
+	for name, n := range d.counts {
+		m[name] = metrics.CounterValue(n)
+	}
+	return m
+}
@@ -0,0 +1,110 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package alerts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestDetector(t *testing.T) {
+	reg := metrics.NewRegistry()
+	latency := metrics.NewIntegerGauge()
+	reg.Add("db/latency", latency)
+	flat := metrics.NewIntegerGauge()
+	reg.Add("db/connections", flat)
+	reg.Add("other", metrics.NewIntegerGauge())
+
+	now := time.Unix(1000, 0)
+	d, err := NewDetector(reg, time.Second, DetectorConfig{Metric: "db/*", Warmup: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.now = func() time.Time { return now }
+	var anomalies []Anomaly
+	d.OnAnomaly(func(a Anomaly) { anomalies = append(anomalies, a) })
+
+	flat.Set(10)
+	for i := 0; i < 20; i++ {
+		latency.Set(int64(100 + i%3))
+		now = now.Add(time.Second)
+		d.Evaluate()
+	}
+	if len(anomalies) != 0 {
+		t.Fatalf("Expected no anomalies within the usual range. Got %+v", anomalies)
+	}
+
+	latency.Set(200)
+	flat.Set(11)
+	now = now.Add(time.Second)
+	d.Evaluate()
+	if len(anomalies) != 1 {
+		t.Fatalf("Expected 1 anomaly. Got %+v", anomalies)
+	}
+	if a := anomalies[0]; a.Metric != "db/latency" || a.Value != 200 || a.ZScore < 3 || !a.Time.Equal(now) {
+		t.Fatalf("Expected an anomaly for db/latency. Got %+v", a)
+	}
+
+	exp := map[string]interface{}{
+		"total":      metrics.CounterValue(1),
+		"db/latency": metrics.CounterValue(1),
+	}
+	if m := d.Metrics(); !reflect.DeepEqual(m, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, m)
+	}
+}
+
+func TestDetectorWarmup(t *testing.T) {
+	reg := metrics.NewRegistry()
+	g := metrics.NewIntegerGauge()
+	reg.Add("g", g)
+	d, err := NewDetector(reg, time.Second, DetectorConfig{Warmup: 3, MinStdDev: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	d.OnAnomaly(func(Anomaly) { n++ })
+
+	for _, v := range []int64{1, 1, 50} {
+		g.Set(v)
+		d.Evaluate()
+	}
+	if n != 0 {
+		t.Fatalf("Expected no anomalies during warmup. Got %d", n)
+	}
+	// The floor on the standard deviation lets a metric that has been flat
+	// be flagged.
+	g.Set(1)
+	for i := 0; i < 60; i++ {
+		d.Evaluate()
+	}
+	g.Set(10)
+	d.Evaluate()
+	if n != 1 {
+		t.Fatalf("Expected 1 anomaly. Got %d", n)
+	}
+
+	// A metric that goes away starts warming up again when it returns
+	reg.Remove("g")
+	d.Evaluate()
+	reg.Add("g", g)
+	g.Set(1000)
+	d.Evaluate()
+	if n != 1 {
+		t.Fatalf("Expected no new anomalies. Got %d", n)
+	}
+}
+
+func TestNewDetectorErrors(t *testing.T) {
+	if _, err := NewDetector(metrics.NewRegistry(), time.Second, DetectorConfig{Metric: "["}); err == nil {
+		t.Fatal("Expected an error for an invalid pattern")
+	}
+	if _, err := NewDetector(metrics.NewRegistry(), time.Second, DetectorConfig{Alpha: 2}); err == nil {
+		t.Fatal("Expected an error for an alpha above 1")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package alerts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// sampler reads the values of metrics that rules and detectors look at, as
+// described in the package documentation.
+type sampler struct {
+	counters   map[string]uint64 // previous count of each counter
+	last       time.Time
+	sources    map[string]string // registered name of each sampled value
+	registered map[string]bool   // names registered at the last sample
+}
+
+// sample returns the value of each metric in registry. Counters are left out
+// of the first sample since they have no rate yet.
+func (s *sampler) sample(registry metrics.Registry, now time.Time) map[string]float64 {
+	values := make(map[string]float64)
+	elapsed := now.Sub(s.last).Seconds()
+	first := s.last.IsZero()
+	s.last = now
+	counters := make(map[string]uint64, len(s.counters))
+	s.registered = make(map[string]bool, len(s.registered))
+	if s.sources == nil {
+		s.sources = make(map[string]string)
+	}
+	registry.Do(func(name string, metric interface{}) error {
+		s.registered[name] = true
+		switch m := metric.(type) {
+		case *metrics.EWMA:
+			values[name] = m.Rate()
+		case *metrics.EWMAGauge:
+			values[name] = m.Mean()
+		case *metrics.Meter:
+			values[name] = m.OneMinuteRate()
+		case metrics.Histogram:
+			if m.Distribution().Count > 0 {
+				for i, p := range m.Percentiles(metrics.DefaultPercentiles) {
+					values[name+"/"+metrics.DefaultPercentileNames[i]] = float64(p)
+					s.sources[name+"/"+metrics.DefaultPercentileNames[i]] = name
+				}
+			}
+		case metrics.DistributionMetric:
+			// Before CounterMetric since a *Distribution also has a Count method
+			if v := m.Value(); v.Count > 0 {
+				values[name+"/mean"] = v.Mean()
+				s.sources[name+"/mean"] = name
+			}
+		case metrics.CounterMetric:
+			count := m.Count()
+			counters[name] = count
+			if prev, ok := s.counters[name]; ok && !first && elapsed > 0 && count >= prev {
+				values[name] = float64(count-prev) / elapsed
+			}
+		case metrics.Info:
+			// Before GaugeMetric since an Info has no useful value
+		case metrics.GaugeMetric:
+			values[name] = m.Value()
+		}
+		return nil
+	})
+	for name := range s.sources {
+		if !s.registered[s.source(name)] {
+			delete(s.sources, name)
+		}
+	}
+	s.counters = counters
+	return values
+}
+
+// source returns the registered name of the metric a value is sampled from.
+func (s *sampler) source(name string) string {
+	if src, ok := s.sources[name]; ok {
+		return src
+	}
+	return name
+}
+
+// runner calls a function every interval in the background.
+type runner struct {
+	mu       sync.Mutex // guards stopChan and doneChan
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+func (r *runner) start(interval time.Duration, f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopChan != nil {
+		return
+	}
+	r.stopChan = make(chan struct{})
+	r.doneChan = make(chan struct{})
+	go r.loop(interval, f, r.stopChan, r.doneChan)
+}
+
+func (r *runner) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopChan == nil {
+		return
+	}
+	close(r.stopChan)
+	<-r.doneChan
+	r.stopChan = nil
+	r.doneChan = nil
+}
+
+func (r *runner) loop(interval time.Duration, f func(), stopChan <-chan struct{}, doneChan chan<- struct{}) {
+	defer close(doneChan)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			f()
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	webhookTimeout   = 10 * time.Second
+	webhookQueueSize = 100
+)
+
+// WebhookHandler returns a handler that posts each event to url as a JSON
+// object with the fields rule, metric, state ("ok" or "alert"), value, op,
+// threshold and time. Events are posted in order by a goroutine so that a
+// slow endpoint doesn't hold up the evaluation of rules. Up to 100 events
+// are queued; events beyond that and failures are logged. If client is nil
+// a client with a 10 second timeout is used.
+func WebhookHandler(url string, client *http.Client) Handler {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	queue := make(chan Event, webhookQueueSize)
+	go func() {
+		for ev := range queue {
+			if err := postEvent(client, url, ev); err != nil {
+				log.Printf("alerts: failed to post %s: %s", ev, err)
+			}
+		}
+	}()
+	return func(ev Event) {
+		select {
+		case queue <- ev:
+		default:
+			log.Printf("alerts: dropped %s: webhook queue is full", ev)
+		}
+	}
+}
+
+// MarshalJSON encodes the event with a value or threshold that isn't
+// finite, such as the NaN of a gauge, as null since JSON has no encoding
+// for it.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(struct {
+		event
+		Value     *float64 `json:"value"`
+		Threshold *float64 `json:"threshold"`
+	}{event(e), jsonFloat(e.Value), jsonFloat(e.Threshold)})
+}
+
+// jsonFloat returns a pointer to v or nil if v isn't finite.
+func jsonFloat(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+func postEvent(client *http.Client, url string, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	res, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
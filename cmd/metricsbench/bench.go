@@ -0,0 +1,193 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// config is what to run.
+type config struct {
+	counters   int
+	histograms int
+	meters     int
+	histogram  string // bucketed, uniform or biased
+	goroutines int
+	rate       int           // total updates per second, 0 for as fast as possible
+	duration   time.Duration // how long to drive updates
+	report     time.Duration // how often to snapshot the registry, 0 for never
+}
+
+// result is what running cost.
+type result struct {
+	updates   uint64
+	snapshots uint64
+	elapsed   time.Duration
+	cpu       time.Duration // CPU time used by the process
+	mallocs   uint64
+	bytes     uint64
+	gcs       uint32
+	heapInuse uint64
+}
+
+func newHistogram(typ string) (metrics.Histogram, error) {
+	switch typ {
+	case "bucketed":
+		return metrics.NewDefaultBucketedHistogram(), nil
+	case "uniform":
+		return metrics.NewUnbiasedHistogram(), nil
+	case "biased":
+		return metrics.NewBiasedHistogram(), nil
+	}
+	return nil, fmt.Errorf("unknown histogram type %q", typ)
+}
+
+// run registers the metrics, updates them from cfg.goroutines goroutines for
+// cfg.duration and returns what it cost.
+func run(cfg config) (*result, error) {
+	registry := metrics.NewRegistry()
+	var updates []func(int64)
+	for i := 0; i < cfg.counters; i++ {
+		c := metrics.NewCounter()
+		registry.Add(fmt.Sprintf("counter%d", i), c)
+		updates = append(updates, func(v int64) { c.Inc(1) })
+	}
+	for i := 0; i < cfg.histograms; i++ {
+		h, err := newHistogram(cfg.histogram)
+		if err != nil {
+			return nil, err
+		}
+		registry.Add(fmt.Sprintf("histogram%d", i), h)
+		updates = append(updates, h.Update)
+	}
+	for i := 0; i < cfg.meters; i++ {
+		m := metrics.NewMeter()
+		defer m.Stop()
+		registry.Add(fmt.Sprintf("meter%d", i), m)
+		updates = append(updates, func(v int64) { m.Update(1) })
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no metrics to update")
+	}
+	if cfg.goroutines < 1 {
+		cfg.goroutines = 1
+	}
+
+	res := &result{}
+	var stop int32
+	var wg sync.WaitGroup
+	stopReport := make(chan struct{})
+	reportDone := make(chan struct{})
+	if cfg.report > 0 {
+		go func() {
+			defer close(reportDone)
+			snapshot := metrics.NewRegistrySnapshot(false)
+			ticker := time.NewTicker(cfg.report)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stopReport:
+					return
+				case <-ticker.C:
+					snapshot.Snapshot(registry)
+					atomic.AddUint64(&res.snapshots, 1)
+				}
+			}
+		}()
+	} else {
+		close(reportDone)
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	cpuBefore := cpuTime()
+	start := time.Now()
+	for g := 0; g < cfg.goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			n := drive(updates, g, float64(cfg.rate)/float64(cfg.goroutines), &stop)
+			atomic.AddUint64(&res.updates, n)
+		}(g)
+	}
+	time.Sleep(cfg.duration)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+	res.elapsed = time.Since(start)
+	res.cpu = cpuTime() - cpuBefore
+	close(stopReport)
+	<-reportDone
+	runtime.ReadMemStats(&after)
+	res.mallocs = after.Mallocs - before.Mallocs
+	res.bytes = after.TotalAlloc - before.TotalAlloc
+	res.gcs = after.NumGC - before.NumGC
+	res.heapInuse = after.HeapInuse
+	return res, nil
+}
+
+// drive calls the update functions in turn, starting at the g'th, until
+// stop is set and returns how many calls it made. If rate is positive it
+// makes that many calls a second, in batches every 10ms.
+func drive(updates []func(int64), g int, rate float64, stop *int32) uint64 {
+	var n uint64
+	i := g % len(updates)
+	update := func() {
+		updates[i](int64(n))
+		n++
+		if i++; i == len(updates) {
+			i = 0
+		}
+	}
+	if rate <= 0 {
+		for atomic.LoadInt32(stop) == 0 {
+			for j := 0; j < 100; j++ {
+				update()
+			}
+		}
+		return n
+	}
+	const tick = 10 * time.Millisecond
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	start := time.Now()
+	for range ticker.C {
+		if atomic.LoadInt32(stop) != 0 {
+			break
+		}
+		// Catch up to the rate rather than counting ticks, which may be
+		// dropped when the goroutine falls behind.
+		target := uint64(time.Since(start).Seconds() * rate)
+		for n < target && atomic.LoadInt32(stop) == 0 {
+			update()
+		}
+	}
+	return n
+}
+
+func (r *result) write(w io.Writer) {
+	per := func(v float64) float64 {
+		if r.updates == 0 {
+			return 0
+		}
+		return v / float64(r.updates)
+	}
+	fmt.Fprintf(w, "updates         %d\n", r.updates)
+	fmt.Fprintf(w, "updates/s       %.0f\n", float64(r.updates)/r.elapsed.Seconds())
+	fmt.Fprintf(w, "snapshots       %d\n", r.snapshots)
+	fmt.Fprintf(w, "cpu             %s (%.1f%% of one core)\n", r.cpu, 100*r.cpu.Seconds()/r.elapsed.Seconds())
+	fmt.Fprintf(w, "cpu/update      %.1fns\n", per(float64(r.cpu.Nanoseconds())))
+	fmt.Fprintf(w, "allocs/update   %.3f\n", per(float64(r.mallocs)))
+	fmt.Fprintf(w, "bytes/update    %.3f\n", per(float64(r.bytes)))
+	fmt.Fprintf(w, "gc cycles       %d\n", r.gcs)
+	fmt.Fprintf(w, "heap in use     %d bytes\n", r.heapInuse)
+}
@@ -0,0 +1,75 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	res, err := run(config{
+		counters:   2,
+		histograms: 2,
+		meters:     1,
+		histogram:  "biased",
+		goroutines: 2,
+		rate:       1000,
+		duration:   200 * time.Millisecond,
+		report:     50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// About 200 updates at 1000/s, allowing for slow machines.
+	if res.updates == 0 || res.updates > 400 {
+		t.Fatalf("Expected about 200 updates. Got %d", res.updates)
+	}
+	if res.snapshots == 0 {
+		t.Fatal("Expected snapshots")
+	}
+	b := &bytes.Buffer{}
+	res.write(b)
+	if !strings.Contains(b.String(), "allocs/update") {
+		t.Fatalf("Expected allocs/update in:\n%s", b)
+	}
+
+	if _, err := run(config{histograms: 1, histogram: "bogus", duration: time.Millisecond}); err == nil {
+		t.Fatal("Expected an error for an unknown histogram type")
+	}
+}
+
+func TestRunLowRate(t *testing.T) {
+	// Fewer updates a second than goroutines must not mean unlimited
+	res, err := run(config{
+		counters:   1,
+		goroutines: 20,
+		rate:       10,
+		duration:   200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.updates > 20 {
+		t.Fatalf("Expected about 2 updates. Got %d", res.updates)
+	}
+}
+
+func TestRunStopsReporting(t *testing.T) {
+	// A long report interval must not hold up the end of the run
+	res, err := run(config{
+		counters: 1,
+		duration: 50 * time.Millisecond,
+		report:   time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.elapsed > time.Second {
+		t.Fatalf("Expected the run to take about 50ms. Got %s", res.elapsed)
+	}
+}
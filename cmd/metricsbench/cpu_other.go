@@ -0,0 +1,23 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package main
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// cpuTime returns the runtime's estimate of the CPU time used by the
+// process so far, which is only updated by garbage collections.
+func cpuTime() time.Duration {
+	sample := []metrics.Sample{{Name: "/cpu/classes/total:cpu-seconds"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return time.Duration(sample[0].Value.Float64() * float64(time.Second))
+}
@@ -0,0 +1,52 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Command metricsbench measures the overhead of recording metrics. It
+// registers counters, histograms and meters, updates them at a target rate
+// from many goroutines, optionally snapshots the registry as a reporter
+// would, and prints the updates made along with the CPU time, allocations
+// and heap they cost.
+//
+//	metricsbench -counters 1000 -histograms 100 -goroutines 64 -rate 1000000 -duration 1m -report 10s
+//
+// The CPU time is the whole process's, including the runtime's and the
+// snapshotting goroutine's, so it's an upper bound of the cost of updates.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"runtime"
+	"time"
+)
+
+var (
+	flagCounters   = flag.Int("counters", 100, "number of counters")
+	flagHistograms = flag.Int("histograms", 10, "number of histograms")
+	flagMeters     = flag.Int("meters", 10, "number of meters")
+	flagHistogram  = flag.String("histogram", "bucketed", "histogram type: bucketed, uniform or biased")
+	flagGoroutines = flag.Int("goroutines", runtime.GOMAXPROCS(0), "number of goroutines making updates")
+	flagRate       = flag.Int("rate", 0, "total updates per second, 0 for as fast as possible")
+	flagDuration   = flag.Duration("duration", 10*time.Second, "how long to run")
+	flagReport     = flag.Duration("report", 0, "snapshot the registry at this interval, 0 for never")
+)
+
+func main() {
+	flag.Parse()
+	res, err := run(config{
+		counters:   *flagCounters,
+		histograms: *flagHistograms,
+		meters:     *flagMeters,
+		histogram:  *flagHistogram,
+		goroutines: *flagGoroutines,
+		rate:       *flagRate,
+		duration:   *flagDuration,
+		report:     *flagReport,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.write(os.Stdout)
+}
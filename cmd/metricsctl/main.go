@@ -0,0 +1,118 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Command metricsctl shows the metrics of a running service from its JSON
+// (reporter.JSONHandler) or Prometheus (metrics.PrometheusHandler) endpoint
+// as a table sorted by name, with the rate of change of each value and a
+// column for each percentile of histograms.
+//
+//	metricsctl -filter '^http/' -watch 2s http://localhost:8080/debug/metrics
+//	metricsctl -top 10 -watch 5s http://localhost:8080/metrics
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	flagFilter  = flag.String("filter", "", "regular expression of names to show")
+	flagFormat  = flag.String("format", "auto", "format of the endpoint: json, prometheus or auto to use the content type")
+	flagTop     = flag.Int("top", 0, "show only the N metrics with the highest rates (needs -watch or a meter's rate)")
+	flagWatch   = flag.Duration("watch", 0, "refresh at this interval instead of showing the metrics once")
+	flagTimeout = flag.Duration("timeout", 10*time.Second, "timeout of each request")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] URL\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	url := flag.Arg(0)
+	v := &view{top: *flagTop}
+	if *flagFilter != "" {
+		re, err := regexp.Compile(*flagFilter)
+		if err != nil {
+			log.Fatalf("invalid -filter: %s", err)
+		}
+		v.filter = re
+	}
+	client := &http.Client{Timeout: *flagTimeout}
+
+	var prev map[string]*row
+	var prevTime time.Time
+	for {
+		now := time.Now()
+		rows, err := fetch(client, url, *flagFormat)
+		if err != nil {
+			if *flagWatch <= 0 {
+				log.Fatal(err)
+			}
+			log.Print(err)
+		} else {
+			setRates(rows, prev, now.Sub(prevTime))
+			prev, prevTime = rows, now
+			b := &bytes.Buffer{}
+			if *flagWatch > 0 {
+				// Clear the terminal and show when the metrics were fetched.
+				fmt.Fprintf(b, "\033[H\033[2J%s  %s\n\n", now.Format("15:04:05"), url)
+			}
+			v.render(b, rows)
+			os.Stdout.Write(b.Bytes())
+		}
+		if *flagWatch <= 0 {
+			return
+		}
+		time.Sleep(*flagWatch)
+	}
+}
+
+// fetch gets and parses the metrics at url.
+func fetch(client *http.Client, url, format string) (map[string]*row, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "json":
+		req.Header.Set("Accept", "application/json")
+	case "prometheus":
+		req.Header.Set("Accept", "text/plain")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("%s: unexpected status %d: %s", url, res.StatusCode, msg)
+	}
+	if format == "auto" {
+		format = "prometheus"
+		if strings.Contains(res.Header.Get("Content-Type"), "json") {
+			format = "json"
+		}
+	}
+	switch format {
+	case "json":
+		return parseJSON(res.Body)
+	case "prometheus":
+		return parsePrometheus(res.Body)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
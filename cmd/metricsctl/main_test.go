@@ -0,0 +1,101 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/reporter"
+)
+
+func testRegistry() metrics.Registry {
+	r := metrics.NewRegistry()
+	c := metrics.NewCounter()
+	c.Inc(3)
+	r.Add("http/requests", c)
+	r.Add("pool/size", metrics.GaugeValue(7))
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(10)
+	r.Add("latency", h)
+	return r
+}
+
+func TestFetch(t *testing.T) {
+	reg := testRegistry()
+	mux := http.NewServeMux()
+	mux.Handle("/json", reporter.JSONHandler(reg))
+	mux.Handle("/prometheus", metrics.PrometheusHandler(reg))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, path := range []string{"/json", "/prometheus"} {
+		rows, err := fetch(http.DefaultClient, server.URL+path, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests := rows["http/requests"]
+		if requests == nil {
+			requests = rows["http_requests"]
+		}
+		if requests == nil || requests.value != 3 {
+			t.Fatalf("%s: expected http/requests to be 3. Got %+v", path, rows)
+		}
+		if l := rows["latency"]; l == nil || l.value != 1 || l.percentiles["p99"] != 10 || l.percentiles["p999"] != 10 {
+			t.Fatalf("%s: expected latency count 1 and percentiles of 10. Got %+v", path, l)
+		}
+		if _, ok := rows["latency_sum"]; ok {
+			t.Fatalf("%s: expected the summary sum to be left out", path)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	prev := map[string]*row{
+		"a": {name: "a", value: 10},
+		"b": {name: "b", value: 10},
+	}
+	rows := map[string]*row{
+		"a": {name: "a", value: 30},
+		"b": {name: "b", value: 20},
+		"c": {name: "c", value: 1.5, percentiles: map[string]float64{"p99": 4, "p50": 2}},
+	}
+	setRates(rows, prev, 2*time.Second)
+	b := &bytes.Buffer{}
+	v := &view{top: 2}
+	if err := v.render(b, rows); err != nil {
+		t.Fatal(err)
+	}
+	exp := "NAME  VALUE  RATE/S\n" +
+		"a     30     10.00\n" +
+		"b     20     5.00\n"
+	if b.String() != exp {
+		t.Fatalf("Expected:\n%q\nGot:\n%q", exp, b.String())
+	}
+
+	b.Reset()
+	v = &view{filter: regexp.MustCompile("^c")}
+	v.render(b, rows)
+	if lines := strings.Split(b.String(), "\n"); !strings.Contains(lines[0], "p50") || strings.Index(lines[0], "p50") > strings.Index(lines[0], "p99") || !strings.Contains(lines[1], "1.500") {
+		t.Fatalf("Expected c with p50 then p99 columns. Got:\n%s", b)
+	}
+}
+
+func TestPercentileName(t *testing.T) {
+	for q, exp := range map[float64]string{0.5: "p50", 0.9: "p90", 0.99: "p99", 0.999: "p999", 1: "p100"} {
+		if name := percentileName(q); name != exp {
+			t.Fatalf("Expected %s for %v. Got %s", exp, q, name)
+		}
+		if v := percentileValue(exp); v != q {
+			t.Fatalf("Expected %v for %s. Got %v", q, exp, v)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// row is what's shown for a metric: its value, which is the count of a
+// histogram or meter, and its percentiles keyed by names such as "p99".
+type row struct {
+	name        string
+	value       float64
+	rate        float64
+	hasRate     bool
+	percentiles map[string]float64
+}
+
+var percentileKey = regexp.MustCompile(`^p[0-9]+$`)
+
+// parseJSON parses the output of reporter.JSONHandler.
+func parseJSON(r io.Reader) (map[string]*row, error) {
+	var metrics map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&metrics); err != nil {
+		return nil, err
+	}
+	rows := make(map[string]*row, len(metrics))
+	for name, raw := range metrics {
+		var value float64
+		if err := json.Unmarshal(raw, &value); err == nil {
+			rows[name] = &row{name: name, value: value}
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			// Such as an info's labels or a null.
+			continue
+		}
+		rw := &row{name: name}
+		if count, ok := fields["count"].(float64); ok {
+			rw.value = count
+		}
+		// Meters hold their own one minute rate.
+		if rate, ok := fields["1"].(float64); ok {
+			rw.rate = rate
+			rw.hasRate = true
+		}
+		for k, v := range fields {
+			if f, ok := v.(float64); ok && percentileKey.MatchString(k) {
+				if rw.percentiles == nil {
+					rw.percentiles = make(map[string]float64)
+				}
+				rw.percentiles[k] = f
+			}
+		}
+		rows[name] = rw
+	}
+	return rows, nil
+}
+
+// parsePrometheus parses the Prometheus text exposition format. Samples of
+// a summary's quantiles become the percentiles of the summary's row and its
+// _count sample the row's value. Other samples are rows of their own named
+// with their labels.
+func parsePrometheus(r io.Reader) (map[string]*row, error) {
+	rows := make(map[string]*row)
+	summaries := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// # TYPE name summary
+			if f := strings.Fields(line); len(f) == 4 && f[1] == "TYPE" && (f[3] == "summary" || f[3] == "histogram") {
+				summaries[f[2]] = true
+			}
+			continue
+		}
+		name, labels, value, err := parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+		if q, ok := quantile(labels); ok && summaries[name] {
+			rw := getRow(rows, name)
+			if rw.percentiles == nil {
+				rw.percentiles = make(map[string]float64)
+			}
+			rw.percentiles[percentileName(q)] = value
+			continue
+		}
+		if base := strings.TrimSuffix(name, "_count"); base != name && summaries[base] && labels == "" {
+			getRow(rows, base).value = value
+			continue
+		}
+		if base := strings.TrimSuffix(name, "_sum"); base != name && summaries[base] {
+			continue
+		}
+		getRow(rows, name+labels).value = value
+	}
+	return rows, s.Err()
+}
+
+func getRow(rows map[string]*row, name string) *row {
+	rw := rows[name]
+	if rw == nil {
+		rw = &row{name: name}
+		rows[name] = rw
+	}
+	return rw
+}
+
+// parseSample splits a sample line into its name, labels including the
+// braces, and value, ignoring any timestamp or exemplar.
+func parseSample(line string) (name, labels string, value float64, err error) {
+	rest := line
+	if i := strings.IndexAny(line, "{ "); i < 0 {
+		return "", "", 0, fmt.Errorf("invalid sample %q", line)
+	} else if line[i] == '{' {
+		end := strings.LastIndex(line, "}")
+		if end < i {
+			return "", "", 0, fmt.Errorf("invalid sample %q", line)
+		}
+		name, labels, rest = line[:i], line[i:end+1], line[end+1:]
+	} else {
+		name, rest = line[:i], line[i:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", "", 0, fmt.Errorf("invalid sample %q", line)
+	}
+	value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid sample %q: %w", line, err)
+	}
+	return name, labels, value, nil
+}
+
+var quantileLabel = regexp.MustCompile(`^\{quantile="([^"]+)"\}$`)
+
+func quantile(labels string) (float64, bool) {
+	m := quantileLabel.FindStringSubmatch(labels)
+	if m == nil {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(m[1], 64)
+	return q, err == nil
+}
+
+// percentileName names a quantile the way histograms name percentiles,
+// such as "p50" for 0.5 and "p999" for 0.999.
+func percentileName(q float64) string {
+	if q >= 1 {
+		return "p100"
+	}
+	digits := strings.TrimPrefix(strconv.FormatFloat(q, 'f', -1, 64), "0.")
+	if len(digits) < 2 {
+		digits += "0"
+	}
+	return "p" + digits
+}
+
+// percentileValue is the inverse of percentileName used to order columns.
+func percentileValue(name string) float64 {
+	if name == "p100" {
+		return 1
+	}
+	q, _ := strconv.ParseFloat("0."+name[1:], 64)
+	return q
+}
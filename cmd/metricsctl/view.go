@@ -0,0 +1,103 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// view selects and orders the rows to show.
+type view struct {
+	filter *regexp.Regexp // nil shows every row
+	top    int            // if > 0 shows only the rows with the highest rates
+}
+
+// setRates sets the rate of each row without one of its own from the
+// change in its value since prev was fetched elapsed ago.
+func setRates(rows, prev map[string]*row, elapsed time.Duration) {
+	if prev == nil || elapsed <= 0 {
+		return
+	}
+	for name, rw := range rows {
+		if p := prev[name]; p != nil && !rw.hasRate {
+			rw.rate = (rw.value - p.value) / elapsed.Seconds()
+			rw.hasRate = true
+		}
+	}
+}
+
+// rows returns the rows matching the filter sorted by name, or by rate
+// from highest to lowest when showing the top rows.
+func (v *view) rows(rows map[string]*row) []*row {
+	out := make([]*row, 0, len(rows))
+	for name, rw := range rows {
+		if v.filter == nil || v.filter.MatchString(name) {
+			out = append(out, rw)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if v.top > 0 && out[i].rate != out[j].rate {
+			return out[i].rate > out[j].rate
+		}
+		return out[i].name < out[j].name
+	})
+	if v.top > 0 && len(out) > v.top {
+		out = out[:v.top]
+	}
+	return out
+}
+
+// render writes the rows as a table with a column for each percentile any
+// of them has.
+func (v *view) render(w io.Writer, rows map[string]*row) error {
+	shown := v.rows(rows)
+	seen := make(map[string]bool)
+	var percentiles []string
+	for _, rw := range shown {
+		for p := range rw.percentiles {
+			if !seen[p] {
+				seen[p] = true
+				percentiles = append(percentiles, p)
+			}
+		}
+	}
+	sort.Slice(percentiles, func(i, j int) bool {
+		return percentileValue(percentiles[i]) < percentileValue(percentiles[j])
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	header := append([]string{"NAME", "VALUE", "RATE/S"}, percentiles...)
+	io.WriteString(tw, strings.Join(header, "\t")+"\n")
+	for _, rw := range shown {
+		cells := []string{rw.name, formatValue(rw.value), ""}
+		if rw.hasRate {
+			cells[2] = strconv.FormatFloat(rw.rate, 'f', 2, 64)
+		}
+		for _, p := range percentiles {
+			value := ""
+			if v, ok := rw.percentiles[p]; ok {
+				value = formatValue(v)
+			}
+			cells = append(cells, value)
+		}
+		io.WriteString(tw, strings.Join(cells, "\t")+"\n")
+	}
+	return tw.Flush()
+}
+
+// formatValue formats whole numbers without a fraction and others with
+// three decimals.
+func formatValue(v float64) string {
+	if v == float64(int64(v)) {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
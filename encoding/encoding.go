@@ -0,0 +1,20 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package encoding writes registry snapshots in the wire formats of common
+// metrics backends independently of any transport, so that the formats can
+// be used in other pipelines such as writing to files or message queues.
+// The reporters use it to encode what they send.
+package encoding
+
+import (
+	"io"
+	"strings"
+)
+
+// writeLines writes lines that each end with a newline.
+func writeLines(w io.Writer, lines []string) error {
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
@@ -0,0 +1,105 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package encoding
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func testSnapshot() *metrics.RegistrySnapshot {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("req/count", counter)
+	dist := metrics.NewDistribution()
+	dist.Update(1)
+	dist.Update(3)
+	registry.Add("req time", dist)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	return snapshot
+}
+
+func TestEncode(t *testing.T) {
+	snapshot := testSnapshot()
+	now := time.Unix(1400000000, 0)
+	cases := []struct {
+		name   string
+		encode func(buf *bytes.Buffer) error
+		exp    string
+	}{
+		{"graphite", func(buf *bytes.Buffer) error { return EncodeGraphite(buf, snapshot, "web1", now) },
+			"req.count.web1 3.000000 1400000000\nreq time.web1 2.000000 1400000000\n"},
+		{"influx", func(buf *bytes.Buffer) error { return EncodeInflux(buf, snapshot, now) },
+			"req/count value=3 1400000000000000000\n" +
+				"req\\ time count=2i,sum=4,min=1,max=3,mean=2 1400000000000000000\n"},
+		{"statsd", func(buf *bytes.Buffer) error { return EncodeStatsd(buf, snapshot, "app") },
+			"app.req.count:3|c\napp.req time:2|ms\n"},
+		{"prometheus", func(buf *bytes.Buffer) error { return EncodePrometheus(buf, snapshot) },
+			"# TYPE req_time summary\nreq_time_sum 4\nreq_time_count 2\n" +
+				"# TYPE req_count gauge\nreq_count 3\n"},
+	}
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		if err := c.encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.exp {
+			t.Errorf("%s: Expected:\n%s\nGot:\n%s", c.name, c.exp, buf.String())
+		}
+	}
+}
+
+func TestInfluxLinesNonFinite(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("ok", metrics.GaugeValue(1))
+	registry.Add("nan", metrics.GaugeValue(math.NaN()))
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	lines := InfluxLines(snapshot, time.Unix(1, 0))
+	if len(lines) != 1 || lines[0] != "ok value=1 1000000000\n" {
+		t.Fatalf("Expected only the finite value. Got %q", lines)
+	}
+}
+
+func TestStatsdLine(t *testing.T) {
+	if line := StatsdLine("a.b", 1, "c", 0.5); line != "a.b:1|c|@0.5" {
+		t.Fatalf("Expected a.b:1|c|@0.5. Got %s", line)
+	}
+	if line := StatsdLine("a.b", 1.5, "g", 1); line != "a.b:1.5|g" {
+		t.Fatalf("Expected a.b:1.5|g. Got %s", line)
+	}
+}
+
+func BenchmarkGraphiteLines(b *testing.B) {
+	snapshot := testSnapshot()
+	ts := time.Unix(100, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GraphiteLines(snapshot, "host", ts)
+	}
+}
+
+func BenchmarkInfluxLines(b *testing.B) {
+	snapshot := testSnapshot()
+	ts := time.Unix(100, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		InfluxLines(snapshot, ts)
+	}
+}
+
+func BenchmarkStatsdLines(b *testing.B) {
+	snapshot := testSnapshot()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		StatsdLines(snapshot, "app")
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package encoding
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// GraphiteLines returns the snapshot in Graphite's plaintext protocol, one
+// newline terminated "path value timestamp" line per metric. Slashes in
+// names become dots and the source, if not empty, is appended to each path.
+// Distributions are written as their mean.
+func GraphiteLines(snapshot *metrics.RegistrySnapshot, source string, ts time.Time) []string {
+	unix := ts.Unix()
+	lines := make([]string, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		lines = append(lines, fmt.Sprintf("%s %f %d\n", graphitePath(v.Name, source), v.Value, unix))
+	}
+	for _, v := range snapshot.Distributions {
+		lines = append(lines, fmt.Sprintf("%s %f %d\n", graphitePath(v.Name, source), v.Value.Mean(), unix))
+	}
+	return lines
+}
+
+// EncodeGraphite writes the lines returned by GraphiteLines to w.
+func EncodeGraphite(w io.Writer, snapshot *metrics.RegistrySnapshot, source string, ts time.Time) error {
+	return writeLines(w, GraphiteLines(snapshot, source, ts))
+}
+
+func graphitePath(name, source string) string {
+	name = strings.Replace(name, "/", ".", -1)
+	if source != "" {
+		return name + "." + source
+	}
+	return name
+}
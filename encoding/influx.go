@@ -0,0 +1,58 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package encoding
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+var influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+// InfluxLines returns the snapshot in InfluxDB line protocol, one newline
+// terminated line per metric with a nanosecond timestamp. Values have a
+// single "value" field and distributions a field for each statistic.
+// Metrics that aren't finite are left out since the protocol can't
+// represent them.
+func InfluxLines(snapshot *metrics.RegistrySnapshot, ts time.Time) []string {
+	nanos := ts.UnixNano()
+	lines := make([]string, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		if !finite(v.Value) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s value=%s %d\n",
+			influxMeasurementReplacer.Replace(v.Name), influxFloat(v.Value), nanos))
+	}
+	for _, v := range snapshot.Distributions {
+		d := v.Value
+		if !finite(d.Sum) || !finite(d.Min) || !finite(d.Max) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s count=%di,sum=%s,min=%s,max=%s,mean=%s %d\n",
+			influxMeasurementReplacer.Replace(v.Name), d.Count,
+			influxFloat(d.Sum), influxFloat(d.Min), influxFloat(d.Max), influxFloat(d.Mean()), nanos))
+	}
+	return lines
+}
+
+// EncodeInflux writes the lines returned by InfluxLines to w.
+func EncodeInflux(w io.Writer, snapshot *metrics.RegistrySnapshot, ts time.Time) error {
+	return writeLines(w, InfluxLines(snapshot, ts))
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func influxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,19 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package encoding
+
+import (
+	"io"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// EncodePrometheus writes the snapshot in the Prometheus text exposition
+// format. Values are written as gauges, since a snapshot's counters are
+// usually the change since the previous snapshot, and distributions as
+// summaries without quantiles.
+func EncodePrometheus(w io.Writer, snapshot *metrics.RegistrySnapshot) error {
+	return metrics.WritePrometheus(w, snapshot)
+}
@@ -0,0 +1,62 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package encoding
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// StatsdName returns the statsd name of a metric: slashes become dots and
+// the prefix, if not empty, is joined to the front with a dot.
+func StatsdName(prefix, name string) string {
+	name = strings.Replace(name, "/", ".", -1)
+	if prefix != "" {
+		return prefix + "." + name
+	}
+	return name
+}
+
+// StatsdLine returns a single statsd line, name:value|typ, without a
+// newline. If sampleRate is between 0 and 1 it's appended as |@rate so the
+// server can scale the value.
+func StatsdLine(name string, value float64, typ string, sampleRate float64) string {
+	line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + typ
+	if sampleRate > 0 && sampleRate < 1 {
+		line += "|@" + strconv.FormatFloat(sampleRate, 'f', -1, 64)
+	}
+	return line
+}
+
+// StatsdLines returns the snapshot as statsd lines without newlines.
+// Counters are written as counts (|c), distributions as timers (|ms) of
+// their mean and everything else as gauges (|g).
+func StatsdLines(snapshot *metrics.RegistrySnapshot, prefix string) []string {
+	lines := make([]string, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		typ := "g"
+		if snapshot.IsCounter(v.Name) {
+			typ = "c"
+		}
+		lines = append(lines, StatsdLine(StatsdName(prefix, v.Name), v.Value, typ, 0))
+	}
+	for _, v := range snapshot.Distributions {
+		lines = append(lines, StatsdLine(StatsdName(prefix, v.Name), v.Value.Mean(), "ms", 0))
+	}
+	return lines
+}
+
+// EncodeStatsd writes the lines returned by StatsdLines to w, each followed
+// by a newline.
+func EncodeStatsd(w io.Writer, snapshot *metrics.RegistrySnapshot, prefix string) error {
+	lines := StatsdLines(snapshot, prefix)
+	for i, line := range lines {
+		lines[i] = line + "\n"
+	}
+	return writeLines(w, lines)
+}
@@ -0,0 +1,101 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package graphite receives metrics in the Carbon plaintext protocol and
+// records them in a registry so they can be exported by any of the reporters.
+package graphite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/samuel/go-metrics/internal/listen"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// ErrListenerClosed is returned by Serve and ServePacket after Close.
+var ErrListenerClosed = errors.New("graphite: listener closed")
+
+// Listener receives Carbon plaintext lines of the form "path value timestamp"
+// and sets a *metrics.FloatGauge in a registry to the value. The timestamp is
+// checked but otherwise ignored as the gauge only holds the latest value.
+type Listener struct {
+	registry metrics.Registry
+
+	// ErrorLog logs malformed lines. If nil the standard logger is used.
+	ErrorLog *log.Logger
+
+	server listen.Server
+}
+
+// NewListener returns a Listener recording metrics in registry.
+func NewListener(registry metrics.Registry) *Listener {
+	return &Listener{
+		registry: registry,
+		server:   listen.Server{ErrClosed: ErrListenerClosed},
+	}
+}
+
+// Serve accepts stream connections on ln, usually TCP port 2003, and reads
+// lines from each until ln is closed.
+func (l *Listener) Serve(ln net.Listener) error {
+	return l.server.Serve(ln, l.handleLine)
+}
+
+// ServePacket reads datagrams, each holding one or more lines, from conn
+// until it's closed.
+func (l *Listener) ServePacket(conn net.PacketConn) error {
+	return l.server.ServePacket(conn, func(packet []byte, addr net.Addr) {
+		for _, line := range bytes.Split(packet, []byte{'\n'}) {
+			l.handleLine(line)
+		}
+	})
+}
+
+// Close stops serving and closes all connections.
+func (l *Listener) Close() error {
+	return l.server.Close()
+}
+
+func (l *Listener) handleLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	if err := l.HandleLine(string(line)); err != nil {
+		if l.ErrorLog != nil {
+			l.ErrorLog.Printf("graphite: %s", err.Error())
+		} else {
+			log.Printf("graphite: %s", err.Error())
+		}
+	}
+}
+
+// HandleLine records a single Carbon plaintext line.
+func (l *Listener) HandleLine(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return fmt.Errorf("expected path, value and timestamp in %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid value in %q", line)
+	}
+	if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+		return fmt.Errorf("invalid timestamp in %q", line)
+	}
+	name := fields[0]
+	g, ok := l.registry.GetOrAdd(name, func() interface{} { return metrics.NewFloatGauge() }).(*metrics.FloatGauge)
+	if !ok {
+		return fmt.Errorf("%s is already registered as %T not a gauge", name, l.registry.Get(name))
+	}
+	g.Set(value)
+	return nil
+}
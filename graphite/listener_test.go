@@ -0,0 +1,97 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestListenerHandleLine(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("counter", metrics.NewCounter())
+	l := NewListener(registry)
+	if err := l.HandleLine("disk.used 10 1400000000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.HandleLine("disk.used  12.5 1400000060"); err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := registry.Get("disk.used").(*metrics.FloatGauge); !ok || g.Value() != 12.5 {
+		t.Fatalf("Expected disk.used gauge of 12.5. Got %+v", registry.Get("disk.used"))
+	}
+	for _, line := range []string{
+		"novalue",
+		"notimestamp 1",
+		"bad x 1400000000",
+		"bad 1 x",
+		"nan NaN 1400000000",
+		"inf +Inf 1400000000",
+		"inf -Inf 1400000000",
+		"counter 1 1400000000",
+	} {
+		if err := l.HandleLine(line); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestListenerServe(t *testing.T) {
+	registry := metrics.NewRegistry()
+	l := NewListener(registry)
+	l.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- l.ServePacket(pc) }()
+	go func() { errc <- l.Serve(ln) }()
+
+	uc, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uc.Close()
+	if _, err := uc.Write([]byte("udp.a 1 1400000000\nudp.b 2 1400000000\n")); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tc.Write([]byte("tcp 3 1400000000\n")); err != nil {
+		t.Fatal(err)
+	}
+	tc.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for registry.Get("udp.b") == nil || registry.Get("tcp") == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected udp and tcp metrics. Got %+v", registry)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if g := registry.Get("tcp").(*metrics.FloatGauge); g.Value() != 3 {
+		t.Fatalf("Expected tcp gauge of 3. Got %f", g.Value())
+	}
+
+	l.Close()
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != ErrListenerClosed {
+			t.Fatalf("Expected %v. Got %v", ErrListenerClosed, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package grpcmetrics records metrics for gRPC servers and clients with
+// interceptors.
+//
+// For each method, such as "pkg.Service/Method", the interceptors record:
+//
+//	requests           counter of calls
+//	latency            histogram of the duration of calls in microseconds
+//	status/OK          counters of calls by status code
+//	messages/received  counter of messages received
+//	messages/sent      counter of messages sent
+//	received_size      histogram of the size of received messages in bytes
+//	sent_size          histogram of the size of sent messages in bytes
+//
+// Use separate registries, or scopes, for servers and clients.
+package grpcmetrics
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxMethods is the number of methods recorded separately. Calls to further
+// methods, such as unknown methods called on a server, are recorded under
+// OtherMethod.
+const MaxMethods = 1000
+
+// OtherMethod is the name calls are recorded under once there are already
+// MaxMethods other methods.
+const OtherMethod = "other"
+
+type method struct {
+	registry     metrics.Registry
+	requests     *metrics.Counter
+	latency      metrics.Histogram
+	received     *metrics.Counter
+	sent         *metrics.Counter
+	receivedSize metrics.Histogram
+	sentSize     metrics.Histogram
+}
+
+func newMethod(registry metrics.Registry) *method {
+	m := &method{
+		registry:     registry,
+		requests:     metrics.NewCounter(),
+		latency:      metrics.NewDefaultBucketedHistogram(),
+		received:     metrics.NewCounter(),
+		sent:         metrics.NewCounter(),
+		receivedSize: metrics.NewDefaultBucketedHistogram(),
+		sentSize:     metrics.NewDefaultBucketedHistogram(),
+	}
+	registry.Add("requests", m.requests)
+	registry.Add("latency", m.latency)
+	registry.Add("messages/received", m.received)
+	registry.Add("messages/sent", m.sent)
+	registry.Add("received_size", m.receivedSize)
+	registry.Add("sent_size", m.sentSize)
+	return m
+}
+
+func (m *method) done(start time.Time, err error) {
+	m.requests.Inc(1)
+	m.latency.Update(int64(time.Since(start) / time.Microsecond))
+	name := "status/" + status.Code(err).String()
+	if c, ok := m.registry.GetOrAdd(name, func() interface{} { return metrics.NewCounter() }).(*metrics.Counter); ok {
+		c.Inc(1)
+	}
+}
+
+func (m *method) recv(msg interface{}) {
+	m.received.Inc(1)
+	if p, ok := msg.(proto.Message); ok {
+		m.receivedSize.Update(int64(proto.Size(p)))
+	}
+}
+
+func (m *method) send(msg interface{}) {
+	m.sent.Inc(1)
+	if p, ok := msg.(proto.Message); ok {
+		m.sentSize.Update(int64(proto.Size(p)))
+	}
+}
+
+type recorder struct {
+	registry metrics.Registry
+	mu       sync.RWMutex
+	methods  map[string]*method
+}
+
+func newRecorder(registry metrics.Registry) *recorder {
+	return &recorder{registry: registry, methods: make(map[string]*method)}
+}
+
+// method returns the metrics for a full method name such as
+// "/pkg.Service/Method".
+func (r *recorder) method(fullMethod string) *method {
+	name := strings.TrimPrefix(fullMethod, "/")
+	r.mu.RLock()
+	m := r.methods[name]
+	r.mu.RUnlock()
+	if m != nil {
+		return m
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m = r.methods[name]; m == nil {
+		if len(r.methods) >= MaxMethods {
+			if m = r.methods[OtherMethod]; m != nil {
+				return m
+			}
+			name = OtherMethod
+		}
+		m = newMethod(r.registry.Scope(name))
+		r.methods[name] = m
+	}
+	return m
+}
+
+// UnaryServerInterceptor returns an interceptor recording the unary calls
+// handled by a server in registry.
+func UnaryServerInterceptor(registry metrics.Registry) grpc.UnaryServerInterceptor {
+	r := newRecorder(registry)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		m := r.method(info.FullMethod)
+		start := time.Now()
+		m.recv(req)
+		res, err := handler(ctx, req)
+		if err == nil {
+			m.send(res)
+		}
+		m.done(start, err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor recording the streaming
+// calls handled by a server in registry.
+func StreamServerInterceptor(registry metrics.Registry) grpc.StreamServerInterceptor {
+	r := newRecorder(registry)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		m := r.method(info.FullMethod)
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, method: m})
+		m.done(start, err)
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	method *method
+}
+
+func (s *serverStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.method.send(msg)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.method.recv(msg)
+	}
+	return err
+}
+
+// UnaryClientInterceptor returns an interceptor recording the unary calls
+// made by a client in registry.
+func UnaryClientInterceptor(registry metrics.Registry) grpc.UnaryClientInterceptor {
+	r := newRecorder(registry)
+	return func(ctx context.Context, fullMethod string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		m := r.method(fullMethod)
+		start := time.Now()
+		m.send(req)
+		err := invoker(ctx, fullMethod, req, reply, cc, opts...)
+		if err == nil {
+			m.recv(reply)
+		}
+		m.done(start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor recording the streaming
+// calls made by a client in registry. A call is recorded once the client
+// has received the end of the stream or an error, or the response of a
+// client streaming call, so calls whose streams are never read to the end
+// are not counted.
+func StreamClientInterceptor(registry metrics.Registry) grpc.StreamClientInterceptor {
+	r := newRecorder(registry)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, fullMethod string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		m := r.method(fullMethod)
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, fullMethod, opts...)
+		if err != nil {
+			m.done(start, err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, method: m, start: start, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	method        *method
+	start         time.Time
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *clientStream) SendMsg(msg interface{}) error {
+	err := s.ClientStream.SendMsg(msg)
+	if err == nil {
+		s.method.send(msg)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err == nil {
+		s.method.recv(msg)
+		if !s.serverStreams {
+			// The single response ends a client streaming call
+			s.once.Do(func() { s.method.done(s.start, nil) })
+		}
+		return nil
+	}
+	s.once.Do(func() {
+		if err == io.EOF {
+			s.method.done(s.start, nil)
+		} else {
+			s.method.done(s.start, err)
+		}
+	})
+	return err
+}
@@ -0,0 +1,141 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package grpcmetrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/metricspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestInterceptors(t *testing.T) {
+	served := metrics.NewRegistry()
+	served.Add("gauge", metrics.GaugeValue(1))
+	serverMetrics := metrics.NewRegistry()
+	clientMetrics := metrics.NewRegistry()
+
+	ln := bufconn.Listen(1 << 16)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverMetrics)),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverMetrics)))
+	metricspb.RegisterRegistryServer(server, metricspb.NewRegistryServer(served))
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientMetrics)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientMetrics)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := metricspb.NewRegistryClient(conn)
+	ctx := context.Background()
+
+	client.GetMetric(ctx, &metricspb.GetMetricRequest{Name: "gauge"})
+	client.GetMetric(ctx, &metricspb.GetMetricRequest{Name: "missing"})
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.WatchMetrics(ctx, &metricspb.WatchMetricsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	stream.Recv()
+
+	for _, reg := range []metrics.Registry{serverMetrics, clientMetrics} {
+		for name, exp := range map[string]uint64{
+			"metrics.Registry/GetMetric/requests":             2,
+			"metrics.Registry/GetMetric/status/OK":            1,
+			"metrics.Registry/GetMetric/status/NotFound":      1,
+			"metrics.Registry/WatchMetrics/messages/sent":     1,
+			"metrics.Registry/WatchMetrics/messages/received": 1,
+		} {
+			if c := reg.Counter(name); c == nil || c.Count() != exp {
+				t.Errorf("Expected %s of %d. Got %+v", name, exp, c)
+			}
+		}
+		if d := reg.Histogram("metrics.Registry/GetMetric/latency").Distribution(); d.Count != 2 {
+			t.Errorf("Expected 2 latencies. Got %+v", d)
+		}
+		if d := reg.Histogram("metrics.Registry/GetMetric/received_size").Distribution(); d.Count == 0 || d.Sum == 0 {
+			t.Errorf("Expected received message sizes. Got %+v", d)
+		}
+	}
+	if c := clientMetrics.Counter("metrics.Registry/WatchMetrics/status/Canceled"); c == nil || c.Count() != 1 {
+		t.Errorf("Expected the canceled stream to be recorded. Got %+v", c)
+	}
+}
+
+func TestClientStreaming(t *testing.T) {
+	clientMetrics := metrics.NewRegistry()
+
+	ln := bufconn.Listen(1 << 16)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			var req metricspb.GetMetricRequest
+			if err := stream.RecvMsg(&req); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		return stream.SendMsg(&metricspb.GetMetricRequest{Name: "done"})
+	}))
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientMetrics)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Like the CloseAndRecv of a generated client streaming client
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ClientStreams: true}, "/test.Upload/Send")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := stream.SendMsg(&metricspb.GetMetricRequest{Name: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var res metricspb.GetMetricRequest
+	if err := stream.RecvMsg(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, exp := range map[string]uint64{
+		"test.Upload/Send/requests":          1,
+		"test.Upload/Send/status/OK":         1,
+		"test.Upload/Send/messages/sent":     2,
+		"test.Upload/Send/messages/received": 1,
+	} {
+		if c := clientMetrics.Counter(name); c == nil || c.Count() != exp {
+			t.Errorf("Expected %s of %d. Got %+v", name, exp, c)
+		}
+	}
+	if d := clientMetrics.Histogram("test.Upload/Send/latency").Distribution(); d.Count != 1 {
+		t.Errorf("Expected 1 latency. Got %+v", d)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package health runs named health checks and serves their results over
+// HTTP for load balancers and Kubernetes liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout limits how long a check may run when served by Handler.
+const DefaultTimeout = 5 * time.Second
+
+var errUnknownCheck = errors.New("unknown check")
+
+// Check returns nil if whatever it checks, such as a database connection,
+// is working. It should give up when ctx is done.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of running a check.
+type Result struct {
+	Healthy  bool
+	Error    string
+	Duration time.Duration
+}
+
+// MarshalJSON encodes the result with the duration in milliseconds.
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Healthy    bool    `json:"healthy"`
+		Error      string  `json:"error,omitempty"`
+		DurationMS float64 `json:"duration_ms"`
+	}{r.Healthy, r.Error, float64(r.Duration) / float64(time.Millisecond)})
+}
+
+// Registry holds named health checks. Separate registries can be used for
+// liveness and readiness.
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+// Register adds check under name replacing any check already registered
+// under it.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	r.checks[name] = check
+	r.mu.Unlock()
+}
+
+// Unregister removes the check registered under name.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.checks, name)
+	r.mu.Unlock()
+}
+
+// Names returns the sorted names of the registered checks.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Run runs all checks at the same time and returns their results by name.
+// A check that panics is unhealthy. If ctx is done before every check has
+// finished Run returns without waiting for the rest, which are reported as
+// unhealthy with the context's error.
+func (r *Registry) Run(ctx context.Context) map[string]Result {
+	r.mu.RLock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	type namedResult struct {
+		name string
+		res  Result
+	}
+	start := time.Now()
+	// Buffered so checks that finish after Run returns don't block
+	done := make(chan namedResult, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			done <- namedResult{name, run(ctx, check)}
+		}(name, check)
+	}
+	results := make(map[string]Result, len(checks))
+	for len(results) < len(checks) {
+		select {
+		case nr := <-done:
+			results[nr.name] = nr.res
+		case <-ctx.Done():
+			for name := range checks {
+				if _, ok := results[name]; !ok {
+					results[name] = Result{Error: ctx.Err().Error(), Duration: time.Since(start)}
+				}
+			}
+		}
+	}
+	return results
+}
+
+func run(ctx context.Context, check Check) (res Result) {
+	start := time.Now()
+	defer func() {
+		res.Duration = time.Since(start)
+		if v := recover(); v != nil {
+			res = Result{Error: "panic: " + panicString(v), Duration: res.Duration}
+		}
+	}()
+	if err := check(ctx); err != nil {
+		return Result{Error: err.Error()}
+	}
+	return Result{Healthy: true}
+}
+
+func panicString(v interface{}) string {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Handler returns an http.Handler, usually mounted at /healthz, that runs
+// the checks in r and replies 200 if all are healthy and 503 otherwise.
+// The body is a JSON object with "status" of "ok" or "unhealthy" and
+// "checks" holding the result of each check by name. Checks are given
+// DefaultTimeout to finish. The query parameter "check" may be repeated to
+// run only the named checks.
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), DefaultTimeout)
+		defer cancel()
+		reg := r
+		if names := req.URL.Query()["check"]; len(names) > 0 {
+			reg = r.subset(names)
+		}
+		results := reg.Run(ctx)
+		status := "ok"
+		code := http.StatusOK
+		for _, res := range results {
+			if !res.Healthy {
+				status = "unhealthy"
+				code = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": results})
+	})
+}
+
+// subset returns a registry holding the named checks. Names without a
+// check are unhealthy.
+func (r *Registry) subset(names []string) *Registry {
+	sub := NewRegistry()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, name := range names {
+		if check, ok := r.checks[name]; ok {
+			sub.checks[name] = check
+		} else {
+			sub.checks[name] = func(ctx context.Context) error { return errUnknownCheck }
+		}
+	}
+	return sub
+}
@@ -0,0 +1,100 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryRun(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	r.Register("cache", func(ctx context.Context) error { return errors.New("down") })
+	r.Register("broken", func(ctx context.Context) error { panic("oops") })
+	r.Register("gone", func(ctx context.Context) error { return nil })
+	r.Unregister("gone")
+
+	if names, exp := r.Names(), []string{"broken", "cache", "db"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, names)
+	}
+	results := r.Run(context.Background())
+	if res := results["db"]; !res.Healthy || res.Duration < 10*time.Millisecond {
+		t.Fatalf("Expected db to be healthy after 10ms. Got %+v", res)
+	}
+	if res := results["cache"]; res.Healthy || res.Error != "down" {
+		t.Fatalf("Expected cache to be down. Got %+v", res)
+	}
+	if res := results["broken"]; res.Healthy || res.Error != "panic: oops" {
+		t.Fatalf("Expected broken to have panicked. Got %+v", res)
+	}
+}
+
+func TestRegistryRunTimeout(t *testing.T) {
+	r := NewRegistry()
+	block := make(chan struct{})
+	defer close(block)
+	r.Register("stuck", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	r.Register("db", func(ctx context.Context) error { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	results := r.Run(ctx)
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected Run to return once the context expired. Took %s", d)
+	}
+	if res := results["stuck"]; res.Healthy || res.Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("Expected stuck to have timed out. Got %+v", res)
+	}
+	if res := results["db"]; !res.Healthy {
+		t.Fatalf("Expected db to be healthy. Got %+v", res)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", func(ctx context.Context) error { return nil })
+	r.Register("cache", func(ctx context.Context) error { return errors.New("down") })
+	handler := Handler(r)
+
+	type body struct {
+		Status string
+		Checks map[string]struct {
+			Healthy    bool
+			Error      string
+			DurationMS *float64 `json:"duration_ms"`
+		}
+	}
+	for url, code := range map[string]int{
+		"/healthz":                    503,
+		"/healthz?check=db":           200,
+		"/healthz?check=db&check=cat": 503,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != code {
+			t.Fatalf("Expected %d for %s. Got %d", code, url, w.Code)
+		}
+		var b body
+		if err := json.Unmarshal(w.Body.Bytes(), &b); err != nil {
+			t.Fatal(err)
+		}
+		if (b.Status == "ok") != (code == 200) || !b.Checks["db"].Healthy || b.Checks["db"].DurationMS == nil {
+			t.Fatalf("Unexpected body for %s: %s", url, w.Body)
+		}
+	}
+}
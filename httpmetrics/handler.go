@@ -0,0 +1,287 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package httpmetrics records metrics for HTTP servers and clients.
+package httpmetrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// DefaultMaxPaths is the number of paths recorded separately when
+// Options.MaxPaths is zero.
+const DefaultMaxPaths = 100
+
+// OtherPath is the name requests are recorded under once there are already
+// MaxPaths other names.
+const OtherPath = "other"
+
+// Options configures Handler.
+type Options struct {
+	// PathFunc returns the name to record a request under as well as the
+	// totals, such as its route ("/users/:id") or NormalizePath of its
+	// path. Names must be few to keep the number of metrics down. If nil
+	// or if it returns "" only the totals are recorded.
+	PathFunc func(req *http.Request) string
+	// MaxPaths limits the number of names returned by PathFunc that are
+	// recorded separately. Requests for further names are recorded under
+	// OtherPath. Defaults to DefaultMaxPaths.
+	MaxPaths int
+	// NewHistogram returns the histograms for latencies and sizes. It
+	// defaults to metrics.NewDefaultBucketedHistogram.
+	NewHistogram func() metrics.Histogram
+}
+
+// endpoint holds the metrics recorded for a path or for all requests.
+type endpoint struct {
+	requests     *metrics.Counter
+	inFlight     *metrics.IntegerGauge
+	status       [6]*metrics.Counter // by status code / 100
+	latency      metrics.Histogram
+	requestSize  metrics.Histogram
+	responseSize metrics.Histogram
+}
+
+func newEndpoint(registry metrics.Registry, newHistogram func() metrics.Histogram) *endpoint {
+	e := &endpoint{
+		requests:     metrics.NewCounter(),
+		inFlight:     metrics.NewIntegerGauge(),
+		latency:      newHistogram(),
+		requestSize:  newHistogram(),
+		responseSize: newHistogram(),
+	}
+	registry.Add("requests", e.requests)
+	registry.Add("in_flight", e.inFlight)
+	registry.Add("latency", e.latency)
+	registry.Add("request_size", e.requestSize)
+	registry.Add("response_size", e.responseSize)
+	for i := 1; i < len(e.status); i++ {
+		e.status[i] = metrics.NewCounter()
+		registry.Add("status/"+strconv.Itoa(i)+"xx", e.status[i])
+	}
+	return e
+}
+
+func (e *endpoint) record(code int, latency time.Duration, requestSize, responseSize int64) {
+	e.requests.Inc(1)
+	if c := code / 100; c > 0 && c < len(e.status) {
+		e.status[c].Inc(1)
+	}
+	e.latency.Update(int64(latency / time.Microsecond))
+	e.requestSize.Update(requestSize)
+	e.responseSize.Update(responseSize)
+}
+
+type handler struct {
+	next     http.Handler
+	registry metrics.Registry
+	opts     Options
+	total    *endpoint
+
+	mu    sync.RWMutex
+	paths map[string]*endpoint
+}
+
+// Handler returns an http.Handler that calls next and records in registry:
+//
+//	requests       counter of requests
+//	in_flight      gauge of requests being handled
+//	status/2xx     counters of responses by status class (1xx to 5xx)
+//	latency        histogram of the time to handle requests in microseconds
+//	request_size   histogram of request body sizes in bytes
+//	response_size  histogram of response body sizes in bytes
+//
+// If opts.PathFunc is set the same metrics are also recorded with the name
+// it returns as a prefix, such as "/users/:id/latency". The opts may be nil.
+func Handler(next http.Handler, registry metrics.Registry, opts *Options) http.Handler {
+	h := &handler{
+		next:     next,
+		registry: registry,
+		paths:    make(map[string]*endpoint),
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.MaxPaths <= 0 {
+		h.opts.MaxPaths = DefaultMaxPaths
+	}
+	if h.opts.NewHistogram == nil {
+		h.opts.NewHistogram = metrics.NewDefaultBucketedHistogram
+	}
+	h.total = newEndpoint(registry, h.opts.NewHistogram)
+	return h
+}
+
+// path returns the endpoint for a name returned by PathFunc.
+func (h *handler) path(name string) *endpoint {
+	h.mu.RLock()
+	e := h.paths[name]
+	h.mu.RUnlock()
+	if e != nil {
+		return e
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e = h.paths[name]; e == nil {
+		if len(h.paths) >= h.opts.MaxPaths {
+			if e = h.paths[OtherPath]; e != nil {
+				return e
+			}
+			name = OtherPath
+		}
+		e = newEndpoint(h.registry.Scope(name), h.opts.NewHistogram)
+		h.paths[name] = e
+	}
+	return e
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var path *endpoint
+	if h.opts.PathFunc != nil {
+		if name := h.opts.PathFunc(req); name != "" {
+			path = h.path(name)
+		}
+	}
+	h.total.inFlight.Inc(1)
+	if path != nil {
+		path.inFlight.Inc(1)
+	}
+
+	body := &countingReader{r: req.Body}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = body
+	}
+	rw := &responseWriter{ResponseWriter: w}
+	start := time.Now()
+	defer func() {
+		latency := time.Since(start)
+		requestSize := body.n
+		if req.ContentLength > requestSize {
+			requestSize = req.ContentLength
+		}
+		code := rw.code
+		if code == 0 {
+			// Nothing was written so net/http sends a 200.
+			code = http.StatusOK
+		}
+		v := recover()
+		if v != nil && rw.code == 0 {
+			// The server replies 500 to a panic before the response.
+			code = http.StatusInternalServerError
+		}
+		h.total.inFlight.Dec(1)
+		h.total.record(code, latency, requestSize, rw.n)
+		if path != nil {
+			path.inFlight.Dec(1)
+			path.record(code, latency, requestSize, rw.n)
+		}
+		if v != nil {
+			panic(v)
+		}
+	}()
+	h.next.ServeHTTP(rw, req)
+}
+
+// NormalizePath replaces the segments of a URL path that look like IDs,
+// such as numbers, UUIDs and long hex strings, with ":id" so that
+// "/users/42/posts" and "/users/43/posts" are both "/users/:id/posts".
+func NormalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if isID(s) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isID(s string) bool {
+	if s == "" {
+		return false
+	}
+	digits, hex := true, true
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == '-':
+			digits = false
+		default:
+			return false
+		}
+	}
+	return digits || (hex && len(s) >= 16)
+}
+
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	return r.r.Close()
+}
+
+// responseWriter records the status code and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	code int
+	n    int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 || w.code/100 == 1 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("httpmetrics: response writer can not be hijacked")
+	}
+	if w.code == 0 {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
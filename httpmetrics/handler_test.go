@@ -0,0 +1,107 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package httpmetrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+			return
+		}
+		if req.URL.Path == "/panic" {
+			panic("oops")
+		}
+		ioutil.ReadAll(req.Body)
+		w.Write([]byte("hello"))
+	})
+	handler := Handler(next, registry, &Options{
+		PathFunc: func(req *http.Request) string { return NormalizePath(req.URL.Path) },
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users/42", strings.NewReader("abc")))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/43", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected the panic to be passed on")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+
+	counts := map[string]uint64{
+		"requests":              4,
+		"status/2xx":            2,
+		"status/4xx":            1,
+		"status/5xx":            1,
+		"/users/:id/requests":   2,
+		"/users/:id/status/2xx": 2,
+		"/missing/status/4xx":   1,
+		"/panic/status/5xx":     1,
+		"/users/:id/status/4xx": 0,
+	}
+	for name, exp := range counts {
+		if c := registry.Counter(name); c == nil || c.Count() != exp {
+			t.Errorf("Expected %s of %d. Got %+v", name, exp, c)
+		}
+	}
+	if g := registry.Get("in_flight").(*metrics.IntegerGauge); g.Value() != 0 {
+		t.Fatalf("Expected no requests in flight. Got %f", g.Value())
+	}
+	if d := registry.Histogram("/users/:id/request_size").Distribution(); d.Count != 2 || d.Max != 3 {
+		t.Fatalf("Expected request sizes up to 3. Got %+v", d)
+	}
+	if d := registry.Histogram("/users/:id/response_size").Distribution(); d.Sum != 10 {
+		t.Fatalf("Expected response sizes of 10 in total. Got %+v", d)
+	}
+	if d := registry.Histogram("latency").Distribution(); d.Count != 4 {
+		t.Fatalf("Expected 4 latencies. Got %+v", d)
+	}
+}
+
+func TestHandlerMaxPaths(t *testing.T) {
+	registry := metrics.NewRegistry()
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	handler := Handler(next, registry, &Options{
+		PathFunc: func(req *http.Request) string { return req.URL.Path },
+		MaxPaths: 2,
+	})
+	for i := 0; i < 5; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/"+strconv.Itoa(i), nil))
+	}
+	if c := registry.Counter(OtherPath + "/requests"); c == nil || c.Count() != 3 {
+		t.Fatalf("Expected 3 requests under %s. Got %+v", OtherPath, c)
+	}
+	if registry.Counter("/3/requests") != nil {
+		t.Fatal("Expected paths beyond MaxPaths not to be recorded separately")
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	for path, exp := range map[string]string{
+		"/users/42/posts": "/users/:id/posts",
+		"/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301": "/orders/:id",
+		"/blobs/deadbeefdeadbeef":                      "/blobs/:id",
+		"/v2/face/cafe":                                "/v2/face/cafe",
+		"/":                                            "/",
+	} {
+		if got := NormalizePath(path); got != exp {
+			t.Errorf("Expected %s for %s. Got %s", exp, path, got)
+		}
+	}
+}
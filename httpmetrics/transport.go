@@ -0,0 +1,134 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// client holds the metrics recorded for a host or named client.
+type client struct {
+	requests    *metrics.Counter
+	errors      *metrics.Counter
+	status      [6]*metrics.Counter // by status code / 100
+	latency     metrics.Histogram
+	newConns    *metrics.Counter
+	reusedConns *metrics.Counter
+}
+
+func newClient(registry metrics.Registry) *client {
+	c := &client{
+		requests:    metrics.NewCounter(),
+		errors:      metrics.NewCounter(),
+		latency:     metrics.NewDefaultBucketedHistogram(),
+		newConns:    metrics.NewCounter(),
+		reusedConns: metrics.NewCounter(),
+	}
+	registry.Add("requests", c.requests)
+	registry.Add("errors", c.errors)
+	registry.Add("latency", c.latency)
+	registry.Add("conns/new", c.newConns)
+	registry.Add("conns/reused", c.reusedConns)
+	for i := 1; i < len(c.status); i++ {
+		c.status[i] = metrics.NewCounter()
+		registry.Add("status/"+strconv.Itoa(i)+"xx", c.status[i])
+	}
+	return c
+}
+
+type transport struct {
+	base     http.RoundTripper
+	registry metrics.Registry
+	name     string
+
+	mu    sync.RWMutex
+	hosts map[string]*client
+}
+
+// NewTransport returns an http.RoundTripper that makes requests with base,
+// or http.DefaultTransport if nil, and records in registry:
+//
+//	requests      counter of requests
+//	errors        counter of requests that failed without a response
+//	status/2xx    counters of responses by status class (1xx to 5xx)
+//	latency       histogram of the time until the response headers were
+//	              received in microseconds
+//	conns/new     counter of requests that opened a connection
+//	conns/reused  counter of requests that reused an idle connection
+//
+// The metrics are recorded with name as a prefix, such as the name of the
+// service being called, or if name is empty then with the host of each
+// request as the prefix. At most DefaultMaxPaths hosts are recorded
+// separately and the rest are recorded under OtherPath.
+func NewTransport(base http.RoundTripper, registry metrics.Registry, name string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{
+		base:     base,
+		registry: registry,
+		name:     name,
+		hosts:    make(map[string]*client),
+	}
+}
+
+func (t *transport) client(name string) *client {
+	t.mu.RLock()
+	c := t.hosts[name]
+	t.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c = t.hosts[name]; c == nil {
+		if len(t.hosts) >= DefaultMaxPaths {
+			if c = t.hosts[OtherPath]; c != nil {
+				return c
+			}
+			name = OtherPath
+		}
+		c = newClient(t.registry.Scope(name))
+		t.hosts[name] = c
+	}
+	return c
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := t.name
+	if name == "" {
+		name = req.URL.Host
+	}
+	c := t.client(name)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				c.reusedConns.Inc(1)
+			} else {
+				c.newConns.Inc(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	c.requests.Inc(1)
+	c.latency.Update(int64(time.Since(start) / time.Microsecond))
+	if err != nil {
+		c.errors.Inc(1)
+		return res, err
+	}
+	if s := res.StatusCode / 100; s > 0 && s < len(c.status) {
+		c.status[s].Inc(1)
+	}
+	return res, nil
+}
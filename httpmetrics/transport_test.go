@@ -0,0 +1,73 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package httpmetrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	registry := metrics.NewRegistry()
+	client := &http.Client{Transport: NewTransport(&http.Transport{}, registry, "")}
+	for _, path := range []string{"/", "/", "/missing"} {
+		res, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+	}
+	bad := &url.URL{Scheme: "http", Host: "127.0.0.1:1", Path: "/"}
+	if _, err := client.Get(bad.String()); err == nil {
+		t.Fatal("Expected an error connecting to a closed port")
+	}
+
+	for name, exp := range map[string]uint64{
+		host + "/requests":       3,
+		host + "/status/2xx":     2,
+		host + "/status/4xx":     1,
+		host + "/conns/new":      1,
+		host + "/conns/reused":   2,
+		host + "/errors":         0,
+		"127.0.0.1:1/errors":     1,
+		"127.0.0.1:1/status/2xx": 0,
+	} {
+		if c := registry.Counter(name); c == nil || c.Count() != exp {
+			t.Errorf("Expected %s of %d. Got %+v", name, exp, c)
+		}
+	}
+	if d := registry.Histogram(host + "/latency").Distribution(); d.Count != 3 {
+		t.Fatalf("Expected 3 latencies. Got %+v", d)
+	}
+}
+
+func TestTransportName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+	registry := metrics.NewRegistry()
+	client := &http.Client{Transport: NewTransport(nil, registry, "users")}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if c := registry.Counter("users/requests"); c == nil || c.Count() != 1 {
+		t.Fatalf("Expected users/requests of 1. Got %+v", c)
+	}
+}
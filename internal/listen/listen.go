@@ -0,0 +1,118 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package listen serves the connections of the listeners that receive
+// metrics over the network and closes them all when the listener is closed.
+package listen
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+)
+
+// MaxPacketSize is the largest UDP payload.
+const MaxPacketSize = 65535
+
+// Server tracks the listeners and connections being served. The zero value
+// is ready to use.
+type Server struct {
+	// ErrClosed is returned by Serve and ServePacket after Close.
+	ErrClosed error
+
+	mu     sync.Mutex
+	closed bool
+	conns  map[io.Closer]struct{}
+}
+
+// Serve accepts stream connections on ln and calls handle with each line
+// read from them until ln is closed.
+func (s *Server) Serve(ln net.Listener, handle func(line []byte)) error {
+	if !s.track(ln) {
+		ln.Close()
+		return s.ErrClosed
+	}
+	defer s.untrack(ln)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return s.ErrClosed
+			}
+			return err
+		}
+		go s.serveConn(conn, handle)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn, handle func(line []byte)) {
+	if !s.track(conn) {
+		conn.Close()
+		return
+	}
+	defer s.untrack(conn)
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		handle(sc.Bytes())
+	}
+}
+
+// ServePacket reads datagrams from conn and calls handle with each until
+// conn is closed. The packet is only valid until handle returns.
+func (s *Server) ServePacket(conn net.PacketConn, handle func(packet []byte, addr net.Addr)) error {
+	if !s.track(conn) {
+		conn.Close()
+		return s.ErrClosed
+	}
+	defer s.untrack(conn)
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return s.ErrClosed
+			}
+			return err
+		}
+		handle(buf[:n], addr)
+	}
+}
+
+// Close stops serving and closes all connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+func (s *Server) track(c io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[io.Closer]struct{})
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	c.Close()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
@@ -0,0 +1,49 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package listen
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestServerClose(t *testing.T) {
+	errClosed := errors.New("closed")
+	s := &Server{ErrClosed: errClosed}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := make(chan string, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.ServePacket(pc, func(packet []byte, addr net.Addr) { packets <- string(packet) })
+	}()
+
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-packets; p != "a" {
+		t.Fatalf("Expected a. Got %s", p)
+	}
+
+	s.Close()
+	if err := <-errc; err != errClosed {
+		t.Fatalf("Expected %v. Got %v", errClosed, err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(ln, func(line []byte) {}); err != errClosed {
+		t.Fatalf("Expected %v after Close. Got %v", errClosed, err)
+	}
+}
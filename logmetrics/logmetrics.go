@@ -0,0 +1,221 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package logmetrics counts log lines by level so that the rate of logged
+// errors can be monitored without a log pipeline.
+//
+// Lines are counted in the counters error, warn, info and debug, and lines
+// without a recognizable level in other.
+package logmetrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// Levels are the names of the counters lines are counted in.
+const (
+	LevelError = "error"
+	LevelWarn  = "warn"
+	LevelInfo  = "info"
+	LevelDebug = "debug"
+	LevelOther = "other"
+)
+
+// maxPartialLine limits how much of a line written in pieces is kept to
+// find its level.
+const maxPartialLine = 1024
+
+type counters struct {
+	error, warn, info, debug, other *metrics.Counter
+}
+
+func newCounters(registry metrics.Registry) *counters {
+	c := &counters{
+		error: metrics.NewCounter(),
+		warn:  metrics.NewCounter(),
+		info:  metrics.NewCounter(),
+		debug: metrics.NewCounter(),
+		other: metrics.NewCounter(),
+	}
+	registry.Add(LevelError, c.error)
+	registry.Add(LevelWarn, c.warn)
+	registry.Add(LevelInfo, c.info)
+	registry.Add(LevelDebug, c.debug)
+	registry.Add(LevelOther, c.other)
+	return c
+}
+
+func (c *counters) inc(level string) {
+	switch level {
+	case LevelError:
+		c.error.Inc(1)
+	case LevelWarn:
+		c.warn.Inc(1)
+	case LevelInfo:
+		c.info.Inc(1)
+	case LevelDebug:
+		c.debug.Inc(1)
+	default:
+		c.other.Inc(1)
+	}
+}
+
+// Writer is an io.Writer that counts the lines written to it by level
+// before passing them on. It suits loggers that write text such as the
+// standard library's log package.
+type Writer struct {
+	w          io.Writer
+	counters   *counters
+	parseLevel func(line []byte) string
+
+	mu      sync.Mutex
+	partial []byte // start of a line that hasn't ended yet
+}
+
+// NewWriter returns a Writer that writes to w, which may be nil to only
+// count lines. The level of each line is found by parseLevel, which
+// defaults to ParseLevel.
+func NewWriter(w io.Writer, registry metrics.Registry, parseLevel func(line []byte) string) *Writer {
+	if w == nil {
+		w = io.Discard
+	}
+	if parseLevel == nil {
+		parseLevel = ParseLevel
+	}
+	return &Writer{
+		w:          w,
+		counters:   newCounters(registry),
+		parseLevel: parseLevel,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	for b := p; len(b) > 0; {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			if n := maxPartialLine - len(w.partial); n > 0 {
+				if n > len(b) {
+					n = len(b)
+				}
+				w.partial = append(w.partial, b[:n]...)
+			}
+			break
+		}
+		line := b[:i]
+		if len(w.partial) > 0 {
+			line = append(w.partial, line...)
+			w.partial = w.partial[:0]
+		}
+		w.counters.inc(w.parseLevel(line))
+		b = b[i+1:]
+	}
+	w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// levelWords maps words that mark the level of a line to the level.
+var levelWords = map[string]string{
+	"error":    LevelError,
+	"fatal":    LevelError,
+	"panic":    LevelError,
+	"critical": LevelError,
+	"crit":     LevelError,
+	"warn":     LevelWarn,
+	"warning":  LevelWarn,
+	"info":     LevelInfo,
+	"notice":   LevelInfo,
+	"debug":    LevelDebug,
+	"trace":    LevelDebug,
+}
+
+var levelKeys = [][]byte{[]byte("level="), []byte(`"level":"`), []byte("lvl="), []byte(`"level": "`)}
+
+// ParseLevel returns the level of a line of text. It prefers a level
+// field such as level=ERROR or "level":"error" and otherwise uses the first
+// word that names a level such as WARN or [error]. It returns LevelOther if
+// there is none.
+func ParseLevel(line []byte) string {
+	lower := bytes.ToLower(line)
+	for _, key := range levelKeys {
+		if i := bytes.Index(lower, key); i >= 0 {
+			if level, ok := levelWords[string(word(lower[i+len(key):]))]; ok {
+				return level
+			}
+		}
+	}
+	for len(lower) > 0 {
+		start := bytes.IndexFunc(lower, isLetter)
+		if start < 0 {
+			break
+		}
+		w := word(lower[start:])
+		if level, ok := levelWords[string(w)]; ok {
+			return level
+		}
+		lower = lower[start+len(w):]
+	}
+	return LevelOther
+}
+
+// word returns the letters at the start of b.
+func word(b []byte) []byte {
+	for i, c := range b {
+		if !isLetter(rune(c)) {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z'
+}
+
+// Handler is a slog.Handler that counts the records it handles by level
+// before passing them to another handler.
+type Handler struct {
+	handler  slog.Handler
+	counters *counters
+}
+
+// NewHandler returns a Handler that passes records to h.
+func NewHandler(h slog.Handler, registry metrics.Registry) *Handler {
+	return &Handler{handler: h, counters: newCounters(registry)}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.counters.inc(slogLevel(r.Level))
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{handler: h.handler.WithAttrs(attrs), counters: h.counters}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{handler: h.handler.WithGroup(name), counters: h.counters}
+}
+
+func slogLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	}
+	return LevelDebug
+}
@@ -0,0 +1,79 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package logmetrics
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]string{
+		`time=2012-01-01 level=ERROR msg="failed"`: LevelError,
+		`{"time":"x","level":"warn","msg":"slow"}`: LevelWarn,
+		`level=info msg="error connecting"`:        LevelInfo,
+		`2012/01/01 [DEBUG] cache miss`:            LevelDebug,
+		`WARNING: disk almost full`:                LevelWarn,
+		`INFO failed with error`:                   LevelInfo,
+		`fatal: cannot open`:                       LevelError,
+		`informational message`:                    LevelOther,
+		`starting server`:                          LevelOther,
+	}
+	for line, exp := range tests {
+		if level := ParseLevel([]byte(line)); level != exp {
+			t.Errorf("Expected %s for %q. Got %s", exp, line, level)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	r := metrics.NewRegistry()
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, r, nil)
+	logger := log.New(w, "", log.LstdFlags)
+	logger.Print("ERROR: one")
+	logger.Print("error: two")
+	logger.Print("INFO: three")
+	logger.Print("hello")
+	// A line written in pieces is counted once it ends.
+	w.Write([]byte("WARN: four"))
+	if c := r.Counter(LevelWarn).Count(); c != 0 {
+		t.Fatalf("Expected no warnings before the end of the line. Got %d", c)
+	}
+	w.Write([]byte(" more\n"))
+
+	for level, exp := range map[string]uint64{LevelError: 2, LevelWarn: 1, LevelInfo: 1, LevelDebug: 0, LevelOther: 1} {
+		if c := r.Counter(level).Count(); c != exp {
+			t.Errorf("Expected %d %s lines. Got %d", exp, level, c)
+		}
+	}
+	if n := bytes.Count(buf.Bytes(), []byte{'\n'}); n != 5 {
+		t.Fatalf("Expected 5 lines passed on. Got %d", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := metrics.NewRegistry()
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), r))
+	logger.Error("one")
+	logger.With("request", 1).Error("two")
+	logger.WithGroup("g").Warn("three")
+	logger.Info("four")
+	logger.Debug("five")
+
+	for level, exp := range map[string]uint64{LevelError: 2, LevelWarn: 1, LevelInfo: 1, LevelDebug: 1, LevelOther: 0} {
+		if c := r.Counter(level).Count(); c != exp {
+			t.Errorf("Expected %d %s records. Got %d", exp, level, c)
+		}
+	}
+	if n := bytes.Count(buf.Bytes(), []byte{'\n'}); n != 5 {
+		t.Fatalf("Expected 5 records passed on. Got %d", n)
+	}
+}
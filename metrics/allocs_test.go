@@ -0,0 +1,76 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// TestAllocs enforces the allocation budgets of recording and reading
+// metrics. Recording values is on the hot path of instrumented code and
+// must not allocate. Reading a registry allocates per report rather than
+// per recorded value so only a limit is kept to catch regressions.
+func TestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	registry := benchmarkRegistry(100)
+	newCounter := func() interface{} { return NewCounter() }
+	counter := NewCounter()
+	integerGauge := NewIntegerGauge()
+	floatGauge := NewFloatGauge()
+	meter := NewMeter()
+	defer meter.Stop()
+	ewma := NewEWMA(5*time.Second, M1Alpha)
+	distribution := NewDistribution()
+	bucketed := NewDefaultBucketedHistogram()
+	uniform := NewUnbiasedHistogram()
+	biased := NewBiasedHistogram()
+	for i := int64(0); i < 2000; i++ {
+		// Fill the samples so updates replace values.
+		uniform.Update(i)
+		biased.Update(i)
+	}
+
+	reportRegistry := NewRegistry()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		c := NewCounter()
+		c.Inc(1)
+		reportRegistry.Add(name+"/counter", c)
+		h := NewDefaultBucketedHistogram()
+		h.Update(10)
+		reportRegistry.Add(name+"/latency", h)
+	}
+	snapshot := NewRegistrySnapshot(false)
+
+	for _, c := range []struct {
+		name   string
+		budget float64
+		f      func()
+	}{
+		{"Counter.Inc", 0, func() { counter.Inc(1) }},
+		{"IntegerGauge.Inc", 0, func() { integerGauge.Inc(1) }},
+		{"IntegerGauge.Set", 0, func() { integerGauge.Set(1) }},
+		{"FloatGauge.Set", 0, func() { floatGauge.Set(1.5) }},
+		{"Meter.Update", 0, func() { meter.Update(1) }},
+		{"EWMA.Update", 0, func() { ewma.Update(1) }},
+		{"Distribution.Update", 0, func() { distribution.Update(1.5) }},
+		{"BucketedHistogram.Update", 0, func() { bucketed.Update(100) }},
+		{"UniformSampledHistogram.Update", 0, func() { uniform.Update(100) }},
+		{"BiasedSampledHistogram.Update", 0, func() { biased.Update(100) }},
+		{"Registry.Get", 0, func() { registry.Get("metric50") }},
+		{"Registry.GetOrAdd", 0, func() { registry.GetOrAdd("metric50", newCounter) }},
+		{"Registry.Counter", 0, func() { registry.Counter("metric50") }},
+		{"RegistrySnapshot.Snapshot", 200, func() { snapshot.Snapshot(reportRegistry) }},
+		{"WritePrometheus", 200, func() { WritePrometheus(io.Discard, reportRegistry) }},
+		{"MarshalJSON", 200, func() { reportRegistry.MarshalJSON() }},
+	} {
+		if allocs := testing.AllocsPerRun(100, c.f); allocs > c.budget {
+			t.Errorf("%s: expected at most %v allocations. Got %v", c.name, c.budget, allocs)
+		}
+	}
+}
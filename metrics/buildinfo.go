@@ -0,0 +1,74 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Info is a metric that describes the program rather than measuring it,
+// such as the version it was built from. It's a gauge with a constant value
+// of 1 so that reporters without labels still report it, and Prometheus
+// reporters write the map as its labels.
+type Info map[string]string
+
+func (i Info) Value() float64 {
+	return 1
+}
+
+func (i Info) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string(i))
+}
+
+// AddBuildInfo adds metrics to the registry that correlate a process with
+// the build it's running:
+//
+//	build_info   Info of the version, commit, commit time, whether the
+//	             working tree was modified and the Go version
+//	start_time   gauge of the time the process started in Unix seconds
+//	uptime       gauge of the seconds since the process started
+//
+// The version and commit are read from the build info embedded by the Go
+// toolchain. A non-empty version replaces the module version, which is
+// "(devel)" unless the program was installed with go install.
+func AddBuildInfo(registry Registry, version string) error {
+	if err := registry.Add("build_info", buildInfo(version)); err != nil {
+		return err
+	}
+	s := processStats{startTime: initTime}
+	readProcessStats(&s)
+	start := s.startTime
+	if err := registry.Add("start_time", GaugeValue(float64(start.UnixNano())/1e9)); err != nil {
+		return err
+	}
+	return registry.Add("uptime", GaugeFunc(func() float64 { return time.Since(start).Seconds() }))
+}
+
+func buildInfo(version string) Info {
+	info := Info{"go_version": runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["version"] = bi.Main.Version
+		if bi.GoVersion != "" {
+			info["go_version"] = bi.GoVersion
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info["commit"] = s.Value
+			case "vcs.time":
+				info["commit_time"] = s.Value
+			case "vcs.modified":
+				info["modified"] = s.Value
+			}
+		}
+	}
+	if version != "" {
+		info["version"] = version
+	}
+	return info
+}
@@ -0,0 +1,57 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+)
+
+func TestAddBuildInfo(t *testing.T) {
+	r := NewRegistry()
+	if err := AddBuildInfo(r, "v1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	info, ok := r.Get("build_info").(Info)
+	if !ok {
+		t.Fatalf("Expected build info. Got %+v", r.Get("build_info"))
+	}
+	if info["version"] != "v1.2.3" || info["go_version"] != runtime.Version() {
+		t.Fatalf("Expected version v1.2.3 and %s. Got %+v", runtime.Version(), info)
+	}
+	start := r.Get("start_time").(GaugeMetric).Value()
+	if start <= 0 {
+		t.Fatalf("Expected a start time. Got %f", start)
+	}
+	if uptime := r.Get("uptime").(GaugeMetric).Value(); uptime < 0 {
+		t.Fatalf("Expected a positive uptime. Got %f", uptime)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	r := NewRegistry()
+	r.Add("build", Info{"version": "v1", "commit": "abc"})
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"build":{"commit":"abc","version":"v1"}}`; string(b) != exp {
+		t.Fatalf("Expected %s. Got %s", exp, b)
+	}
+
+	buf := &bytes.Buffer{}
+	WritePrometheus(buf, r)
+	if exp := "# TYPE build gauge\nbuild{commit=\"abc\",version=\"v1\"} 1\n"; buf.String() != exp {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", exp, buf.String())
+	}
+	buf.Reset()
+	WriteOpenMetrics(buf, r)
+	if exp := "# TYPE build info\nbuild_info{commit=\"abc\",version=\"v1\"} 1\n# EOF\n"; buf.String() != exp {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", exp, buf.String())
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"time"
+)
+
+// CacheStats are the cumulative statistics of a cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRatio returns the fraction of lookups that were hits or 0 if there
+// have been none.
+func (s CacheStats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// CacheStatser is implemented by caches that keep their own statistics.
+// Most cache libraries expose counters that are easily adapted with
+// CacheStatsFunc.
+type CacheStatser interface {
+	CacheStats() CacheStats
+}
+
+// CacheStatsFunc adapts a function to the CacheStatser interface.
+type CacheStatsFunc func() CacheStats
+
+func (f CacheStatsFunc) CacheStats() CacheStats {
+	return f()
+}
+
+// AddCacheStats adds metrics to the registry that read the statistics of a
+// cache that keeps its own: name/hits, name/misses and name/evictions
+// counters and a name/hit_ratio gauge.
+func AddCacheStats(registry Registry, name string, cache CacheStatser) {
+	registry.Add(name+"/hits", CounterFunc(func() uint64 { return cache.CacheStats().Hits }))
+	registry.Add(name+"/misses", CounterFunc(func() uint64 { return cache.CacheStats().Misses }))
+	registry.Add(name+"/evictions", CounterFunc(func() uint64 { return cache.CacheStats().Evictions }))
+	registry.Add(name+"/hit_ratio", GaugeFunc(func() float64 { return cache.CacheStats().HitRatio() }))
+}
+
+// CacheMetrics records the effectiveness of a cache that doesn't keep its
+// own statistics.
+type CacheMetrics struct {
+	Hits      *Counter
+	Misses    *Counter
+	Evictions *Counter
+	// Load is the time taken to load missing values in microseconds.
+	Load Histogram
+}
+
+// NewCacheMetrics returns CacheMetrics added to the registry as name/hits,
+// name/misses, name/evictions and name/load along with a name/hit_ratio
+// gauge.
+func NewCacheMetrics(registry Registry, name string) *CacheMetrics {
+	m := &CacheMetrics{
+		Hits:      NewCounter(),
+		Misses:    NewCounter(),
+		Evictions: NewCounter(),
+		Load:      NewDefaultBucketedHistogram(),
+	}
+	registry.Add(name+"/hits", m.Hits)
+	registry.Add(name+"/misses", m.Misses)
+	registry.Add(name+"/evictions", m.Evictions)
+	registry.Add(name+"/load", m.Load)
+	registry.Add(name+"/hit_ratio", GaugeFunc(func() float64 { return m.CacheStats().HitRatio() }))
+	return m
+}
+
+// Hit records a lookup that found a value.
+func (m *CacheMetrics) Hit() {
+	m.Hits.Inc(1)
+}
+
+// Miss records a lookup that didn't find a value.
+func (m *CacheMetrics) Miss() {
+	m.Misses.Inc(1)
+}
+
+// Lookup records a hit if found is true and a miss otherwise.
+func (m *CacheMetrics) Lookup(found bool) {
+	if found {
+		m.Hits.Inc(1)
+	} else {
+		m.Misses.Inc(1)
+	}
+}
+
+// Evict records n values being evicted.
+func (m *CacheMetrics) Evict(n int) {
+	m.Evictions.Inc(uint64(n))
+}
+
+// Loaded records the time taken by a load that started at start.
+func (m *CacheMetrics) Loaded(start time.Time) {
+	m.Load.Update(int64(time.Since(start) / time.Microsecond))
+}
+
+// CacheStats returns the recorded statistics.
+func (m *CacheMetrics) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:      m.Hits.Count(),
+		Misses:    m.Misses.Count(),
+		Evictions: m.Evictions.Count(),
+	}
+}
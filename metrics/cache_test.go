@@ -0,0 +1,50 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheMetrics(t *testing.T) {
+	r := NewRegistry()
+	m := NewCacheMetrics(r, "cache")
+	m.Hit()
+	m.Lookup(true)
+	m.Lookup(true)
+	m.Miss()
+	m.Evict(3)
+	m.Loaded(time.Now())
+
+	exp := CacheStats{Hits: 3, Misses: 1, Evictions: 3}
+	if s := m.CacheStats(); s != exp {
+		t.Fatalf("Expected %+v. Got %+v", exp, s)
+	}
+	if v := r.Get("cache/hit_ratio").(GaugeMetric).Value(); v != 0.75 {
+		t.Fatalf("Expected hit ratio of 0.75. Got %f", v)
+	}
+	if c := m.Load.Distribution().Count; c != 1 {
+		t.Fatalf("Expected 1 load. Got %d", c)
+	}
+}
+
+func TestAddCacheStats(t *testing.T) {
+	r := NewRegistry()
+	stats := CacheStats{Hits: 1, Misses: 3, Evictions: 2}
+	AddCacheStats(r, "lru", CacheStatsFunc(func() CacheStats { return stats }))
+	if c := r.Get("lru/misses").(CounterMetric).Count(); c != 3 {
+		t.Fatalf("Expected 3 misses. Got %d", c)
+	}
+	if c := r.Get("lru/evictions").(CounterMetric).Count(); c != 2 {
+		t.Fatalf("Expected 2 evictions. Got %d", c)
+	}
+	if v := r.Get("lru/hit_ratio").(GaugeMetric).Value(); v != 0.25 {
+		t.Fatalf("Expected hit ratio of 0.25. Got %f", v)
+	}
+	if v := (CacheStats{}).HitRatio(); v != 0 {
+		t.Fatalf("Expected hit ratio of 0 without lookups. Got %f", v)
+	}
+}
@@ -7,6 +7,7 @@ package metrics
 import (
 	"strconv"
 	"sync/atomic"
+	"time"
 )
 
 // Counter is the interface for a counter metric.
@@ -27,26 +28,72 @@ func (f CounterFunc) Count() uint64 {
 }
 
 type Counter struct {
-	value uint64
+	value    uint64
+	restored uint64       // count added by LoadState since the last reset
+	created  int64        // UnixNano, zero if unknown
+	exemplar atomic.Value // *Exemplar
+}
+
+// Exemplar is an example of an event recorded by a metric, such as the
+// trace ID of a request that was counted. It's exposed in the OpenMetrics
+// format so that a spike in a graph can be traced back to the requests
+// behind it.
+type Exemplar struct {
+	// Labels identify the example such as {"trace_id": "abc"}. OpenMetrics
+	// limits the labels to 128 characters in total.
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
 }
 
 // NewCounter returns a counter implemented as an atomic uint64.
 func NewCounter() *Counter {
-	return &Counter{}
+	return &Counter{created: time.Now().UnixNano()}
 }
 
 func (c *Counter) Inc(delta uint64) {
 	atomic.AddUint64(&c.value, delta)
 }
 
+// IncWithExemplar increments the counter and records labels as the
+// counter's latest exemplar.
+func (c *Counter) IncWithExemplar(delta uint64, labels map[string]string) {
+	atomic.AddUint64(&c.value, delta)
+	c.exemplar.Store(&Exemplar{Labels: labels, Value: float64(delta), Timestamp: time.Now()})
+}
+
+// Exemplar returns the latest exemplar or nil if there is none.
+func (c *Counter) Exemplar() *Exemplar {
+	e, _ := c.exemplar.Load().(*Exemplar)
+	return e
+}
+
+// Created returns the time the counter was created or last reset. It's
+// zero for a counter that wasn't created by NewCounter.
+func (c *Counter) Created() time.Time {
+	if ns := atomic.LoadInt64(&c.created); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
 func (c *Counter) Count() uint64 {
 	return atomic.LoadUint64(&c.value)
 }
 
 func (c *Counter) Reset() uint64 {
+	atomic.StoreInt64(&c.created, time.Now().UnixNano())
+	atomic.StoreUint64(&c.restored, 0)
 	return atomic.SwapUint64(&c.value, 0)
 }
 
+// restore adds a count saved by SaveState. It's remembered so that
+// snapshots reporting the change in counters don't report it as new.
+func (c *Counter) restore(count uint64) {
+	atomic.AddUint64(&c.restored, count)
+	atomic.AddUint64(&c.value, count)
+}
+
 func (c *Counter) String() string {
 	return strconv.FormatUint(c.Count(), 10)
 }
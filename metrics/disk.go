@@ -0,0 +1,109 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// diskSectorSize is the unit of sectors in /proc/diskstats, which is
+// always 512 bytes regardless of the device.
+const diskSectorSize = 512
+
+type diskMetrics struct {
+	paths map[string]string // metric name to path
+}
+
+// fsStats are the usage of a filesystem.
+type fsStats struct {
+	total, free, used      uint64 // bytes
+	inodesFree, inodesUsed uint64
+}
+
+// diskIO is the I/O of a block device since boot.
+type diskIO struct {
+	reads, writes           uint64
+	readBytes, writtenBytes uint64
+}
+
+// NewDiskMetrics returns a collection of the usage of the filesystems
+// holding each path, such as a service's data directories. Each path's
+// metrics are named after it with the leading and trailing slashes removed
+// ("root" for "/"):
+//
+//	fs/<path>/bytes_total    gauge of the size of the filesystem
+//	fs/<path>/bytes_free     gauge of the bytes available to unprivileged users
+//	fs/<path>/bytes_used     gauge of the bytes in use
+//	fs/<path>/inodes_free    gauge of free inodes
+//	fs/<path>/inodes_used    gauge of inodes in use
+//	fs/<path>/reads          counter of reads completed by the device
+//	fs/<path>/writes         counter of writes completed by the device
+//	fs/<path>/read_bytes     counter of bytes read from the device
+//	fs/<path>/written_bytes  counter of bytes written to the device
+//
+// The usage is available on Linux, macOS and FreeBSD. The I/O counters are
+// read from /proc/diskstats on Linux and only for filesystems on block
+// devices.
+func NewDiskMetrics(paths ...string) Collection {
+	d := &diskMetrics{paths: make(map[string]string, len(paths))}
+	for _, path := range paths {
+		name := strings.Trim(path, "/")
+		if name == "" {
+			name = "root"
+		}
+		d.paths[name] = path
+	}
+	return d
+}
+
+func (d *diskMetrics) Metrics() map[string]interface{} {
+	m := make(map[string]interface{})
+	for name, path := range d.paths {
+		prefix := "fs/" + name + "/"
+		if s, ok := readFSStats(path); ok {
+			m[prefix+"bytes_total"] = GaugeValue(s.total)
+			m[prefix+"bytes_free"] = GaugeValue(s.free)
+			m[prefix+"bytes_used"] = GaugeValue(s.used)
+			m[prefix+"inodes_free"] = GaugeValue(s.inodesFree)
+			m[prefix+"inodes_used"] = GaugeValue(s.inodesUsed)
+		}
+		if dio, ok := readDiskIO(path); ok {
+			m[prefix+"reads"] = CounterValue(dio.reads)
+			m[prefix+"writes"] = CounterValue(dio.writes)
+			m[prefix+"read_bytes"] = CounterValue(dio.readBytes)
+			m[prefix+"written_bytes"] = CounterValue(dio.writtenBytes)
+		}
+	}
+	return m
+}
+
+// parseDiskstats returns the I/O of the device with the given major and
+// minor numbers from r, which is in the format of /proc/diskstats.
+func parseDiskstats(r io.Reader, major, minor uint64) (diskIO, bool) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		if fields[0] != strconv.FormatUint(major, 10) || fields[1] != strconv.FormatUint(minor, 10) {
+			continue
+		}
+		field := func(n int) uint64 {
+			v, _ := strconv.ParseUint(fields[n], 10, 64)
+			return v
+		}
+		return diskIO{
+			reads:        field(3),
+			readBytes:    field(5) * diskSectorSize,
+			writes:       field(7),
+			writtenBytes: field(9) * diskSectorSize,
+		}, true
+	}
+	return diskIO{}, false
+}
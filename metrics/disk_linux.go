@@ -0,0 +1,27 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+// readDiskIO reads the I/O of the block device holding path.
+func readDiskIO(path string) (diskIO, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return diskIO{}, false
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return diskIO{}, false
+	}
+	defer f.Close()
+	return parseDiskstats(f, major, minor)
+}
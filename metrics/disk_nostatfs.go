@@ -0,0 +1,11 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd
+
+package metrics
+
+func readFSStats(path string) (fsStats, bool) {
+	return fsStats{}, false
+}
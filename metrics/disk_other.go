@@ -0,0 +1,11 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package metrics
+
+func readDiskIO(path string) (diskIO, bool) {
+	return diskIO{}, false
+}
@@ -0,0 +1,24 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd
+
+package metrics
+
+import "syscall"
+
+func readFSStats(path string) (fsStats, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsStats{}, false
+	}
+	bsize := uint64(st.Bsize)
+	return fsStats{
+		total:      uint64(st.Blocks) * bsize,
+		free:       uint64(st.Bavail) * bsize,
+		used:       (uint64(st.Blocks) - uint64(st.Bfree)) * bsize,
+		inodesFree: uint64(st.Ffree),
+		inodesUsed: uint64(st.Files) - uint64(st.Ffree),
+	}, true
+}
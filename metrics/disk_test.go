@@ -0,0 +1,45 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskstats(t *testing.T) {
+	in := `   7       0 loop0 10 0 20 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+   8       0 sda 100 5 2000 30 50 3 400 10 0 40 40 0 0 0 0 0 0
+   8       1 sda1 90 5 1800 25 40 3 300 8 0 30 33 0 0 0 0 0 0
+`
+	io, ok := parseDiskstats(strings.NewReader(in), 8, 1)
+	exp := diskIO{reads: 90, readBytes: 1800 * 512, writes: 40, writtenBytes: 300 * 512}
+	if !ok || io != exp {
+		t.Fatalf("Expected %+v. Got %+v", exp, io)
+	}
+	if _, ok := parseDiskstats(strings.NewReader(in), 8, 2); ok {
+		t.Fatal("Expected no stats for a missing device")
+	}
+}
+
+func TestDiskMetrics(t *testing.T) {
+	m := NewDiskMetrics("/", os.TempDir()).Metrics()
+	if runtime.GOOS != "linux" {
+		return
+	}
+	total, ok := m["fs/root/bytes_total"].(GaugeValue)
+	if !ok || total <= 0 {
+		t.Fatalf("Expected the size of the root filesystem. Got %+v", m)
+	}
+	if free := m["fs/root/bytes_free"].(GaugeValue); free > total {
+		t.Fatalf("Expected free bytes of at most %f. Got %f", total, free)
+	}
+	name := "fs/" + strings.Trim(os.TempDir(), "/") + "/bytes_used"
+	if _, ok := m[name].(GaugeValue); !ok {
+		t.Fatalf("Expected %s. Got %+v", name, m)
+	}
+}
@@ -5,6 +5,7 @@
 package metrics
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"strconv"
@@ -26,6 +27,25 @@ func (v DistributionValue) Mean() float64 {
 	return 0.0
 }
 
+func (v DistributionValue) String() string {
+	return fmt.Sprintf("{\"count\":%d,\"sum\":%s,\"min\":%s,\"max\":%s,\"stddev\":%s}",
+		v.Count,
+		strconv.FormatFloat(v.Sum, 'g', -1, 64),
+		strconv.FormatFloat(v.Min, 'g', -1, 64),
+		strconv.FormatFloat(v.Max, 'g', -1, 64),
+		strconv.FormatFloat(math.Sqrt(v.Variance), 'g', -1, 64))
+}
+
+func (v DistributionValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Count  uint64    `json:"count"`
+		Sum    jsonFloat `json:"sum"`
+		Min    jsonFloat `json:"min"`
+		Max    jsonFloat `json:"max"`
+		StdDev jsonFloat `json:"stddev"`
+	}{v.Count, jsonFloat(v.Sum), jsonFloat(v.Min), jsonFloat(v.Max), jsonFloat(math.Sqrt(v.Variance))})
+}
+
 type DistributionMetric interface {
 	Value() DistributionValue
 }
@@ -53,17 +73,11 @@ func NewDistribution() *Distribution {
 }
 
 func (d *Distribution) String() string {
-	v := d.Value()
-	return fmt.Sprintf("{\"count\":%d,\"sum\":%s,\"min\":%s,\"max\":%s,\"stddev\":%s}",
-		v.Count,
-		strconv.FormatFloat(v.Sum, 'g', -1, 64),
-		strconv.FormatFloat(v.Min, 'g', -1, 64),
-		strconv.FormatFloat(v.Max, 'g', -1, 64),
-		strconv.FormatFloat(math.Sqrt(v.Variance), 'g', -1, 64))
+	return d.Value().String()
 }
 
 func (d *Distribution) MarshalJSON() ([]byte, error) {
-	return []byte(d.String()), nil
+	return d.Value().MarshalJSON()
 }
 
 func (d *Distribution) MarshalText() ([]byte, error) {
@@ -104,6 +118,37 @@ func (d *Distribution) Update(value float64) {
 	d.mu.Unlock()
 }
 
+// Merge adds the data points summarized by v, such as a distribution
+// recorded by another process, as if each had been passed to Update.
+func (d *Distribution) Merge(v DistributionValue) {
+	if v.Count == 0 {
+		return
+	}
+	d.mu.Lock()
+	mean := v.Sum / float64(v.Count)
+	s := v.Variance * float64(v.Count-1)
+	if d.count == 0 {
+		d.variance = variance{m: mean, s: s}
+	} else {
+		// Combine the sums of squared differences from the two means.
+		n := float64(d.count + v.Count)
+		delta := mean - d.variance.m
+		d.variance = variance{
+			m: d.variance.m + delta*float64(v.Count)/n,
+			s: d.variance.s + s + delta*delta*float64(d.count)*float64(v.Count)/n,
+		}
+	}
+	d.count += v.Count
+	d.sum += v.Sum
+	if v.Min < d.min {
+		d.min = v.Min
+	}
+	if v.Max > d.max {
+		d.max = v.Max
+	}
+	d.mu.Unlock()
+}
+
 // Count returns the number of data points
 func (d *Distribution) Count() uint64 {
 	d.mu.Lock()
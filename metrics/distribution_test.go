@@ -4,7 +4,10 @@
 
 package metrics
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestDistribution(t *testing.T) {
 	d := NewDistribution()
@@ -50,6 +53,29 @@ func TestDistribution(t *testing.T) {
 	}
 }
 
+func TestDistributionMerge(t *testing.T) {
+	d := NewDistribution()
+	d.Update(2.0)
+	other := NewDistribution()
+	other.Update(9.0)
+	other.Update(4.0)
+	d.Merge(other.Value())
+	d.Merge(NewDistribution().Value())
+	v := d.Value()
+	if v.Count != 3 || v.Sum != 15.0 || v.Min != 2.0 || v.Max != 9.0 {
+		t.Fatalf("Expected count 3, sum 15, min 2, max 9. Got %+v", v)
+	}
+	if math.Abs(v.Variance-13.0) > 1e-9 {
+		t.Fatalf("Expected variance of 13.0. Got %f", v.Variance)
+	}
+
+	empty := NewDistribution()
+	empty.Merge(v)
+	if ev := empty.Value(); ev.Count != v.Count || math.Abs(ev.Variance-v.Variance) > 1e-9 {
+		t.Fatalf("Expected %+v. Got %+v", v, ev)
+	}
+}
+
 func BenchmarkDistributionConcurrentUpdate(b *testing.B) {
 	concurrency := 100
 	d := NewDistribution()
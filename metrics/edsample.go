@@ -137,8 +137,10 @@ func (s *exponentiallyDecayingSample) Update(value int64) {
 		heap.Push(s.values, priorityValue{priority: priority, value: value})
 	} else {
 		if first := s.values.Get(0); first.priority < priority {
-			heap.Pop(s.values)
-			heap.Push(s.values, priorityValue{priority: priority, value: value})
+			// Replace the lowest priority value in place rather than with
+			// Pop and Push, which box the value and so allocate.
+			s.values.samples[0] = priorityValue{priority: priority, value: value}
+			heap.Fix(s.values, 0)
 		}
 	}
 }
@@ -29,7 +29,7 @@ type EWMA struct {
 	rate           uint64        // really a float64 but using uint64 for atomicity
 	alpha          float64       // the smoothing constant
 	uncounted      uint64
-	initialized    bool
+	initialized    uint32 // set atomically
 	ticker         *time.Ticker
 	tickerStopChan chan bool
 }
@@ -37,9 +37,8 @@ type EWMA struct {
 // NewEWMA returns a new exponentially-weighte moving average.
 func NewEWMA(interval time.Duration, alpha float64) *EWMA {
 	return &EWMA{
-		interval:    interval,
-		alpha:       alpha,
-		initialized: false,
+		interval: interval,
+		alpha:    alpha,
 	}
 }
 
@@ -103,11 +102,18 @@ func (e *EWMA) Tick() {
 	count := atomic.SwapUint64(&e.uncounted, 0)
 	instantRate := float64(count) / e.interval.Seconds()
 	rate := e.Rate()
-	if e.initialized {
+	if atomic.LoadUint32(&e.initialized) != 0 {
 		rate += e.alpha * (instantRate - rate)
 	} else {
 		rate = instantRate
-		e.initialized = true
+		atomic.StoreUint32(&e.initialized, 1)
 	}
 	atomic.StoreUint64(&e.rate, math.Float64bits(rate))
 }
+
+// Reset the moving average to its initial state
+func (e *EWMA) Reset() {
+	atomic.StoreUint32(&e.initialized, 0)
+	atomic.StoreUint64(&e.uncounted, 0)
+	atomic.StoreUint64(&e.rate, 0)
+}
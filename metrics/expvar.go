@@ -0,0 +1,158 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"expvar"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// RegistryVar is an expvar.Var that renders all metrics in a registry
+// as a JSON object each time it's read.
+type RegistryVar struct {
+	Registry Registry
+}
+
+func (v *RegistryVar) String() string {
+	b, err := v.Registry.MarshalJSON()
+	if err != nil {
+		log.Printf("metrics: failed to encode registry for expvar: %s", err.Error())
+		return "null"
+	}
+	return string(b)
+}
+
+// PublishExpvar publishes the registry as an expvar variable so the
+// metrics show up under /debug/vars. Like expvar.Publish it panics
+// if the name is already in use.
+func PublishExpvar(name string, reg Registry) {
+	expvar.Publish(name, &RegistryVar{Registry: reg})
+}
+
+// ImportExpvar adds an expvar variable to the registry. *expvar.Int and
+// *expvar.Float are added as gauges that read the variable's current
+// value and *expvar.Map is added as a collection of its numeric entries.
+// It returns false if the variable is of an unsupported type.
+func ImportExpvar(reg Registry, name string, v expvar.Var) bool {
+	switch x := v.(type) {
+	case *expvar.Int:
+		reg.Add(name, GaugeFunc(func() float64 { return float64(x.Value()) }))
+	case *expvar.Float:
+		reg.Add(name, GaugeFunc(x.Value))
+	case *expvar.Map:
+		reg.Add(name, &expvarMap{x})
+	default:
+		return false
+	}
+	return true
+}
+
+// ImportExpvars adds all currently published expvar variables of a
+// supported type to the registry.
+func ImportExpvars(reg Registry) {
+	expvar.Do(func(kv expvar.KeyValue) {
+		ImportExpvar(reg, kv.Key, kv.Value)
+	})
+}
+
+// ExpvarCounter returns a counter for an expvar.Int that only ever
+// increases. Unlike the gauge added by ImportExpvar, a counter is
+// reported as the change since the previous report.
+func ExpvarCounter(v *expvar.Int) CounterFunc {
+	return func() uint64 { return uint64(v.Value()) }
+}
+
+// ExpvarHandler returns an http.Handler that serves the metrics in reg in
+// the same JSON shape as expvar.Handler at /debug/vars, so that tools which
+// scrape expvar keep working. Each metric is a top-level key next to the
+// published expvar variables, such as "cmdline" and "memstats", which are
+// passed through unchanged. A metric hides a variable with the same name.
+func ExpvarHandler(reg Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := WriteExpvar(w, reg); err != nil {
+			log.Printf("metrics: failed to encode registry: %s", err.Error())
+		}
+	})
+}
+
+// WriteExpvar writes the metrics in reg and the published expvar variables
+// to w as described by ExpvarHandler.
+func WriteExpvar(w io.Writer, reg Registry) error {
+	vars := make(map[string]string)
+	expvar.Do(func(kv expvar.KeyValue) {
+		vars[kv.Key] = kv.Value.String()
+	})
+	err := reg.Do(func(name string, metric interface{}) error {
+		b, err := metricJSON(metric)
+		if err != nil {
+			return err
+		}
+		vars[name] = string(b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Written the same way as expvar.Handler.
+	bw := bufio.NewWriter(w)
+	bw.WriteString("{\n")
+	for i, name := range names {
+		if i > 0 {
+			bw.WriteString(",\n")
+		}
+		bw.WriteString(strconv.Quote(name))
+		bw.WriteString(": ")
+		bw.WriteString(vars[name])
+	}
+	bw.WriteString("\n}\n")
+	return bw.Flush()
+}
+
+// metricJSON encodes a metric as JSON. Gauges and counters that don't
+// implement json.Marshaler, such as GaugeFunc, are encoded as numbers, or
+// null if the value isn't finite.
+func metricJSON(metric interface{}) ([]byte, error) {
+	switch m := metric.(type) {
+	case json.Marshaler:
+		return m.MarshalJSON()
+	case GaugeMetric:
+		return jsonFloat(m.Value()).MarshalJSON()
+	case CounterMetric:
+		return []byte(strconv.FormatUint(m.Count(), 10)), nil
+	}
+	return json.Marshal(metric)
+}
+
+type expvarMap struct {
+	m *expvar.Map
+}
+
+func (e *expvarMap) Metrics() map[string]interface{} {
+	metrics := make(map[string]interface{})
+	e.m.Do(func(kv expvar.KeyValue) {
+		switch x := kv.Value.(type) {
+		case *expvar.Int:
+			metrics[kv.Key] = GaugeValue(x.Value())
+		case *expvar.Float:
+			metrics[kv.Key] = GaugeValue(x.Value())
+		case *expvar.Map:
+			metrics[kv.Key] = &expvarMap{x}
+		}
+	})
+	return metrics
+}
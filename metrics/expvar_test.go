@@ -0,0 +1,91 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// publishExpvarRuns gives each run of TestPublishExpvar its own name since
+// expvar names can't be published twice.
+var publishExpvarRuns int
+
+func TestPublishExpvar(t *testing.T) {
+	publishExpvarRuns++
+	name := fmt.Sprintf("TestPublishExpvar%d", publishExpvarRuns)
+	r := NewRegistry()
+	PublishExpvar(name, r)
+	counter := NewCounter()
+	r.Add("counter", counter)
+	counter.Inc(2)
+
+	var out map[string]uint64
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["counter"] != 2 {
+		t.Fatalf("Expected counter of 2 in expvar. Got %+v", out)
+	}
+}
+
+func TestImportExpvar(t *testing.T) {
+	i := new(expvar.Int)
+	m := new(expvar.Map).Init()
+	m.Add("hits", 3)
+	m.AddFloat("ratio", 0.5)
+	m.Set("name", new(expvar.String))
+	r := NewRegistry()
+	if !ImportExpvar(r, "int", i) || !ImportExpvar(r, "map", m) {
+		t.Fatal("ImportExpvar should support Int and Map")
+	}
+	if ImportExpvar(r, "string", new(expvar.String)) {
+		t.Fatal("ImportExpvar should not support String")
+	}
+	i.Set(7)
+
+	values := make(map[string]float64)
+	r.Do(func(name string, metric interface{}) error {
+		values[name] = metric.(GaugeMetric).Value()
+		return nil
+	})
+	exp := map[string]float64{"int": 7, "map/hits": 3, "map/ratio": 0.5}
+	if !reflect.DeepEqual(values, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, values)
+	}
+}
+
+func TestExpvarHandler(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounter()
+	counter.Inc(2)
+	r.Add("counter", counter)
+	r.Add("func", GaugeFunc(func() float64 { return 1.5 }))
+
+	rec := httptest.NewRecorder()
+	ExpvarHandler(r).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "{\n") || !strings.HasSuffix(body, "\n}\n") || !strings.Contains(body, "\n\"counter\": 2,\n") {
+		t.Fatalf("Expected the expvar format. Got %s", body)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["counter"] != 2.0 || out["func"] != 1.5 {
+		t.Fatalf("Expected metrics. Got %+v", out)
+	}
+	if memstats, ok := out["memstats"].(map[string]interface{}); !ok || memstats["Alloc"] == nil {
+		t.Fatalf("Expected memstats. Got %+v", out["memstats"])
+	}
+	if _, ok := out["cmdline"].([]interface{}); !ok {
+		t.Fatalf("Expected cmdline. Got %+v", out["cmdline"])
+	}
+}
@@ -5,6 +5,7 @@
 package metrics
 
 import (
+	"math"
 	"strconv"
 	"sync/atomic"
 )
@@ -68,3 +69,45 @@ func (c *IntegerGauge) MarshalJSON() ([]byte, error) {
 func (c *IntegerGauge) MarshalText() ([]byte, error) {
 	return c.MarshalJSON()
 }
+
+// FloatGauge is a gauge holding a float64 that can be set or adjusted.
+type FloatGauge struct {
+	bits uint64 // math.Float64bits of the value for atomicity
+}
+
+func NewFloatGauge() *FloatGauge {
+	return &FloatGauge{}
+}
+
+func (g *FloatGauge) Set(value float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(value))
+}
+
+// Add adds delta, which may be negative, to the value.
+func (g *FloatGauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		value := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, value) {
+			return
+		}
+	}
+}
+
+func (g *FloatGauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *FloatGauge) String() string {
+	return strconv.FormatFloat(g.Value(), 'g', -1, 64)
+}
+
+// MarshalJSON encodes the value as a number, or null if it's NaN or an
+// infinity since JSON can't represent them.
+func (g *FloatGauge) MarshalJSON() ([]byte, error) {
+	return jsonFloat(g.Value()).MarshalJSON()
+}
+
+func (g *FloatGauge) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
@@ -21,6 +21,18 @@ func TestIntegerGauge(t *testing.T) {
 	}
 }
 
+func TestFloatGauge(t *testing.T) {
+	g := NewFloatGauge()
+	g.Set(1.5)
+	g.Add(-0.25)
+	if g.Value() != 1.25 {
+		t.Fatalf("FloatGauge should be 1.25 not %f", g.Value())
+	}
+	if g.String() != "1.25" {
+		t.Fatalf("FloatGauge string should be 1.25 not %s", g.String())
+	}
+}
+
 func BenchmarkIntegerGaugeInc(b *testing.B) {
 	c := NewIntegerGauge()
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,117 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// GoroutineMetrics is a collection with a single gauge, goroutines, of the
+// number of goroutines that currently exist.
+var GoroutineMetrics = &goroutineMetrics{}
+
+type goroutineMetrics struct{}
+
+func (g *goroutineMetrics) Metrics() map[string]interface{} {
+	return map[string]interface{}{
+		"goroutines": GaugeValue(runtime.NumGoroutine()),
+	}
+}
+
+// GoroutineLeakWatch is a collection that samples the number of goroutines
+// at an interval to help catch leaks before they exhaust memory:
+//
+//	goroutines         gauge of the current number of goroutines
+//	goroutines/max     gauge of the highest number seen
+//	goroutines/growth  meter of the increase between samples
+//
+// A steady rate of growth with no matching fall means goroutines are being
+// started faster than they finish.
+type GoroutineLeakWatch struct {
+	growth   *Meter
+	stopChan chan struct{}
+	stopOnce sync.Once
+
+	mu   sync.Mutex
+	last int
+	max  int
+}
+
+// NewGoroutineLeakWatch starts sampling the number of goroutines every
+// interval. Call Stop once it's no longer needed.
+func NewGoroutineLeakWatch(interval time.Duration) *GoroutineLeakWatch {
+	n := runtime.NumGoroutine()
+	w := &GoroutineLeakWatch{
+		growth:   NewMeter(),
+		stopChan: make(chan struct{}),
+		last:     n,
+		max:      n,
+	}
+	go w.run(interval)
+	return w
+}
+
+func (w *GoroutineLeakWatch) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.sample()
+		}
+	}
+}
+
+func (w *GoroutineLeakWatch) sample() {
+	n := runtime.NumGoroutine()
+	w.mu.Lock()
+	if n > w.last {
+		w.growth.Update(uint64(n - w.last))
+	}
+	if n > w.max {
+		w.max = n
+	}
+	w.last = n
+	w.mu.Unlock()
+}
+
+// Max returns the highest number of goroutines seen.
+func (w *GoroutineLeakWatch) Max() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.max
+}
+
+// Growth returns the meter of the increase in goroutines between samples.
+func (w *GoroutineLeakWatch) Growth() *Meter {
+	return w.growth
+}
+
+// Stop stops sampling.
+func (w *GoroutineLeakWatch) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+		w.growth.Stop()
+	})
+}
+
+func (w *GoroutineLeakWatch) Metrics() map[string]interface{} {
+	n := runtime.NumGoroutine()
+	w.mu.Lock()
+	if n > w.max {
+		w.max = n
+	}
+	max := w.max
+	w.mu.Unlock()
+	return map[string]interface{}{
+		"goroutines":        GaugeValue(n),
+		"goroutines/max":    GaugeValue(max),
+		"goroutines/growth": w.growth,
+	}
+}
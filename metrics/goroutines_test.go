@@ -0,0 +1,43 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGoroutineMetrics(t *testing.T) {
+	if v := GoroutineMetrics.Metrics()["goroutines"].(GaugeValue); v < 1 {
+		t.Fatalf("Expected at least 1 goroutine. Got %f", v)
+	}
+}
+
+func TestGoroutineLeakWatch(t *testing.T) {
+	w := NewGoroutineLeakWatch(time.Hour)
+	defer w.Stop()
+	before := w.Max()
+
+	stop := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() { <-stop }()
+	}
+	w.sample()
+	close(stop)
+
+	if w.Max() < before+10 {
+		t.Fatalf("Expected a high-water mark of at least %d. Got %d", before+10, w.Max())
+	}
+	if c := w.Growth().Count(); c < 10 {
+		t.Fatalf("Expected growth of at least 10. Got %d", c)
+	}
+	m := w.Metrics()
+	if v := m["goroutines/max"].(GaugeValue); int(v) != w.Max() {
+		t.Fatalf("Expected max %d. Got %f", w.Max(), v)
+	}
+	if m["goroutines/growth"] != w.Growth() {
+		t.Fatal("Expected the growth meter in the collection")
+	}
+}
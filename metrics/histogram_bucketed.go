@@ -15,6 +15,21 @@ var (
 	bucketCacheLock sync.Mutex
 )
 
+// HistogramBucket is the number of values recorded by a histogram that are
+// less than UpperBound and at least the upper bound of the previous bucket.
+type HistogramBucket struct {
+	UpperBound int64 // math.MaxInt64 for the last bucket
+	Count      uint64
+}
+
+// BucketHistogram is implemented by histograms that count values in fixed
+// buckets such as those returned by NewBucketedHistogram.
+type BucketHistogram interface {
+	Histogram
+	// Buckets returns the buckets that hold any values in order.
+	Buckets() []HistogramBucket
+}
+
 type bucketedHistogram struct {
 	bucketOffsets []int64
 	bucketCounts  []uint64
@@ -117,7 +132,6 @@ func (h *bucketedHistogram) Update(value int64) {
 	bucketIndex := h.bucketIndex(value)
 	h.bucketCounts[bucketIndex] += 1
 	h.count++
-	atomic.AddInt64(&h.sum, value)
 	h.sum += value
 	if value < h.min {
 		h.min = value
@@ -142,6 +156,23 @@ func (h *bucketedHistogram) Distribution() DistributionValue {
 	return v
 }
 
+func (h *bucketedHistogram) Buckets() []HistogramBucket {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var buckets []HistogramBucket
+	for i, count := range h.bucketCounts {
+		if count == 0 {
+			continue
+		}
+		upper := int64(math.MaxInt64)
+		if i < len(h.bucketOffsets) {
+			upper = h.bucketOffsets[i]
+		}
+		buckets = append(buckets, HistogramBucket{UpperBound: upper, Count: count})
+	}
+	return buckets
+}
+
 func (h *bucketedHistogram) Percentiles(percentiles []float64) []int64 {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -6,12 +6,20 @@ package metrics
 
 import (
 	"math"
+	"reflect"
 	"testing"
 )
 
 func TestBucketedHistogram(t *testing.T) {
 	h := NewDefaultBucketedHistogram().(*bucketedHistogram)
 
+	h.Update(3)
+	h.Update(4)
+	if d := h.Distribution(); d.Count != 2 || d.Sum != 7 {
+		t.Fatalf("Expected count 2 and sum 7. Got %+v", d)
+	}
+
+	h.Clear()
 	h.Update(0)
 	if h.bucketCounts[0] != 1 {
 		t.Fatalf("Expected 0 to fall into first bucket")
@@ -130,3 +138,18 @@ func BenchmarkBucketedHistogramPercentiles(b *testing.B) {
 func BenchmarkBucketedHistogramConcurrentUpdate(b *testing.B) {
 	benchmarkHistogramConcurrentUpdate(b, NewDefaultBucketedHistogram())
 }
+
+func TestBucketedHistogramBuckets(t *testing.T) {
+	h := NewDefaultBucketedHistogram().(BucketHistogram)
+	if b := h.Buckets(); len(b) != 0 {
+		t.Fatalf("Expected no buckets for an empty histogram. Got %+v", b)
+	}
+	h.Update(0)
+	h.Update(10)
+	h.Update(11)
+	h.Update(math.MaxInt64)
+	exp := []HistogramBucket{{1, 1}, {12, 2}, {math.MaxInt64, 1}}
+	if b := h.Buckets(); !reflect.DeepEqual(b, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, b)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	histogramDataVersion = 1
+
+	histogramDataSummary = 0
+	histogramDataSamples = 1
+	histogramDataBuckets = 2
+)
+
+var errHistogramDataTruncated = errors.New("metrics: histogram data truncated")
+
+// HistogramData is the state of a histogram in a form that can be sent to
+// another process and merged into a histogram there, so that percentiles
+// can be computed over the values recorded by many processes rather than
+// combined from percentiles computed by each. It holds the sample values of
+// a histogram from NewSampledHistogram or the bucket counts of one from
+// NewBucketedHistogram along with the count, sum, min and max.
+type HistogramData struct {
+	Count   uint64
+	Sum     int64
+	Min     int64
+	Max     int64
+	Samples []int64
+	Buckets []HistogramBucket
+}
+
+// ExportHistogram returns the state of h. It returns false if h isn't a
+// histogram whose state can be exported. The histogram isn't cleared.
+func ExportHistogram(h Histogram) (*HistogramData, bool) {
+	var d *HistogramData
+	switch m := h.(type) {
+	case *sampledHistogram:
+		d = histogramData(m.Distribution())
+		d.Samples = m.SampleValues()
+	case *bucketedHistogram:
+		d = histogramData(m.Distribution())
+		d.Buckets = m.Buckets()
+	default:
+		return nil, false
+	}
+	return d, true
+}
+
+func histogramData(v DistributionValue) *HistogramData {
+	return &HistogramData{Count: v.Count, Sum: int64(v.Sum), Min: int64(v.Min), Max: int64(v.Max)}
+}
+
+// Distribution returns the count, sum, min and max.
+func (d *HistogramData) Distribution() DistributionValue {
+	v := DistributionValue{Count: d.Count, Sum: float64(d.Sum)}
+	if d.Count > 0 {
+		v.Min = float64(d.Min)
+		v.Max = float64(d.Max)
+	}
+	return v
+}
+
+// MergeInto adds the data to h as if the values it summarizes had been
+// recorded by h. Sample values can only be merged into a histogram from
+// NewSampledHistogram and bucket counts into one from NewBucketedHistogram.
+// Bucket counts are added to the buckets of h that hold each bucket's
+// values, which are the same buckets if both histograms were created with
+// the same bucket offsets.
+func (d *HistogramData) MergeInto(h Histogram) error {
+	if d.Count == 0 {
+		return nil
+	}
+	switch m := h.(type) {
+	case *sampledHistogram:
+		if len(d.Buckets) == 0 {
+			m.restore(d)
+			return nil
+		}
+	case *bucketedHistogram:
+		if len(d.Samples) == 0 {
+			m.restore(d)
+			return nil
+		}
+	}
+	return fmt.Errorf("metrics: can't merge histogram data into %T", h)
+}
+
+func (h *sampledHistogram) restore(d *HistogramData) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.count == 0 || d.Min < h.min {
+		h.min = d.Min
+	}
+	if h.count == 0 || d.Max > h.max {
+		h.max = d.Max
+	}
+	h.count += d.Count
+	h.sum += d.Sum
+	for _, v := range d.Samples {
+		h.sample.Update(v)
+	}
+}
+
+func (h *bucketedHistogram) restore(d *HistogramData) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if d.Min < h.min {
+		h.min = d.Min
+	}
+	if d.Max > h.max {
+		h.max = d.Max
+	}
+	h.count += d.Count
+	h.sum += d.Sum
+	for _, b := range d.Buckets {
+		// Every value in a bucket is less than its upper bound.
+		h.bucketCounts[h.bucketIndex(b.UpperBound-1)] += b.Count
+	}
+}
+
+// MarshalBinary encodes the data compactly using varints. Samples are
+// sorted and encoded as the differences between them, as are the upper
+// bounds of buckets.
+func (d *HistogramData) MarshalBinary() ([]byte, error) {
+	kind := byte(histogramDataSummary)
+	switch {
+	case len(d.Samples) > 0 && len(d.Buckets) > 0:
+		return nil, errors.New("metrics: histogram data has both samples and buckets")
+	case len(d.Samples) > 0:
+		kind = histogramDataSamples
+	case len(d.Buckets) > 0:
+		kind = histogramDataBuckets
+	}
+	b := make([]byte, 0, 2+4*binary.MaxVarintLen64+len(d.Samples)*2+len(d.Buckets)*4)
+	b = append(b, histogramDataVersion, kind)
+	b = binary.AppendUvarint(b, d.Count)
+	b = binary.AppendVarint(b, d.Sum)
+	b = binary.AppendVarint(b, d.Min)
+	b = binary.AppendVarint(b, d.Max)
+	switch kind {
+	case histogramDataSamples:
+		samples := append([]int64(nil), d.Samples...)
+		sort.Sort(int64Slice(samples))
+		b = binary.AppendUvarint(b, uint64(len(samples)))
+		b = binary.AppendVarint(b, samples[0])
+		for i := 1; i < len(samples); i++ {
+			b = binary.AppendUvarint(b, uint64(samples[i]-samples[i-1]))
+		}
+	case histogramDataBuckets:
+		b = binary.AppendUvarint(b, uint64(len(d.Buckets)))
+		prev := int64(0)
+		for _, bucket := range d.Buckets {
+			b = binary.AppendVarint(b, bucket.UpperBound-prev)
+			b = binary.AppendUvarint(b, bucket.Count)
+			prev = bucket.UpperBound
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes data encoded by MarshalBinary.
+func (d *HistogramData) UnmarshalBinary(b []byte) error {
+	if len(b) < 2 {
+		return errHistogramDataTruncated
+	}
+	if b[0] != histogramDataVersion {
+		return fmt.Errorf("metrics: unsupported histogram data version %d", b[0])
+	}
+	kind := b[1]
+	r := &varintReader{b: b[2:]}
+	*d = HistogramData{
+		Count: r.uvarint(),
+		Sum:   r.varint(),
+		Min:   r.varint(),
+		Max:   r.varint(),
+	}
+	switch kind {
+	case histogramDataSummary:
+	case histogramDataSamples:
+		n := r.length()
+		d.Samples = make([]int64, n)
+		for i := range d.Samples {
+			if i == 0 {
+				d.Samples[i] = r.varint()
+			} else {
+				d.Samples[i] = d.Samples[i-1] + int64(r.uvarint())
+			}
+		}
+	case histogramDataBuckets:
+		n := r.length()
+		d.Buckets = make([]HistogramBucket, n)
+		prev := int64(0)
+		for i := range d.Buckets {
+			prev += r.varint()
+			d.Buckets[i] = HistogramBucket{UpperBound: prev, Count: r.uvarint()}
+		}
+	default:
+		return fmt.Errorf("metrics: unknown histogram data kind %d", kind)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.b) > 0 {
+		return errors.New("metrics: trailing bytes after histogram data")
+	}
+	return nil
+}
+
+// EncodeHistogram returns the state of h encoded by MarshalBinary.
+func EncodeHistogram(h Histogram) ([]byte, error) {
+	d, ok := ExportHistogram(h)
+	if !ok {
+		return nil, fmt.Errorf("metrics: can't export histogram %T", h)
+	}
+	return d.MarshalBinary()
+}
+
+// DecodeHistogram decodes data returned by EncodeHistogram.
+func DecodeHistogram(b []byte) (*HistogramData, error) {
+	d := &HistogramData{}
+	if err := d.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// varintReader reads varints, remembering the first error.
+type varintReader struct {
+	b   []byte
+	err error
+}
+
+func (r *varintReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errHistogramDataTruncated
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *varintReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errHistogramDataTruncated
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// length reads a count of items, each at least one byte long, guarding
+// against allocating more than the remaining data could hold.
+func (r *varintReader) length() int {
+	n := r.uvarint()
+	if n > uint64(len(r.b)) {
+		if r.err == nil {
+			r.err = errHistogramDataTruncated
+		}
+		return 0
+	}
+	return int(n)
+}
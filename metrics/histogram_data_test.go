@@ -0,0 +1,104 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistogramDataSampled(t *testing.T) {
+	h := NewSampledHistogram(NewUniformSample(100))
+	for _, v := range []int64{30, -5, 10, 20} {
+		h.Update(v)
+	}
+	b, err := EncodeHistogram(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := DecodeHistogram(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &HistogramData{Count: 4, Sum: 55, Min: -5, Max: 30, Samples: []int64{-5, 10, 20, 30}}
+	if !reflect.DeepEqual(d, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, d)
+	}
+
+	agg := NewSampledHistogram(NewUniformSample(100))
+	agg.Update(50)
+	if err := d.MergeInto(agg); err != nil {
+		t.Fatal(err)
+	}
+	if v := agg.Distribution(); v.Count != 5 || v.Sum != 105 || v.Min != -5 || v.Max != 50 {
+		t.Fatalf("Expected count 5, sum 105, min -5, max 50. Got %+v", v)
+	}
+	if p := agg.Percentiles([]float64{0.5}); p[0] != 20 {
+		t.Fatalf("Expected median 20. Got %d", p[0])
+	}
+	if err := d.MergeInto(NewDefaultBucketedHistogram()); err == nil {
+		t.Fatal("Expected an error merging samples into a bucketed histogram")
+	}
+}
+
+func TestHistogramDataBucketed(t *testing.T) {
+	h := NewDefaultBucketedHistogram()
+	for i := int64(1); i <= 1000; i++ {
+		h.Update(i)
+	}
+	h.Update(1 << 62)
+	b, err := EncodeHistogram(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := DecodeHistogram(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := h.(BucketHistogram).Buckets(); !reflect.DeepEqual(d.Buckets, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, d.Buckets)
+	}
+
+	agg := NewDefaultBucketedHistogram()
+	if err := d.MergeInto(agg); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.MergeInto(agg); err != nil {
+		t.Fatal(err)
+	}
+	ps := []float64{0.5, 0.99}
+	if exp, got := h.Percentiles(ps), agg.Percentiles(ps); !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Expected %+v. Got %+v", exp, got)
+	}
+	if v := agg.Distribution(); v.Count != 2002 || v.Max != 1<<62 {
+		t.Fatalf("Expected count 2002 and max 1<<62. Got %+v", v)
+	}
+}
+
+func TestHistogramDataErrors(t *testing.T) {
+	if _, err := EncodeHistogram(NewMunroPatersonHistogram(10, 10)); err == nil {
+		t.Fatal("Expected an error encoding an unsupported histogram")
+	}
+	d := &HistogramData{Count: 3, Sum: 6, Min: 1, Max: 3, Samples: []int64{1, 2, 3}}
+	b, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(b); i++ {
+		if _, err := DecodeHistogram(b[:i]); err == nil {
+			t.Fatalf("Expected an error decoding %d of %d bytes", i, len(b))
+		}
+	}
+	if _, err := DecodeHistogram(append(b, 0)); err == nil {
+		t.Fatal("Expected an error decoding trailing bytes")
+	}
+	empty, err := DecodeHistogram([]byte{histogramDataVersion, histogramDataSummary, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.MergeInto(NewMunroPatersonHistogram(10, 10)); err != nil {
+		t.Fatalf("Expected merging empty data to succeed. Got %+v", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"time"
+)
+
+// Instrument calls fn and records the call in the registry: name/calls,
+// name/errors and name/panics counters and a name/latency histogram in
+// microseconds. A panic in fn is recorded and then re-raised. The metrics
+// are created on the first call and reused after that.
+func Instrument(registry Registry, name string, fn func() error) error {
+	_, err := InstrumentT(registry, name, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// InstrumentT is Instrument for functions that return a value.
+func InstrumentT[T any](registry Registry, name string, fn func() (T, error)) (T, error) {
+	start := time.Now()
+	panicked := true
+	var err error
+	defer func() {
+		getOrAddCounter(registry, name+"/calls").Inc(1)
+		if panicked {
+			getOrAddCounter(registry, name+"/panics").Inc(1)
+		} else if err != nil {
+			getOrAddCounter(registry, name+"/errors").Inc(1)
+		}
+		if h, ok := registry.GetOrAdd(name+"/latency", func() interface{} { return NewDefaultBucketedHistogram() }).(Histogram); ok {
+			h.Update(int64(time.Since(start) / time.Microsecond))
+		}
+	}()
+	v, err := fn()
+	panicked = false
+	return v, err
+}
+
+// getOrAddCounter returns the counter registered as name, or a throwaway counter if
+// it can't be registered or the name is taken by another type.
+func getOrAddCounter(registry Registry, name string) *Counter {
+	if c, ok := registry.GetOrAdd(name, func() interface{} { return NewCounter() }).(*Counter); ok {
+		return c
+	}
+	return NewCounter()
+}
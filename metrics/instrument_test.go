@@ -0,0 +1,52 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	r := NewRegistry()
+	errFail := errors.New("fail")
+	if err := Instrument(r, "job", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Instrument(r, "job", func() error { return errFail }); err != errFail {
+		t.Fatalf("Expected %+v. Got %+v", errFail, err)
+	}
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("Expected panic boom. Got %+v", p)
+			}
+		}()
+		Instrument(r, "job", func() error { panic("boom") })
+	}()
+
+	for name, exp := range map[string]uint64{"job/calls": 3, "job/errors": 1, "job/panics": 1} {
+		if c := r.Counter(name).Count(); c != exp {
+			t.Fatalf("Expected %s of %d. Got %d", name, exp, c)
+		}
+	}
+	if c := r.Histogram("job/latency").Distribution().Count; c != 3 {
+		t.Fatalf("Expected 3 latencies. Got %d", c)
+	}
+}
+
+func TestInstrumentT(t *testing.T) {
+	r := NewRegistry()
+	v, err := InstrumentT(r, "load", func() (int, error) { return 42, nil })
+	if err != nil || v != 42 {
+		t.Fatalf("Expected 42. Got %d, %+v", v, err)
+	}
+	if c := r.Counter("load/calls").Count(); c != 1 {
+		t.Fatalf("Expected 1 call. Got %d", c)
+	}
+	if r.Counter("load/errors") != nil {
+		t.Fatal("Expected no errors counter")
+	}
+}
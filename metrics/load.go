@@ -0,0 +1,56 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// LoadMetrics is a collection of the system load averages, the same
+// exponentially weighted averages that EWMA computes but of the number of
+// runnable processes, along with the number of CPUs to compare them to:
+//
+//	load/1     gauge of the 1 minute load average
+//	load/5     gauge of the 5 minute load average
+//	load/15    gauge of the 15 minute load average
+//	cpu/count  gauge of the number of logical CPUs
+//
+// The load averages are read from /proc/loadavg and so are only available
+// on Linux.
+var LoadMetrics = &loadMetrics{}
+
+type loadMetrics struct{}
+
+func (l *loadMetrics) Metrics() map[string]interface{} {
+	m := map[string]interface{}{
+		"cpu/count": GaugeValue(runtime.NumCPU()),
+	}
+	if avg, ok := readLoadAverages(); ok {
+		m["load/1"] = GaugeValue(avg[0])
+		m["load/5"] = GaugeValue(avg[1])
+		m["load/15"] = GaugeValue(avg[2])
+	}
+	return m
+}
+
+// parseLoadavg parses the 1, 5 and 15 minute load averages at the start of
+// s, which is in the format of /proc/loadavg.
+func parseLoadavg(s string) ([3]float64, bool) {
+	var avg [3]float64
+	fields := strings.Fields(s)
+	if len(fields) < len(avg) {
+		return avg, false
+	}
+	for i := range avg {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return avg, false
+		}
+		avg[i] = v
+	}
+	return avg, true
+}
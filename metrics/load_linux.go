@@ -0,0 +1,15 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import "io/ioutil"
+
+func readLoadAverages() ([3]float64, bool) {
+	b, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return [3]float64{}, false
+	}
+	return parseLoadavg(string(b))
+}
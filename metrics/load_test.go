@@ -0,0 +1,35 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestParseLoadavg(t *testing.T) {
+	avg, ok := parseLoadavg("0.52 0.62 1.54 2/71 30597\n")
+	if exp := [3]float64{0.52, 0.62, 1.54}; !ok || avg != exp {
+		t.Fatalf("Expected %+v. Got %+v", exp, avg)
+	}
+	if _, ok := parseLoadavg("0.52 x 1.54"); ok {
+		t.Fatal("Expected an error for a bad load average")
+	}
+}
+
+func TestLoadMetrics(t *testing.T) {
+	m := LoadMetrics.Metrics()
+	if v := m["cpu/count"].(GaugeValue); int(v) != runtime.NumCPU() {
+		t.Fatalf("Expected %d CPUs. Got %f", runtime.NumCPU(), v)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	for _, name := range []string{"load/1", "load/5", "load/15"} {
+		if v, ok := m[name].(GaugeValue); !ok || v < 0 {
+			t.Fatalf("Expected %s. Got %+v", name, m[name])
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type measurementKey struct{}
+
+// Measurement times an operation that spans layers of a program. It's
+// started at the edge (such as when a request arrives) and carried in a
+// context so that code deeper in the stack can tag it and finish it without
+// being handed metrics. All methods may be called on a nil Measurement,
+// which does nothing, so code need not check that a context carries one.
+type Measurement struct {
+	registry Registry
+	name     string
+	start    time.Time
+
+	mu       sync.Mutex
+	tags     map[string]string
+	finished bool
+}
+
+// WithMeasurement starts a measurement called name and returns a copy of
+// ctx that carries it.
+func WithMeasurement(ctx context.Context, registry Registry, name string) (context.Context, *Measurement) {
+	m := &Measurement{
+		registry: registry,
+		name:     name,
+		start:    time.Now(),
+	}
+	return context.WithValue(ctx, measurementKey{}, m), m
+}
+
+// MeasurementFromContext returns the measurement carried by ctx or nil if
+// there is none.
+func MeasurementFromContext(ctx context.Context) *Measurement {
+	m, _ := ctx.Value(measurementKey{}).(*Measurement)
+	return m
+}
+
+// SetTag sets the tag key to value, replacing any earlier value.
+func (m *Measurement) SetTag(key, value string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if m.tags == nil {
+		m.tags = make(map[string]string)
+	}
+	m.tags[key] = value
+	m.mu.Unlock()
+}
+
+// Tags returns a copy of the measurement's tags.
+func (m *Measurement) Tags() map[string]string {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags := make(map[string]string, len(m.tags))
+	for k, v := range m.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// Finish records the time since the measurement started in microseconds
+// and returns it. The time is recorded in the histogram name and, for each
+// tag, in name/key/value so that latency can be broken down by each tag
+// without a histogram for every combination of them. Only the first call
+// records anything; later calls return zero.
+func (m *Measurement) Finish() time.Duration {
+	if m == nil {
+		return 0
+	}
+	elapsed := time.Since(m.start)
+	m.mu.Lock()
+	if m.finished {
+		m.mu.Unlock()
+		return 0
+	}
+	m.finished = true
+	names := make([]string, 0, len(m.tags)+1)
+	names = append(names, m.name)
+	for k, v := range m.tags {
+		names = append(names, m.name+"/"+k+"/"+v)
+	}
+	m.mu.Unlock()
+
+	us := int64(elapsed / time.Microsecond)
+	for _, name := range names {
+		if h, ok := m.registry.GetOrAdd(name, func() interface{} { return NewDefaultBucketedHistogram() }).(Histogram); ok {
+			h.Update(us)
+		}
+	}
+	return elapsed
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMeasurement(t *testing.T) {
+	r := NewRegistry()
+	ctx, m := WithMeasurement(context.Background(), r, "request")
+	if MeasurementFromContext(ctx) != m {
+		t.Fatal("Expected the measurement to be carried by the context")
+	}
+	MeasurementFromContext(ctx).SetTag("route", "users")
+	MeasurementFromContext(ctx).SetTag("db", "primary")
+	exp := map[string]string{"route": "users", "db": "primary"}
+	if tags := m.Tags(); !reflect.DeepEqual(tags, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, tags)
+	}
+	MeasurementFromContext(ctx).Finish()
+	if d := m.Finish(); d != 0 {
+		t.Fatalf("Expected a second finish to return 0. Got %s", d)
+	}
+
+	for _, name := range []string{"request", "request/route/users", "request/db/primary"} {
+		h := r.Histogram(name)
+		if h == nil {
+			t.Fatalf("Expected histogram %s", name)
+		}
+		if c := h.Distribution().Count; c != 1 {
+			t.Fatalf("Expected 1 value in %s. Got %d", name, c)
+		}
+	}
+}
+
+func TestMeasurementNil(t *testing.T) {
+	m := MeasurementFromContext(context.Background())
+	if m != nil {
+		t.Fatalf("Expected no measurement. Got %+v", m)
+	}
+	m.SetTag("a", "b")
+	if m.Tags() != nil || m.Finish() != 0 {
+		t.Fatal("Expected a nil measurement to do nothing")
+	}
+}
@@ -6,6 +6,8 @@ package metrics
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -13,18 +15,20 @@ import (
 // Meter is the combination of three EWMA metrics: 1 min, 5 min, and 15 min.
 type Meter struct {
 	count          uint64
+	startTime      int64 // UnixNano
 	m1Rate         *EWMA
 	m5Rate         *EWMA
 	m15Rate        *EWMA
-	startTime      time.Time
 	ticker         *time.Ticker
 	tickerStopChan chan bool
+	stopOnce       sync.Once
 }
 
 // NewMeter returns a new instance of Meter
 func NewMeter() *Meter {
 	interval := time.Second * 5
-	m := Meter{
+	m := &Meter{
+		startTime:      time.Now().UnixNano(),
 		m1Rate:         NewEWMA(interval, M1Alpha),
 		m5Rate:         NewEWMA(interval, M5Alpha),
 		m15Rate:        NewEWMA(interval, M15Alpha),
@@ -32,11 +36,12 @@ func NewMeter() *Meter {
 		tickerStopChan: make(chan bool),
 	}
 	go m.tickWatcher()
-	return &m
+	return m
 }
 
 func (m *Meter) String() string {
-	return fmt.Sprintf("{\"1\": %s, \"5\": %s, \"15\": %s}",
+	return fmt.Sprintf("{\"count\": %d, \"mean\": %s, \"1\": %s, \"5\": %s, \"15\": %s}",
+		m.Count(), strconv.FormatFloat(m.MeanRate(), 'g', -1, 64),
 		m.m1Rate.String(), m.m5Rate.String(), m.m15Rate.String())
 }
 
@@ -49,17 +54,14 @@ func (m *Meter) MarshalText() ([]byte, error) {
 }
 
 func (m *Meter) tickWatcher() {
-watcher:
 	for {
 		select {
 		case _ = <-m.tickerStopChan:
-			break watcher
+			return
 		case _ = <-m.ticker.C:
 			m.tick()
 		}
 	}
-	m.ticker = nil
-	m.tickerStopChan = nil
 }
 
 func (m *Meter) tick() {
@@ -68,12 +70,21 @@ func (m *Meter) tick() {
 	m.m15Rate.Tick()
 }
 
-// Stop the ticker
+// Stop the ticker. It's safe to call Stop more than once.
 func (m *Meter) Stop() {
-	if m.ticker != nil {
+	m.stopOnce.Do(func() {
 		m.ticker.Stop()
 		close(m.tickerStopChan)
-	}
+	})
+}
+
+// Reset the count and rates to zero
+func (m *Meter) Reset() {
+	atomic.StoreUint64(&m.count, 0)
+	atomic.StoreInt64(&m.startTime, time.Now().UnixNano())
+	m.m1Rate.Reset()
+	m.m5Rate.Reset()
+	m.m15Rate.Reset()
 }
 
 // Update increments the EWMA metrics.
@@ -91,7 +102,7 @@ func (m *Meter) Count() uint64 {
 
 // MeanRate returns the average rate
 func (m *Meter) MeanRate() float64 {
-	tdelta := time.Now().Sub(m.startTime)
+	tdelta := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&m.startTime))
 	count := m.Count()
 	return float64(count) / tdelta.Seconds()
 }
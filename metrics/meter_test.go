@@ -12,3 +12,12 @@ func TestMeter(t *testing.T) {
 	m.MeanRate()
 	m.Stop()
 }
+
+func BenchmarkMeterUpdate(b *testing.B) {
+	m := NewMeter()
+	defer m.Stop()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Update(1)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// MetricSnapshot is a copy of the value of a single metric taken at one
+// point in time. Unlike the metrics themselves, whose JSON encodings vary
+// by type, every snapshot encodes as a JSON object with a "type" field
+// naming one of the snapshot types below, so that individual metrics can be
+// written to logs and APIs and compared in tests.
+type MetricSnapshot interface {
+	json.Marshaler
+	// MetricType returns the "type" field: "counter", "gauge", "meter",
+	// "histogram", "distribution" or "info".
+	MetricType() string
+}
+
+// TakeSnapshot returns a snapshot of the metric's current value or nil if
+// the metric's type isn't recognized. Histograms aren't cleared.
+func TakeSnapshot(metric interface{}) MetricSnapshot {
+	switch m := metric.(type) {
+	case *EWMA:
+		return GaugeSnapshot{Value: m.Rate()}
+	case *EWMAGauge:
+		return GaugeSnapshot{Value: m.Mean()}
+	case *Meter:
+		return MeterSnapshot{
+			Count:    m.Count(),
+			Rate1:    m.OneMinuteRate(),
+			Rate5:    m.FiveMinuteRate(),
+			Rate15:   m.FifteenMinuteRate(),
+			RateMean: m.MeanRate(),
+		}
+	case Histogram:
+		s := HistogramSnapshot{Distribution: m.Distribution()}
+		if s.Distribution.Count > 0 {
+			s.Percentiles = make(map[string]int64, len(DefaultPercentiles))
+			for i, p := range m.Percentiles(DefaultPercentiles) {
+				s.Percentiles[DefaultPercentileNames[i]] = p
+			}
+		}
+		return s
+	case NamedDistribution:
+		return DistributionSnapshot{Distribution: m.Value}
+	case DistributionMetric:
+		// Before CounterMetric since a *Distribution also has a Count method
+		return DistributionSnapshot{Distribution: m.Value()}
+	case CounterMetric:
+		return CounterSnapshot{Count: m.Count()}
+	case Info:
+		// Before GaugeMetric since an Info is a gauge of 1
+		labels := make(map[string]string, len(m))
+		for k, v := range m {
+			labels[k] = v
+		}
+		return InfoSnapshot{Labels: labels}
+	case GaugeMetric:
+		return GaugeSnapshot{Value: m.Value()}
+	}
+	return nil
+}
+
+// CounterSnapshot encodes as {"type":"counter","count":N}.
+type CounterSnapshot struct {
+	Count uint64
+}
+
+func (s CounterSnapshot) MetricType() string {
+	return "counter"
+}
+
+func (s CounterSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Count uint64 `json:"count"`
+	}{s.MetricType(), s.Count})
+}
+
+// GaugeSnapshot encodes as {"type":"gauge","value":V}. A value that is NaN
+// or infinite is encoded as null.
+type GaugeSnapshot struct {
+	Value float64
+}
+
+func (s GaugeSnapshot) MetricType() string {
+	return "gauge"
+}
+
+func (s GaugeSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string    `json:"type"`
+		Value jsonFloat `json:"value"`
+	}{s.MetricType(), jsonFloat(s.Value)})
+}
+
+// MeterSnapshot encodes as {"type":"meter","count":N,"rates":{"1m":R,
+// "5m":R,"15m":R,"mean":R}} with rates in events per second.
+type MeterSnapshot struct {
+	Count    uint64
+	Rate1    float64
+	Rate5    float64
+	Rate15   float64
+	RateMean float64
+}
+
+func (s MeterSnapshot) MetricType() string {
+	return "meter"
+}
+
+func (s MeterSnapshot) MarshalJSON() ([]byte, error) {
+	type rates struct {
+		Rate1    jsonFloat `json:"1m"`
+		Rate5    jsonFloat `json:"5m"`
+		Rate15   jsonFloat `json:"15m"`
+		RateMean jsonFloat `json:"mean"`
+	}
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Count uint64 `json:"count"`
+		Rates rates  `json:"rates"`
+	}{s.MetricType(), s.Count, rates{jsonFloat(s.Rate1), jsonFloat(s.Rate5), jsonFloat(s.Rate15), jsonFloat(s.RateMean)}})
+}
+
+// HistogramSnapshot encodes as {"type":"histogram","count":N,"sum":S,
+// "min":M,"max":M,"mean":M,"percentiles":{"p50":V,...}} with the
+// percentiles named by DefaultPercentileNames. The percentiles are left
+// out of a snapshot of an empty histogram.
+type HistogramSnapshot struct {
+	Distribution DistributionValue
+	Percentiles  map[string]int64
+}
+
+func (s HistogramSnapshot) MetricType() string {
+	return "histogram"
+}
+
+func (s HistogramSnapshot) MarshalJSON() ([]byte, error) {
+	d := s.Distribution
+	return json.Marshal(struct {
+		Type        string           `json:"type"`
+		Count       uint64           `json:"count"`
+		Sum         jsonFloat        `json:"sum"`
+		Min         jsonFloat        `json:"min"`
+		Max         jsonFloat        `json:"max"`
+		Mean        jsonFloat        `json:"mean"`
+		Percentiles map[string]int64 `json:"percentiles,omitempty"`
+	}{s.MetricType(), d.Count, jsonFloat(d.Sum), jsonFloat(d.Min), jsonFloat(d.Max), jsonFloat(d.Mean()), s.Percentiles})
+}
+
+// DistributionSnapshot encodes as {"type":"distribution","count":N,"sum":S,
+// "min":M,"max":M,"mean":M,"stddev":D}.
+type DistributionSnapshot struct {
+	Distribution DistributionValue
+}
+
+func (s DistributionSnapshot) MetricType() string {
+	return "distribution"
+}
+
+func (s DistributionSnapshot) MarshalJSON() ([]byte, error) {
+	d := s.Distribution
+	return json.Marshal(struct {
+		Type   string    `json:"type"`
+		Count  uint64    `json:"count"`
+		Sum    jsonFloat `json:"sum"`
+		Min    jsonFloat `json:"min"`
+		Max    jsonFloat `json:"max"`
+		Mean   jsonFloat `json:"mean"`
+		Stddev jsonFloat `json:"stddev"`
+	}{s.MetricType(), d.Count, jsonFloat(d.Sum), jsonFloat(d.Min), jsonFloat(d.Max), jsonFloat(d.Mean()), jsonFloat(math.Sqrt(d.Variance))})
+}
+
+// InfoSnapshot encodes as {"type":"info","labels":{...}}.
+type InfoSnapshot struct {
+	Labels map[string]string
+}
+
+func (s InfoSnapshot) MetricType() string {
+	return "info"
+}
+
+func (s InfoSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	}{s.MetricType(), s.Labels})
+}
+
+// jsonFloat encodes NaN and infinities, which JSON can't represent, as null.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	c := NewCounter()
+	c.Inc(3)
+	g := NewIntegerGauge()
+	g.Set(-2)
+	h := NewUnbiasedHistogram()
+	h.Update(10)
+	h.Update(10)
+	d := NewDistribution()
+	d.Update(2)
+	d.Update(4)
+	d.Update(6)
+
+	tests := []struct {
+		metric interface{}
+		exp    string
+	}{
+		{c, `{"type":"counter","count":3}`},
+		{CounterFunc(func() uint64 { return 7 }), `{"type":"counter","count":7}`},
+		{g, `{"type":"gauge","value":-2}`},
+		{GaugeValue(math.NaN()), `{"type":"gauge","value":null}`},
+		{h, `{"type":"histogram","count":2,"sum":20,"min":10,"max":10,"mean":10,"percentiles":{"p50":10,"p75":10,"p90":10,"p99":10,"p999":10}}`},
+		{NewUnbiasedHistogram(), `{"type":"histogram","count":0,"sum":0,"min":0,"max":0,"mean":0}`},
+		{d, `{"type":"distribution","count":3,"sum":12,"min":2,"max":6,"mean":4,"stddev":2}`},
+		{Info{"version": "v1"}, `{"type":"info","labels":{"version":"v1"}}`},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(TakeSnapshot(test.metric))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.exp {
+			t.Errorf("Expected %s. Got %s", test.exp, b)
+		}
+	}
+	if h.Distribution().Count != 2 {
+		t.Fatal("Expected the histogram not to be cleared")
+	}
+	if s := TakeSnapshot("string"); s != nil {
+		t.Fatalf("Expected no snapshot of an unknown type. Got %+v", s)
+	}
+}
+
+func TestMeterSnapshot(t *testing.T) {
+	m := NewMeter()
+	defer m.Stop()
+	m.Update(5)
+	s, ok := TakeSnapshot(m).(MeterSnapshot)
+	if !ok || s.Count != 5 {
+		t.Fatalf("Expected a meter snapshot with a count of 5. Got %+v", s)
+	}
+	var v struct {
+		Type  string
+		Count uint64
+		Rates map[string]float64
+	}
+	b, _ := json.Marshal(s)
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != "meter" || v.Count != 5 || len(v.Rates) != 4 {
+		t.Fatalf("Expected a meter with 4 rates. Got %s", b)
+	}
+}
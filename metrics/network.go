@@ -0,0 +1,117 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NetworkMetrics is a collection of the host's TCP connections and the
+// process's file descriptor usage for capacity monitoring:
+//
+//	tcp/<state>             gauge of TCP connections in each state such as
+//	                        tcp/established and tcp/time_wait
+//	tcp/listen_overflows    counter of connections dropped because a
+//	                        listen queue was full
+//	tcp/listen_drops        counter of connections dropped by listeners
+//	                        for any reason
+//	fds/usage_percent       gauge of open file descriptors as a percentage
+//	                        of the limit
+//
+// The TCP stats are read from /proc/net and so are only available on Linux,
+// where they cover the process's network namespace rather than only its own
+// connections.
+var NetworkMetrics = &networkMetrics{}
+
+type networkMetrics struct{}
+
+// tcpStates names the states in /proc/net/tcp by their hex number.
+var tcpStates = map[string]string{
+	"01": "established",
+	"02": "syn_sent",
+	"03": "syn_recv",
+	"04": "fin_wait1",
+	"05": "fin_wait2",
+	"06": "time_wait",
+	"07": "close",
+	"08": "close_wait",
+	"09": "last_ack",
+	"0A": "listen",
+	"0B": "closing",
+}
+
+func (n *networkMetrics) Metrics() map[string]interface{} {
+	m := make(map[string]interface{})
+	if states, ok := readTCPStates(); ok {
+		for _, state := range tcpStates {
+			m["tcp/"+state] = GaugeValue(states[state])
+		}
+	}
+	if ext, ok := readTCPExt(); ok {
+		if v, ok := ext["ListenOverflows"]; ok {
+			m["tcp/listen_overflows"] = CounterValue(v)
+		}
+		if v, ok := ext["ListenDrops"]; ok {
+			m["tcp/listen_drops"] = CounterValue(v)
+		}
+	}
+	s := processStats{openFDs: -1, maxFDs: -1}
+	readProcessStats(&s)
+	if s.openFDs >= 0 && s.maxFDs > 0 {
+		m["fds/usage_percent"] = GaugeValue(100 * float64(s.openFDs) / float64(s.maxFDs))
+	}
+	return m
+}
+
+// parseTCPStates adds the number of connections in each state listed in r,
+// which is in the format of /proc/net/tcp, to counts.
+func parseTCPStates(r io.Reader, counts map[string]int) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		if state, ok := tcpStates[strings.ToUpper(fields[3])]; ok {
+			counts[state]++
+		}
+	}
+	return scanner.Err()
+}
+
+// parseNetstat returns the values in the section named prefix (such as
+// "TcpExt") of r, which is in the format of /proc/net/netstat where each
+// section is a line of names followed by a line of values.
+func parseNetstat(r io.Reader, prefix string) (map[string]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	prefix += ":"
+	var names []string
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != prefix {
+			continue
+		}
+		if names == nil {
+			names = fields[1:]
+			continue
+		}
+		values := make(map[string]uint64, len(names))
+		for i, name := range names {
+			if i+1 >= len(fields) {
+				break
+			}
+			if v, err := strconv.ParseUint(fields[i+1], 10, 64); err == nil {
+				values[name] = v
+			}
+		}
+		return values, nil
+	}
+	return nil, scanner.Err()
+}
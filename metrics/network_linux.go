@@ -0,0 +1,35 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"os"
+)
+
+func readTCPStates() (map[string]int, bool) {
+	counts := make(map[string]int)
+	ok := false
+	for _, name := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		if err := parseTCPStates(f, counts); err == nil {
+			ok = true
+		}
+		f.Close()
+	}
+	return counts, ok
+}
+
+func readTCPExt() (map[string]uint64, bool) {
+	f, err := os.Open("/proc/net/netstat")
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	values, err := parseNetstat(f, "TcpExt")
+	return values, err == nil && values != nil
+}
@@ -0,0 +1,15 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package metrics
+
+func readTCPStates() (map[string]int, bool) {
+	return nil, false
+}
+
+func readTCPExt() (map[string]uint64, bool) {
+	return nil, false
+}
@@ -0,0 +1,58 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseTCPStates(t *testing.T) {
+	in := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:07E8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 662 1
+   1: 0100007F:BC8F 0100007F:07E8 01 00000000:00000000 00:00000000 00000000     0        0 1068 1
+   2: 0100007F:BC90 0100007F:07E8 01 00000000:00000000 00:00000000 00000000     0        0 1069 1
+   3: 0100007F:BC91 0100007F:07E8 06 00000000:00000000 00:00000000 00000000     0        0 0 1
+`
+	counts := make(map[string]int)
+	if err := parseTCPStates(strings.NewReader(in), counts); err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]int{"listen": 1, "established": 2, "time_wait": 1}
+	if !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, counts)
+	}
+}
+
+func TestParseNetstat(t *testing.T) {
+	in := `TcpExt: SyncookiesSent ListenOverflows ListenDrops
+TcpExt: 1 5 7
+IpExt: InNoRoutes
+IpExt: 3
+`
+	values, err := parseNetstat(strings.NewReader(in), "TcpExt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]uint64{"SyncookiesSent": 1, "ListenOverflows": 5, "ListenDrops": 7}
+	if !reflect.DeepEqual(values, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, values)
+	}
+}
+
+func TestNetworkMetrics(t *testing.T) {
+	m := NetworkMetrics.Metrics()
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if v, ok := m["fds/usage_percent"].(GaugeValue); !ok || v <= 0 || v > 100 {
+		t.Fatalf("Expected fd usage. Got %+v", m["fds/usage_percent"])
+	}
+	if _, ok := m["tcp/established"].(GaugeValue); !ok {
+		t.Fatalf("Expected established connections. Got %+v", m)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PercentileConfig is an ordered list of percentiles, as fractions between
+// 0 and 1, and the names they're reported under. Names[i] is the name of
+// Percentiles[i].
+type PercentileConfig struct {
+	Percentiles []float64
+	Names       []string
+}
+
+// DefaultPercentileConfig holds DefaultPercentiles and DefaultPercentileNames.
+var DefaultPercentileConfig = PercentileConfig{
+	Percentiles: DefaultPercentiles,
+	Names:       DefaultPercentileNames,
+}
+
+// NewPercentileConfig returns a config for the percentiles in order, named
+// by PercentileName.
+func NewPercentileConfig(percentiles ...float64) (PercentileConfig, error) {
+	c := PercentileConfig{
+		Percentiles: percentiles,
+		Names:       make([]string, len(percentiles)),
+	}
+	for i, p := range percentiles {
+		c.Names[i] = PercentileName(p)
+	}
+	return c, c.Validate()
+}
+
+// ParsePercentileConfig parses a comma separated list of percentiles such
+// as "0.5,0.9,0.99".
+func ParsePercentileConfig(s string) (PercentileConfig, error) {
+	var percentiles []float64
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return PercentileConfig{}, fmt.Errorf("metrics: invalid percentile %q: %w", f, err)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return NewPercentileConfig(percentiles...)
+}
+
+// Validate checks that every percentile is between 0 and 1 and has a name
+// and that no two share a name.
+func (c PercentileConfig) Validate() error {
+	if len(c.Percentiles) != len(c.Names) {
+		return fmt.Errorf("metrics: %d percentiles but %d names", len(c.Percentiles), len(c.Names))
+	}
+	seen := make(map[string]bool, len(c.Names))
+	for i, p := range c.Percentiles {
+		name := c.Names[i]
+		switch {
+		case !(p >= 0 && p <= 1):
+			return fmt.Errorf("metrics: percentile %g is not between 0 and 1", p)
+		case name == "":
+			return fmt.Errorf("metrics: percentile %g has no name", p)
+		case seen[name]:
+			return fmt.Errorf("metrics: duplicate percentile name %s", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// PercentileName returns the conventional name of a percentile, for example
+// p50 for 0.5 and p999 for 0.999.
+func PercentileName(p float64) string {
+	// Round off floating point error from scaling, e.g. 0.999*100
+	s := strconv.FormatFloat(math.Round(p*1e8)/1e6, 'f', -1, 64)
+	return "p" + strings.Replace(s, ".", "", 1)
+}
@@ -0,0 +1,45 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPercentileName(t *testing.T) {
+	for p, exp := range map[float64]string{
+		0.5:    "p50",
+		0.75:   "p75",
+		0.9:    "p90",
+		0.99:   "p99",
+		0.999:  "p999",
+		0.9999: "p9999",
+		0.05:   "p5",
+		1:      "p100",
+	} {
+		if name := PercentileName(p); name != exp {
+			t.Fatalf("Expected %s for %g. Got %s", exp, p, name)
+		}
+	}
+}
+
+func TestPercentileConfig(t *testing.T) {
+	c, err := ParsePercentileConfig("0.5, 0.75,0.9,0.99,0.999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, DefaultPercentileConfig) {
+		t.Fatalf("Expected %+v. Got %+v", DefaultPercentileConfig, c)
+	}
+	for _, s := range []string{"0.5,x", "1.5", "0.5,0.5"} {
+		if _, err := ParsePercentileConfig(s); err == nil {
+			t.Fatalf("Expected an error for %q", s)
+		}
+	}
+	if err := (PercentileConfig{Percentiles: []float64{0.5}}).Validate(); err == nil {
+		t.Fatal("Expected an error for missing names")
+	}
+}
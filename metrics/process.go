@@ -0,0 +1,80 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import "time"
+
+// ProcessMetrics is a collection of operating system stats for the current
+// process, read each time it's reported:
+//
+//	cpu/user_ms    counter of CPU time spent in user mode
+//	cpu/system_ms  counter of CPU time spent in the kernel
+//	memory/rss     gauge of resident memory in bytes
+//	memory/vsz     gauge of virtual memory in bytes
+//	fds/open       gauge of open file descriptors
+//	fds/max        gauge of the limit on open file descriptors
+//	threads        gauge of operating system threads
+//	start_time     gauge of the time the process started in Unix seconds
+//
+// All are read from /proc on Linux. Other platforms include the stats they
+// make available, at least the CPU times and file descriptor limit on other
+// Unix systems, and the start time is otherwise when the package was
+// initialized.
+var ProcessMetrics = &processMetrics{}
+
+var initTime = time.Now()
+
+type processMetrics struct{}
+
+// processStats holds the stats that are known. Unknown values are negative.
+type processStats struct {
+	cpuUser   time.Duration
+	cpuSystem time.Duration
+	rss       int64
+	vsz       int64
+	openFDs   int64
+	maxFDs    int64
+	threads   int64
+	startTime time.Time
+}
+
+func (p *processMetrics) Metrics() map[string]interface{} {
+	s := processStats{
+		cpuUser:   -1,
+		cpuSystem: -1,
+		rss:       -1,
+		vsz:       -1,
+		openFDs:   -1,
+		maxFDs:    -1,
+		threads:   -1,
+		startTime: initTime,
+	}
+	readProcessStats(&s)
+	m := map[string]interface{}{
+		"start_time": GaugeValue(float64(s.startTime.UnixNano()) / 1e9),
+	}
+	if s.cpuUser >= 0 {
+		m["cpu/user_ms"] = CounterValue(s.cpuUser / time.Millisecond)
+	}
+	if s.cpuSystem >= 0 {
+		m["cpu/system_ms"] = CounterValue(s.cpuSystem / time.Millisecond)
+	}
+	gauges := []struct {
+		name  string
+		value int64
+	}{
+		{"memory/rss", s.rss},
+		{"memory/vsz", s.vsz},
+		{"fds/open", s.openFDs},
+		{"fds/max", s.maxFDs},
+		{"threads", s.threads},
+	}
+	for _, g := range gauges {
+		if g.value >= 0 {
+			m[g.name] = GaugeValue(g.value)
+		}
+	}
+	return m
+}
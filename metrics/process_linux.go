@@ -0,0 +1,85 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks is the unit of times in /proc (USER_HZ), which is 100 on all
+// common architectures.
+const clockTicks = 100
+
+func readProcessStats(s *processStats) {
+	if b, err := ioutil.ReadFile("/proc/self/stat"); err == nil {
+		// The command name is in parentheses and may hold spaces.
+		if i := bytes.LastIndexByte(b, ')'); i >= 0 {
+			fields := strings.Fields(string(b[i+1:]))
+			// fields[0] is the state, the third field of the file.
+			field := func(n int) int64 {
+				if n-3 >= len(fields) {
+					return -1
+				}
+				v, err := strconv.ParseInt(fields[n-3], 10, 64)
+				if err != nil {
+					return -1
+				}
+				return v
+			}
+			if v := field(14); v >= 0 {
+				s.cpuUser = ticks(v)
+			}
+			if v := field(15); v >= 0 {
+				s.cpuSystem = ticks(v)
+			}
+			s.threads = field(20)
+			if v := field(22); v >= 0 {
+				if boot, ok := bootTime(); ok {
+					s.startTime = boot.Add(ticks(v))
+				}
+			}
+			s.vsz = field(23)
+			if v := field(24); v >= 0 {
+				s.rss = v * int64(os.Getpagesize())
+			}
+		}
+	}
+	if fds, err := ioutil.ReadDir("/proc/self/fd"); err == nil && len(fds) > 0 {
+		// Leave out the descriptor ReadDir opened to list the directory
+		s.openFDs = int64(len(fds) - 1)
+	}
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err == nil {
+		s.maxFDs = int64(limit.Cur)
+	}
+}
+
+func ticks(n int64) time.Duration {
+	return time.Duration(n) * time.Second / clockTicks
+}
+
+// bootTime returns the time the system booted from /proc/stat.
+func bootTime() (time.Time, bool) {
+	b, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[len("btime "):]), 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return time.Unix(sec, 0), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,10 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package metrics
+
+// readProcessStats leaves all stats unknown except the start time.
+func readProcessStats(s *processStats) {}
@@ -0,0 +1,35 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestProcessMetrics(t *testing.T) {
+	m := ProcessMetrics.Metrics()
+	start, ok := m["start_time"].(GaugeValue)
+	if !ok || start.Value() <= 0 || start.Value() > float64(time.Now().Unix()+1) {
+		t.Fatalf("Expected a start time. Got %+v", m["start_time"])
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	for _, name := range []string{"cpu/user_ms", "cpu/system_ms"} {
+		if _, ok := m[name].(CounterValue); !ok {
+			t.Errorf("Expected counter %s. Got %+v", name, m[name])
+		}
+	}
+	for _, name := range []string{"memory/rss", "memory/vsz", "fds/open", "fds/max", "threads"} {
+		if v, ok := m[name].(GaugeValue); !ok || v <= 0 {
+			t.Errorf("Expected gauge %s. Got %+v", name, m[name])
+		}
+	}
+	if start.Value() < float64(initTime.Add(-time.Hour).Unix()) || start.Value() > float64(initTime.Unix()+1) {
+		t.Errorf("Expected the start time to be close to %s. Got %f", initTime, start.Value())
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+//go:build unix && !linux
+
+package metrics
+
+import (
+	"syscall"
+	"time"
+)
+
+func readProcessStats(s *processStats) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err == nil {
+		s.cpuUser = time.Duration(usage.Utime.Nano())
+		s.cpuSystem = time.Duration(usage.Stime.Nano())
+	}
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err == nil {
+		s.maxFDs = int64(limit.Cur)
+	}
+}
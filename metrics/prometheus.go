@@ -0,0 +1,243 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// PrometheusHandler returns an http.Handler that renders the registry in the
+// Prometheus text exposition format so that it can be scraped directly.
+// Scrapers that accept application/openmetrics-text are sent the OpenMetrics
+// format instead.
+func PrometheusHandler(reg Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		b := &bytes.Buffer{}
+		if err := writePrometheus(b, reg, openMetrics); err != nil {
+			log.Printf("metrics: failed to encode registry: %s", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if openMetrics {
+			w.Header().Set("Content-Type", openMetricsContentType)
+		} else {
+			w.Header().Set("Content-Type", prometheusContentType)
+		}
+		w.Write(b.Bytes())
+	})
+}
+
+// WritePrometheus writes the metrics in reg to w in the Prometheus text
+// exposition format. Counters are written as counters, histograms and
+// distributions as summaries, and meters and everything else as gauges.
+// Names are sanitized to the characters Prometheus allows and metrics whose
+// sanitized name collides with an earlier one are skipped. Reading the
+// metrics does not reset them.
+func WritePrometheus(w io.Writer, reg Registry) error {
+	return writePrometheus(w, reg, false)
+}
+
+// WriteOpenMetrics writes the metrics in reg to w in the OpenMetrics text
+// format. It's the same as WritePrometheus except that counter samples have
+// a _total suffix, are followed by the time they were created and carry
+// their latest exemplar, and the output ends with "# EOF".
+func WriteOpenMetrics(w io.Writer, reg Registry) error {
+	return writePrometheus(w, reg, true)
+}
+
+func writePrometheus(w io.Writer, reg Registry, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	seen := make(map[string]bool)
+	err := reg.DoSorted(func(name string, metric interface{}) error {
+		name = PrometheusName(name)
+		if openMetrics && isPrometheusCounter(metric) {
+			// The _total suffix belongs to the sample, not the family.
+			name = strings.TrimSuffix(name, "_total")
+		}
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		switch m := metric.(type) {
+		case *EWMA:
+			writePrometheusValue(bw, name, "gauge", m.Rate())
+		case *EWMAGauge:
+			writePrometheusValue(bw, name, "gauge", m.Mean())
+		case *Meter:
+			writePrometheusType(bw, name, "gauge")
+			writePrometheusSample(bw, name, `{window="1m"}`, m.OneMinuteRate())
+			writePrometheusSample(bw, name, `{window="5m"}`, m.FiveMinuteRate())
+			writePrometheusSample(bw, name, `{window="15m"}`, m.FifteenMinuteRate())
+			writePrometheusSample(bw, name, `{window="mean"}`, m.MeanRate())
+		case Histogram:
+			dist := m.Distribution()
+			writePrometheusType(bw, name, "summary")
+			if dist.Count > 0 {
+				for i, p := range m.Percentiles(DefaultPercentiles) {
+					labels := `{quantile="` + strconv.FormatFloat(DefaultPercentiles[i], 'g', -1, 64) + `"}`
+					writePrometheusSample(bw, name, labels, float64(p))
+				}
+			}
+			writePrometheusSample(bw, name+"_sum", "", dist.Sum)
+			writePrometheusSample(bw, name+"_count", "", float64(dist.Count))
+		case NamedDistribution:
+			writePrometheusDistribution(bw, name, m.Value)
+		case DistributionMetric:
+			// Before CounterMetric since a *Distribution also has a Count method
+			writePrometheusDistribution(bw, name, m.Value())
+		case *Counter:
+			if openMetrics {
+				writeOpenMetricsCounter(bw, name, m)
+			} else {
+				writePrometheusValue(bw, name, "counter", float64(m.Count()))
+			}
+		case CounterMetric:
+			if openMetrics {
+				writePrometheusType(bw, name, "counter")
+				writePrometheusSample(bw, name+"_total", "", float64(m.Count()))
+			} else {
+				writePrometheusValue(bw, name, "counter", float64(m.Count()))
+			}
+		case Info:
+			// Before GaugeMetric since an Info is a gauge of 1
+			if openMetrics {
+				writePrometheusType(bw, strings.TrimSuffix(name, "_info"), "info")
+				name = strings.TrimSuffix(name, "_info") + "_info"
+			} else {
+				writePrometheusType(bw, name, "gauge")
+			}
+			bw.WriteString(name)
+			writePrometheusLabels(bw, m)
+			bw.WriteString(" 1\n")
+		case GaugeMetric:
+			writePrometheusValue(bw, name, "gauge", m.Value())
+		default:
+			log.Printf("metrics: unrecognized metric type for %s: %T %+v", name, m, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// PrometheusName converts a metric name to a valid Prometheus metric name
+// by replacing any disallowed characters (such as '/' and '.') with '_'.
+func PrometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || (i > 0 && c >= '0' && c <= '9')) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func writePrometheusDistribution(w *bufio.Writer, name string, dist DistributionValue) {
+	writePrometheusType(w, name, "summary")
+	writePrometheusSample(w, name+"_sum", "", dist.Sum)
+	writePrometheusSample(w, name+"_count", "", float64(dist.Count))
+}
+
+func writePrometheusValue(w *bufio.Writer, name, typ string, value float64) {
+	writePrometheusType(w, name, typ)
+	writePrometheusSample(w, name, "", value)
+}
+
+func writePrometheusType(w *bufio.Writer, name, typ string) {
+	w.WriteString("# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(typ)
+	w.WriteByte('\n')
+}
+
+func writePrometheusSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	w.WriteString(labels)
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.WriteByte('\n')
+}
+
+// isPrometheusCounter returns true if the metric is written as a counter.
+func isPrometheusCounter(metric interface{}) bool {
+	switch metric.(type) {
+	case *Meter, Histogram, DistributionMetric:
+		return false
+	case CounterMetric:
+		return true
+	}
+	return false
+}
+
+func writeOpenMetricsCounter(w *bufio.Writer, name string, c *Counter) {
+	writePrometheusType(w, name, "counter")
+	w.WriteString(name)
+	w.WriteString("_total ")
+	w.WriteString(strconv.FormatUint(c.Count(), 10))
+	if e := c.Exemplar(); e != nil {
+		w.WriteString(" # ")
+		writePrometheusLabels(w, e.Labels)
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(e.Value, 'g', -1, 64))
+		w.WriteByte(' ')
+		w.WriteString(openMetricsTimestamp(e.Timestamp))
+	}
+	w.WriteByte('\n')
+	if created := c.Created(); !created.IsZero() {
+		w.WriteString(name)
+		w.WriteString("_created ")
+		w.WriteString(openMetricsTimestamp(created))
+		w.WriteByte('\n')
+	}
+}
+
+// openMetricsTimestamp formats t as Unix seconds.
+func openMetricsTimestamp(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
+
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writePrometheusLabels(w *bufio.Writer, labels map[string]string) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(PrometheusName(name))
+		w.WriteString(`="`)
+		w.WriteString(prometheusLabelReplacer.Replace(labels[name]))
+		w.WriteByte('"')
+	}
+	w.WriteByte('}')
+}
@@ -0,0 +1,94 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter()
+	c.Inc(3)
+	r.Add("http/requests", c)
+	g := NewIntegerGauge()
+	g.Set(7)
+	r.Add("pool.size", g)
+	h := NewUnbiasedHistogram()
+	h.Update(10)
+	r.Add("latency", h)
+	r.Add("3xx", NewCounter())
+
+	rec := httptest.NewRecorder()
+	PrometheusHandler(r).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != prometheusContentType {
+		t.Fatalf("Expected content type %s. Got %s", prometheusContentType, ct)
+	}
+	exp := `# TYPE _xx counter
+_xx 0
+# TYPE http_requests counter
+http_requests 3
+# TYPE latency summary
+latency{quantile="0.5"} 10
+latency{quantile="0.75"} 10
+latency{quantile="0.9"} 10
+latency{quantile="0.99"} 10
+latency{quantile="0.999"} 10
+latency_sum 10
+latency_count 1
+# TYPE pool_size gauge
+pool_size 7
+`
+	if body := rec.Body.String(); body != exp {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", exp, body)
+	}
+	if h.Distribution().Count != 1 {
+		t.Fatal("Expected histogram not to be cleared")
+	}
+}
+
+func TestOpenMetrics(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter()
+	c.Inc(2)
+	c.IncWithExemplar(1, map[string]string{"trace_id": "a\"b"})
+	r.Add("requests_total", c)
+	r.Add("hits", CounterFunc(func() uint64 { return 4 }))
+	g := NewIntegerGauge()
+	g.Set(7)
+	r.Add("pool.size", g)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;q=0.5")
+	rec := httptest.NewRecorder()
+	PrometheusHandler(r).ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != openMetricsContentType {
+		t.Fatalf("Expected content type %s. Got %s", openMetricsContentType, ct)
+	}
+	exp := regexp.MustCompile(`^# TYPE hits counter
+hits_total 4
+# TYPE pool_size gauge
+pool_size 7
+# TYPE requests counter
+requests_total 3 # \{trace_id="a\\"b"\} 1 \d+(\.\d+)?
+requests_created \d+(\.\d+)?
+# EOF
+$`)
+	if body := rec.Body.String(); !exp.MatchString(body) {
+		t.Fatalf("Expected to match:\n%s\nGot:\n%s", exp, body)
+	}
+}
+
+func BenchmarkWritePrometheus(b *testing.B) {
+	r := benchmarkRegistry(100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		WritePrometheus(io.Discard, r)
+	}
+}
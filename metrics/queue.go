@@ -0,0 +1,95 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// InstrumentChannel adds gauges of the number of items buffered in the
+// channel ch (name/len) and of its capacity (name/cap) to the registry. It
+// returns an error if ch is not a channel.
+func InstrumentChannel(registry Registry, name string, ch interface{}) error {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan {
+		return errors.New("metrics: InstrumentChannel requires a channel")
+	}
+	if err := registry.Add(name+"/len", GaugeFunc(func() float64 { return float64(v.Len()) })); err != nil {
+		return err
+	}
+	return registry.Add(name+"/cap", GaugeValue(v.Cap()))
+}
+
+// WorkerPoolMetrics records the jobs passing through a queue to a pool of
+// workers. Call Enqueue when a job is queued, keeping the time it returns
+// with the job, then Dequeue with that time when a worker takes the job and
+// Done with the time Dequeue returns when the job is finished.
+type WorkerPoolMetrics struct {
+	// Enqueued and Dequeued are the rates at which jobs are queued and
+	// taken by workers.
+	Enqueued *Meter
+	Dequeued *Meter
+	// Depth is the number of jobs waiting and Busy the number of workers
+	// running a job.
+	Depth *IntegerGauge
+	Busy  *IntegerGauge
+	// Wait is the time jobs spent queued and Run the time workers spent
+	// running them, both in microseconds.
+	Wait Histogram
+	Run  Histogram
+}
+
+// NewWorkerPoolMetrics returns WorkerPoolMetrics added to the registry as
+// name/enqueued, name/dequeued, name/depth, name/busy, name/wait and
+// name/run.
+func NewWorkerPoolMetrics(registry Registry, name string) *WorkerPoolMetrics {
+	m := &WorkerPoolMetrics{
+		Enqueued: NewMeter(),
+		Dequeued: NewMeter(),
+		Depth:    NewIntegerGauge(),
+		Busy:     NewIntegerGauge(),
+		Wait:     NewDefaultBucketedHistogram(),
+		Run:      NewDefaultBucketedHistogram(),
+	}
+	registry.Add(name+"/enqueued", m.Enqueued)
+	registry.Add(name+"/dequeued", m.Dequeued)
+	registry.Add(name+"/depth", m.Depth)
+	registry.Add(name+"/busy", m.Busy)
+	registry.Add(name+"/wait", m.Wait)
+	registry.Add(name+"/run", m.Run)
+	return m
+}
+
+// Enqueue records a job being queued and returns the time it was queued.
+func (m *WorkerPoolMetrics) Enqueue() time.Time {
+	m.Enqueued.Update(1)
+	m.Depth.Inc(1)
+	return time.Now()
+}
+
+// Dequeue records a worker taking the job queued at enqueued and returns
+// the time it started.
+func (m *WorkerPoolMetrics) Dequeue(enqueued time.Time) time.Time {
+	now := time.Now()
+	m.Dequeued.Update(1)
+	m.Depth.Dec(1)
+	m.Busy.Inc(1)
+	m.Wait.Update(int64(now.Sub(enqueued) / time.Microsecond))
+	return now
+}
+
+// Done records the end of the job started at started.
+func (m *WorkerPoolMetrics) Done(started time.Time) {
+	m.Busy.Dec(1)
+	m.Run.Update(int64(time.Since(started) / time.Microsecond))
+}
+
+// Stop stops the meters' background goroutines.
+func (m *WorkerPoolMetrics) Stop() {
+	m.Enqueued.Stop()
+	m.Dequeued.Stop()
+}
@@ -0,0 +1,54 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstrumentChannel(t *testing.T) {
+	r := NewRegistry()
+	ch := make(chan int, 4)
+	if err := InstrumentChannel(r, "jobs", ch); err != nil {
+		t.Fatal(err)
+	}
+	ch <- 1
+	ch <- 2
+	if v := r.Get("jobs/len").(GaugeMetric).Value(); v != 2 {
+		t.Fatalf("Expected len of 2. Got %f", v)
+	}
+	if v := r.Get("jobs/cap").(GaugeMetric).Value(); v != 4 {
+		t.Fatalf("Expected cap of 4. Got %f", v)
+	}
+	if err := InstrumentChannel(r, "notchan", 1); err == nil {
+		t.Fatal("Expected an error for a value that isn't a channel")
+	}
+}
+
+func TestWorkerPoolMetrics(t *testing.T) {
+	r := NewRegistry()
+	m := NewWorkerPoolMetrics(r, "pool")
+	defer m.Stop()
+
+	q1 := m.Enqueue()
+	m.Enqueue()
+	time.Sleep(2 * time.Millisecond)
+	started := m.Dequeue(q1)
+	if m.Depth.IntegerValue() != 1 || m.Busy.IntegerValue() != 1 {
+		t.Fatalf("Expected depth 1 and busy 1. Got %d and %d", m.Depth.IntegerValue(), m.Busy.IntegerValue())
+	}
+	m.Done(started)
+	if m.Busy.IntegerValue() != 0 || m.Enqueued.Count() != 2 || m.Dequeued.Count() != 1 {
+		t.Fatalf("Expected 2 enqueued and 1 dequeued with none busy. Got %d, %d and %d",
+			m.Enqueued.Count(), m.Dequeued.Count(), m.Busy.IntegerValue())
+	}
+	if d := m.Wait.Distribution(); d.Count != 1 || d.Min < 2000 {
+		t.Fatalf("Expected a wait of at least 2ms. Got %+v", d)
+	}
+	if r.Histogram("pool/run") == nil || r.Meter("pool/enqueued") == nil {
+		t.Fatal("Expected metrics in the registry")
+	}
+}
@@ -5,31 +5,209 @@
 package metrics
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type Registry interface {
 	Scope(scope string) Registry
-	Add(name string, metric interface{})
+	// Add registers metric under name. If a metric is already registered
+	// under the name then the outcome depends on the registry's DuplicatePolicy.
+	Add(name string, metric interface{}) error
+	// RegisterLazy registers a factory for a metric that is created on
+	// first lookup (Get, GetOrAdd, or a typed accessor). Until then the
+	// metric is not included by Do.
+	RegisterLazy(name string, newMetric func() interface{}) error
 	Remove(name string)
+	// Clear resets the value of all metrics that support it (counters,
+	// integer gauges, histograms, distributions, meters, and EWMAs).
+	// On a scope only the metrics within the scope are reset.
+	Clear()
+	// UnregisterAll removes all metrics and stops any that have a
+	// background goroutine (such as meters). On a scope only the metrics
+	// within the scope are removed.
+	UnregisterAll()
 	Do(f Doer) error
+	// MarshalJSON encodes the registry as an object of metric names to values.
+	MarshalJSON() ([]byte, error)
+	// DoSorted is like Do but calls f in lexicographic order of metric names.
+	DoSorted(f Doer) error
+
+	// AddListener registers l to be notified when metrics are added to
+	// or removed from the registry (including all of its scopes).
+	AddListener(l RegistryListener)
+	// RemoveListener unregisters l. Listeners are matched by identity so
+	// they should be pointers. A listener of a type that can't be compared
+	// with ==, such as a func or a slice, is never removed.
+	RemoveListener(l RegistryListener)
+
+	// Import adds all metrics from other to this registry with their
+	// names optionally prefixed. Metrics later added to or removed from
+	// other are kept in sync. Metrics that can't be added, for instance
+	// because of the DuplicatePolicy, are logged and skipped.
+	Import(other Registry, prefix string)
+
+	// Len returns the number of registered metrics. Collections count as one.
+	Len() int
+	// Names returns the sorted names of all registered metrics.
+	Names() []string
+	// Types returns the Go type of each registered metric keyed by name.
+	// Lazy metrics that have not yet been created are reported as "lazy".
+	Types() map[string]string
+
+	// Alias registers alias as another name for the metric registered
+	// under name. Do reports the metric under both names. The metric
+	// doesn't have to be registered yet and removing it leaves the
+	// alias with nothing to report.
+	Alias(alias, name string) error
+
+	// Get returns the metric registered under name or nil if there is none.
+	Get(name string) interface{}
+	// GetOrAdd returns the metric registered under name. If there is none
+	// then newMetric is called and its result is added to the registry.
+	// It returns nil if the registry is full or the name is invalid.
+	GetOrAdd(name string, newMetric func() interface{}) interface{}
+	// Counter returns the *Counter registered under name. It returns nil if
+	// there is no such metric or if it is of a different type.
+	Counter(name string) *Counter
+	// Histogram returns the Histogram registered under name. It returns nil if
+	// there is no such metric or if it is of a different type.
+	Histogram(name string) Histogram
+	// Meter returns the *Meter registered under name. It returns nil if
+	// there is no such metric or if it is of a different type.
+	Meter(name string) *Meter
+}
+
+// RegistryListener is notified of registration changes. Names are
+// fully scoped. The methods are called after the registry has been
+// updated and without any locks held so they may call back into the
+// registry.
+type RegistryListener interface {
+	MetricAdded(name string, metric interface{})
+	MetricRemoved(name string, metric interface{})
+}
+
+// DuplicatePolicy determines how a registry handles adding a metric
+// under a name that is already registered.
+type DuplicatePolicy int
+
+const (
+	// DuplicateReplace replaces the existing metric. This is the default.
+	DuplicateReplace DuplicatePolicy = iota
+	// DuplicateError keeps the existing metric and Add returns ErrDuplicateMetric.
+	DuplicateError
+	// DuplicatePanic panics.
+	DuplicatePanic
+	// DuplicateKeepExisting keeps the existing metric without an error.
+	// Use Get or GetOrAdd to retrieve it.
+	DuplicateKeepExisting
+)
+
+// EvictionPolicy determines how a registry with a MaxMetrics limit
+// handles adding a new metric when it's full.
+type EvictionPolicy int
+
+const (
+	// EvictReject rejects the new metric. This is the default.
+	EvictReject EvictionPolicy = iota
+	// EvictLRU removes the metric that was least recently added or
+	// looked up (through Get, GetOrAdd, or a typed accessor).
+	EvictLRU
+)
+
+var (
+	ErrDuplicateMetric = errors.New("metrics: duplicate metric name")
+	ErrRegistryFull    = errors.New("metrics: registry is full")
+)
+
+// RegistryConfig holds the options for NewRegistryWithConfig.
+type RegistryConfig struct {
+	DuplicatePolicy DuplicatePolicy
+
+	// MaxMetrics limits the number of registered metrics if greater than zero.
+	MaxMetrics     int
+	EvictionPolicy EvictionPolicy
+	// Rejected, if not nil, is incremented for each metric rejected
+	// because the registry is full.
+	Rejected *Counter
+	// Evicted, if not nil, is incremented for each metric evicted to make
+	// room. Evicted metrics with a background goroutine (such as meters)
+	// are stopped.
+	Evicted *Counter
+
+	// NameSanitizer, if not nil, rewrites fully scoped names on
+	// registration and lookup.
+	NameSanitizer NameSanitizer
+	// NameValidator, if not nil, is called with the sanitized name on
+	// registration. If it returns an error the metric is not registered.
+	NameValidator NameValidator
 }
 
 type registry struct {
-	scope   string
-	metrics map[string]interface{}
-	mutex   sync.RWMutex
+	scope string
+	*registryState
+}
+
+// registryState is shared between a registry and all of its scopes.
+//
+// The metrics map is copy-on-write: readers (Get, Do) load the current
+// map without locking while writers, which are serialized by mutex, store
+// a modified copy. Registration is rare compared to lookups and reporting
+// so this keeps the read path free of contention, and reporters don't
+// block registration while they're busy sending a snapshot.
+type registryState struct {
+	clock     uint64 // logical time of last access for MaxMetrics
+	config    RegistryConfig
+	metrics   atomic.Value // map[string]interface{}
+	access    atomic.Value // map[string]*uint64 when MaxMetrics > 0
+	listeners []RegistryListener
+	mutex     sync.Mutex
+}
+
+// lazyMetric holds a metric registered with RegisterLazy.
+type lazyMetric struct {
+	mu        sync.Mutex
+	newMetric func() interface{}
+	metric    interface{}
+}
+
+// get returns the metric creating it if necessary.
+func (l *lazyMetric) get() interface{} {
+	l.mu.Lock()
+	if l.metric == nil {
+		l.metric = l.newMetric()
+	}
+	m := l.metric
+	l.mu.Unlock()
+	return m
+}
+
+// peek returns the metric or nil if it has not been created yet.
+func (l *lazyMetric) peek() interface{} {
+	l.mu.Lock()
+	m := l.metric
+	l.mu.Unlock()
+	return m
+}
+
+// aliasMetric is registered under an alias to refer to another metric.
+type aliasMetric struct {
+	target string
 }
 
 type filteredRegistry struct {
 	registry Registry
 	include  []*regexp.Regexp
 	exclude  []*regexp.Regexp
+	keep     func(name string) bool
 }
 
 type Collection interface {
@@ -41,84 +219,624 @@ type Doer func(name string, metric interface{}) error
 // Registry
 
 func NewRegistry() Registry {
-	return &registry{
-		metrics: make(map[string]interface{}),
+	return NewRegistryWithConfig(RegistryConfig{})
+}
+
+func NewRegistryWithConfig(config RegistryConfig) Registry {
+	r := &registry{
+		registryState: &registryState{config: config},
 	}
+	r.store(make(map[string]interface{}))
+	return r
+}
+
+// NewChildRegistry returns a new registry whose metrics are also included
+// in parent under prefix. Since parent's reporters see the child's metrics,
+// a framework can hand out per-module child registries that flow into the
+// application's reporting. The child inherits parent's configuration.
+func NewChildRegistry(parent Registry, prefix string) Registry {
+	config := RegistryConfig{}
+	if p, ok := parent.(*registry); ok {
+		config = p.config
+	}
+	child := NewRegistryWithConfig(config)
+	parent.Import(child, prefix)
+	return child
+}
+
+func (s *registryState) load() map[string]interface{} {
+	return s.metrics.Load().(map[string]interface{})
+}
+
+// store replaces the current metrics map. The mutex must be held.
+func (s *registryState) store(metrics map[string]interface{}) {
+	if s.config.MaxMetrics > 0 {
+		old, _ := s.access.Load().(map[string]*uint64)
+		access := make(map[string]*uint64, len(metrics))
+		for name := range metrics {
+			if t := old[name]; t != nil {
+				access[name] = t
+			} else {
+				t := atomic.AddUint64(&s.clock, 1)
+				access[name] = &t
+			}
+		}
+		s.access.Store(access)
+	}
+	s.metrics.Store(metrics)
+}
+
+// touch records an access of a metric for LRU eviction.
+func (s *registryState) touch(name string) {
+	if s.config.MaxMetrics > 0 {
+		if t := s.access.Load().(map[string]*uint64)[name]; t != nil {
+			atomic.StoreUint64(t, atomic.AddUint64(&s.clock, 1))
+		}
+	}
+}
+
+// reserve makes room in metrics for a new entry if the registry is limited.
+// It returns false if the new metric should be rejected. If an existing
+// metric is evicted it's removed from metrics and returned. The mutex
+// must be held.
+func (s *registryState) reserve(metrics map[string]interface{}) (string, interface{}, bool) {
+	if s.config.MaxMetrics <= 0 || len(metrics) < s.config.MaxMetrics {
+		return "", nil, true
+	}
+	if s.config.EvictionPolicy != EvictLRU {
+		if s.config.Rejected != nil {
+			s.config.Rejected.Inc(1)
+		}
+		return "", nil, false
+	}
+	var oldestName string
+	var oldest uint64
+	for name, t := range s.access.Load().(map[string]*uint64) {
+		if at := atomic.LoadUint64(t); oldestName == "" || at < oldest {
+			oldestName = name
+			oldest = at
+		}
+	}
+	evicted := metrics[oldestName]
+	delete(metrics, oldestName)
+	if s.config.Evicted != nil {
+		s.config.Evicted.Inc(1)
+	}
+	return oldestName, evicted, true
+}
+
+// copyMetrics returns a copy of the current metrics map. The mutex must be held.
+func (s *registryState) copyMetrics(extra int) map[string]interface{} {
+	metrics := s.load()
+	cp := make(map[string]interface{}, len(metrics)+extra)
+	for name, metric := range metrics {
+		cp[name] = metric
+	}
+	return cp
 }
 
 func (r *registry) scopedName(name string) string {
 	if r.scope != "" {
-		return r.scope + "/" + name
+		name = r.scope + "/" + name
+	}
+	if r.config.NameSanitizer != nil {
+		name = r.config.NameSanitizer(name)
 	}
 	return name
 }
 
+func (r *registry) validateName(name string) error {
+	if r.config.NameValidator != nil {
+		return r.config.NameValidator(name)
+	}
+	return nil
+}
+
+// inScope reports whether a fully scoped name is within the registry's scope.
+func (r *registry) inScope(name string) bool {
+	return r.scope == "" || strings.HasPrefix(name, r.scope+"/")
+}
+
 func (r *registry) Scope(scope string) Registry {
 	return &registry{
-		scope:   r.scopedName(scope),
-		metrics: r.metrics,
+		scope:         r.scopedName(scope),
+		registryState: r.registryState,
 	}
 }
 
-func (r *registry) Add(name string, metric interface{}) {
+func (r *registry) Add(name string, metric interface{}) error {
+	name = r.scopedName(name)
+	if err := r.validateName(name); err != nil {
+		return err
+	}
 	r.mutex.Lock()
-	r.metrics[r.scopedName(name)] = metric
+	old, replaced := r.load()[name]
+	if replaced {
+		switch r.config.DuplicatePolicy {
+		case DuplicateError:
+			r.mutex.Unlock()
+			return ErrDuplicateMetric
+		case DuplicatePanic:
+			r.mutex.Unlock()
+			panic("metrics: duplicate metric " + name)
+		case DuplicateKeepExisting:
+			r.mutex.Unlock()
+			return nil
+		}
+	}
+	metrics := r.copyMetrics(1)
+	var evictedName string
+	var evicted interface{}
+	if !replaced {
+		var ok bool
+		if evictedName, evicted, ok = r.reserve(metrics); !ok {
+			r.mutex.Unlock()
+			return ErrRegistryFull
+		}
+	}
+	metrics[name] = metric
+	r.store(metrics)
+	listeners := r.listeners
 	r.mutex.Unlock()
+	if evictedName != "" {
+		stopMetric(evicted)
+	}
+	for _, l := range listeners {
+		if evictedName != "" {
+			l.MetricRemoved(evictedName, evicted)
+		}
+		if replaced {
+			l.MetricRemoved(name, old)
+		}
+		l.MetricAdded(name, metric)
+	}
+	return nil
+}
+
+func (r *registry) RegisterLazy(name string, newMetric func() interface{}) error {
+	return r.Add(name, &lazyMetric{newMetric: newMetric})
+}
+
+func (r *registry) Alias(alias, name string) error {
+	target := r.scopedName(name)
+	if a, ok := r.load()[target].(*aliasMetric); ok {
+		target = a.target
+	}
+	return r.Add(alias, &aliasMetric{target: target})
 }
 
 func (r *registry) Remove(name string) {
+	name = r.scopedName(name)
+	r.mutex.Lock()
+	old, ok := r.load()[name]
+	if ok {
+		metrics := r.copyMetrics(0)
+		delete(metrics, name)
+		r.store(metrics)
+	}
+	listeners := r.listeners
+	r.mutex.Unlock()
+	if ok {
+		for _, l := range listeners {
+			l.MetricRemoved(name, old)
+		}
+	}
+}
+
+func (r *registry) Clear() {
+	for name, metric := range r.load() {
+		if !r.inScope(name) {
+			continue
+		}
+		if l, ok := metric.(*lazyMetric); ok {
+			metric = l.peek()
+		}
+		switch m := metric.(type) {
+		case *Counter:
+			m.Reset()
+		case *IntegerGauge:
+			m.Reset()
+		case *Distribution:
+			m.Reset()
+		case *Meter:
+			m.Reset()
+		case *EWMA:
+			m.Reset()
+		case Histogram:
+			m.Clear()
+		}
+	}
+}
+
+func (r *registry) UnregisterAll() {
 	r.mutex.Lock()
-	delete(r.metrics, r.scopedName(name))
+	metrics := make(map[string]interface{})
+	remaining := r.copyMetrics(0)
+	for name, metric := range remaining {
+		if r.inScope(name) {
+			metrics[name] = metric
+			delete(remaining, name)
+		}
+	}
+	r.store(remaining)
+	listeners := r.listeners
 	r.mutex.Unlock()
+	for name, metric := range metrics {
+		metric = stopMetric(metric)
+		for _, l := range listeners {
+			l.MetricRemoved(name, metric)
+		}
+	}
+}
+
+// stopMetric stops a metric that has a background goroutine (such as a
+// meter) once it's no longer registered. Lazy metrics are resolved if they
+// have been created and the metric is returned.
+func stopMetric(metric interface{}) interface{} {
+	if l, ok := metric.(*lazyMetric); ok {
+		metric = l.peek()
+	}
+	if s, ok := metric.(interface {
+		Stop()
+	}); ok {
+		s.Stop()
+	}
+	return metric
+}
+
+func (r *registry) AddListener(l RegistryListener) {
+	r.mutex.Lock()
+	// Copy on write so notifications can iterate without holding the lock
+	listeners := make([]RegistryListener, len(r.listeners), len(r.listeners)+1)
+	copy(listeners, r.listeners)
+	r.listeners = append(listeners, l)
+	r.mutex.Unlock()
+}
+
+func (r *registry) RemoveListener(l RegistryListener) {
+	r.mutex.Lock()
+	listeners := make([]RegistryListener, 0, len(r.listeners))
+	for _, x := range r.listeners {
+		if !sameListener(x, l) {
+			listeners = append(listeners, x)
+		}
+	}
+	r.listeners = listeners
+	r.mutex.Unlock()
+}
+
+// sameListener reports whether a and b are the same listener without
+// panicking on types that can't be compared.
+func sameListener(a, b RegistryListener) bool {
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+	return a == b
 }
 
 func (r *registry) Do(f Doer) error {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-	return do("", r.metrics, f)
+	return do("", r.load(), f)
+}
+
+func (r *registry) DoSorted(f Doer) error {
+	return doSorted(r.Do, f)
+}
+
+func (r *registry) Import(other Registry, prefix string) {
+	imp := &registryImporter{registry: r, prefix: prefix}
+	other.AddListener(imp)
+	if o, ok := other.(*registry); ok {
+		// Use the raw metrics so collections stay live
+		for name, metric := range o.load() {
+			imp.MetricAdded(name, metric)
+		}
+	} else {
+		other.Do(func(name string, metric interface{}) error {
+			imp.MetricAdded(name, metric)
+			return nil
+		})
+	}
+}
+
+func (r *registry) Len() int {
+	return len(r.load())
+}
+
+func (r *registry) Names() []string {
+	metrics := r.load()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *registry) Types() map[string]string {
+	metrics := r.load()
+	types := make(map[string]string, len(metrics))
+	for name, metric := range metrics {
+		types[name] = metricType(metric)
+	}
+	return types
+}
+
+func (r *registry) Get(name string) interface{} {
+	name = r.scopedName(name)
+	r.touch(name)
+	metrics := r.load()
+	return resolveLazy(resolveAlias(metrics, metrics[name]))
+}
+
+func (r *registry) GetOrAdd(name string, newMetric func() interface{}) interface{} {
+	name = r.scopedName(name)
+	if m, ok := r.load()[name]; ok {
+		r.touch(name)
+		return resolveLazy(resolveAlias(r.load(), m))
+	}
+
+	if err := r.validateName(name); err != nil {
+		log.Printf("metrics: not registering %s: %s", name, err.Error())
+		return nil
+	}
+
+	r.mutex.Lock()
+	if m, ok := r.load()[name]; ok {
+		r.mutex.Unlock()
+		r.touch(name)
+		return resolveLazy(resolveAlias(r.load(), m))
+	}
+	metrics := r.copyMetrics(1)
+	evictedName, evicted, ok := r.reserve(metrics)
+	if !ok {
+		r.mutex.Unlock()
+		return nil
+	}
+	m := newMetric()
+	metrics[name] = m
+	r.store(metrics)
+	listeners := r.listeners
+	r.mutex.Unlock()
+	if evictedName != "" {
+		stopMetric(evicted)
+	}
+	for _, l := range listeners {
+		if evictedName != "" {
+			l.MetricRemoved(evictedName, evicted)
+		}
+		l.MetricAdded(name, m)
+	}
+	return m
+}
+
+func (r *registry) Counter(name string) *Counter {
+	c, _ := r.Get(name).(*Counter)
+	return c
+}
+
+func (r *registry) Histogram(name string) Histogram {
+	h, _ := r.Get(name).(Histogram)
+	return h
+}
+
+func (r *registry) Meter(name string) *Meter {
+	m, _ := r.Get(name).(*Meter)
+	return m
+}
+
+// registryImporter mirrors registration changes from an imported registry.
+type registryImporter struct {
+	registry *registry
+	prefix   string
+}
+
+func (i *registryImporter) importedName(name string) string {
+	if i.prefix != "" {
+		return i.prefix + "/" + name
+	}
+	return name
+}
+
+func (i *registryImporter) MetricAdded(name string, metric interface{}) {
+	if a, ok := metric.(*aliasMetric); ok {
+		// Alias targets are full names so apply the scope that Add does
+		metric = &aliasMetric{target: i.registry.scopedName(i.importedName(a.target))}
+	}
+	if err := i.registry.Add(i.importedName(name), metric); err != nil {
+		log.Printf("metrics: not importing %s: %s", i.importedName(name), err.Error())
+	}
+}
+
+func (i *registryImporter) MetricRemoved(name string, metric interface{}) {
+	i.registry.Remove(i.importedName(name))
 }
 
 // FilteredRegistry
 
 func NewFilterdRegistry(registry Registry, include []*regexp.Regexp, exclude []*regexp.Regexp) Registry {
-	return &filteredRegistry{registry, include, exclude}
+	return &filteredRegistry{registry: registry, include: include, exclude: exclude}
+}
+
+// NewFilterFuncRegistry returns a view of registry holding only the metrics
+// whose name keep returns true for. Metrics added through the view are added
+// to registry.
+func NewFilterFuncRegistry(registry Registry, keep func(name string) bool) Registry {
+	return &filteredRegistry{registry: registry, keep: keep}
 }
 
 func (r *filteredRegistry) Do(f Doer) error {
-	return r.registry.Do(func(name string, metric interface{}) error {
-		if r.exclude != nil {
-			for _, re := range r.exclude {
-				if re.MatchString(name) {
-					return nil
-				}
-			}
-		}
-		if r.include == nil {
+	return r.registry.Do(r.filter(f))
+}
+
+func (r *filteredRegistry) DoSorted(f Doer) error {
+	return r.registry.DoSorted(r.filter(f))
+}
+
+func (r *filteredRegistry) filter(f Doer) Doer {
+	return func(name string, metric interface{}) error {
+		if r.match(name) {
 			return f(name, metric)
 		}
-		for _, re := range r.include {
+		return nil
+	}
+}
+
+func (r *filteredRegistry) match(name string) bool {
+	if r.keep != nil && !r.keep(name) {
+		return false
+	}
+	if r.exclude != nil {
+		for _, re := range r.exclude {
 			if re.MatchString(name) {
-				return f(name, metric)
+				return false
 			}
 		}
-		return nil
-	})
+	}
+	if r.include == nil {
+		return true
+	}
+	for _, re := range r.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *filteredRegistry) Scope(scope string) Registry {
-	return &filteredRegistry{r.registry.Scope(scope), r.include, r.exclude}
+	return &filteredRegistry{r.registry.Scope(scope), r.include, r.exclude, r.keep}
 }
 
-func (r *filteredRegistry) Add(name string, metric interface{}) {
-	r.registry.Add(name, metric)
+func (r *filteredRegistry) Add(name string, metric interface{}) error {
+	return r.registry.Add(name, metric)
+}
+
+func (r *filteredRegistry) RegisterLazy(name string, newMetric func() interface{}) error {
+	return r.registry.RegisterLazy(name, newMetric)
 }
 
 func (r *filteredRegistry) Remove(name string) {
 	r.registry.Remove(name)
 }
 
+func (r *filteredRegistry) Clear() {
+	r.registry.Clear()
+}
+
+func (r *filteredRegistry) UnregisterAll() {
+	r.registry.UnregisterAll()
+}
+
+func (r *filteredRegistry) AddListener(l RegistryListener) {
+	r.registry.AddListener(l)
+}
+
+func (r *filteredRegistry) RemoveListener(l RegistryListener) {
+	r.registry.RemoveListener(l)
+}
+
+func (r *filteredRegistry) Import(other Registry, prefix string) {
+	r.registry.Import(other, prefix)
+}
+
+func (r *filteredRegistry) Len() int {
+	return len(r.Names())
+}
+
+func (r *filteredRegistry) Names() []string {
+	var names []string
+	for _, name := range r.registry.Names() {
+		if r.match(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (r *filteredRegistry) Types() map[string]string {
+	types := r.registry.Types()
+	for name := range types {
+		if !r.match(name) {
+			delete(types, name)
+		}
+	}
+	return types
+}
+
+func (r *filteredRegistry) Alias(alias, name string) error {
+	return r.registry.Alias(alias, name)
+}
+
+func (r *filteredRegistry) Get(name string) interface{} {
+	if !r.match(name) {
+		return nil
+	}
+	return r.registry.Get(name)
+}
+
+func (r *filteredRegistry) GetOrAdd(name string, newMetric func() interface{}) interface{} {
+	return r.registry.GetOrAdd(name, newMetric)
+}
+
+func (r *filteredRegistry) Counter(name string) *Counter {
+	if !r.match(name) {
+		return nil
+	}
+	return r.registry.Counter(name)
+}
+
+func (r *filteredRegistry) Histogram(name string) Histogram {
+	if !r.match(name) {
+		return nil
+	}
+	return r.registry.Histogram(name)
+}
+
+func (r *filteredRegistry) Meter(name string) *Meter {
+	if !r.match(name) {
+		return nil
+	}
+	return r.registry.Meter(name)
+}
+
+func metricType(metric interface{}) string {
+	if a, ok := metric.(*aliasMetric); ok {
+		return "alias:" + a.target
+	}
+	if l, ok := metric.(*lazyMetric); ok {
+		if metric = l.peek(); metric == nil {
+			return "lazy"
+		}
+	}
+	return fmt.Sprintf("%T", metric)
+}
+
+// resolveAlias returns the metric an alias refers to, or the metric itself.
+func resolveAlias(metrics map[string]interface{}, metric interface{}) interface{} {
+	if a, ok := metric.(*aliasMetric); ok {
+		return metrics[a.target]
+	}
+	return metric
+}
+
+func resolveLazy(metric interface{}) interface{} {
+	if l, ok := metric.(*lazyMetric); ok {
+		return l.get()
+	}
+	return metric
+}
+
 func do(scope string, metrics map[string]interface{}, f Doer) error {
 	for name, metric := range metrics {
+		if metric = resolveAlias(metrics, metric); metric == nil {
+			continue
+		}
+		if l, ok := metric.(*lazyMetric); ok {
+			if metric = l.peek(); metric == nil {
+				continue
+			}
+		}
 		if scope != "" {
 			name = scope + "/" + name
 		}
@@ -136,25 +854,53 @@ func do(scope string, metrics map[string]interface{}, f Doer) error {
 	return nil
 }
 
+type namedMetric struct {
+	name   string
+	metric interface{}
+}
+
+type namedMetricSlice []namedMetric
+
+func (s namedMetricSlice) Len() int {
+	return len(s)
+}
+
+func (s namedMetricSlice) Less(a, b int) bool {
+	return s[a].name < s[b].name
+}
+
+func (s namedMetricSlice) Swap(a, b int) {
+	s[a], s[b] = s[b], s[a]
+}
+
+// doSorted collects all metrics using do and then calls f on them in order of name.
+func doSorted(do func(Doer) error, f Doer) error {
+	var metrics namedMetricSlice
+	if err := do(func(name string, metric interface{}) error {
+		metrics = append(metrics, namedMetric{name, metric})
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.Sort(metrics)
+	for _, m := range metrics {
+		if err := f(m.name, m.metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func RegistryHandler(reg Registry) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := reg.MarshalJSON()
+		if err != nil {
+			log.Printf("metrics: failed to encode registry: %s", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		fmt.Fprintf(w, "{\n")
-		first := true
-		enc := json.NewEncoder(w)
-		reg.Do(func(name string, metric interface{}) error {
-			if !first {
-				fmt.Fprintf(w, ",")
-			}
-			first = false
-			fmt.Fprintf(w, "%q: ", name)
-			// Ignore any error since there's not much that can
-			// be done at this point since the headers have been sent
-			if err := enc.Encode(metric); err != nil {
-				log.Printf("metrics: failed to encode metric of type %T: %s", metric, err.Error())
-			}
-			return nil
-		})
-		fmt.Fprintf(w, "\n}\n")
+		w.Write(b)
+		w.Write([]byte{'\n'})
 	})
 }
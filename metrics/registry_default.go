@@ -0,0 +1,98 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import "log"
+
+// DefaultRegistry is the registry used by the package level convenience
+// functions. Metrics are created on first use.
+var DefaultRegistry = NewRegistry()
+
+// IncCounter increments the counter name in the DefaultRegistry by one.
+func IncCounter(name string) {
+	if c := GetOrAddCounter(DefaultRegistry, name); c != nil {
+		c.Inc(1)
+	}
+}
+
+// AddCounter increments the counter name in the DefaultRegistry by delta.
+func AddCounter(name string, delta uint64) {
+	if c := GetOrAddCounter(DefaultRegistry, name); c != nil {
+		c.Inc(delta)
+	}
+}
+
+// SetGauge sets the integer gauge name in the DefaultRegistry.
+func SetGauge(name string, value int64) {
+	if g := GetOrAddIntegerGauge(DefaultRegistry, name); g != nil {
+		g.Set(value)
+	}
+}
+
+// UpdateHistogram adds a value to the histogram name in the DefaultRegistry.
+func UpdateHistogram(name string, value int64) {
+	if h := GetOrAddHistogram(DefaultRegistry, name); h != nil {
+		h.Update(value)
+	}
+}
+
+// UpdateMeter marks delta events on the meter name in the DefaultRegistry.
+func UpdateMeter(name string, delta uint64) {
+	if m := GetOrAddMeter(DefaultRegistry, name); m != nil {
+		m.Update(delta)
+	}
+}
+
+// GetOrAddCounter returns the counter registered under name, creating
+// it if necessary. It returns nil if name is registered as a different type
+// or the registry is full.
+func GetOrAddCounter(r Registry, name string) *Counter {
+	m := r.GetOrAdd(name, func() interface{} { return NewCounter() })
+	c, ok := m.(*Counter)
+	if !ok && m != nil {
+		logTypeMismatch(name, "*Counter", m)
+	}
+	return c
+}
+
+// GetOrAddIntegerGauge returns the integer gauge registered under name, creating
+// it if necessary. It returns nil if name is registered as a different type
+// or the registry is full.
+func GetOrAddIntegerGauge(r Registry, name string) *IntegerGauge {
+	m := r.GetOrAdd(name, func() interface{} { return NewIntegerGauge() })
+	g, ok := m.(*IntegerGauge)
+	if !ok && m != nil {
+		logTypeMismatch(name, "*IntegerGauge", m)
+	}
+	return g
+}
+
+// GetOrAddHistogram returns the histogram registered under name, creating
+// a biased histogram if necessary. It returns nil if name is registered
+// as a different type or the registry is full.
+func GetOrAddHistogram(r Registry, name string) Histogram {
+	m := r.GetOrAdd(name, func() interface{} { return NewBiasedHistogram() })
+	h, ok := m.(Histogram)
+	if !ok && m != nil {
+		logTypeMismatch(name, "Histogram", m)
+	}
+	return h
+}
+
+// GetOrAddMeter returns the meter registered under name, creating
+// it if necessary. It returns nil if name is registered as a different type
+// or the registry is full.
+func GetOrAddMeter(r Registry, name string) *Meter {
+	m := r.GetOrAdd(name, func() interface{} { return NewMeter() })
+	mt, ok := m.(*Meter)
+	if !ok && m != nil {
+		logTypeMismatch(name, "*Meter", m)
+	}
+	return mt
+}
+
+func logTypeMismatch(name, expected string, metric interface{}) {
+	log.Printf("metrics: %s is registered as %T not %s", name, metric, expected)
+}
@@ -0,0 +1,36 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import "testing"
+
+func TestDefaultRegistry(t *testing.T) {
+	IncCounter("test/counter")
+	AddCounter("test/counter", 2)
+	if c := DefaultRegistry.Counter("test/counter"); c == nil || c.Count() != 3 {
+		t.Fatalf("Expected counter with value 3. Got %+v", c)
+	}
+	DefaultRegistry.Remove("test/counter")
+
+	SetGauge("test/gauge", 5)
+	if g := GetOrAddIntegerGauge(DefaultRegistry, "test/gauge"); g.IntegerValue() != 5 {
+		t.Fatalf("Expected gauge with value 5. Got %d", g.IntegerValue())
+	}
+	DefaultRegistry.Remove("test/gauge")
+
+	UpdateHistogram("test/hist", 10)
+	if h := DefaultRegistry.Histogram("test/hist"); h == nil || h.Distribution().Count != 1 {
+		t.Fatalf("Expected histogram with 1 value. Got %+v", h)
+	}
+	DefaultRegistry.Remove("test/hist")
+}
+
+func TestGetOrAddTypeMismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Add("name", NewIntegerGauge())
+	if c := GetOrAddCounter(r, "name"); c != nil {
+		t.Fatalf("GetOrAddCounter should return nil on type mismatch instead of %+v", c)
+	}
+}
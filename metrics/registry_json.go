@@ -0,0 +1,56 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+func (r *registry) MarshalJSON() ([]byte, error) {
+	return marshalRegistryJSON(r)
+}
+
+func (r *filteredRegistry) MarshalJSON() ([]byte, error) {
+	return marshalRegistryJSON(r)
+}
+
+func (rs *RegistrySnapshot) MarshalJSON() ([]byte, error) {
+	return marshalRegistryJSON(rs)
+}
+
+// marshalRegistryJSON encodes a registry as a JSON object mapping metric
+// names (in sorted order) to the JSON encoding of each metric.
+func marshalRegistryJSON(reg Registry) ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteByte('{')
+	first := true
+	err := reg.DoSorted(func(name string, metric interface{}) error {
+		if d, ok := metric.(NamedDistribution); ok {
+			metric = d.Value
+		}
+		value, err := metricJSON(metric)
+		if err != nil {
+			return err
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestRegistryMarshalJSON(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounter()
+	counter.Inc(3)
+	r.Add("counter", counter)
+	hist := NewUnbiasedHistogram()
+	hist.Update(10)
+	r.Add("hist", hist)
+	meter := NewMeter()
+	defer meter.Stop()
+	meter.Update(2)
+	r.Add("meter", meter)
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Counter uint64
+		Hist    struct {
+			Count uint64
+			P99   int64
+		}
+		Meter struct {
+			Count uint64
+		}
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("%s: %s", err, string(b))
+	}
+	if out.Counter != 3 || out.Hist.Count != 1 || out.Hist.P99 != 10 || out.Meter.Count != 2 {
+		t.Fatalf("Unexpected JSON encoding of registry: %s", string(b))
+	}
+
+	snap := NewRegistrySnapshot(true)
+	snap.Snapshot(r)
+	if _, err := json.Marshal(snap); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryMarshalJSONNonFinite(t *testing.T) {
+	r := NewRegistry()
+	g := NewFloatGauge()
+	g.Set(math.NaN())
+	r.Add("nan", g)
+	r.Add("inf", GaugeFunc(func() float64 { return math.Inf(1) }))
+	d := NewDistribution()
+	d.Update(math.Inf(-1))
+	r.Add("dist", d)
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("%s: %s", err, string(b))
+	}
+	if out["nan"] != nil || out["inf"] != nil {
+		t.Fatalf("Expected null for non-finite gauges. Got %s", b)
+	}
+
+	snap := NewRegistrySnapshot(true)
+	snap.Snapshot(r)
+	if b, err := json.Marshal(snap); err != nil || !json.Valid(b) {
+		t.Fatalf("Expected a valid snapshot. Got %s and %v", b, err)
+	}
+}
+
+func BenchmarkRegistryMarshalJSON(b *testing.B) {
+	r := benchmarkRegistry(100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.MarshalJSON()
+	}
+}
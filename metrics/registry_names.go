@@ -0,0 +1,101 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// NameSanitizer rewrites a metric name before it's registered or looked up.
+type NameSanitizer func(name string) string
+
+// NameValidator returns an error if a metric name may not be registered.
+type NameValidator func(name string) error
+
+// ChainSanitizers returns a sanitizer that applies each sanitizer in order.
+func ChainSanitizers(sanitizers ...NameSanitizer) NameSanitizer {
+	return func(name string) string {
+		for _, s := range sanitizers {
+			name = s(name)
+		}
+		return name
+	}
+}
+
+// ChainValidators returns a validator that fails with the error of the
+// first failing validator.
+func ChainValidators(validators ...NameValidator) NameValidator {
+	return func(name string) error {
+		for _, v := range validators {
+			if err := v(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ReplaceNameChars returns a sanitizer that replaces every rune for which
+// allowed returns false with replacement.
+func ReplaceNameChars(allowed func(rune) bool, replacement rune) NameSanitizer {
+	return func(name string) string {
+		return strings.Map(func(r rune) rune {
+			if allowed(r) {
+				return r
+			}
+			return replacement
+		}, name)
+	}
+}
+
+// NormalizeNameSeparators returns a sanitizer that replaces any of the
+// runes in separators with the registry's scope separator '/' and
+// collapses repeated separators.
+func NormalizeNameSeparators(separators string) NameSanitizer {
+	return func(name string) string {
+		name = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(separators, r) {
+				return '/'
+			}
+			return r
+		}, name)
+		for strings.Contains(name, "//") {
+			name = strings.Replace(name, "//", "/", -1)
+		}
+		return strings.Trim(name, "/")
+	}
+}
+
+// MaxNameLength returns a validator that rejects names longer than n runes.
+func MaxNameLength(n int) NameValidator {
+	return func(name string) error {
+		if utf8.RuneCountInString(name) > n {
+			return fmt.Errorf("metrics: name %q is longer than %d characters", name, n)
+		}
+		return nil
+	}
+}
+
+// AllowedNameChars returns a validator that rejects names containing a
+// rune for which allowed returns false.
+func AllowedNameChars(allowed func(rune) bool) NameValidator {
+	return func(name string) error {
+		for _, r := range name {
+			if !allowed(r) {
+				return fmt.Errorf("metrics: name %q contains invalid character %q", name, r)
+			}
+		}
+		return nil
+	}
+}
+
+// IsStandardNameChar returns true for ASCII letters, digits, and the
+// characters '_', '-', '.', and '/' which are accepted by most backends.
+func IsStandardNameChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '_' || r == '-' || r == '.' || r == '/'
+}
@@ -0,0 +1,48 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistryNameSanitizer(t *testing.T) {
+	r := NewRegistryWithConfig(RegistryConfig{
+		NameSanitizer: ChainSanitizers(
+			NormalizeNameSeparators(". "),
+			ReplaceNameChars(IsStandardNameChar, '_'),
+		),
+	})
+	r.Add("http.requests  total", 1)
+	r.Scope("db").Add("query(ms)", 2)
+	if names, exp := r.Names(), []string{"db/query_ms_", "http/requests/total"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected sanitized names %+v. Got %+v", exp, names)
+	}
+	if m := r.Get("http.requests total"); m != 1 {
+		t.Fatalf("Get should sanitize the name. Got %+v", m)
+	}
+}
+
+func TestRegistryNameValidator(t *testing.T) {
+	r := NewRegistryWithConfig(RegistryConfig{
+		NameValidator: ChainValidators(MaxNameLength(8), AllowedNameChars(IsStandardNameChar)),
+	})
+	if err := r.Add("ok", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("much/too/long", 1); err == nil {
+		t.Fatal("Expected error for long name")
+	}
+	if err := r.Add("bad name", 1); err == nil {
+		t.Fatal("Expected error for invalid character")
+	}
+	if m := r.GetOrAdd("bad name", func() interface{} { return 1 }); m != nil {
+		t.Fatalf("Expected GetOrAdd to return nil for invalid name. Got %+v", m)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("Expected only valid name to be registered")
+	}
+}
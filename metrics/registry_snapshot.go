@@ -1,6 +1,14 @@
 package metrics
 
-import "log"
+import (
+	"log"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type NamedValue struct {
 	Name  string
@@ -17,70 +25,313 @@ type RegistrySnapshot struct {
 	Distributions []NamedDistribution
 
 	resetOnSnapshot bool
+	counterMode     CounterMode
+	percentiles     PercentileConfig
+	rateScale       float64 // converts per second rates to the rate unit
+	durationScale   float64 // converts histogram values to the duration unit
 	counterValues   map[string]uint64
+	counterNames    map[string]bool
+	keepBuckets     bool
+	buckets         map[string][]HistogramBucket // by name when keepBuckets
+
+	// Counter state is dropped once a counter has not been seen for
+	// counterExpiry snapshots so that it doesn't grow forever when
+	// metric names are dynamic.
+	snapshots     uint64
+	counterExpiry uint64
+	counterSeen   map[string]uint64
+
+	removedMu sync.Mutex
+	removed   []string
+
+	// Values of metrics that are reset when read. These are kept for
+	// the duration of a snapshot so that a metric registered under
+	// more than one name (aliases) reports the same value for each.
+	resetCounters map[*Counter]uint64
+	histograms    map[Histogram]histogramSnapshot
+}
+
+// CounterMode selects how counters are reported by a RegistrySnapshot.
+type CounterMode int
+
+const (
+	// CounterDelta reports the change in a counter since the previous
+	// snapshot. This is the default.
+	CounterDelta CounterMode = iota
+	// CounterCumulative reports the total count. Counters that are reset
+	// on snapshot are summed so the total keeps growing.
+	CounterCumulative
+)
+
+type histogramSnapshot struct {
+	dist    DistributionValue
+	perc    []int64
+	buckets []HistogramBucket
 }
 
 func NewRegistrySnapshot(resetOnSnapshot bool) *RegistrySnapshot {
 	return &RegistrySnapshot{
 		resetOnSnapshot: resetOnSnapshot,
+		percentiles:     DefaultPercentileConfig,
+		rateScale:       1,
+		durationScale:   1,
 		counterValues:   make(map[string]uint64),
+		counterNames:    make(map[string]bool),
+		counterExpiry:   1,
+		counterSeen:     make(map[string]uint64),
+		resetCounters:   make(map[*Counter]uint64),
+		histograms:      make(map[Histogram]histogramSnapshot),
+	}
+}
+
+// SetCounterMode sets whether counters are reported as the change since
+// the previous snapshot or as cumulative totals.
+func (rs *RegistrySnapshot) SetCounterMode(mode CounterMode) {
+	rs.counterMode = mode
+}
+
+// SetPercentiles sets the percentiles of histograms that are included in
+// snapshots, as values named after the histogram and the percentile's name.
+func (rs *RegistrySnapshot) SetPercentiles(config PercentileConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	rs.percentiles = config
+	return nil
+}
+
+// SetRateUnit sets the unit of time that rates of meters and EWMAs are
+// reported per, for example time.Minute for events per minute. The default
+// is per second.
+func (rs *RegistrySnapshot) SetRateUnit(unit time.Duration) {
+	rs.rateScale = float64(unit) / float64(time.Second)
+}
+
+// SetDurationUnit converts the values of histograms, which are recorded in
+// the recorded unit, to the reported unit. For example histograms of
+// latencies recorded in nanoseconds can be reported in milliseconds with
+// SetDurationUnit(time.Nanosecond, time.Millisecond). By default values are
+// reported as they were recorded.
+func (rs *RegistrySnapshot) SetDurationUnit(recorded, reported time.Duration) {
+	rs.durationScale = float64(recorded) / float64(reported)
+}
+
+// scaleDistribution converts a histogram's distribution to the duration unit.
+func (rs *RegistrySnapshot) scaleDistribution(d DistributionValue) DistributionValue {
+	if s := rs.durationScale; s != 1 {
+		d.Sum *= s
+		d.Min *= s
+		d.Max *= s
+		d.Variance *= s * s
+	}
+	return d
+}
+
+// SetKeepBuckets sets whether the buckets of histograms with fixed buckets
+// are kept with each snapshot, for Buckets, so that they can be sent to
+// something that merges histograms. Their bounds are converted to the
+// duration unit.
+func (rs *RegistrySnapshot) SetKeepBuckets(keep bool) {
+	rs.keepBuckets = keep
+}
+
+// Buckets returns the buckets of the histogram name if SetKeepBuckets is on
+// and the histogram has fixed buckets, or nil otherwise.
+func (rs *RegistrySnapshot) Buckets(name string) []HistogramBucket {
+	return rs.buckets[name]
+}
+
+// scaleBuckets converts the bounds of a histogram's buckets to the duration
+// unit.
+func (rs *RegistrySnapshot) scaleBuckets(buckets []HistogramBucket) []HistogramBucket {
+	s := rs.durationScale
+	if s == 1 {
+		return buckets
 	}
+	out := make([]HistogramBucket, len(buckets))
+	for i, b := range buckets {
+		out[i] = b
+		if b.UpperBound != math.MaxInt64 {
+			out[i].UpperBound = int64(math.Ceil(float64(b.UpperBound) * s))
+		}
+	}
+	return out
+}
+
+// SetCounterExpiry sets the number of snapshots for which the previous
+// value of a counter is kept after it was last seen. The default of 1
+// forgets a counter as soon as it's missing from a snapshot.
+func (rs *RegistrySnapshot) SetCounterExpiry(snapshots int) {
+	if snapshots < 1 {
+		snapshots = 1
+	}
+	rs.counterExpiry = uint64(snapshots)
+}
+
+// MetricAdded implements RegistryListener.
+func (rs *RegistrySnapshot) MetricAdded(name string, metric interface{}) {}
+
+// MetricRemoved implements RegistryListener so that the state kept for a
+// counter is forgotten when it's removed from the registry. Otherwise a new
+// counter added under the same name would be reported relative to the old.
+func (rs *RegistrySnapshot) MetricRemoved(name string, metric interface{}) {
+	rs.removedMu.Lock()
+	rs.removed = append(rs.removed, name)
+	rs.removedMu.Unlock()
+}
+
+// markCounter records that name is a counter seen in this snapshot.
+func (rs *RegistrySnapshot) markCounter(name string) {
+	rs.counterNames[name] = true
+	rs.counterSeen[name] = rs.snapshots
+}
+
+// forgetRemoved forgets counters that were removed from the registry.
+func (rs *RegistrySnapshot) forgetRemoved() {
+	rs.removedMu.Lock()
+	removed := rs.removed
+	rs.removed = nil
+	rs.removedMu.Unlock()
+	for _, name := range removed {
+		delete(rs.counterValues, name)
+		delete(rs.counterSeen, name)
+	}
+}
+
+// expireCounters forgets counters that haven't been seen recently.
+func (rs *RegistrySnapshot) expireCounters() {
+	for name, seen := range rs.counterSeen {
+		if rs.snapshots-seen >= rs.counterExpiry {
+			delete(rs.counterValues, name)
+			delete(rs.counterSeen, name)
+		}
+	}
+}
+
+// counterValue returns the value to report for a counter that is not reset
+// on snapshot given its current count and the part of it restored by
+// LoadState.
+func (rs *RegistrySnapshot) counterValue(name string, newValue, restored uint64) uint64 {
+	if rs.counterMode == CounterCumulative {
+		return newValue
+	}
+	oldValue, ok := rs.counterValues[name]
+	if !ok {
+		// The count restored by LoadState isn't new.
+		oldValue = restored
+	}
+	rs.counterValues[name] = newValue
+	if newValue < oldValue {
+		// The counter was reset so all of its count is new.
+		return newValue
+	}
+	return newValue - oldValue
+}
+
+func (rs *RegistrySnapshot) resetCounter(c *Counter) uint64 {
+	v, ok := rs.resetCounters[c]
+	if !ok {
+		restored := atomic.SwapUint64(&c.restored, 0)
+		v = c.Reset()
+		if rs.counterMode == CounterDelta && restored <= v {
+			// The count restored by LoadState isn't new.
+			v -= restored
+		}
+		rs.resetCounters[c] = v
+	}
+	return v
+}
+
+func (rs *RegistrySnapshot) snapshotHistogram(h Histogram) histogramSnapshot {
+	comparable := reflect.TypeOf(h).Comparable()
+	if comparable {
+		if hs, ok := rs.histograms[h]; ok {
+			return hs
+		}
+	}
+	hs := histogramSnapshot{dist: h.Distribution()}
+	if hs.dist.Count > 0 {
+		hs.perc = h.Percentiles(rs.percentiles.Percentiles)
+		if b, ok := h.(BucketHistogram); ok && rs.keepBuckets {
+			hs.buckets = b.Buckets()
+		}
+		h.Clear()
+	}
+	if comparable {
+		rs.histograms[h] = hs
+	}
+	return hs
 }
 
 func (rs *RegistrySnapshot) Snapshot(registry Registry) {
 	rs.Values = rs.Values[:0]
 	rs.Distributions = rs.Distributions[:0]
+	for name := range rs.counterNames {
+		delete(rs.counterNames, name)
+	}
+	for c := range rs.resetCounters {
+		delete(rs.resetCounters, c)
+	}
+	for h := range rs.histograms {
+		delete(rs.histograms, h)
+	}
+	for name := range rs.buckets {
+		delete(rs.buckets, name)
+	}
+	rs.snapshots++
+	rs.forgetRemoved()
+	defer rs.expireCounters()
 	registry.Do(func(name string, metric interface{}) error {
 		switch m := metric.(type) {
 		case *EWMA:
-			rs.Values = append(rs.Values, NamedValue{Name: name, Value: m.Rate()})
+			rs.Values = append(rs.Values, NamedValue{Name: name, Value: m.Rate() * rs.rateScale})
 		case *EWMAGauge:
 			rs.Values = append(rs.Values, NamedValue{Name: name, Value: m.Mean()})
 		case *Meter:
 			rs.Values = append(rs.Values,
-				NamedValue{Name: name + "/1m", Value: m.OneMinuteRate()},
-				NamedValue{Name: name + "/5m", Value: m.FiveMinuteRate()},
-				NamedValue{Name: name + "/15m", Value: m.FifteenMinuteRate()},
+				NamedValue{Name: name + "/1m", Value: m.OneMinuteRate() * rs.rateScale},
+				NamedValue{Name: name + "/5m", Value: m.FiveMinuteRate() * rs.rateScale},
+				NamedValue{Name: name + "/15m", Value: m.FifteenMinuteRate() * rs.rateScale},
 			)
 		case Histogram:
-			v := m.Distribution()
-			if v.Count > 0 {
-				perc := m.Percentiles(DefaultPercentiles)
-				m.Clear()
-				rs.Distributions = append(rs.Distributions, NamedDistribution{Name: name, Value: v})
-				for i, p := range perc {
+			hs := rs.snapshotHistogram(m)
+			if hs.dist.Count > 0 {
+				rs.Distributions = append(rs.Distributions, NamedDistribution{Name: name, Value: rs.scaleDistribution(hs.dist)})
+				if hs.buckets != nil {
+					if rs.buckets == nil {
+						rs.buckets = make(map[string][]HistogramBucket)
+					}
+					rs.buckets[name] = rs.scaleBuckets(hs.buckets)
+				}
+				for i, p := range hs.perc {
 					rs.Values = append(rs.Values, NamedValue{
-						Name:  name + "/" + DefaultPercentileNames[i],
-						Value: float64(p),
+						Name:  name + "/" + rs.percentiles.Names[i],
+						Value: float64(p) * rs.durationScale,
 					})
 				}
 			}
 		case *Counter:
+			rs.markCounter(name)
+			var value uint64
 			if rs.resetOnSnapshot {
-				rs.Values = append(rs.Values, NamedValue{Name: name, Value: float64(m.Reset())})
-			} else {
-				oldValue := rs.counterValues[name]
-				newValue := m.Count()
-				rs.counterValues[name] = newValue
-				delta := newValue
-				if newValue > oldValue {
-					delta = newValue - oldValue
+				value = rs.resetCounter(m)
+				if rs.counterMode == CounterCumulative {
+					// Keep the running total since the counter itself is reset.
+					value += rs.counterValues[name]
+					rs.counterValues[name] = value
 				}
-				rs.Values = append(rs.Values, NamedValue{Name: name, Value: float64(delta)})
+			} else {
+				value = rs.counterValue(name, m.Count(), atomic.LoadUint64(&m.restored))
 			}
+			rs.Values = append(rs.Values, NamedValue{Name: name, Value: float64(value)})
+		case DistributionMetric:
+			// Before CounterMetric since a *Distribution also has a Count method
+			rs.Distributions = append(rs.Distributions, NamedDistribution{Name: name, Value: m.Value()})
 		case CounterMetric:
-			oldValue := rs.counterValues[name]
-			newValue := m.Count()
-			rs.counterValues[name] = newValue
-			delta := newValue
-			if newValue > oldValue {
-				delta = newValue - oldValue
-			}
-			rs.Values = append(rs.Values, NamedValue{Name: name, Value: float64(delta)})
+			rs.markCounter(name)
+			rs.Values = append(rs.Values, NamedValue{Name: name, Value: float64(rs.counterValue(name, m.Count(), 0))})
 		case GaugeMetric:
 			rs.Values = append(rs.Values, NamedValue{Name: name, Value: m.Value()})
-		case DistributionMetric:
-			rs.Distributions = append(rs.Distributions, NamedDistribution{Name: name, Value: m.Value()})
 		default:
 			log.Printf("metrics.RegistrySnapshot: unrecognized metric type for %s: %T %+v", name, m, m)
 		}
@@ -88,18 +339,166 @@ func (rs *RegistrySnapshot) Snapshot(registry Registry) {
 	})
 }
 
+// IsCounter returns true if the value for name came from a counter
+// (and is the change since the previous snapshot) rather than a gauge.
+func (rs *RegistrySnapshot) IsCounter(name string) bool {
+	return rs.counterNames[name]
+}
+
+// Filter returns a copy of the snapshot holding only the values and
+// distributions whose name keep returns true for. The copy is only for
+// reading and can not be used to take further snapshots.
+func (rs *RegistrySnapshot) Filter(keep func(name string) bool) *RegistrySnapshot {
+	out := &RegistrySnapshot{counterNames: make(map[string]bool)}
+	for _, v := range rs.Values {
+		if keep(v.Name) {
+			out.Values = append(out.Values, v)
+			if rs.counterNames[v.Name] {
+				out.counterNames[v.Name] = true
+			}
+		}
+	}
+	for _, d := range rs.Distributions {
+		if keep(d.Name) {
+			out.Distributions = append(out.Distributions, d)
+			if b := rs.buckets[d.Name]; b != nil {
+				if out.buckets == nil {
+					out.buckets = make(map[string][]HistogramBucket)
+				}
+				out.buckets[d.Name] = b
+			}
+		}
+	}
+	return out
+}
+
+// Rename returns a copy of the snapshot with every name passed through
+// rename. Like Filter the copy is only for reading.
+func (rs *RegistrySnapshot) Rename(rename func(name string) string) *RegistrySnapshot {
+	out := &RegistrySnapshot{
+		Values:        make([]NamedValue, len(rs.Values)),
+		Distributions: make([]NamedDistribution, len(rs.Distributions)),
+		counterNames:  make(map[string]bool, len(rs.counterNames)),
+	}
+	for i, v := range rs.Values {
+		out.Values[i] = NamedValue{Name: rename(v.Name), Value: v.Value}
+		if rs.counterNames[v.Name] {
+			out.counterNames[out.Values[i].Name] = true
+		}
+	}
+	for i, d := range rs.Distributions {
+		out.Distributions[i] = NamedDistribution{Name: rename(d.Name), Value: d.Value}
+		if b := rs.buckets[d.Name]; b != nil {
+			if out.buckets == nil {
+				out.buckets = make(map[string][]HistogramBucket)
+			}
+			out.buckets[out.Distributions[i].Name] = b
+		}
+	}
+	return out
+}
+
 func (rs *RegistrySnapshot) Scope(scope string) Registry {
 	panic("Scope called on RegistrySnapshot")
 }
 
-func (rs *RegistrySnapshot) Add(name string, metric interface{}) {
+func (rs *RegistrySnapshot) Add(name string, metric interface{}) error {
 	panic("Add called on RegistrySnapshot")
 }
 
+func (rs *RegistrySnapshot) RegisterLazy(name string, newMetric func() interface{}) error {
+	panic("RegisterLazy called on RegistrySnapshot")
+}
+
 func (rs *RegistrySnapshot) Remove(name string) {
 	panic("Remove called on RegistrySnapshot")
 }
 
+func (rs *RegistrySnapshot) Clear() {
+	panic("Clear called on RegistrySnapshot")
+}
+
+func (rs *RegistrySnapshot) UnregisterAll() {
+	panic("UnregisterAll called on RegistrySnapshot")
+}
+
+func (rs *RegistrySnapshot) AddListener(l RegistryListener) {
+	panic("AddListener called on RegistrySnapshot")
+}
+
+func (rs *RegistrySnapshot) RemoveListener(l RegistryListener) {
+	panic("RemoveListener called on RegistrySnapshot")
+}
+
+func (rs *RegistrySnapshot) Import(other Registry, prefix string) {
+	panic("Import called on RegistrySnapshot")
+}
+
+func (rs *RegistrySnapshot) Len() int {
+	return len(rs.Values) + len(rs.Distributions)
+}
+
+func (rs *RegistrySnapshot) Names() []string {
+	names := make([]string, 0, rs.Len())
+	for _, v := range rs.Values {
+		names = append(names, v.Name)
+	}
+	for _, v := range rs.Distributions {
+		names = append(names, v.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (rs *RegistrySnapshot) Types() map[string]string {
+	types := make(map[string]string, rs.Len())
+	for _, v := range rs.Values {
+		types[v.Name] = "metrics.GaugeValue"
+	}
+	for _, v := range rs.Distributions {
+		types[v.Name] = "metrics.NamedDistribution"
+	}
+	return types
+}
+
+func (rs *RegistrySnapshot) Alias(alias, name string) error {
+	panic("Alias called on RegistrySnapshot")
+}
+
+// Get returns the snapshotted value for name as a GaugeValue or NamedDistribution.
+func (rs *RegistrySnapshot) Get(name string) interface{} {
+	for _, v := range rs.Values {
+		if v.Name == name {
+			return GaugeValue(v.Value)
+		}
+	}
+	for _, v := range rs.Distributions {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func (rs *RegistrySnapshot) GetOrAdd(name string, newMetric func() interface{}) interface{} {
+	panic("GetOrAdd called on RegistrySnapshot")
+}
+
+// Counter always returns nil since a snapshot holds only values.
+func (rs *RegistrySnapshot) Counter(name string) *Counter {
+	return nil
+}
+
+// Histogram always returns nil since a snapshot holds only values.
+func (rs *RegistrySnapshot) Histogram(name string) Histogram {
+	return nil
+}
+
+// Meter always returns nil since a snapshot holds only values.
+func (rs *RegistrySnapshot) Meter(name string) *Meter {
+	return nil
+}
+
 func (rs *RegistrySnapshot) Do(f Doer) error {
 	for _, v := range rs.Values {
 		if err := f(v.Name, GaugeValue(v.Value)); err != nil {
@@ -113,3 +512,7 @@ func (rs *RegistrySnapshot) Do(f Doer) error {
 	}
 	return nil
 }
+
+func (rs *RegistrySnapshot) DoSorted(f Doer) error {
+	return doSorted(rs.Do, f)
+}
@@ -1,8 +1,11 @@
 package metrics
 
 import (
+	"reflect"
 	"sort"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type namedValueSlice []NamedValue
@@ -50,6 +53,9 @@ func TestRegistrySnapshot(t *testing.T) {
 	if e := (NamedValue{Name: "gauge", Value: 3}); snap.Values[1] != e {
 		t.Errorf("Expected %+v. Got %+v", e, snap.Values[1])
 	}
+	if !snap.IsCounter("counter") || snap.IsCounter("gauge") {
+		t.Errorf("Expected only counter to be identified as a counter")
+	}
 
 	counter.Inc(1)
 	gauge.Set(4)
@@ -66,3 +72,220 @@ func TestRegistrySnapshot(t *testing.T) {
 		t.Errorf("Expected %+v. Got %+v", e, snap.Values[1])
 	}
 }
+
+func TestRegistrySnapshotFilter(t *testing.T) {
+	reg := NewRegistry()
+	reg.Add("keep/counter", NewCounter())
+	reg.Add("drop/gauge", NewIntegerGauge())
+	dist := NewDistribution()
+	dist.Update(1)
+	reg.Add("keep/dist", dist)
+
+	snap := NewRegistrySnapshot(true)
+	snap.Snapshot(reg)
+	filtered := snap.Filter(func(name string) bool { return name[:4] == "keep" })
+	if len(filtered.Values) != 1 || filtered.Values[0].Name != "keep/counter" || !filtered.IsCounter("keep/counter") {
+		t.Fatalf("Unexpected values %+v", filtered.Values)
+	}
+	if len(filtered.Distributions) != 1 {
+		t.Fatalf("Unexpected distributions %+v", filtered.Distributions)
+	}
+	if len(snap.Values) != 2 {
+		t.Fatal("Expected original snapshot to be unchanged")
+	}
+}
+
+func TestRegistrySnapshotRename(t *testing.T) {
+	reg := NewRegistry()
+	reg.Add("counter", NewCounter())
+	snap := NewRegistrySnapshot(true)
+	snap.Snapshot(reg)
+	renamed := snap.Rename(func(name string) string { return "app/" + name })
+	if renamed.Values[0].Name != "app/counter" || !renamed.IsCounter("app/counter") {
+		t.Fatalf("Unexpected values %+v", renamed.Values)
+	}
+	if snap.Values[0].Name != "counter" {
+		t.Fatal("Expected original snapshot to be unchanged")
+	}
+}
+
+func TestRegistrySnapshotCounterMode(t *testing.T) {
+	for _, mode := range []CounterMode{CounterDelta, CounterCumulative} {
+		for _, reset := range []bool{true, false} {
+			reg := NewRegistry()
+			counter := NewCounter()
+			reg.Add("counter", counter)
+			var cf uint64
+			reg.Add("counterfunc", CounterFunc(func() uint64 { return cf }))
+
+			snap := NewRegistrySnapshot(reset)
+			snap.SetCounterMode(mode)
+			var total uint64
+			for _, inc := range []uint64{2, 0, 3} {
+				counter.Inc(inc)
+				cf += inc
+				total += inc
+				snap.Snapshot(reg)
+				exp := float64(inc)
+				if mode == CounterCumulative {
+					exp = float64(total)
+				}
+				for _, v := range snap.Values {
+					if v.Value != exp {
+						t.Fatalf("Expected %f for mode %d (reset %t). Got %+v", exp, mode, reset, snap.Values)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRegistrySnapshotCounterExpiry(t *testing.T) {
+	reg := NewRegistry()
+	snap := NewRegistrySnapshot(false)
+	reg.AddListener(snap)
+	for i := 0; i < 10; i++ {
+		c := NewCounter()
+		c.Inc(1)
+		reg.Add("dynamic/"+strconv.Itoa(i), c)
+		snap.Snapshot(reg)
+		reg.Remove("dynamic/" + strconv.Itoa(i))
+	}
+	snap.Snapshot(reg)
+	if len(snap.counterValues) != 0 || len(snap.counterSeen) != 0 {
+		t.Fatalf("Expected removed counters to be forgotten. Got %+v", snap.counterValues)
+	}
+
+	// Removed and added again between snapshots
+	c := NewCounter()
+	c.Inc(5)
+	reg.Add("c", c)
+	snap.Snapshot(reg)
+	reg.Remove("c")
+	c = NewCounter()
+	c.Inc(7)
+	reg.Add("c", c)
+	snap.Snapshot(reg)
+	if v := snap.Get("c"); v != GaugeValue(7) {
+		t.Fatalf("Expected 7 for a new counter. Got %+v", v)
+	}
+
+	// Expiry without a listener
+	reg = NewRegistry()
+	reg.Add("c", NewCounter())
+	snap = NewRegistrySnapshot(false)
+	snap.SetCounterExpiry(2)
+	snap.Snapshot(reg)
+	reg.Remove("c")
+	snap.Snapshot(reg)
+	if _, ok := snap.counterValues["c"]; !ok {
+		t.Fatal("Expected counter to be kept until it expires")
+	}
+	snap.Snapshot(reg)
+	if _, ok := snap.counterValues["c"]; ok {
+		t.Fatal("Expected counter to expire")
+	}
+}
+
+func TestRegistrySnapshotPercentiles(t *testing.T) {
+	reg := NewRegistry()
+	h := NewUnbiasedHistogram()
+	for i := int64(1); i <= 100; i++ {
+		h.Update(i)
+	}
+	reg.Add("h", h)
+	snap := NewRegistrySnapshot(false)
+	if err := snap.SetPercentiles(PercentileConfig{Percentiles: []float64{0.5}}); err == nil {
+		t.Fatal("Expected an invalid config to be rejected")
+	}
+	config, _ := NewPercentileConfig(0.95, 0.1)
+	if err := snap.SetPercentiles(config); err != nil {
+		t.Fatal(err)
+	}
+	snap.Snapshot(reg)
+	var names []string
+	for _, v := range snap.Values {
+		names = append(names, v.Name)
+	}
+	if exp := []string{"h/p95", "h/p10"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, names)
+	}
+}
+
+func TestRegistrySnapshotUnits(t *testing.T) {
+	reg := NewRegistry()
+	e := NewEWMA(time.Second*5, M1Alpha)
+	e.Update(10)
+	e.Tick()
+	reg.Add("rate", e)
+	h := NewUnbiasedHistogram()
+	h.Update(2000000)
+	h.Update(4000000)
+	reg.Add("latency", h)
+
+	snap := NewRegistrySnapshot(false)
+	snap.SetRateUnit(time.Minute)
+	snap.SetDurationUnit(time.Nanosecond, time.Millisecond)
+	snap.Snapshot(reg)
+	if v, exp := snap.Get("rate"), GaugeValue(e.Rate()*60); v != exp {
+		t.Fatalf("Expected rate per minute %f. Got %+v", exp, v)
+	}
+	d := snap.Get("latency").(NamedDistribution).Value
+	if d.Min != 2 || d.Max != 4 || d.Sum != 6 {
+		t.Fatalf("Expected distribution in milliseconds. Got %+v", d)
+	}
+	if v, ok := snap.Get("latency/p50").(GaugeValue); !ok || v < 2 || v > 4 {
+		t.Fatalf("Expected p50 in milliseconds. Got %+v", v)
+	}
+}
+
+func BenchmarkRegistrySnapshot(b *testing.B) {
+	r := benchmarkRegistry(100)
+	rs := NewRegistrySnapshot(false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs.Snapshot(r)
+	}
+}
+
+func TestRegistrySnapshotBuckets(t *testing.T) {
+	registry := NewRegistry()
+	h := NewBucketedHistogram([]int64{10, 100})
+	registry.Add("latency", h)
+	registry.Add("sizes", NewUnbiasedHistogram())
+	h.Update(5)
+	h.Update(50)
+	registry.Histogram("sizes").Update(1)
+
+	rs := NewRegistrySnapshot(true)
+	rs.Snapshot(registry)
+	if b := rs.Buckets("latency"); b != nil {
+		t.Fatalf("Expected no buckets unless kept. Got %+v", b)
+	}
+
+	rs.SetKeepBuckets(true)
+	h.Update(5)
+	h.Update(50)
+	registry.Histogram("sizes").Update(1)
+	rs.Snapshot(registry)
+	exp := []HistogramBucket{{UpperBound: 10, Count: 1}, {UpperBound: 100, Count: 1}}
+	if b := rs.Buckets("latency"); !reflect.DeepEqual(b, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, b)
+	}
+	if b := rs.Buckets("sizes"); b != nil {
+		t.Fatalf("Expected no buckets for a sampled histogram. Got %+v", b)
+	}
+	renamed := rs.Rename(func(name string) string { return "app/" + name })
+	if b := renamed.Buckets("app/latency"); !reflect.DeepEqual(b, exp) {
+		t.Fatalf("Expected renamed buckets %+v. Got %+v", exp, b)
+	}
+	if b := rs.Filter(func(name string) bool { return name == "latency" }).Buckets("latency"); !reflect.DeepEqual(b, exp) {
+		t.Fatalf("Expected filtered buckets %+v. Got %+v", exp, b)
+	}
+
+	rs.Snapshot(registry)
+	if b := rs.Buckets("latency"); b != nil {
+		t.Fatalf("Expected no buckets for an empty histogram. Got %+v", b)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -30,6 +31,397 @@ func TestRegistry(t *testing.T) {
 	}
 }
 
+func TestRegistryDuplicatePolicy(t *testing.T) {
+	r := NewRegistryWithConfig(RegistryConfig{DuplicatePolicy: DuplicateError})
+	if err := r.Add("num", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("num", 2); err != ErrDuplicateMetric {
+		t.Fatalf("Expected ErrDuplicateMetric. Got %+v", err)
+	}
+	if m := r.Get("num"); m != 1 {
+		t.Fatalf("Existing metric should have been kept instead of %+v", m)
+	}
+
+	r = NewRegistryWithConfig(RegistryConfig{DuplicatePolicy: DuplicateKeepExisting})
+	r.Add("num", 1)
+	if err := r.Add("num", 2); err != nil {
+		t.Fatal(err)
+	}
+	if m := r.Get("num"); m != 1 {
+		t.Fatalf("Existing metric should have been kept instead of %+v", m)
+	}
+
+	r = NewRegistry()
+	r.Add("num", 1)
+	r.Add("num", 2)
+	if m := r.Get("num"); m != 2 {
+		t.Fatalf("Existing metric should have been replaced instead of %+v", m)
+	}
+
+	r = NewRegistryWithConfig(RegistryConfig{DuplicatePolicy: DuplicatePanic})
+	r.Add("num", 1)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected duplicate metric to panic")
+		}
+	}()
+	r.Add("num", 2)
+}
+
+func TestRegistryMaxMetricsReject(t *testing.T) {
+	rejected := NewCounter()
+	r := NewRegistryWithConfig(RegistryConfig{MaxMetrics: 2, Rejected: rejected})
+	r.Add("a", 1)
+	r.Add("b", 2)
+	if err := r.Add("c", 3); err != ErrRegistryFull {
+		t.Fatalf("Expected ErrRegistryFull. Got %+v", err)
+	}
+	if m := r.GetOrAdd("d", func() interface{} { return 4 }); m != nil {
+		t.Fatalf("Expected GetOrAdd to return nil when full. Got %+v", m)
+	}
+	if err := r.Add("a", 5); err != nil {
+		t.Fatalf("Replacing an existing metric should be allowed when full. Got %+v", err)
+	}
+	if rejected.Count() != 2 {
+		t.Fatalf("Expected 2 rejected registrations. Got %d", rejected.Count())
+	}
+}
+
+func TestRegistryMaxMetricsLRU(t *testing.T) {
+	evicted := NewCounter()
+	r := NewRegistryWithConfig(RegistryConfig{MaxMetrics: 2, EvictionPolicy: EvictLRU, Evicted: evicted})
+	l := &testListener{}
+	r.AddListener(l)
+	r.Add("a", 1)
+	r.Add("b", 2)
+	r.Get("a")
+	if err := r.Add("c", 3); err != nil {
+		t.Fatal(err)
+	}
+	if names, exp := r.Names(), []string{"a", "c"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected least recently used metric to be evicted leaving %+v. Got %+v", exp, names)
+	}
+	if evicted.Count() != 1 {
+		t.Fatalf("Expected 1 evicted metric. Got %d", evicted.Count())
+	}
+	if exp := "remove b 2"; l.events[2] != exp {
+		t.Fatalf("Expected listener to receive %q. Got %q", exp, l.events[2])
+	}
+}
+
+func TestRegistryEvictionStopsMeter(t *testing.T) {
+	r := NewRegistryWithConfig(RegistryConfig{MaxMetrics: 1, EvictionPolicy: EvictLRU})
+	meter := NewMeter()
+	r.Add("meter", meter)
+	r.GetOrAdd("counter", func() interface{} { return NewCounter() })
+	select {
+	case <-meter.tickerStopChan:
+	default:
+		t.Fatal("Expected the evicted meter to be stopped")
+	}
+}
+
+func TestRegistryAlias(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounter()
+	r.Add("new", counter)
+	if err := r.Alias("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if c := r.Counter("old"); c != counter {
+		t.Fatalf("Expected alias to return the counter. Got %+v", c)
+	}
+	metrics := make(map[string]interface{})
+	r.Do(func(name string, metric interface{}) error {
+		metrics[name] = metric
+		return nil
+	})
+	if exp := map[string]interface{}{"new": counter, "old": counter}; !reflect.DeepEqual(metrics, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, metrics)
+	}
+
+	// Both names must report the same value even though reading resets the counter
+	counter.Inc(3)
+	snap := NewRegistrySnapshot(true)
+	snap.Snapshot(r)
+	if v := snap.Get("old"); v != GaugeValue(3) {
+		t.Fatalf("Expected alias to report 3. Got %+v", v)
+	}
+	if v := snap.Get("new"); v != GaugeValue(3) {
+		t.Fatalf("Expected metric to report 3. Got %+v", v)
+	}
+
+	r.Remove("new")
+	count := 0
+	r.Do(func(name string, metric interface{}) error {
+		count++
+		return nil
+	})
+	if count != 0 {
+		t.Fatalf("Expected dangling alias to be skipped")
+	}
+}
+
+func TestRegistryLazy(t *testing.T) {
+	r := NewRegistry()
+	created := 0
+	r.RegisterLazy("lazy", func() interface{} {
+		created++
+		return NewCounter()
+	})
+	count := 0
+	r.Do(func(name string, metric interface{}) error {
+		count++
+		return nil
+	})
+	if count != 0 || created != 0 {
+		t.Fatalf("Lazy metric should not be created or reported before first use")
+	}
+	c := r.Counter("lazy")
+	if c == nil || created != 1 {
+		t.Fatalf("Lazy metric should have been created on first use")
+	}
+	if c2 := r.GetOrAdd("lazy", func() interface{} { return nil }); c2 != c || created != 1 {
+		t.Fatalf("Lazy metric should only be created once")
+	}
+	r.Do(func(name string, metric interface{}) error {
+		if metric != c {
+			t.Errorf("Expected %+v. Got %+v", c, metric)
+		}
+		count++
+		return nil
+	})
+	if count != 1 {
+		t.Fatalf("Lazy metric should be reported once created")
+	}
+}
+
+func TestRegistryIntrospection(t *testing.T) {
+	r := NewRegistry()
+	r.Add("b", NewCounter())
+	r.Scope("a").Add("hist", NewUnbiasedHistogram())
+	r.RegisterLazy("lazy", func() interface{} { return NewCounter() })
+	if n := r.Len(); n != 3 {
+		t.Errorf("Expected Len of 3. Got %d", n)
+	}
+	if names, exp := r.Names(), []string{"a/hist", "b", "lazy"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("Expected Names %+v. Got %+v", exp, names)
+	}
+	exp := map[string]string{
+		"a/hist": "*metrics.sampledHistogram",
+		"b":      "*metrics.Counter",
+		"lazy":   "lazy",
+	}
+	if types := r.Types(); !reflect.DeepEqual(types, exp) {
+		t.Errorf("Expected Types %+v. Got %+v", exp, types)
+	}
+
+	fr := NewFilterdRegistry(r, nil, []*regexp.Regexp{regexp.MustCompile("^a/")})
+	if names, exp := fr.Names(), []string{"b", "lazy"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("Expected filtered Names %+v. Got %+v", exp, names)
+	}
+}
+
+func TestRegistryDoSorted(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"c", "a", "d", "b"} {
+		r.Add(name, 1)
+	}
+	r.Scope("b").Add("a", 1)
+	var names []string
+	r.DoSorted(func(name string, metric interface{}) error {
+		names = append(names, name)
+		return nil
+	})
+	exp := []string{"a", "b", "b/a", "c", "d"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatalf("registry.DoSorted should have returned %+v instead of %+v", exp, names)
+	}
+}
+
+func TestRegistryTypedLookup(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounter()
+	hist := NewUnbiasedHistogram()
+	meter := NewMeter()
+	defer meter.Stop()
+	r.Add("counter", counter)
+	r.Scope("sub").Add("hist", hist)
+	r.Add("meter", meter)
+
+	if c := r.Counter("counter"); c != counter {
+		t.Errorf("Counter returned %+v instead of %+v", c, counter)
+	}
+	if h := r.Histogram("sub/hist"); h != hist {
+		t.Errorf("Histogram returned %+v instead of %+v", h, hist)
+	}
+	if h := r.Scope("sub").Histogram("hist"); h != hist {
+		t.Errorf("Histogram on scope returned %+v instead of %+v", h, hist)
+	}
+	if m := r.Meter("meter"); m != meter {
+		t.Errorf("Meter returned %+v instead of %+v", m, meter)
+	}
+	if c := r.Counter("meter"); c != nil {
+		t.Errorf("Counter should return nil on type mismatch instead of %+v", c)
+	}
+	if m := r.Get("missing"); m != nil {
+		t.Errorf("Get should return nil for a missing metric instead of %+v", m)
+	}
+}
+
+type testListener struct {
+	events []string
+}
+
+func (l *testListener) MetricAdded(name string, metric interface{}) {
+	l.events = append(l.events, fmt.Sprintf("add %s %+v", name, metric))
+}
+
+func (l *testListener) MetricRemoved(name string, metric interface{}) {
+	l.events = append(l.events, fmt.Sprintf("remove %s %+v", name, metric))
+}
+
+func TestRegistryListener(t *testing.T) {
+	r := NewRegistry()
+	l := &testListener{}
+	r.AddListener(l)
+	r.Add("num", 1)
+	r.Scope("test").Add("num", 2)
+	r.Add("num", 3)
+	r.Remove("num")
+	r.Remove("missing")
+	r.RemoveListener(l)
+	r.Add("ignored", 4)
+	exp := []string{
+		"add num 1",
+		"add test/num 2",
+		"remove num 1",
+		"add num 3",
+		"remove num 3",
+	}
+	if !reflect.DeepEqual(l.events, exp) {
+		t.Fatalf("Listener should have received %+v instead of %+v", exp, l.events)
+	}
+}
+
+type sliceListener []string
+
+func (l sliceListener) MetricAdded(name string, metric interface{})   {}
+func (l sliceListener) MetricRemoved(name string, metric interface{}) {}
+
+func TestRegistryRemoveUncomparableListener(t *testing.T) {
+	r := NewRegistry()
+	l := &testListener{}
+	r.AddListener(sliceListener{"a"})
+	r.AddListener(l)
+	r.RemoveListener(sliceListener{"a"})
+	r.RemoveListener(l)
+	r.Add("num", 1)
+	if len(l.events) != 0 {
+		t.Fatalf("Expected the pointer listener to be removed. Got %+v", l.events)
+	}
+}
+
+func TestRegistryImport(t *testing.T) {
+	r := NewRegistry()
+	sub := NewRegistry()
+	sub.Add("num", 1)
+	r.Import(sub, "sub")
+	sub.Add("later", 2)
+	sub.Add("removed", 3)
+	sub.Remove("removed")
+	metrics := make(map[string]string)
+	r.Do(func(name string, metric interface{}) error {
+		metrics[name] = fmt.Sprintf("%+v", metric)
+		return nil
+	})
+	exp := map[string]string{"sub/num": "1", "sub/later": "2"}
+	if !reflect.DeepEqual(metrics, exp) {
+		t.Fatalf("registry.Do should have returned %+v instead of %+v", exp, metrics)
+	}
+}
+
+func TestRegistryImportAliasIntoScope(t *testing.T) {
+	r := NewRegistry()
+	sub := NewRegistry()
+	sub.Add("num", 1)
+	sub.Alias("alias", "num")
+	r.Scope("app").Import(sub, "sub")
+	if m := r.Get("app/sub/alias"); m != 1 {
+		t.Fatalf("Expected the alias to resolve to 1. Got %+v", m)
+	}
+}
+
+func TestChildRegistry(t *testing.T) {
+	parent := NewRegistryWithConfig(RegistryConfig{DuplicatePolicy: DuplicateError})
+	child := NewChildRegistry(parent, "child")
+	grandchild := NewChildRegistry(child, "grandchild")
+	child.Add("num", 1)
+	grandchild.Add("num", 2)
+	if names, exp := parent.Names(), []string{"child/grandchild/num", "child/num"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected parent to include %+v. Got %+v", exp, names)
+	}
+	if names, exp := child.Names(), []string{"grandchild/num", "num"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected child to include %+v. Got %+v", exp, names)
+	}
+	if err := child.Add("num", 3); err != ErrDuplicateMetric {
+		t.Fatalf("Child should have inherited the duplicate policy")
+	}
+}
+
+func TestScopeClear(t *testing.T) {
+	r := NewRegistry()
+	in, out := NewCounter(), NewCounter()
+	in.Inc(1)
+	out.Inc(1)
+	r.Scope("a").Add("counter", in)
+	r.Add("ab/counter", out)
+	r.Scope("a").Clear()
+	if in.Count() != 0 || out.Count() != 1 {
+		t.Fatalf("Expected only the scoped counter to be reset. Got %d and %d", in.Count(), out.Count())
+	}
+	r.Scope("a").UnregisterAll()
+	if names, exp := r.Names(), []string{"ab/counter"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, names)
+	}
+}
+
+func TestRegistryClear(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounter()
+	counter.Inc(2)
+	r.Add("counter", counter)
+	hist := NewUnbiasedHistogram()
+	hist.Update(1)
+	r.Add("hist", hist)
+	meter := NewMeter()
+	defer meter.Stop()
+	meter.Update(1)
+	r.Add("meter", meter)
+	r.Clear()
+	if counter.Count() != 0 || hist.Distribution().Count != 0 || meter.Count() != 0 {
+		t.Fatalf("Clear should have reset all metrics")
+	}
+	if r.Len() != 3 {
+		t.Fatalf("Clear should not remove metrics")
+	}
+}
+
+func TestRegistryUnregisterAll(t *testing.T) {
+	parent := NewRegistry()
+	r := NewChildRegistry(parent, "child")
+	meter := NewMeter()
+	r.Add("meter", meter)
+	r.Add("num", 1)
+	r.UnregisterAll()
+	if r.Len() != 0 || parent.Len() != 0 {
+		t.Fatalf("UnregisterAll should have removed all metrics from the registry and its parent")
+	}
+	// The meter should already be stopped so this must not panic
+	meter.Stop()
+}
+
 func TestFilteredRegistry(t *testing.T) {
 	r := NewRegistry()
 	r.Add("num", 1)
@@ -70,6 +462,23 @@ func TestFilteredRegistry(t *testing.T) {
 	if !reflect.DeepEqual(out, exp) {
 		t.Fatalf("filteredRegistry.Do should have returned %+v instead of %+v", exp, out)
 	}
+	if fr.Get("num") != nil || fr.Get("string") != "x" {
+		t.Fatal("filteredRegistry.Get should only return included metrics")
+	}
+}
+
+func TestFilterFuncRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Add("public/requests", NewCounter())
+	r.Add("internal/queue", NewCounter())
+
+	fr := NewFilterFuncRegistry(r, func(name string) bool { return strings.HasPrefix(name, "public/") })
+	if names, exp := fr.Names(), []string{"public/requests"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, names)
+	}
+	if fr.Counter("internal/queue") != nil || fr.Counter("public/requests") == nil {
+		t.Fatal("Expected only public counters")
+	}
 }
 
 func TestRegistryHandler(t *testing.T) {
@@ -91,3 +500,124 @@ func TestRegistryHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRegistryConcurrentAccess(t *testing.T) {
+	r := NewRegistry()
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			r.Add(fmt.Sprintf("m%d", i%10), i)
+		}
+		done <- true
+	}()
+	for i := 0; i < 1000; i++ {
+		r.Get("m1")
+		r.Do(func(name string, metric interface{}) error {
+			// Registering during iteration must not deadlock
+			if i%100 == 0 {
+				r.Add("do", i)
+			}
+			return nil
+		})
+	}
+	<-done
+}
+
+func benchmarkRegistry(n int) Registry {
+	r := NewRegistry()
+	for i := 0; i < n; i++ {
+		r.Add(fmt.Sprintf("metric%d", i), NewCounter())
+	}
+	return r
+}
+
+func BenchmarkRegistryGet(b *testing.B) {
+	r := benchmarkRegistry(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Get("metric50")
+	}
+}
+
+func BenchmarkRegistryGetParallel(b *testing.B) {
+	r := benchmarkRegistry(100)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.Get("metric50")
+		}
+	})
+}
+
+func BenchmarkRegistryDo(b *testing.B) {
+	r := benchmarkRegistry(100)
+	f := func(name string, metric interface{}) error { return nil }
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Do(f)
+	}
+}
+
+// Lookups from many goroutines while reporters iterate and a writer registers.
+func BenchmarkRegistryGetWithReportersAndWriter(b *testing.B) {
+	r := benchmarkRegistry(100)
+	stop := make(chan bool)
+	f := func(name string, metric interface{}) error { return nil }
+	for i := 0; i < 2; i++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					r.Do(f)
+				}
+			}
+		}()
+	}
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				r.Add(fmt.Sprintf("dynamic%d", i%100), i)
+			}
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.Get("metric50")
+		}
+	})
+	b.StopTimer()
+	close(stop)
+}
+
+func BenchmarkRegistryAdd(b *testing.B) {
+	r := benchmarkRegistry(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Add("metric50", i)
+	}
+}
+
+func BenchmarkRegistryGetOrAdd(b *testing.B) {
+	r := benchmarkRegistry(100)
+	newCounter := func() interface{} { return NewCounter() }
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrAdd("metric50", newCounter)
+	}
+}
+
+func BenchmarkRegistryCounter(b *testing.B) {
+	r := benchmarkRegistry(100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Counter("metric50")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync/atomic"
+)
+
+// stateVersion is the version of the format written by SaveState.
+const stateVersion = 1
+
+type savedState struct {
+	Version int                     `json:"version"`
+	Metrics map[string]*savedMetric `json:"metrics"`
+}
+
+type savedMetric struct {
+	Type     string            `json:"type"`
+	Count    uint64            `json:"count"`
+	Sum      float64           `json:"sum,omitempty"`
+	Min      float64           `json:"min,omitempty"`
+	Max      float64           `json:"max,omitempty"`
+	Variance float64           `json:"variance,omitempty"`
+	Start    int64             `json:"start,omitempty"` // UnixNano
+	Rates    []float64         `json:"rates,omitempty"`
+	Samples  []int64           `json:"samples,omitempty"`
+	Buckets  []HistogramBucket `json:"buckets,omitempty"`
+}
+
+// SaveState writes the state of the registry's cumulative metrics to w as
+// JSON so that LoadState can restore it after the process restarts and
+// dashboards don't see counters fall to zero. It saves:
+//
+//   - the count of each *Counter
+//   - the count, rates and start time of each *Meter
+//   - the values of histograms from NewSampledHistogram and the bucket counts
+//     of those from NewBucketedHistogram along with their count, sum, min
+//     and max
+//   - the summary of each *Distribution
+//
+// Other metrics, such as gauges which reflect the state of the process, are
+// left out, as are metrics that haven't recorded anything.
+func SaveState(w io.Writer, reg Registry) error {
+	state := savedState{
+		Version: stateVersion,
+		Metrics: make(map[string]*savedMetric),
+	}
+	err := reg.Do(func(name string, metric interface{}) error {
+		if m := saveMetric(metric); m != nil && m.Count > 0 {
+			state.Metrics[name] = m
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(&state)
+}
+
+func saveMetric(metric interface{}) *savedMetric {
+	switch m := metric.(type) {
+	case *Counter:
+		return &savedMetric{Type: "counter", Count: m.Count()}
+	case *Meter:
+		return &savedMetric{
+			Type:  "meter",
+			Count: m.Count(),
+			Start: atomic.LoadInt64(&m.startTime),
+			Rates: []float64{m.OneMinuteRate(), m.FiveMinuteRate(), m.FifteenMinuteRate()},
+		}
+	case Histogram:
+		if d, ok := ExportHistogram(m); ok {
+			return &savedMetric{
+				Type:    "histogram",
+				Count:   d.Count,
+				Sum:     float64(d.Sum),
+				Min:     float64(d.Min),
+				Max:     float64(d.Max),
+				Samples: d.Samples,
+				Buckets: d.Buckets,
+			}
+		}
+	case *Distribution:
+		v := m.Value()
+		return &savedMetric{Type: "distribution", Count: v.Count, Sum: v.Sum, Min: v.Min, Max: v.Max, Variance: v.Variance}
+	}
+	return nil
+}
+
+// LoadState restores state written by SaveState into the registry, adding
+// the saved values to the metrics' current ones. Counters and distributions
+// that aren't registered are added. Meters and histograms must be
+// registered first since their configuration isn't saved; saved state for
+// any that aren't is ignored. LoadState returns an error if a saved metric
+// is registered with a different type but restores the rest.
+//
+// Restored counts aren't reported as new by snapshots in CounterDelta mode
+// so reporters sending the change in counters don't see a spike on every
+// restart. That relies on LoadState running before a counter is first
+// snapshot; a count restored after that is reported as its change.
+func LoadState(r io.Reader, reg Registry) error {
+	var state savedState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return err
+	}
+	if state.Version != stateVersion {
+		return fmt.Errorf("metrics: unsupported state version %d", state.Version)
+	}
+	var errs []string
+	for name, s := range state.Metrics {
+		if err := loadMetric(reg, name, s); err != "" {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("metrics: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func loadMetric(reg Registry, name string, s *savedMetric) string {
+	var metric interface{}
+	switch s.Type {
+	case "counter":
+		metric = reg.GetOrAdd(name, func() interface{} { return NewCounter() })
+	case "distribution":
+		metric = reg.GetOrAdd(name, func() interface{} { return NewDistribution() })
+	default:
+		metric = reg.Get(name)
+		if metric == nil {
+			return ""
+		}
+	}
+	switch m := metric.(type) {
+	case *Counter:
+		if s.Type == "counter" {
+			m.restore(s.Count)
+			return ""
+		}
+	case *Distribution:
+		if s.Type == "distribution" {
+			m.Merge(DistributionValue{Count: s.Count, Sum: s.Sum, Min: s.Min, Max: s.Max, Variance: s.Variance})
+			return ""
+		}
+	case *Meter:
+		if s.Type == "meter" && len(s.Rates) == 3 {
+			m.restore(s.Count, s.Start, s.Rates)
+			return ""
+		}
+	case Histogram:
+		if s.Type == "histogram" {
+			d := &HistogramData{
+				Count:   s.Count,
+				Sum:     int64(s.Sum),
+				Min:     int64(s.Min),
+				Max:     int64(s.Max),
+				Samples: s.Samples,
+				Buckets: s.Buckets,
+			}
+			if d.MergeInto(m) == nil {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("%s is registered as %T not a saved %s", name, metric, s.Type)
+}
+
+// restore adds a saved count and takes the saved rates and start time.
+func (m *Meter) restore(count uint64, start int64, rates []float64) {
+	atomic.AddUint64(&m.count, count)
+	if start > 0 && start < atomic.LoadInt64(&m.startTime) {
+		atomic.StoreInt64(&m.startTime, start)
+	}
+	for i, e := range []*EWMA{m.m1Rate, m.m5Rate, m.m15Rate} {
+		atomic.StoreUint64(&e.rate, math.Float64bits(rates[i]))
+		atomic.StoreUint32(&e.initialized, 1)
+	}
+}
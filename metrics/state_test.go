@@ -0,0 +1,124 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newStateRegistry() Registry {
+	r := NewRegistry()
+	r.Add("meter", NewMeter())
+	r.Add("sampled", NewUnbiasedHistogram())
+	r.Add("bucketed", NewDefaultBucketedHistogram())
+	r.Add("gauge", NewIntegerGauge())
+	return r
+}
+
+func TestSaveLoadState(t *testing.T) {
+	r := newStateRegistry()
+	defer r.Meter("meter").Stop()
+	r.GetOrAdd("requests", func() interface{} { return NewCounter() }).(*Counter).Inc(5)
+	r.GetOrAdd("empty", func() interface{} { return NewCounter() })
+	r.Meter("meter").Update(7)
+	r.Meter("meter").tick()
+	for _, v := range []int64{10, 20, 30} {
+		r.Histogram("sampled").Update(v)
+		r.Histogram("bucketed").Update(v)
+	}
+	d := NewDistribution()
+	d.Update(2)
+	d.Update(4)
+	r.Add("dist", d)
+	r.Get("gauge").(*IntegerGauge).Set(9)
+
+	buf := &bytes.Buffer{}
+	if err := SaveState(buf, r); err != nil {
+		t.Fatal(err)
+	}
+
+	r2 := newStateRegistry()
+	defer r2.Meter("meter").Stop()
+	r2.Histogram("sampled").Update(40)
+	if err := LoadState(bytes.NewReader(buf.Bytes()), r2); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := r2.Counter("requests"); c == nil || c.Count() != 5 {
+		t.Fatalf("Expected requests of 5. Got %+v", r2.Get("requests"))
+	}
+	if r2.Get("empty") != nil {
+		t.Fatal("Expected an empty counter not to be saved")
+	}
+	m := r2.Meter("meter")
+	if m.Count() != 7 || m.OneMinuteRate() != r.Meter("meter").OneMinuteRate() {
+		t.Fatalf("Expected the meter's count and rate. Got %d and %f", m.Count(), m.OneMinuteRate())
+	}
+	if d := r2.Histogram("sampled").Distribution(); d.Count != 4 || d.Sum != 100 || d.Min != 10 || d.Max != 40 {
+		t.Fatalf("Expected the saved and new values. Got %+v", d)
+	}
+	if p := r2.Histogram("sampled").Percentiles([]float64{0.5}); p[0] != 25 {
+		t.Fatalf("Expected a median of 25. Got %d", p[0])
+	}
+	b2 := r2.Histogram("bucketed").(BucketHistogram)
+	if d := b2.Distribution(); d.Count != 3 || d.Sum != 60 || d.Min != 10 || d.Max != 30 {
+		t.Fatalf("Expected the saved values. Got %+v", d)
+	}
+	if exp := r.Histogram("bucketed").(BucketHistogram).Buckets(); !reflect.DeepEqual(b2.Buckets(), exp) {
+		t.Fatalf("Expected buckets %+v. Got %+v", exp, b2.Buckets())
+	}
+	if v := r2.Get("dist").(*Distribution).Value(); v != d.Value() {
+		t.Fatalf("Expected %+v. Got %+v", d.Value(), v)
+	}
+	if v := r2.Get("gauge").(*IntegerGauge).IntegerValue(); v != 0 {
+		t.Fatalf("Expected gauges not to be restored. Got %d", v)
+	}
+}
+
+func TestLoadStateConflict(t *testing.T) {
+	r := NewRegistry()
+	r.Add("requests", NewIntegerGauge())
+	in := `{"version":1,"metrics":{"requests":{"type":"counter","count":3},"other":{"type":"counter","count":2}}}`
+	err := LoadState(strings.NewReader(in), r)
+	if err == nil {
+		t.Fatal("Expected an error for a metric of another type")
+	}
+	if c := r.Counter("other"); c == nil || c.Count() != 2 {
+		t.Fatalf("Expected the rest to be restored. Got %+v", r.Get("other"))
+	}
+	if err := LoadState(strings.NewReader(`{"version":2}`), r); err == nil {
+		t.Fatal("Expected an error for an unknown version")
+	}
+}
+
+func TestLoadStateCounterDelta(t *testing.T) {
+	in := `{"version":1,"metrics":{"requests":{"type":"counter","count":100}}}`
+	for _, mode := range []CounterMode{CounterDelta, CounterCumulative} {
+		for _, reset := range []bool{true, false} {
+			r := NewRegistry()
+			if err := LoadState(strings.NewReader(in), r); err != nil {
+				t.Fatal(err)
+			}
+			snap := NewRegistrySnapshot(reset)
+			snap.SetCounterMode(mode)
+			total := uint64(100)
+			for _, inc := range []uint64{2, 3} {
+				r.Counter("requests").Inc(inc)
+				total += inc
+				snap.Snapshot(r)
+				exp := float64(inc)
+				if mode == CounterCumulative {
+					exp = float64(total)
+				}
+				if v := snap.Values; len(v) != 1 || v[0].Value != exp {
+					t.Fatalf("Expected %f for mode %d (reset %t). Got %+v", exp, mode, reset, v)
+				}
+			}
+		}
+	}
+}
@@ -14,8 +14,6 @@ import (
 	"net"
 	"net/http"
 	_ "net/http/pprof"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -82,17 +80,12 @@ func parseFlags() {
 	if *flagStatHatEmail == "" && (*flagLibratoUsername == "" || *flagLibratoToken == "") && *flagGraphite == "" {
 		log.Fatal("Either StatHat email, Librato username & token, or Graphite/Carbon required")
 	}
-	for _, s := range strings.Split(*flagPercentiles, ",") {
-		p, err := strconv.ParseFloat(s, 64)
-		switch {
-		case err != nil:
-			log.Fatal("Couldn't parse percentile flag: " + err.Error())
-		case p < 0 || p > 1:
-			log.Fatalf("Invalid percentile: %f", p)
-		}
-		percentiles = append(percentiles, p)
-		percentileNames = append(percentileNames, strings.Replace(s, "0.", "p", 1))
+	config, err := metrics.ParsePercentileConfig(*flagPercentiles)
+	if err != nil {
+		log.Fatal("Couldn't parse percentile flag: " + err.Error())
 	}
+	percentiles = config.Percentiles
+	percentileNames = config.Names
 }
 
 func listen() *net.UDPConn {
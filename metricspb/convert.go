@@ -0,0 +1,178 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricspb
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// FromSnapshot returns the message for a registry snapshot taken at ts.
+// Names in the form of TaggedName are split into the name and tags, and the
+// buckets of histograms are included if the snapshot keeps them (see
+// metrics.RegistrySnapshot.SetKeepBuckets) so that the receiver can merge
+// them without losing their percentiles.
+func FromSnapshot(snapshot *metrics.RegistrySnapshot, source string, ts time.Time) *Snapshot {
+	s := &Snapshot{
+		Timestamp:     ts.UnixNano(),
+		Source:        source,
+		Values:        make([]*Value, len(snapshot.Values)),
+		Distributions: make([]*Distribution, len(snapshot.Distributions)),
+	}
+	for i, v := range snapshot.Values {
+		name, tags := SplitTaggedName(v.Name)
+		s.Values[i] = &Value{Name: name, Value: v.Value, Counter: snapshot.IsCounter(v.Name), Tags: tags}
+	}
+	for i, d := range snapshot.Distributions {
+		name, tags := SplitTaggedName(d.Name)
+		s.Distributions[i] = distribution(name, d.Value)
+		s.Distributions[i].Tags = tags
+		if b := snapshot.Buckets(d.Name); len(b) > 0 {
+			s.Distributions[i].Buckets = buckets(b)
+		}
+	}
+	return s
+}
+
+// DistributionValue returns the distribution as a metrics.DistributionValue.
+func (d *Distribution) DistributionValue() metrics.DistributionValue {
+	return metrics.DistributionValue{
+		Count:    d.GetCount(),
+		Sum:      d.GetSum(),
+		Min:      d.GetMin(),
+		Max:      d.GetMax(),
+		Variance: d.GetVariance(),
+	}
+}
+
+// HistogramBuckets returns the buckets of the distribution.
+func (d *Distribution) HistogramBuckets() []metrics.HistogramBucket {
+	buckets := make([]metrics.HistogramBucket, len(d.GetBuckets()))
+	for i, b := range d.GetBuckets() {
+		upper := int64(math.MaxInt64)
+		if !math.IsInf(b.GetUpperBound(), 1) {
+			upper = int64(b.GetUpperBound())
+		}
+		buckets[i] = metrics.HistogramBucket{UpperBound: upper, Count: b.GetCount()}
+	}
+	return buckets
+}
+
+// TaggedName returns name followed by the tags sorted by key in the form
+// name;k1=v1;k2=v2, the syntax of Graphite's tagged series, so that points
+// of a metric family can be kept apart in a registry. It returns name if
+// there are no tags.
+func TaggedName(name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(';')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+// SplitTaggedName returns the name and tags of a name in the form returned
+// by TaggedName. A name whose parts after the first ';' are not all
+// key=value pairs is returned as it is with no tags.
+func SplitTaggedName(taggedName string) (string, map[string]string) {
+	i := strings.IndexByte(taggedName, ';')
+	if i < 0 {
+		return taggedName, nil
+	}
+	parts := strings.Split(taggedName[i+1:], ";")
+	tags := make(map[string]string, len(parts))
+	for _, p := range parts {
+		j := strings.IndexByte(p, '=')
+		if j <= 0 {
+			return taggedName, nil
+		}
+		tags[p[:j]] = p[j+1:]
+	}
+	return taggedName[:i], tags
+}
+
+// FromMetric returns the message for a metric from a registry, or nil if
+// the type of metric isn't known. Reading the metric does not reset it.
+func FromMetric(name string, metric interface{}) *Metric {
+	m := &Metric{Name: name}
+	switch v := metric.(type) {
+	case *metrics.EWMA:
+		m.Type = MetricType_METRIC_TYPE_GAUGE
+		m.Value = v.Rate()
+	case *metrics.EWMAGauge:
+		m.Type = MetricType_METRIC_TYPE_GAUGE
+		m.Value = v.Mean()
+	case *metrics.Meter:
+		m.Type = MetricType_METRIC_TYPE_METER
+		m.Fields = map[string]float64{
+			"count": float64(v.Count()),
+			"1m":    v.OneMinuteRate(),
+			"5m":    v.FiveMinuteRate(),
+			"15m":   v.FifteenMinuteRate(),
+			"mean":  v.MeanRate(),
+		}
+	case metrics.Histogram:
+		m.Type = MetricType_METRIC_TYPE_HISTOGRAM
+		m.Distribution = distribution(name, v.Distribution())
+		if b, ok := v.(metrics.BucketHistogram); ok {
+			m.Distribution.Buckets = buckets(b.Buckets())
+		}
+		m.Fields = make(map[string]float64, len(metrics.DefaultPercentiles))
+		for i, p := range v.Percentiles(metrics.DefaultPercentiles) {
+			m.Fields[metrics.DefaultPercentileNames[i]] = float64(p)
+		}
+	case metrics.DistributionMetric:
+		// Before CounterMetric since a *Distribution also has a Count method
+		m.Type = MetricType_METRIC_TYPE_DISTRIBUTION
+		m.Distribution = distribution(name, v.Value())
+	case metrics.CounterMetric:
+		m.Type = MetricType_METRIC_TYPE_COUNTER
+		m.Value = float64(v.Count())
+	case metrics.GaugeMetric:
+		m.Type = MetricType_METRIC_TYPE_GAUGE
+		m.Value = v.Value()
+	default:
+		return nil
+	}
+	return m
+}
+
+func distribution(name string, v metrics.DistributionValue) *Distribution {
+	return &Distribution{
+		Name:     name,
+		Count:    v.Count,
+		Sum:      v.Sum,
+		Min:      v.Min,
+		Max:      v.Max,
+		Variance: v.Variance,
+	}
+}
+
+func buckets(hb []metrics.HistogramBucket) []*Bucket {
+	buckets := make([]*Bucket, len(hb))
+	for i, b := range hb {
+		upper := float64(b.UpperBound)
+		if b.UpperBound == math.MaxInt64 {
+			upper = math.Inf(1)
+		}
+		buckets[i] = &Bucket{UpperBound: upper, Count: b.Count}
+	}
+	return buckets
+}
@@ -0,0 +1,10 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package metricspb holds the protocol buffer messages and gRPC services
+// used to stream metrics to a collector and to serve a registry, and an
+// Encoder and Decoder for streams of snapshots.
+package metricspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
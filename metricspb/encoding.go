@@ -0,0 +1,59 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricspb
+
+import (
+	"bufio"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// MaxEncodedSize is the largest snapshot a Decoder accepts.
+const MaxEncodedSize = 16 << 20
+
+// Encoder writes snapshots to a stream such as a file, socket or message
+// queue. Each snapshot is prefixed with its size as a varint, the framing
+// of protodelim and Java's writeDelimitedTo, so that a stream can hold any
+// number of them.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a snapshot.
+func (e *Encoder) Encode(s *Snapshot) error {
+	_, err := protodelim.MarshalTo(e.w, s)
+	return err
+}
+
+// Decoder reads snapshots written by an Encoder.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder that reads from r. It may read more from r
+// than the snapshots it returns.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br}
+}
+
+// Decode reads the next snapshot. It returns io.EOF once there are no more
+// and io.ErrUnexpectedEOF if the stream ends within one.
+func (d *Decoder) Decode() (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := (protodelim.UnmarshalOptions{MaxSize: MaxEncodedSize}).UnmarshalFrom(d.r, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricspb
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEncoder(t *testing.T) {
+	snapshots := []*Snapshot{
+		{
+			Timestamp: 1,
+			Source:    "a",
+			Tags:      map[string]string{"env": "prod"},
+			Values:    []*Value{{Name: "requests", Value: 3, Counter: true, Tags: map[string]string{"route": "/"}}},
+		},
+		{
+			Timestamp:     2,
+			Distributions: []*Distribution{{Name: "latency", Count: 2, Buckets: []*Bucket{{UpperBound: 12, Count: 2}}}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	for _, s := range snapshots {
+		if err := enc.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	for _, exp := range snapshots {
+		s, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(s, exp) {
+			t.Fatalf("Expected %+v. Got %+v", exp, s)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("Expected io.EOF. Got %+v", err)
+	}
+
+	dec = NewDecoder(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	dec.Decode()
+	if _, err := dec.Decode(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF for a truncated snapshot. Got %+v", err)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := metrics.NewDefaultBucketedHistogram()
+	h.Update(10)
+	h.Update(math.MaxInt64)
+	m := FromMetric("latency", h)
+	if n := len(m.Distribution.Buckets); n != 2 || !math.IsInf(m.Distribution.Buckets[1].UpperBound, 1) {
+		t.Fatalf("Expected 2 buckets ending at +Inf. Got %+v", m.Distribution.Buckets)
+	}
+	if b := m.Distribution.HistogramBuckets(); !reflect.DeepEqual(b, h.(metrics.BucketHistogram).Buckets()) {
+		t.Fatalf("Expected %+v. Got %+v", h.(metrics.BucketHistogram).Buckets(), b)
+	}
+}
+
+func TestTaggedName(t *testing.T) {
+	if n := TaggedName("requests", nil); n != "requests" {
+		t.Fatalf("Expected requests. Got %s", n)
+	}
+	if n := TaggedName("requests", map[string]string{"route": "/", "code": "200"}); n != "requests;code=200;route=/" {
+		t.Fatalf("Expected requests;code=200;route=/. Got %s", n)
+	}
+	tags := map[string]string{"route": "/", "code": "200"}
+	if name, got := SplitTaggedName(TaggedName("requests", tags)); name != "requests" || !reflect.DeepEqual(got, tags) {
+		t.Fatalf("Expected requests and %+v. Got %s and %+v", tags, name, got)
+	}
+	if name, got := SplitTaggedName("odd;name"); name != "odd;name" || got != nil {
+		t.Fatalf("Expected odd;name without tags. Got %s and %+v", name, got)
+	}
+}
+
+func TestFromSnapshotRoundTrip(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := metrics.NewBucketedHistogram([]int64{10, 100})
+	registry.Add(TaggedName("latency", map[string]string{"route": "/"}), h)
+	c := metrics.NewCounter()
+	c.Inc(2)
+	registry.Add(TaggedName("requests", map[string]string{"code": "200"}), c)
+	for _, v := range []int64{5, 50, 500} {
+		h.Update(v)
+	}
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.SetKeepBuckets(true)
+	snapshot.Snapshot(registry)
+	b, err := proto.Marshal(FromSnapshot(snapshot, "a", time.Unix(1, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Snapshot{}
+	if err := proto.Unmarshal(b, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Distributions) != 1 {
+		t.Fatalf("Expected 1 distribution. Got %+v", s.Distributions)
+	}
+	d := s.Distributions[0]
+	if d.Name != "latency" || d.Tags["route"] != "/" || d.Count != 3 {
+		t.Fatalf("Expected tagged latency. Got %+v", d)
+	}
+	exp := []metrics.HistogramBucket{{UpperBound: 10, Count: 1}, {UpperBound: 100, Count: 1}, {UpperBound: math.MaxInt64, Count: 1}}
+	if got := d.HistogramBuckets(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Expected buckets %+v. Got %+v", exp, got)
+	}
+	found := false
+	for _, v := range s.Values {
+		if v.Name == "requests" {
+			found = true
+			if v.Tags["code"] != "200" || !v.Counter || v.Value != 2 {
+				t.Fatalf("Expected tagged requests counter. Got %+v", v)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("Expected requests. Got %+v", s.Values)
+	}
+}
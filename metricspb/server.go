@@ -0,0 +1,79 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricspb
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultWatchInterval = 5 * time.Second
+	minWatchInterval     = 100 * time.Millisecond
+)
+
+type registryServer struct {
+	UnimplementedRegistryServer
+	registry metrics.Registry
+}
+
+// NewRegistryServer returns a RegistryServer serving the metrics of
+// registry, to be registered with RegisterRegistryServer.
+func NewRegistryServer(registry metrics.Registry) RegistryServer {
+	return &registryServer{registry: registry}
+}
+
+func (s *registryServer) ListMetrics(ctx context.Context, req *ListMetricsRequest) (*ListMetricsResponse, error) {
+	return s.list(req.GetPrefix()), nil
+}
+
+func (s *registryServer) GetMetric(ctx context.Context, req *GetMetricRequest) (*Metric, error) {
+	if metric := s.registry.Get(req.GetName()); metric != nil {
+		if m := FromMetric(req.GetName(), metric); m != nil {
+			return m, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "metric %q not found", req.GetName())
+}
+
+func (s *registryServer) WatchMetrics(req *WatchMetricsRequest, stream Registry_WatchMetricsServer) error {
+	interval := defaultWatchInterval
+	if ms := req.GetIntervalMillis(); ms > 0 {
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	if interval < minWatchInterval {
+		interval = minWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(s.list(req.GetPrefix())); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *registryServer) list(prefix string) *ListMetricsResponse {
+	res := &ListMetricsResponse{Timestamp: time.Now().UnixNano()}
+	s.registry.DoSorted(func(name string, metric interface{}) error {
+		if strings.HasPrefix(name, prefix) {
+			if m := FromMetric(name, metric); m != nil {
+				res.Metrics = append(res.Metrics, m)
+			}
+		}
+		return nil
+	})
+	return res
+}
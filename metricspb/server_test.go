@@ -0,0 +1,85 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricspb
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRegistryServer(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("http/requests", counter)
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(10)
+	registry.Add("http/latency", h)
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(7)
+	registry.Add("db/conns", gauge)
+
+	ln := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	RegisterRegistryServer(server, NewRegistryServer(registry))
+	go server.Serve(ln)
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewRegistryClient(conn)
+	ctx := context.Background()
+
+	list, err := client.ListMetrics(ctx, &ListMetricsRequest{Prefix: "http/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Metrics) != 2 || list.Metrics[0].Name != "http/latency" || list.Metrics[1].Name != "http/requests" {
+		t.Fatalf("Expected the http metrics. Got %+v", list.Metrics)
+	}
+
+	m, err := client.GetMetric(ctx, &GetMetricRequest{Name: "http/latency"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != MetricType_METRIC_TYPE_HISTOGRAM || m.Distribution.GetCount() != 1 || m.Fields["p99"] != 10 {
+		t.Fatalf("Expected a histogram with one value. Got %+v", m)
+	}
+	if h.Distribution().Count != 1 {
+		t.Fatal("Expected histogram not to be cleared")
+	}
+	if _, err := client.GetMetric(ctx, &GetMetricRequest{Name: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound. Got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchMetrics(ctx, &WatchMetricsRequest{Prefix: "db/", IntervalMillis: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 2; i++ {
+		gauge.Set(i)
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Metrics) != 1 || res.Metrics[0].Type != MetricType_METRIC_TYPE_GAUGE {
+			t.Fatalf("Expected the db/conns gauge. Got %+v", res.Metrics)
+		}
+	}
+}
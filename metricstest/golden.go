@@ -0,0 +1,95 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricstest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+var updateGolden = flag.Bool("metricstest.update", false, "rewrite golden files with the current snapshots")
+
+// WriteCanonical writes snapshot to w in a deterministic text form suited
+// to comparing with a golden file. The first line is the time now, which
+// tests should pass from a fixed clock. Then there's a line for each value
+// and distribution sorted by name:
+//
+//	counter requests 5
+//	gauge pool/size 7.5
+//	distribution latency count=2 sum=30 min=10 max=20 stddev=7.0710678118654755
+//
+// Floats are formatted with the fewest digits that represent them exactly.
+func WriteCanonical(w io.Writer, snapshot *metrics.RegistrySnapshot, now time.Time) error {
+	type line struct {
+		name, text string
+	}
+	lines := make([]line, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		typ := "gauge"
+		if snapshot.IsCounter(v.Name) {
+			typ = "counter"
+		}
+		lines = append(lines, line{v.Name, typ + " " + v.Name + " " + formatFloat(v.Value)})
+	}
+	for _, d := range snapshot.Distributions {
+		v := d.Value
+		lines = append(lines, line{d.Name, fmt.Sprintf("distribution %s count=%d sum=%s min=%s max=%s stddev=%s",
+			d.Name, v.Count, formatFloat(v.Sum), formatFloat(v.Min), formatFloat(v.Max), formatFloat(math.Sqrt(v.Variance)))})
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].name < lines[j].name
+	})
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "time %s\n", now.UTC().Format(time.RFC3339Nano))
+	for _, l := range lines {
+		b.WriteString(l.text)
+		b.WriteByte('\n')
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// AssertGolden fails the test unless the canonical form of snapshot, as
+// written by WriteCanonical, is the same as the contents of the golden file
+// at path. Running the tests with -metricstest.update writes the file
+// instead, creating any missing directories.
+func AssertGolden(t testing.TB, snapshot *metrics.RegistrySnapshot, path string, now time.Time) {
+	t.Helper()
+	got := &bytes.Buffer{}
+	if err := WriteCanonical(got, snapshot, now); err != nil {
+		t.Fatal(err)
+	}
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s (run with -metricstest.update to create it)", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("Snapshot doesn't match %s (run with -metricstest.update to update it)\nExpected:\n%s\nGot:\n%s", path, want, got)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricstest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestGolden(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.NewCounter()
+	c.Inc(5)
+	reg.Add("requests", c)
+	reg.Add("pool/size", metrics.GaugeValue(7.5))
+	d := metrics.NewDistribution()
+	d.Update(10)
+	d.Update(20)
+	reg.Add("latency", d)
+	snapshot := metrics.NewRegistrySnapshot(false)
+	snapshot.Snapshot(reg)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	b := &bytes.Buffer{}
+	if err := WriteCanonical(b, snapshot, now); err != nil {
+		t.Fatal(err)
+	}
+	exp := `time 2020-01-02T03:04:05Z
+distribution latency count=2 sum=30 min=10 max=20 stddev=7.0710678118654755
+gauge pool/size 7.5
+counter requests 5
+`
+	if b.String() != exp {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", exp, b)
+	}
+
+	path := filepath.Join(t.TempDir(), "testdata", "snapshot.golden")
+	*updateGolden = true
+	AssertGolden(t, snapshot, path, now)
+	*updateGolden = false
+	if got, err := os.ReadFile(path); err != nil || string(got) != exp {
+		t.Fatalf("Expected golden file to be written. Got %q, %+v", got, err)
+	}
+	AssertGolden(t, snapshot, path, now)
+}
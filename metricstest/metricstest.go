@@ -0,0 +1,217 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package metricstest helps tests check the metrics recorded by the code
+// under test, either directly in a registry:
+//
+//	metricstest.AssertCounter(t, registry, "requests", 5)
+//	metricstest.AssertHistogramP99Below(t, registry, "latency", 1000)
+//
+// or in what a reporter would report, without waiting for its interval:
+//
+//	r, rec := metricstest.NewReporter(registry)
+//	r.Flush()
+//	metricstest.AssertValues(t, rec.Last(), map[string]float64{"requests": 5}, metricstest.Tolerance{})
+//
+// AssertGolden compares a snapshot with a golden file to catch changes to
+// the names and values that dashboards depend on.
+package metricstest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/reporter"
+)
+
+// Recorder is a reporter.Backend that keeps every snapshot it's given in
+// memory.
+type Recorder struct {
+	mu        sync.Mutex
+	snapshots []*metrics.RegistrySnapshot
+}
+
+// NewReporter returns a reporter of registry to a new Recorder. The
+// reporter isn't started: call Flush to report.
+func NewReporter(registry metrics.Registry) (*reporter.PeriodicReporter, *Recorder) {
+	rec := &Recorder{}
+	return reporter.NewPeriodicReporter(registry, time.Hour, false, false, rec), rec
+}
+
+// Report keeps a copy of snapshot.
+func (r *Recorder) Report(snapshot *metrics.RegistrySnapshot) {
+	s := snapshot.Filter(func(string) bool { return true })
+	r.mu.Lock()
+	r.snapshots = append(r.snapshots, s)
+	r.mu.Unlock()
+}
+
+// Snapshots returns the snapshots reported so far, oldest first.
+func (r *Recorder) Snapshots() []*metrics.RegistrySnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*metrics.RegistrySnapshot(nil), r.snapshots...)
+}
+
+// Last returns the latest snapshot or nil if nothing has been reported.
+func (r *Recorder) Last() *metrics.RegistrySnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.snapshots) == 0 {
+		return nil
+	}
+	return r.snapshots[len(r.snapshots)-1]
+}
+
+// Reset forgets the snapshots reported so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.snapshots = nil
+	r.mu.Unlock()
+}
+
+// Tolerance is how far a value may be from the expected value and still
+// be equal to it: within Absolute or within Relative times the expected
+// value. The zero Tolerance requires values to be exactly equal.
+type Tolerance struct {
+	Absolute float64
+	Relative float64
+}
+
+// Equal returns true if got is within the tolerance of want.
+func (tol Tolerance) Equal(want, got float64) bool {
+	if want == got {
+		return true
+	}
+	diff := math.Abs(want - got)
+	return diff <= tol.Absolute || diff <= tol.Relative*math.Abs(want)
+}
+
+// CompareValues compares the values in reg, such as a snapshot, to want and
+// returns a description of each one that's missing or not within tol.
+func CompareValues(reg metrics.Registry, want map[string]float64, tol Tolerance) []string {
+	names := make([]string, 0, len(want))
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var diffs []string
+	for _, name := range names {
+		got, err := value(reg, name)
+		if err != nil {
+			diffs = append(diffs, err.Error())
+		} else if !tol.Equal(want[name], got) {
+			diffs = append(diffs, fmt.Sprintf("%s is %v, expected %v", name, got, want[name]))
+		}
+	}
+	return diffs
+}
+
+// AssertValues fails the test unless the values in reg, such as a snapshot,
+// are within tol of want. Values not in want aren't checked.
+func AssertValues(t testing.TB, reg metrics.Registry, want map[string]float64, tol Tolerance) {
+	t.Helper()
+	if s, ok := reg.(*metrics.RegistrySnapshot); reg == nil || ok && s == nil {
+		t.Fatal("metricstest: nothing has been reported")
+	}
+	for _, diff := range CompareValues(reg, want, tol) {
+		t.Error(diff)
+	}
+}
+
+// AssertCounter fails the test unless the counter name in reg is want.
+func AssertCounter(t testing.TB, reg metrics.Registry, name string, want uint64) {
+	t.Helper()
+	got, err := value(reg, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != float64(want) {
+		t.Fatalf("%s is %v, expected %d", name, got, want)
+	}
+}
+
+// AssertGauge fails the test unless the gauge name in reg is within tol of
+// want.
+func AssertGauge(t testing.TB, reg metrics.Registry, name string, want float64, tol Tolerance) {
+	t.Helper()
+	got, err := value(reg, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tol.Equal(want, got) {
+		t.Fatalf("%s is %v, expected %v", name, got, want)
+	}
+}
+
+// AssertHistogramCount fails the test unless the histogram name in reg has
+// recorded want values.
+func AssertHistogramCount(t testing.TB, reg metrics.Registry, name string, want uint64) {
+	t.Helper()
+	h := histogram(t, reg, name)
+	if got := h.Distribution().Count; got != want {
+		t.Fatalf("%s has %d values, expected %d", name, got, want)
+	}
+}
+
+// AssertHistogramP99Below fails the test unless the 99th percentile of the
+// histogram name in reg is less than max.
+func AssertHistogramP99Below(t testing.TB, reg metrics.Registry, name string, max int64) {
+	t.Helper()
+	h := histogram(t, reg, name)
+	if h.Distribution().Count == 0 {
+		t.Fatalf("%s has no values", name)
+	}
+	if p99 := h.Percentiles([]float64{0.99})[0]; p99 >= max {
+		t.Fatalf("%s p99 is %d, expected below %d", name, p99, max)
+	}
+}
+
+func histogram(t testing.TB, reg metrics.Registry, name string) metrics.Histogram {
+	t.Helper()
+	metric := lookup(reg, name)
+	if metric == nil {
+		t.Fatalf("%s is not registered", name)
+	}
+	h, ok := metric.(metrics.Histogram)
+	if !ok {
+		t.Fatalf("%s is a %T not a histogram", name, metric)
+	}
+	return h
+}
+
+// value returns the value of the counter or gauge name in reg.
+func value(reg metrics.Registry, name string) (float64, error) {
+	switch m := lookup(reg, name).(type) {
+	case nil:
+		return 0, fmt.Errorf("%s is not registered", name)
+	case metrics.CounterMetric:
+		return float64(m.Count()), nil
+	case metrics.GaugeMetric:
+		return m.Value(), nil
+	default:
+		return 0, fmt.Errorf("%s is a %T not a counter or gauge", name, m)
+	}
+}
+
+// lookup returns the metric name in reg, including metrics of collections
+// and scopes that Get doesn't find.
+func lookup(reg metrics.Registry, name string) interface{} {
+	if m := reg.Get(name); m != nil {
+		return m
+	}
+	var found interface{}
+	reg.Do(func(n string, metric interface{}) error {
+		if n == name {
+			found = metric
+		}
+		return nil
+	})
+	return found
+}
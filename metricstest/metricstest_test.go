@@ -0,0 +1,58 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package metricstest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestAssertions(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.NewCounter()
+	c.Inc(5)
+	reg.Add("requests", c)
+	reg.Add("pool/size", metrics.GaugeValue(7.5))
+	h := metrics.NewUnbiasedHistogram()
+	for i := int64(1); i <= 100; i++ {
+		h.Update(i)
+	}
+	reg.Add("latency", h)
+
+	AssertCounter(t, reg, "requests", 5)
+	AssertGauge(t, reg, "pool/size", 7, Tolerance{Absolute: 0.5})
+	AssertHistogramCount(t, reg, "latency", 100)
+	AssertHistogramP99Below(t, reg, "latency", 101)
+
+	r, rec := NewReporter(reg)
+	if rec.Last() != nil {
+		t.Fatal("Expected no snapshot before reporting")
+	}
+	r.Flush()
+	c.Inc(2)
+	r.Flush()
+	if n := len(rec.Snapshots()); n != 2 {
+		t.Fatalf("Expected 2 snapshots. Got %d", n)
+	}
+	AssertValues(t, rec.Snapshots()[0], map[string]float64{"requests": 5, "latency/p50": 50}, Tolerance{Relative: 0.1})
+	AssertValues(t, rec.Last(), map[string]float64{"requests": 2}, Tolerance{})
+	rec.Reset()
+	if rec.Last() != nil {
+		t.Fatal("Expected no snapshot after Reset")
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.Add("a", metrics.GaugeValue(100))
+	reg.Add("b", metrics.GaugeValue(1))
+	diffs := CompareValues(reg, map[string]float64{"a": 105, "b": 2, "c": 0}, Tolerance{Relative: 0.1})
+	exp := []string{"b is 1, expected 2", "c is not registered"}
+	if !reflect.DeepEqual(diffs, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, diffs)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package otelmetrics bridges a metrics.Registry into an OpenTelemetry SDK
+// pipeline so that its metrics are exported by the SDK's exporters along
+// with the service's OpenTelemetry instruments instead of by a second set
+// of reporters.
+//
+//	reader := metric.NewPeriodicReader(exporter,
+//		metric.WithProducer(otelmetrics.NewProducer(metrics.DefaultRegistry)))
+package otelmetrics
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// ScopeName is the name of the instrumentation scope of produced metrics.
+const ScopeName = "github.com/samuel/go-metrics"
+
+type producer struct {
+	registry metrics.Registry
+	start    time.Time
+}
+
+// NewProducer returns a producer of the metrics in reg for a metric.Reader.
+// Metrics keep their names and are converted as follows:
+//
+//   - counters are cumulative monotonic sums
+//   - gauges and EWMAs are gauges
+//   - meters are gauges of their rates with a window attribute of 1m, 5m,
+//     15m or mean
+//   - infos are gauges of 1 with their values as attributes
+//   - histograms are summaries with the default percentiles and
+//     distributions are summaries without any
+//
+// Producing doesn't reset the metrics, so summaries cover every value
+// recorded. The start time of cumulative values is the time the producer was
+// created, or the time a *metrics.Counter was created or last reset if
+// that's later.
+func NewProducer(reg metrics.Registry) metric.Producer {
+	return &producer{registry: reg, start: time.Now()}
+}
+
+func (p *producer) Produce(ctx context.Context) ([]metricdata.ScopeMetrics, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var ms []metricdata.Metrics
+	err := p.registry.DoSorted(func(name string, metric interface{}) error {
+		if data := p.aggregation(metric, now); data != nil {
+			ms = append(ms, metricdata.Metrics{Name: name, Data: data})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		return nil, nil
+	}
+	return []metricdata.ScopeMetrics{{
+		Scope:   instrumentation.Scope{Name: ScopeName},
+		Metrics: ms,
+	}}, nil
+}
+
+func (p *producer) aggregation(metric interface{}, now time.Time) metricdata.Aggregation {
+	switch m := metric.(type) {
+	case *metrics.EWMA:
+		return gauge(m.Rate(), now)
+	case *metrics.EWMAGauge:
+		return gauge(m.Mean(), now)
+	case *metrics.Meter:
+		g := metricdata.Gauge[float64]{}
+		for _, r := range []struct {
+			window string
+			rate   float64
+		}{
+			{"1m", m.OneMinuteRate()},
+			{"5m", m.FiveMinuteRate()},
+			{"15m", m.FifteenMinuteRate()},
+			{"mean", m.MeanRate()},
+		} {
+			g.DataPoints = append(g.DataPoints, metricdata.DataPoint[float64]{
+				Attributes: attribute.NewSet(attribute.String("window", r.window)),
+				Time:       now,
+				Value:      r.rate,
+			})
+		}
+		return g
+	case metrics.Histogram:
+		dist := m.Distribution()
+		var quantiles []metricdata.QuantileValue
+		if dist.Count > 0 {
+			for i, v := range m.Percentiles(metrics.DefaultPercentiles) {
+				quantiles = append(quantiles, metricdata.QuantileValue{
+					Quantile: metrics.DefaultPercentiles[i],
+					Value:    float64(v),
+				})
+			}
+		}
+		return p.summary(dist, quantiles, now)
+	case metrics.NamedDistribution:
+		return p.summary(m.Value, nil, now)
+	case metrics.DistributionMetric:
+		// Before CounterMetric since a *Distribution also has a Count method
+		return p.summary(m.Value(), nil, now)
+	case *metrics.Counter:
+		start := p.start
+		if created := m.Created(); created.After(start) {
+			start = created
+		}
+		return sum(m.Count(), start, now)
+	case metrics.CounterMetric:
+		return sum(m.Count(), p.start, now)
+	case metrics.Info:
+		// Before GaugeMetric since an Info is a gauge of 1
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]attribute.KeyValue, len(keys))
+		for i, k := range keys {
+			attrs[i] = attribute.String(k, m[k])
+		}
+		return metricdata.Gauge[int64]{DataPoints: []metricdata.DataPoint[int64]{{
+			Attributes: attribute.NewSet(attrs...),
+			Time:       now,
+			Value:      1,
+		}}}
+	case metrics.GaugeMetric:
+		return gauge(m.Value(), now)
+	}
+	return nil
+}
+
+func gauge(value float64, now time.Time) metricdata.Gauge[float64] {
+	return metricdata.Gauge[float64]{DataPoints: []metricdata.DataPoint[float64]{{
+		Time:  now,
+		Value: value,
+	}}}
+}
+
+func sum(count uint64, start, now time.Time) metricdata.Sum[int64] {
+	return metricdata.Sum[int64]{
+		DataPoints: []metricdata.DataPoint[int64]{{
+			StartTime: start,
+			Time:      now,
+			Value:     int64(count),
+		}},
+		Temporality: metricdata.CumulativeTemporality,
+		IsMonotonic: true,
+	}
+}
+
+func (p *producer) summary(dist metrics.DistributionValue, quantiles []metricdata.QuantileValue, now time.Time) metricdata.Summary {
+	return metricdata.Summary{DataPoints: []metricdata.SummaryDataPoint{{
+		StartTime:      p.start,
+		Time:           now,
+		Count:          dist.Count,
+		Sum:            dist.Sum,
+		QuantileValues: quantiles,
+	}}}
+}
@@ -0,0 +1,66 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package otelmetrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProducer(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := metrics.NewCounter()
+	c.Inc(3)
+	r.Add("http/requests", c)
+	r.Add("pool/size", metrics.GaugeValue(7))
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(10)
+	r.Add("latency", h)
+	r.Add("build", metrics.Info{"version": "1.2"})
+
+	reader := metric.NewManualReader(metric.WithProducer(NewProducer(r)))
+	provider := metric.NewMeterProvider(metric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != ScopeName {
+		t.Fatalf("Expected one scope %s. Got %+v", ScopeName, rm.ScopeMetrics)
+	}
+	data := make(map[string]metricdata.Aggregation)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		data[m.Name] = m.Data
+	}
+
+	if s, ok := data["http/requests"].(metricdata.Sum[int64]); !ok || !s.IsMonotonic || s.DataPoints[0].Value != 3 {
+		t.Fatalf("Expected a monotonic sum of 3. Got %+v", data["http/requests"])
+	}
+	if g, ok := data["pool/size"].(metricdata.Gauge[float64]); !ok || g.DataPoints[0].Value != 7 {
+		t.Fatalf("Expected a gauge of 7. Got %+v", data["pool/size"])
+	}
+	s, ok := data["latency"].(metricdata.Summary)
+	if !ok || s.DataPoints[0].Count != 1 || s.DataPoints[0].Sum != 10 {
+		t.Fatalf("Expected a summary of one value of 10. Got %+v", data["latency"])
+	}
+	if q := s.DataPoints[0].QuantileValues; len(q) != len(metrics.DefaultPercentiles) || q[0].Value != 10 {
+		t.Fatalf("Expected quantiles of 10. Got %+v", q)
+	}
+	info, ok := data["build"].(metricdata.Gauge[int64])
+	if !ok {
+		t.Fatalf("Expected an int64 gauge. Got %+v", data["build"])
+	}
+	if v, _ := info.DataPoints[0].Attributes.Value(attribute.Key("version")); v.AsString() != "1.2" {
+		t.Fatalf("Expected version 1.2. Got %+v", info.DataPoints[0].Attributes)
+	}
+	if h.Distribution().Count != 1 {
+		t.Fatal("Expected histogram not to be cleared")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package prommetrics adapts a metrics.Registry to a prometheus.Collector
+// so that its metrics can be served by an existing promhttp endpoint along
+// with the service's other Prometheus metrics instead of from a second
+// scrape target.
+//
+//	prometheus.MustRegister(prommetrics.NewCollector(metrics.DefaultRegistry))
+package prommetrics
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+type collector struct {
+	registry metrics.Registry
+}
+
+// NewCollector returns a collector of the metrics in reg. Metrics are
+// converted the same way metrics.WritePrometheus writes them: counters are
+// counters, histograms and distributions are summaries, meters are gauges
+// with a window label, infos are gauges of 1 labeled with their values, and
+// everything else is a gauge. Names are converted with
+// metrics.PrometheusName and metrics whose converted name collides with an
+// earlier one are skipped. Collecting doesn't reset the metrics.
+//
+// The collector is unchecked since the metrics in reg can change, so the
+// Prometheus registry only reports a name that is also used by another
+// collector when gathering.
+func NewCollector(reg metrics.Registry) prometheus.Collector {
+	return &collector{registry: reg}
+}
+
+// Describe sends no descriptions, which makes the collector unchecked.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	seen := make(map[string]bool)
+	c.registry.DoSorted(func(name string, metric interface{}) error {
+		name = metrics.PrometheusName(name)
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		collect(ch, name, metric)
+		return nil
+	})
+}
+
+func collect(ch chan<- prometheus.Metric, name string, metric interface{}) {
+	switch m := metric.(type) {
+	case *metrics.EWMA:
+		gauge(ch, name, m.Rate())
+	case *metrics.EWMAGauge:
+		gauge(ch, name, m.Mean())
+	case *metrics.Meter:
+		desc := prometheus.NewDesc(name, "", []string{"window"}, nil)
+		for _, r := range []struct {
+			window string
+			rate   float64
+		}{
+			{"1m", m.OneMinuteRate()},
+			{"5m", m.FiveMinuteRate()},
+			{"15m", m.FifteenMinuteRate()},
+			{"mean", m.MeanRate()},
+		} {
+			ch <- constMetric(desc, prometheus.GaugeValue, r.rate, r.window)
+		}
+	case metrics.Histogram:
+		dist := m.Distribution()
+		var quantiles map[float64]float64
+		if dist.Count > 0 {
+			quantiles = make(map[float64]float64, len(metrics.DefaultPercentiles))
+			for i, p := range m.Percentiles(metrics.DefaultPercentiles) {
+				quantiles[metrics.DefaultPercentiles[i]] = float64(p)
+			}
+		}
+		summary(ch, name, dist, quantiles)
+	case metrics.NamedDistribution:
+		summary(ch, name, m.Value, nil)
+	case metrics.DistributionMetric:
+		// Before CounterMetric since a *Distribution also has a Count method
+		summary(ch, name, m.Value(), nil)
+	case *metrics.Counter:
+		desc := prometheus.NewDesc(name, "", nil, nil)
+		var cm prometheus.Metric
+		var err error
+		if created := m.Created(); created.IsZero() {
+			cm, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, float64(m.Count()))
+		} else {
+			cm, err = prometheus.NewConstMetricWithCreatedTimestamp(desc, prometheus.CounterValue, float64(m.Count()), created)
+		}
+		if err == nil {
+			if e := m.Exemplar(); e != nil {
+				cm, err = prometheus.NewMetricWithExemplars(cm, prometheus.Exemplar{
+					Value:     e.Value,
+					Labels:    e.Labels,
+					Timestamp: e.Timestamp,
+				})
+			}
+		}
+		if err != nil {
+			cm = prometheus.NewInvalidMetric(desc, err)
+		}
+		ch <- cm
+	case metrics.CounterMetric:
+		ch <- constMetric(prometheus.NewDesc(name, "", nil, nil), prometheus.CounterValue, float64(m.Count()))
+	case metrics.Info:
+		// Before GaugeMetric since an Info is a gauge of 1
+		names := make([]string, 0, len(m))
+		for k := range m {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		values := make([]string, len(names))
+		for i, k := range names {
+			values[i] = m[k]
+		}
+		ch <- constMetric(prometheus.NewDesc(name, "", names, nil), prometheus.GaugeValue, 1, values...)
+	case metrics.GaugeMetric:
+		gauge(ch, name, m.Value())
+	}
+}
+
+func gauge(ch chan<- prometheus.Metric, name string, value float64) {
+	ch <- constMetric(prometheus.NewDesc(name, "", nil, nil), prometheus.GaugeValue, value)
+}
+
+func summary(ch chan<- prometheus.Metric, name string, dist metrics.DistributionValue, quantiles map[float64]float64) {
+	desc := prometheus.NewDesc(name, "", nil, nil)
+	m, err := prometheus.NewConstSummary(desc, dist.Count, dist.Sum, quantiles)
+	if err != nil {
+		m = prometheus.NewInvalidMetric(desc, err)
+	}
+	ch <- m
+}
+
+// constMetric returns a constant metric, or an invalid metric reporting
+// why it couldn't be created such as for an invalid label name.
+func constMetric(desc *prometheus.Desc, typ prometheus.ValueType, value float64, labels ...string) prometheus.Metric {
+	m, err := prometheus.NewConstMetric(desc, typ, value, labels...)
+	if err != nil {
+		return prometheus.NewInvalidMetric(desc, err)
+	}
+	return m
+}
@@ -0,0 +1,57 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package prommetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestCollector(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := metrics.NewCounter()
+	c.Inc(3)
+	r.Add("http/requests", c)
+	g := metrics.NewIntegerGauge()
+	g.Set(7)
+	r.Add("pool.size", g)
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(10)
+	r.Add("latency", h)
+	r.Add("build", metrics.Info{"version": "1.2"})
+	r.Add("pool/size", metrics.GaugeValue(1))
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewCollector(r))
+	exp := `# HELP build 
+# TYPE build gauge
+build{version="1.2"} 1
+# HELP http_requests 
+# TYPE http_requests counter
+http_requests 3
+# HELP latency 
+# TYPE latency summary
+latency{quantile="0.5"} 10
+latency{quantile="0.75"} 10
+latency{quantile="0.9"} 10
+latency{quantile="0.99"} 10
+latency{quantile="0.999"} 10
+latency_sum 10
+latency_count 1
+# HELP pool_size 
+# TYPE pool_size gauge
+pool_size 7
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(exp)); err != nil {
+		t.Fatal(err)
+	}
+	if h.Distribution().Count != 1 {
+		t.Fatal("Expected histogram not to be cleared")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package rcrowleymetrics exposes the metrics of a github.com/rcrowley/go-metrics
+// registry through a metrics.Registry so that code using that package can be
+// moved over gradually while everything is reported by the reporters here.
+//
+//	registry.Add("legacy", rcrowleymetrics.NewCollection(gometrics.DefaultRegistry))
+package rcrowleymetrics
+
+import (
+	"math"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// NewCollection returns a collection of the metrics in r, converted each
+// time it's read:
+//
+//   - counters are counters, with negative counts reported as 0
+//   - gauges and EWMAs are gauges
+//   - meters are the gauges name/1m, name/5m and name/15m of their per
+//     second rates, as a *metrics.Meter is reported
+//   - histograms are histograms, and timers are histograms of durations in
+//     microseconds
+//
+// Histograms and timers are never cleared by reporters since they may
+// still be read through r. Their distributions cover every value recorded
+// rather than those since the last report, as r's own reporters report
+// them. Health checks and metrics of other types are left out.
+func NewCollection(r gometrics.Registry) metrics.Collection {
+	return &collection{registry: r}
+}
+
+type collection struct {
+	registry gometrics.Registry
+}
+
+func (c *collection) Metrics() map[string]interface{} {
+	m := make(map[string]interface{})
+	c.registry.Each(func(name string, metric interface{}) {
+		switch v := metric.(type) {
+		case gometrics.Counter:
+			count := v.Count()
+			if count < 0 {
+				count = 0
+			}
+			m[name] = metrics.CounterValue(count)
+		case gometrics.Gauge:
+			m[name] = metrics.GaugeValue(v.Value())
+		case gometrics.GaugeFloat64:
+			m[name] = metrics.GaugeValue(v.Value())
+		case gometrics.EWMA:
+			m[name] = metrics.GaugeValue(v.Rate())
+		case gometrics.Meter:
+			s := v.Snapshot()
+			m[name+"/1m"] = metrics.GaugeValue(s.Rate1())
+			m[name+"/5m"] = metrics.GaugeValue(s.Rate5())
+			m[name+"/15m"] = metrics.GaugeValue(s.Rate15())
+		case gometrics.Histogram:
+			m[name] = &histogram{h: v}
+		case gometrics.Timer:
+			m[name] = &timer{t: v}
+		}
+	})
+	return m
+}
+
+// histogram is a metrics.Histogram reading a gometrics.Histogram.
+type histogram struct {
+	h gometrics.Histogram
+}
+
+func (h *histogram) Clear() {}
+
+func (h *histogram) Update(value int64) {
+	h.h.Update(value)
+}
+
+func (h *histogram) Distribution() metrics.DistributionValue {
+	s := h.h.Snapshot()
+	return distribution(s.Count(), s.Mean(), s.Min(), s.Max(), s.Variance(), 1)
+}
+
+func (h *histogram) Percentiles(percentiles []float64) []int64 {
+	return percentileValues(h.h.Snapshot().Percentiles(fractions(percentiles)), 1)
+}
+
+func (h *histogram) String() string {
+	return histogramString(h)
+}
+
+// timer is a metrics.Histogram reading a gometrics.Timer in microseconds.
+type timer struct {
+	t gometrics.Timer
+}
+
+const nsPerMicrosecond = float64(time.Microsecond)
+
+func (t *timer) Clear() {}
+
+func (t *timer) Update(value int64) {
+	t.t.Update(time.Duration(value) * time.Microsecond)
+}
+
+func (t *timer) Distribution() metrics.DistributionValue {
+	s := t.t.Snapshot()
+	return distribution(s.Count(), s.Mean(), s.Min(), s.Max(), s.Variance(), nsPerMicrosecond)
+}
+
+func (t *timer) Percentiles(percentiles []float64) []int64 {
+	return percentileValues(t.t.Snapshot().Percentiles(fractions(percentiles)), nsPerMicrosecond)
+}
+
+func (t *timer) String() string {
+	return histogramString(t)
+}
+
+// distribution returns a distribution of values divided by scale. The sum
+// is estimated from the mean since a gometrics sample only sums the values
+// it holds, which are fewer than count once the reservoir is full.
+func distribution(count int64, mean float64, min, max int64, variance, scale float64) metrics.DistributionValue {
+	if count <= 0 {
+		return metrics.DistributionValue{}
+	}
+	return metrics.DistributionValue{
+		Count:    uint64(count),
+		Sum:      mean * float64(count) / scale,
+		Min:      float64(min) / scale,
+		Max:      float64(max) / scale,
+		Variance: variance / (scale * scale),
+	}
+}
+
+// fractions converts percentiles given as percentages (such as 99) to
+// fractions (0.99) as gometrics expects.
+func fractions(percentiles []float64) []float64 {
+	out := make([]float64, len(percentiles))
+	for i, p := range percentiles {
+		if p > 1.0 {
+			p /= 100.0
+		}
+		out[i] = p
+	}
+	return out
+}
+
+func percentileValues(values []float64, scale float64) []int64 {
+	out := make([]int64, len(values))
+	for i, v := range values {
+		out[i] = int64(math.Round(v / scale))
+	}
+	return out
+}
+
+func histogramString(h metrics.Histogram) string {
+	b, _ := metrics.TakeSnapshot(h).MarshalJSON()
+	return string(b)
+}
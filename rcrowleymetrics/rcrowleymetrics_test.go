@@ -0,0 +1,91 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package rcrowleymetrics
+
+import (
+	"testing"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestCollection(t *testing.T) {
+	r := gometrics.NewRegistry()
+	gometrics.GetOrRegisterCounter("requests", r).Inc(3)
+	gometrics.GetOrRegisterGauge("conns", r).Update(7)
+	gometrics.GetOrRegisterGaugeFloat64("load", r).Update(0.5)
+	meter := gometrics.GetOrRegisterMeter("events", r)
+	defer meter.Stop()
+	meter.Mark(1)
+	h := gometrics.GetOrRegisterHistogram("size", r, gometrics.NewUniformSample(100))
+	for i := int64(1); i <= 100; i++ {
+		h.Update(i)
+	}
+	tm := gometrics.GetOrRegisterTimer("latency", r)
+	defer tm.Stop()
+	tm.Update(2 * time.Millisecond)
+	tm.Update(4 * time.Millisecond)
+
+	reg := metrics.NewRegistry()
+	reg.Add("legacy", NewCollection(r))
+	snap := metrics.NewRegistrySnapshot(false)
+	snap.Snapshot(reg)
+
+	values := make(map[string]float64)
+	for _, v := range snap.Values {
+		values[v.Name] = v.Value
+	}
+	for name, exp := range map[string]float64{
+		"legacy/requests":     3,
+		"legacy/conns":        7,
+		"legacy/load":         0.5,
+		"legacy/size/p999":    100,
+		"legacy/latency/p999": 4000,
+	} {
+		if values[name] != exp {
+			t.Fatalf("Expected %s to be %+v. Got %+v", name, exp, values[name])
+		}
+	}
+	if _, ok := values["legacy/events/1m"]; !ok {
+		t.Fatalf("Expected meter rates. Got %+v", values)
+	}
+	if !snap.IsCounter("legacy/requests") {
+		t.Fatal("Expected legacy/requests to be a counter")
+	}
+
+	dists := make(map[string]metrics.DistributionValue)
+	for _, d := range snap.Distributions {
+		dists[d.Name] = d.Value
+	}
+	if d := dists["legacy/latency"]; d.Count != 2 || d.Sum != 6000 || d.Min != 2000 || d.Max != 4000 {
+		t.Fatalf("Expected latency count 2, sum 6000, min 2000, max 4000. Got %+v", d)
+	}
+	if d := dists["legacy/size"]; d.Count != 100 || d.Sum != 5050 {
+		t.Fatalf("Expected size count 100 and sum 5050. Got %+v", d)
+	}
+	if h.Count() != 100 {
+		t.Fatal("Expected the histogram not to be cleared")
+	}
+}
+
+func TestHistogramSumBeyondReservoir(t *testing.T) {
+	r := gometrics.NewRegistry()
+	h := gometrics.GetOrRegisterHistogram("size", r, gometrics.NewUniformSample(1028))
+	for i := 0; i < 5000; i++ {
+		h.Update(10)
+	}
+	reg := metrics.NewRegistry()
+	reg.Add("legacy", NewCollection(r))
+	snap := metrics.NewRegistrySnapshot(false)
+	snap.Snapshot(reg)
+
+	if len(snap.Distributions) != 1 {
+		t.Fatalf("Expected 1 distribution. Got %+v", snap.Distributions)
+	}
+	if d := snap.Distributions[0].Value; d.Count != 5000 || d.Sum != 50000 || d.Mean() != 10 {
+		t.Fatalf("Expected count 5000 and sum 50000. Got %+v", d)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// BasicAuth returns an http.Handler that calls h only for requests with
+// HTTP basic authentication matching username and password. Other requests
+// are asked to authenticate for realm.
+func BasicAuth(h http.Handler, username, password, realm string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, pass, ok := req.BasicAuth()
+		// Both are compared so the time taken doesn't tell which was wrong.
+		userOK := secureCompare(user, username)
+		passOK := secureCompare(pass, password)
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+strings.Replace(realm, `"`, `'`, -1)+`"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// BearerAuth returns an http.Handler that calls h only for requests with an
+// "Authorization: Bearer <token>" header whose token validate returns true
+// for, such as by checking it against a list of API keys or verifying a JWT.
+func BearerAuth(h http.Handler, validate func(token string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) || !validate(auth[len(prefix):]) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// secureCompare compares strings in time that only depends on their
+// lengths, which are hidden by hashing them first.
+func secureCompare(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// RequestFilter returns true if the metric called name may be shown in the
+// response to req.
+type RequestFilter func(req *http.Request, name string) bool
+
+// FilteredHandler returns an http.Handler that serves each request with the
+// handler returned by newHandler, such as JSONHandler or
+// metrics.PrometheusHandler, for a view of the registry holding only the
+// metrics that filter allows for the request. It lets different callers,
+// such as ones identified by their credentials, see different metrics.
+func FilteredHandler(registry metrics.Registry, filter RequestFilter, newHandler func(metrics.Registry) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		view := metrics.NewFilterFuncRegistry(registry, func(name string) bool { return filter(req, name) })
+		newHandler(view).ServeHTTP(w, req)
+	})
+}
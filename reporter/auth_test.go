@@ -0,0 +1,87 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestBasicAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("ok")) })
+	handler := BasicAuth(ok, "admin", "secret", "metrics")
+	for _, c := range []struct {
+		user, pass string
+		code       int
+	}{
+		{"admin", "secret", 200},
+		{"admin", "wrong", 401},
+		{"other", "secret", 401},
+		{"", "", 401},
+	} {
+		req := httptest.NewRequest("GET", "/debug/metrics", nil)
+		if c.user != "" {
+			req.SetBasicAuth(c.user, c.pass)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("Expected %d for %s:%s. Got %d", c.code, c.user, c.pass, w.Code)
+		}
+		if w.Code == 401 && w.Header().Get("WWW-Authenticate") != `Basic realm="metrics"` {
+			t.Errorf("Expected a basic auth challenge. Got %q", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("ok")) })
+	handler := BearerAuth(ok, func(token string) bool { return token == "t0ken" })
+	for header, code := range map[string]int{
+		"Bearer t0ken": 200,
+		"bearer t0ken": 200,
+		"Bearer nope":  401,
+		"Bearer ":      401,
+		"Basic t0ken":  401,
+		"":             401,
+	} {
+		req := httptest.NewRequest("GET", "/debug/metrics", nil)
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("Expected %d for %q. Got %d", code, header, w.Code)
+		}
+	}
+}
+
+func TestFilteredHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("public/requests", metrics.NewCounter())
+	registry.Add("internal/queue", metrics.NewCounter())
+
+	handler := FilteredHandler(registry, func(req *http.Request, name string) bool {
+		return req.Header.Get("X-Team") == "infra" || strings.HasPrefix(name, "public/")
+	}, JSONHandler)
+
+	for team, exp := range map[string]int{"infra": 2, "web": 1} {
+		req := httptest.NewRequest("GET", "/debug/metrics", nil)
+		req.Header.Set("X-Team", team)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var out map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != exp {
+			t.Fatalf("Expected %d metrics for %s. Got %s", exp, team, w.Body)
+		}
+	}
+}
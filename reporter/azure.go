@@ -0,0 +1,190 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const (
+	azureLoginEndpoint = "https://login.microsoftonline.com/"
+	azureResource      = "https://monitoring.azure.com/"
+)
+
+// AzureTokenFunc returns an Azure Active Directory bearer token for the
+// https://monitoring.azure.com/ resource.
+type AzureTokenFunc func() (string, error)
+
+type azureReporter struct {
+	backendBase
+	endpoint  string
+	namespace string
+	tokenFunc AzureTokenFunc
+	client    *http.Client
+}
+
+type azureSeries struct {
+	DimValues []string `json:"dimValues"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     uint64   `json:"count"`
+}
+
+type azureMetric struct {
+	Time string `json:"time"`
+	Data struct {
+		BaseData struct {
+			Metric    string        `json:"metric"`
+			Namespace string        `json:"namespace"`
+			DimNames  []string      `json:"dimNames"`
+			Series    []azureSeries `json:"series"`
+		} `json:"baseData"`
+	} `json:"data"`
+}
+
+// NewAzureMonitorReporter returns a reporter that sends custom metrics to
+// Azure Monitor for the resource with the given ID (such as an AKS cluster
+// "/subscriptions/.../resourceGroups/.../providers/Microsoft.ContainerService/managedClusters/...")
+// in region using the regional ingestion endpoint. Values are sent with a
+// count of one and distributions with their count, sum, min and max.
+func NewAzureMonitorReporter(registry metrics.Registry, interval time.Duration, latched bool, region, resourceID, namespace string, tokenFunc AzureTokenFunc) *PeriodicReporter {
+	endpoint := "https://" + region + ".monitoring.azure.com" + resourceID + "/metrics"
+	return NewPeriodicReporter(registry, interval, true, latched, newAzureReporter(endpoint, namespace, tokenFunc))
+}
+
+func newAzureReporter(endpoint, namespace string, tokenFunc AzureTokenFunc) *azureReporter {
+	return &azureReporter{
+		endpoint:  endpoint,
+		namespace: namespace,
+		tokenFunc: tokenFunc,
+		client:    &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+func (r *azureReporter) metric(name, ts string, series azureSeries) *azureMetric {
+	m := &azureMetric{Time: ts}
+	m.Data.BaseData.Metric = name
+	m.Data.BaseData.Namespace = r.namespace
+	m.Data.BaseData.DimNames = []string{}
+	series.DimValues = []string{}
+	m.Data.BaseData.Series = []azureSeries{series}
+	return m
+}
+
+func (r *azureReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	token, err := r.tokenFunc()
+	if err != nil {
+		r.errorf("", "azure: failed to get token: %w", err)
+		r.drop(snapshot.Len())
+		return
+	}
+	ts := time.Now().UTC().Format(time.RFC3339)
+	// The custom metrics API accepts a single metric per request.
+	for _, v := range snapshot.Values {
+		m := r.metric(v.Name, ts, azureSeries{Min: v.Value, Max: v.Value, Sum: v.Value, Count: 1})
+		if err := r.send(func() error { return r.post(token, m) }); err != nil {
+			r.errorf(v.Name, "azure: failed to send metric %s: %w", v.Name, err)
+			r.drop(1)
+		}
+	}
+	for _, v := range snapshot.Distributions {
+		if v.Value.Count == 0 {
+			continue
+		}
+		m := r.metric(v.Name, ts, azureSeries{Min: v.Value.Min, Max: v.Value.Max, Sum: v.Value.Sum, Count: v.Value.Count})
+		if err := r.send(func() error { return r.post(token, m) }); err != nil {
+			r.errorf(v.Name, "azure: failed to send metric %s: %w", v.Name, err)
+			r.drop(1)
+		}
+	}
+}
+
+func (r *azureReporter) post(token string, m *azureMetric) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+// AzureClientCredentials returns an AzureTokenFunc that authenticates a
+// service principal with Azure Active Directory using the client credentials
+// grant. Tokens are cached until shortly before they expire.
+func AzureClientCredentials(tenantID, clientID, clientSecret string) AzureTokenFunc {
+	return azureClientCredentials(azureLoginEndpoint+tenantID+"/oauth2/token", clientID, clientSecret)
+}
+
+func azureClientCredentials(tokenURL, clientID, clientSecret string) AzureTokenFunc {
+	var (
+		mu      sync.Mutex
+		token   string
+		expires time.Time
+	)
+	client := &http.Client{Timeout: 15 * time.Second}
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if token != "" && time.Now().Before(expires) {
+			return token, nil
+		}
+		res, err := client.PostForm(tokenURL, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"resource":      {azureResource},
+		})
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			msg, _ := ioutil.ReadAll(res.Body)
+			return "", fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+		}
+		var body struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   string `json:"expires_in"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			return "", err
+		}
+		expiresIn, _ := strconv.Atoi(body.ExpiresIn)
+		token = body.AccessToken
+		// Refresh a minute early so a token doesn't expire mid-report.
+		expires = time.Now().Add(time.Duration(expiresIn)*time.Second - time.Minute)
+		return token, nil
+	}
+}
+
+func (r *azureReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
@@ -0,0 +1,59 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestAzureMonitorReporter(t *testing.T) {
+	tokenRequests := 0
+	var auth []string
+	var sent []azureMetric
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/token":
+			tokenRequests++
+			if req.FormValue("client_id") != "id" || req.FormValue("resource") != azureResource {
+				t.Errorf("Unexpected token request %+v", req.Form)
+			}
+			w.Write([]byte(`{"access_token":"tok","expires_in":"3600"}`))
+		case "/metrics":
+			auth = append(auth, req.Header.Get("Authorization"))
+			var m azureMetric
+			json.NewDecoder(req.Body).Decode(&m)
+			sent = append(sent, m)
+		}
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	dist := metrics.NewDistribution()
+	dist.Update(2)
+	dist.Update(6)
+	registry.Add("latency", dist)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	r := newAzureReporter(server.URL+"/metrics", "app", azureClientCredentials(server.URL+"/token", "id", "secret"))
+	r.Report(snapshot)
+	r.Report(snapshot)
+
+	if tokenRequests != 1 {
+		t.Fatalf("Expected token to be cached. Got %d requests", tokenRequests)
+	}
+	if len(sent) != 2 || auth[0] != "Bearer tok" {
+		t.Fatalf("Unexpected requests %+v %+v", sent, auth)
+	}
+	b := sent[0].Data.BaseData
+	if b.Metric != "latency" || b.Namespace != "app" || b.Series[0].Count != 2 || b.Series[0].Sum != 8 || b.Series[0].Max != 6 {
+		t.Fatalf("Unexpected metric %+v", b)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// ErrorHandler is called when a backend fails to send metrics. The metric
+// is the name of the metric that failed or empty if the failure was not
+// for a single metric.
+type ErrorHandler func(metric string, err error)
+
+// backendBase is embedded in backends to hold the settings shared by all
+// of them. It counts errors and passes them to the configured ErrorHandler,
+// logging them if there is none, and retries sends.
+type backendBase struct {
+	handler ErrorHandler
+	logger  Logger
+	retry   *RetryPolicy
+	count   uint64
+	bytes   uint64
+	dropped uint64
+}
+
+// defaultHTTPTimeout is the timeout of the HTTP client used by backends
+// unless one is provided.
+const defaultHTTPTimeout = 15 * time.Second
+
+// baser is implemented by backends that embed a backendBase.
+type baser interface {
+	base() *backendBase
+}
+
+// httpClientSetter is implemented by backends that make HTTP requests.
+type httpClientSetter interface {
+	setHTTPClient(client *http.Client)
+}
+
+// packetSizer is implemented by backends that batch datapoints into
+// packets.
+type packetSizer interface {
+	setMaxPacketSize(size int)
+}
+
+// closer is implemented by backends that hold connections open between
+// reports.
+type closer interface {
+	close()
+}
+
+// unwrapper is implemented by decorators to return the backend they wrap.
+type unwrapper interface {
+	unwrap() Backend
+}
+
+// innermost returns the backend underneath any decorators.
+func innermost(b Backend) Backend {
+	for {
+		u, ok := b.(unwrapper)
+		if !ok {
+			return b
+		}
+		b = u.unwrap()
+	}
+}
+
+func (e *backendBase) base() *backendBase {
+	return e
+}
+
+// errorf formats an error, wrapping any %w argument, and reports it.
+func (e *backendBase) errorf(metric, format string, args ...interface{}) {
+	atomic.AddUint64(&e.count, 1)
+	err := fmt.Errorf(format, args...)
+	if e.handler != nil {
+		e.handler(metric, err)
+	} else {
+		e.logf("%s", err.Error())
+	}
+}
+
+// logf logs to the configured Logger or the standard logger.
+func (e *backendBase) logf(format string, args ...interface{}) {
+	if e.logger != nil {
+		e.logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+func (e *backendBase) errorCount() uint64 {
+	return atomic.LoadUint64(&e.count)
+}
+
+// sent records n bytes sent to the backend.
+func (e *backendBase) sent(n int) {
+	atomic.AddUint64(&e.bytes, uint64(n))
+}
+
+// drop records n datapoints that were given up on.
+func (e *backendBase) drop(n int) {
+	atomic.AddUint64(&e.dropped, uint64(n))
+}
+
+// finite returns the snapshot without the values and distributions that
+// aren't finite, which JSON and most line protocols can't encode, and
+// counts them as dropped.
+func (e *backendBase) finite(snapshot *metrics.RegistrySnapshot) *metrics.RegistrySnapshot {
+	var skip map[string]bool
+	for _, v := range snapshot.Values {
+		if !isFinite(v.Value) {
+			if skip == nil {
+				skip = make(map[string]bool)
+			}
+			skip[v.Name] = true
+		}
+	}
+	for _, v := range snapshot.Distributions {
+		d := v.Value
+		if !isFinite(d.Sum) || !isFinite(d.Min) || !isFinite(d.Max) || !isFinite(d.Variance) {
+			if skip == nil {
+				skip = make(map[string]bool)
+			}
+			skip[v.Name] = true
+		}
+	}
+	if skip == nil {
+		return snapshot
+	}
+	out := snapshot.Filter(func(name string) bool { return !skip[name] })
+	e.drop(snapshot.Len() - out.Len())
+	return out
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// send calls f, retrying failures according to the retry policy.
+func (e *backendBase) send(f func() error) error {
+	return e.retry.do(f)
+}
@@ -0,0 +1,123 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+type failingBackend struct {
+	backendBase
+}
+
+var errBackend = errors.New("backend down")
+
+func (b *failingBackend) Report(snapshot *metrics.RegistrySnapshot) {
+	for _, v := range snapshot.Values {
+		b.errorf(v.Name, "failing: failed to post metric %s: %w", v.Name, errBackend)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("requests", metrics.NewCounter())
+
+	var failed []string
+	r := NewPeriodicReporter(registry, time.Minute, false, true, FilterBackend(&failingBackend{}, nil, nil))
+	r.SetErrorHandler(func(metric string, err error) {
+		if !errors.Is(err, errBackend) {
+			t.Errorf("Expected wrapped backend error. Got %s", err)
+		}
+		failed = append(failed, metric)
+	})
+	r.Flush()
+	r.Flush()
+
+	if len(failed) != 2 || failed[0] != "requests" {
+		t.Fatalf("Expected two failures for requests. Got %+v", failed)
+	}
+	if n := r.ErrorCount(); n != 2 {
+		t.Fatalf("Expected error count of 2. Got %d", n)
+	}
+}
+
+func TestHTTPBackendDrops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	registry.Add("gauge", metrics.GaugeValue(1))
+	dist := metrics.NewDistribution()
+	dist.Update(1)
+	registry.Add("dist", dist)
+	token := func() (string, error) { return "token", nil }
+
+	for name, b := range map[string]Backend{
+		"datadog":       newDatadogReporter(server.URL, "key", "host", nil, time.Minute),
+		"appoptics":     newAppOpticsReporter(server.URL, "token", nil, nil),
+		"opentsdb":      newOpenTSDBReporter(server.URL, nil, nil),
+		"elasticsearch": newElasticsearchReporter(server.URL, "metrics", "", ""),
+		"googlecloud":   newGoogleCloudReporter(server.URL, server.Client(), "project", "global", nil),
+		"azure":         newAzureReporter(server.URL, "namespace", token),
+		"stathat":       &statHatReporter{email: "me@example.com", endpoint: server.URL, client: server.Client()},
+		"webhook":       innermost(NewWebhookReporter(registry, time.Minute, true, WebhookConfig{URL: server.URL}).reporter),
+	} {
+		snapshot := metrics.NewRegistrySnapshot(true)
+		snapshot.Snapshot(registry)
+		base := b.(baser).base()
+		base.handler = func(metric string, err error) {}
+		b.Report(snapshot)
+		if base.errorCount() == 0 || base.dropped == 0 {
+			t.Errorf("%s: expected errors and dropped datapoints. Got %d errors and %d dropped", name, base.errorCount(), base.dropped)
+		}
+		if base.bytes != 0 {
+			t.Errorf("%s: expected no bytes sent. Got %d", name, base.bytes)
+		}
+	}
+}
+
+func TestJSONBackendNonFinite(t *testing.T) {
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, b)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	registry.Add("ok", metrics.GaugeValue(1))
+	registry.Add("nan", metrics.GaugeValue(math.NaN()))
+	registry.Add("inf", metrics.GaugeValue(math.Inf(1)))
+
+	for name, b := range map[string]Backend{
+		"elasticsearch": newElasticsearchReporter(server.URL, "metrics", "", ""),
+		"opentsdb":      newOpenTSDBReporter(server.URL, nil, nil),
+		"webhook":       innermost(NewWebhookReporter(registry, time.Minute, true, WebhookConfig{URL: server.URL}).reporter),
+	} {
+		bodies = nil
+		snapshot := metrics.NewRegistrySnapshot(true)
+		snapshot.Snapshot(registry)
+		base := b.(baser).base()
+		base.handler = func(metric string, err error) { t.Errorf("%s: %s", name, err) }
+		b.Report(snapshot)
+		if base.dropped != 2 {
+			t.Errorf("%s: expected 2 dropped values. Got %d", name, base.dropped)
+		}
+		if n := len(bodies); n == 0 || !bytes.Contains(bodies[n-1], []byte(`"ok"`)) {
+			t.Errorf("%s: expected the finite value to be sent. Got %q", name, bodies)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package reporter
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -16,6 +15,7 @@ import (
 )
 
 type cloudWatchReporter struct {
+	backendBase
 	namespace  string
 	client     *aws4.Client
 	dimensions map[string]string
@@ -48,7 +48,7 @@ func newCloudWatchReporter(interval time.Duration, region string, authFunc AWSAu
 	}
 
 	awsTransport := &http.Transport{
-		Dial: (&net.Dialer{Timeout: timeout}).Dial,
+		Dial:                  (&net.Dialer{Timeout: timeout}).Dial,
 		ResponseHeaderTimeout: timeout,
 	}
 	awsClient := &http.Client{
@@ -100,7 +100,7 @@ func (r *cloudWatchReporter) Report(snapshot *metrics.RegistrySnapshot) {
 				case uint64:
 					params.Set(prefix+"Value", strconv.FormatUint(x, 10))
 				default:
-					log.Printf("metrics/report/cloudwatch: unrecognized value type %T", m.value)
+					r.errorf(name, "metrics/reporter/cloudwatch: unrecognized value type %T", m.value)
 				}
 			} else if m.stats.sampleCount > 0 {
 				params.Set(prefix+"StatisticValues.Sum", strconv.FormatFloat(m.stats.sum, 'E', 10, 64))
@@ -108,7 +108,7 @@ func (r *cloudWatchReporter) Report(snapshot *metrics.RegistrySnapshot) {
 				params.Set(prefix+"StatisticValues.Minimum", strconv.FormatFloat(m.stats.min, 'E', 10, 64))
 				params.Set(prefix+"StatisticValues.Maximum", strconv.FormatFloat(m.stats.max, 'E', 10, 64))
 			} else {
-				log.Printf("metrics/reporter/cloudwatch: metric %s missing value or statistics", name)
+				r.errorf(name, "metrics/reporter/cloudwatch: metric %s missing value or statistics", name)
 				continue
 			}
 			params.Set(prefix+"MetricName", name)
@@ -127,18 +127,25 @@ func (r *cloudWatchReporter) Report(snapshot *metrics.RegistrySnapshot) {
 		if securityToken != "" {
 			params.Set("SecurityToken", securityToken)
 		}
-		res, err := r.client.PostForm(r.endpoint, params)
-		if err != nil {
-			log.Printf("metrics/reporter/cloudwatch: failed to send metrics to CloudWatch: %+v", err)
+		if err := r.send(func() error { return r.post(params) }); err != nil {
+			r.errorf("", "metrics/reporter/cloudwatch: failed to send metrics to CloudWatch: %w", err)
+			r.drop(len(mets))
 		}
-		defer res.Body.Close()
-		if res.StatusCode != http.StatusOK {
-			body, err := ioutil.ReadAll(res.Body)
-			if err != nil {
-				log.Printf("metrics/reporter/cloudwatch: failed to read response body: %+v", err)
-			} else {
-				log.Printf("metrics/reporter/cloudwatch: failed to send metrics to CloudWatch: %d %s", res.StatusCode, string(body))
-			}
+	}
+}
+
+func (r *cloudWatchReporter) post(params url.Values) error {
+	res, err := r.client.PostForm(r.endpoint, params)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
 		}
+		return fmt.Errorf("%d %s", res.StatusCode, string(body))
 	}
+	return nil
 }
@@ -0,0 +1,97 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes the messages sent by the webhook, NATS and MQTT reporters.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	// ContentType is the MIME type of encoded messages.
+	ContentType() string
+}
+
+var (
+	// JSONCodec encodes messages as JSON. It's the default.
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec encodes messages as MessagePack, which is smaller and
+	// faster to encode and decode than JSON. Messages have the same fields
+	// as in JSON.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// codecSetter is implemented by backends that encode messages with a Codec.
+type codecSetter interface {
+	setCodec(codec Codec)
+}
+
+// messageCodec is embedded in backends that encode messages with a Codec.
+type messageCodec struct {
+	codec Codec // defaults to JSONCodec
+}
+
+func (c *messageCodec) setCodec(codec Codec) {
+	c.codec = codec
+}
+
+func (c *messageCodec) currentCodec() Codec {
+	if c.codec == nil {
+		return JSONCodec
+	}
+	return c.codec
+}
+
+func (c *messageCodec) marshal(v interface{}) ([]byte, error) {
+	return c.currentCodec().Marshal(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	b := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(b)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (msgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// EncodeMsgpack encodes the distribution with the same fields as its JSON
+// encoding rather than those of metrics.DistributionValue.
+func (m messageDistribution) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.Encode(map[string]interface{}{
+		"name":      m.Name,
+		"timestamp": m.Timestamp,
+		"value": map[string]interface{}{
+			"count":  m.Value.Count,
+			"sum":    m.Value.Sum,
+			"min":    m.Value.Min,
+			"max":    m.Value.Max,
+			"stddev": math.Sqrt(m.Value.Variance),
+		},
+	})
+}
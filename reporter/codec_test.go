@@ -0,0 +1,89 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func unmarshalMsgpack(b []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	b, err := MsgpackCodec.Marshal(&messageValue{Name: "requests", Timestamp: 100, Value: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := unmarshalMsgpack(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["name"] != "requests" || v["value"] != 2.0 {
+		t.Fatalf("Unexpected message %+v", v)
+	}
+	if _, ok := v["counter"]; ok {
+		t.Fatalf("Expected counter to be omitted. Got %+v", v)
+	}
+
+	d := messageDistribution{Name: "latency", Timestamp: 100, Value: metrics.DistributionValue{Count: 2, Sum: 6, Min: 2, Max: 4, Variance: 4}}
+	b, err = MsgpackCodec.Marshal(&d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dm struct {
+		Name  string `json:"name"`
+		Value struct {
+			Count  uint64  `json:"count"`
+			StdDev float64 `json:"stddev"`
+		} `json:"value"`
+	}
+	if err := unmarshalMsgpack(b, &dm); err != nil {
+		t.Fatal(err)
+	}
+	if dm.Name != "latency" || dm.Value.Count != 2 || dm.Value.StdDev != 2 {
+		t.Fatalf("Unexpected distribution %+v", dm)
+	}
+}
+
+func TestWebhookReporterCodec(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(2)
+	registry.Add("requests", counter)
+	r := NewWebhookReporter(registry, time.Minute, true, WebhookConfig{URL: server.URL})
+	r.SetCodec(MsgpackCodec)
+	r.Flush()
+	if ct := header.Get("Content-Type"); ct != "application/msgpack" {
+		t.Fatalf("Expected application/msgpack. Got %s", ct)
+	}
+	var d WebhookData
+	if err := unmarshalMsgpack(body, &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Values) != 1 || d.Values[0].Name != "requests" || d.Values[0].Value != 2 {
+		t.Fatalf("Expected requests counter. Got %+v", d)
+	}
+}
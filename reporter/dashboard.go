@@ -0,0 +1,152 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"net/http"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// DashboardHandler returns an http.Handler serving a self-contained HTML
+// page that shows the registry in a table, refreshed every few seconds,
+// with a sparkline of the recent history of each value. The page loads
+// the metrics from the same URL with "?format=json", which is served by
+// JSONHandler, so it needs nothing else to be mounted.
+func DashboardHandler(registry metrics.Registry) http.Handler {
+	jsonHandler := JSONHandler(registry)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.FormValue("format") == "json" {
+			jsonHandler.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardHTML))
+	})
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Metrics</title>
+<style>
+body { font: 13px/1.4 -apple-system, Helvetica, Arial, sans-serif; margin: 20px; color: #222; }
+h1 { font-size: 18px; }
+input { font: inherit; padding: 3px 6px; width: 300px; }
+table { border-collapse: collapse; margin-top: 10px; }
+th, td { padding: 3px 10px; border-bottom: 1px solid #eee; text-align: left; white-space: nowrap; }
+th { background: #f6f6f6; }
+td.num { text-align: right; font-family: Menlo, Consolas, monospace; }
+svg polyline { fill: none; stroke: #2a7ae2; stroke-width: 1.5; }
+#status { color: #888; margin-left: 10px; }
+</style>
+</head>
+<body>
+<h1>Metrics</h1>
+<input id="filter" placeholder="Filter by name"><span id="status"></span>
+<table>
+<thead><tr><th>Name</th><th>Value</th><th>Details</th><th>History</th></tr></thead>
+<tbody id="metrics"></tbody>
+</table>
+<script>
+var historyLength = 60, refresh = 5000, history = {};
+
+function fmt(v) {
+	if (typeof v !== "number") return String(v);
+	return Math.abs(v) >= 1e6 || (v !== 0 && Math.abs(v) < 1e-3) ? v.toExponential(3) : +v.toFixed(3) + "";
+}
+
+function sparkline(points) {
+	if (points.length < 2) return "";
+	var min = Math.min.apply(null, points), max = Math.max.apply(null, points), w = 120, h = 20;
+	var coords = points.map(function(p, i) {
+		var y = max === min ? h / 2 : h - (p - min) / (max - min) * h;
+		return (i * w / (historyLength - 1)).toFixed(1) + "," + y.toFixed(1);
+	});
+	return '<svg width="' + w + '" height="' + h + '"><polyline points="' + coords.join(" ") + '"/></svg>';
+}
+
+function cell(text, cls) {
+	var td = document.createElement("td");
+	if (cls) td.className = cls;
+	td.textContent = text;
+	return td;
+}
+
+// summary returns the value shown for a metric and the details of
+// meters, histograms and distributions. Meters show their 1 minute rate
+// and histograms and distributions their mean.
+function summary(v) {
+	if (v === null || typeof v !== "object") return {value: v, details: ""};
+	var key, value = "";
+	if ("1" in v) {
+		key = "1";
+		value = v["1"];
+	} else if ("mean" in v) {
+		key = "mean";
+		value = v.mean;
+	} else if ("count" in v && "sum" in v) {
+		// Distributions have no mean of their own
+		value = v.count > 0 ? v.sum / v.count : 0;
+	}
+	return {
+		value: value,
+		details: Object.keys(v).filter(function(k) { return k !== key; }).map(function(k) {
+			return k + "=" + fmt(v[k]);
+		}).join(" ")
+	};
+}
+
+function record(data) {
+	Object.keys(data).forEach(function(name) {
+		var value = summary(data[name]).value;
+		if (typeof value === "number") {
+			history[name] = (history[name] || []).concat([value]).slice(-historyLength);
+		}
+	});
+}
+
+function render(data) {
+	var filter = document.getElementById("filter").value, body = document.getElementById("metrics");
+	body.innerHTML = "";
+	Object.keys(data).sort().forEach(function(name) {
+		if (filter && name.indexOf(filter) < 0) return;
+		var s = summary(data[name]);
+		var tr = document.createElement("tr");
+		tr.appendChild(cell(name));
+		tr.appendChild(cell(fmt(s.value), "num"));
+		tr.appendChild(cell(s.details));
+		var spark = cell("");
+		spark.innerHTML = sparkline(history[name] || []);
+		tr.appendChild(spark);
+		body.appendChild(tr);
+	});
+}
+
+var last = {};
+function load() {
+	var req = new XMLHttpRequest();
+	req.open("GET", location.pathname + "?format=json");
+	req.onload = function() {
+		if (req.status !== 200) {
+			document.getElementById("status").textContent = "Error " + req.status;
+			return;
+		}
+		last = JSON.parse(req.responseText);
+		record(last);
+		render(last);
+		document.getElementById("status").textContent = "Updated " + new Date().toLocaleTimeString();
+	};
+	req.onerror = function() { document.getElementById("status").textContent = "Unreachable"; };
+	req.send();
+}
+document.getElementById("filter").oninput = function() { render(last); };
+load();
+setInterval(load, refresh);
+</script>
+</body>
+</html>
+`
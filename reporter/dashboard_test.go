@@ -0,0 +1,34 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("requests", metrics.NewCounter())
+	handler := DashboardHandler(registry)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/dashboard", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("Expected HTML. Got %s", ct)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<html>") || strings.Contains(body, "src=\"http") {
+		t.Fatal("Expected a self-contained HTML page")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/dashboard?format=json", nil))
+	if body := w.Body.String(); body != `{"requests":0}` {
+		t.Fatalf("Expected the registry as JSON. Got %s", body)
+	}
+}
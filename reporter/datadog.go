@@ -0,0 +1,213 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const (
+	// Datadog accepts up to 5MB of uncompressed series per request.
+	// Staying well below it also keeps the compressed body small.
+	datadogMaxPayloadSize = 2 << 20
+
+	datadogDefaultSite = "datadoghq.com"
+
+	// Series types for the v2 API.
+	datadogTypeCount = 1
+	datadogTypeGauge = 3
+)
+
+type datadogReporter struct {
+	backendBase
+	endpoint string
+	apiKey   string
+	host     string
+	tags     []string
+	interval int64
+	client   *http.Client
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type datadogResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type datadogSeries struct {
+	Metric    string            `json:"metric"`
+	Type      int               `json:"type"`
+	Interval  int64             `json:"interval,omitempty"`
+	Points    []datadogPoint    `json:"points"`
+	Tags      []string          `json:"tags,omitempty"`
+	Resources []datadogResource `json:"resources,omitempty"`
+}
+
+// NewDatadogReporter returns a reporter that posts series directly to the
+// Datadog v2 metrics API. The site selects the Datadog region (such as
+// "datadoghq.eu") and defaults to "datadoghq.com". If host is not empty it
+// is attached to every series as the host resource along with the tags.
+// Requests are gzip compressed and split into batches when large.
+func NewDatadogReporter(registry metrics.Registry, interval time.Duration, latched bool, apiKey, site, host string, tags []string) *PeriodicReporter {
+	if site == "" {
+		site = datadogDefaultSite
+	}
+	dr := newDatadogReporter("https://api."+site+"/api/v2/series", apiKey, host, tags, interval)
+	return NewPeriodicReporter(registry, interval, true, latched, dr)
+}
+
+func newDatadogReporter(endpoint, apiKey, host string, tags []string, interval time.Duration) *datadogReporter {
+	return &datadogReporter{
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		host:     host,
+		tags:     tags,
+		interval: int64(interval / time.Second),
+		client:   &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+func (r *datadogReporter) series(name string, typ int, ts int64, value float64) datadogSeries {
+	s := datadogSeries{
+		Metric:   strings.Replace(name, "/", ".", -1),
+		Type:     typ,
+		Points:   []datadogPoint{{Timestamp: ts, Value: value}},
+		Tags:     r.tags,
+		Interval: r.interval,
+	}
+	if r.host != "" {
+		s.Resources = []datadogResource{{Name: r.host, Type: "host"}}
+	}
+	return s
+}
+
+func (r *datadogReporter) allSeries(snapshot *metrics.RegistrySnapshot, ts int64) []datadogSeries {
+	series := make([]datadogSeries, 0, len(snapshot.Values)+len(snapshot.Distributions)*4)
+	for _, v := range snapshot.Values {
+		typ := datadogTypeGauge
+		if snapshot.IsCounter(v.Name) {
+			typ = datadogTypeCount
+		}
+		series = append(series, r.series(v.Name, typ, ts, v.Value))
+	}
+	for _, v := range snapshot.Distributions {
+		series = append(series,
+			r.series(v.Name+"/count", datadogTypeCount, ts, float64(v.Value.Count)),
+			r.series(v.Name+"/avg", datadogTypeGauge, ts, v.Value.Mean()),
+			r.series(v.Name+"/min", datadogTypeGauge, ts, v.Value.Min),
+			r.series(v.Name+"/max", datadogTypeGauge, ts, v.Value.Max),
+		)
+	}
+	return series
+}
+
+func (r *datadogReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	series := r.allSeries(snapshot, time.Now().Unix())
+	if len(series) == 0 {
+		return
+	}
+	batches, err := datadogBatches(series, datadogMaxPayloadSize)
+	if err != nil {
+		r.errorf("", "datadog: failed to encode metrics: %w", err)
+		return
+	}
+	for _, b := range batches {
+		if err := r.send(func() error { return r.post(b.body) }); err != nil {
+			r.errorf("", "datadog: failed to send metrics: %w", err)
+			r.drop(b.series)
+		}
+	}
+}
+
+func (r *datadogReporter) post(payload []byte) error {
+	body := &bytes.Buffer{}
+	zw := gzip.NewWriter(body)
+	if _, err := zw.Write(payload); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	size := body.Len()
+	req, err := http.NewRequest("POST", r.endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("DD-API-KEY", r.apiKey)
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	r.sent(size)
+	return nil
+}
+
+// datadogBatch is an encoded payload and the number of series in it.
+type datadogBatch struct {
+	body   []byte
+	series int
+}
+
+// datadogBatches encodes series as {"series":[...]} payloads of at most
+// maxSize bytes. A single series larger than maxSize is sent on its own.
+func datadogBatches(series []datadogSeries, maxSize int) ([]datadogBatch, error) {
+	const head, tail = `{"series":[`, `]}`
+	var batches []datadogBatch
+	buf := &bytes.Buffer{}
+	n := 0
+	for _, s := range series {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 && buf.Len()+1+len(b)+len(tail) > maxSize {
+			buf.WriteString(tail)
+			batches = append(batches, datadogBatch{body: append([]byte(nil), buf.Bytes()...), series: n})
+			buf.Reset()
+			n = 0
+		}
+		if n == 0 {
+			buf.WriteString(head)
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(b)
+		n++
+	}
+	if n > 0 {
+		buf.WriteString(tail)
+		batches = append(batches, datadogBatch{body: buf.Bytes(), series: n})
+	}
+	return batches, nil
+}
+
+func (r *datadogReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
+
+func (r *datadogReporter) addTags(tags map[string]string) {
+	r.tags = append(append([]string(nil), r.tags...), tagList(tags)...)
+}
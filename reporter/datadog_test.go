@@ -0,0 +1,84 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestDatadogReporter(t *testing.T) {
+	var got struct {
+		Series []datadogSeries `json:"series"`
+	}
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		apiKey = req.Header.Get("DD-API-KEY")
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := json.NewDecoder(zr).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(4)
+	registry.Add("requests", counter)
+	dist := metrics.NewDistribution()
+	dist.Update(2)
+	registry.Add("latency", dist)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	r := newDatadogReporter(server.URL, "secret", "web1", []string{"env:prod"}, time.Minute)
+	r.Report(snapshot)
+
+	if apiKey != "secret" {
+		t.Fatalf("Expected API key secret. Got %s", apiKey)
+	}
+	if len(got.Series) != 5 {
+		t.Fatalf("Expected 5 series. Got %+v", got.Series)
+	}
+	s := got.Series[0]
+	if s.Metric != "requests" || s.Type != datadogTypeCount || s.Points[0].Value != 4 || s.Interval != 60 {
+		t.Fatalf("Unexpected series %+v", s)
+	}
+	if len(s.Resources) != 1 || s.Resources[0].Name != "web1" || len(s.Tags) != 1 {
+		t.Fatalf("Unexpected host or tags %+v", s)
+	}
+}
+
+func TestDatadogBatches(t *testing.T) {
+	series := []datadogSeries{{Metric: "a"}, {Metric: "b"}, {Metric: "c"}}
+	one, _ := json.Marshal(series[0])
+	batches, err := datadogBatches(series, len(one)*2+20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("Expected 2 batches. Got %d", len(batches))
+	}
+	for _, b := range batches {
+		var v struct{ Series []datadogSeries }
+		if err := json.Unmarshal(b.body, &v); err != nil {
+			t.Fatalf("Invalid batch %s: %s", b.body, err)
+		}
+		if len(v.Series) != b.series {
+			t.Fatalf("Expected %d series in batch. Got %d", b.series, len(v.Series))
+		}
+	}
+}
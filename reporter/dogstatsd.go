@@ -0,0 +1,96 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// TagFunc splits a metric name into the name to report and a list of
+// tags (in "key:value" form). It allows dimensions encoded in registry
+// names, such as "requests/status:200", to be reported as tags.
+type TagFunc func(name string) (string, []string)
+
+type dogStatsdReporter struct {
+	statsdReporter
+	tags    []string
+	tagFunc TagFunc
+}
+
+// NewDogStatsdReporter returns a reporter that sends metrics to a Datadog
+// agent using the DogStatsD protocol. The addr accepts the same transports
+// as NewStatsdReporter, such as "unixgram:///var/run/datadog/dsd.socket".
+// The tags (such as "host:web1" or "env:prod") are attached to every metric,
+// as are any returned by tagFunc which may be nil. Distributions are sent as Datadog distributions (|d).
+func NewDogStatsdReporter(registry metrics.Registry, interval time.Duration, latched bool, addr, prefix string, tags []string, tagFunc TagFunc) *PeriodicReporter {
+	network, addr := parseStatsdAddr(addr)
+	dr := &dogStatsdReporter{
+		statsdReporter: statsdReporter{
+			network: network,
+			addr:    addr,
+			prefix:  prefix,
+		},
+		tags:    tags,
+		tagFunc: tagFunc,
+	}
+	return NewPeriodicReporter(registry, interval, false, latched, dr)
+}
+
+func (r *dogStatsdReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	lines := r.lines(snapshot)
+	if len(lines) == 0 {
+		return
+	}
+	r.sendLines("dogstatsd", lines)
+}
+
+func (r *dogStatsdReporter) taggedLine(name string, value float64, typ string) string {
+	tags := r.tags
+	if r.tagFunc != nil {
+		var extra []string
+		name, extra = r.tagFunc(name)
+		if len(extra) > 0 {
+			tags = append(append(make([]string, 0, len(tags)+len(extra)), tags...), extra...)
+		}
+	}
+	line := r.line(name, value, typ, false)
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(sanitizeTags(tags), ",")
+	}
+	return line
+}
+
+func (r *dogStatsdReporter) lines(snapshot *metrics.RegistrySnapshot) []string {
+	lines := make([]string, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		typ := "g"
+		if snapshot.IsCounter(v.Name) {
+			typ = "c"
+		}
+		lines = append(lines, r.taggedLine(v.Name, v.Value, typ))
+	}
+	for _, v := range snapshot.Distributions {
+		lines = append(lines, r.taggedLine(v.Name, v.Value.Mean(), "d"))
+	}
+	return lines
+}
+
+var dogStatsdTagReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_")
+
+// sanitizeTags replaces characters that are part of the DogStatsD protocol.
+func sanitizeTags(tags []string) []string {
+	out := make([]string, len(tags))
+	for i, t := range tags {
+		out[i] = dogStatsdTagReplacer.Replace(t)
+	}
+	return out
+}
+
+func (r *dogStatsdReporter) addTags(tags map[string]string) {
+	r.tags = append(append([]string(nil), r.tags...), tagList(tags)...)
+}
@@ -0,0 +1,46 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestDogStatsdLines(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(2)
+	registry.Add("requests/status:200", counter)
+	dist := metrics.NewDistribution()
+	dist.Update(4)
+	registry.Add("latency", dist)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	r := &dogStatsdReporter{
+		statsdReporter: statsdReporter{prefix: "app"},
+		tags:           []string{"env:prod", "bad|tag"},
+		tagFunc: func(name string) (string, []string) {
+			if i := strings.LastIndex(name, "/"); i >= 0 && strings.Contains(name[i:], ":") {
+				return name[:i], []string{name[i+1:]}
+			}
+			return name, nil
+		},
+	}
+	lines := r.lines(snapshot)
+	sort.Strings(lines)
+	exp := []string{
+		"app.latency:4|d|#env:prod,bad_tag",
+		"app.requests:2|c|#env:prod,bad_tag,status:200",
+	}
+	if !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, lines)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"io"
+	"time"
+
+	"github.com/samuel/go-metrics/encoding"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// DryRunFormat is the wire format written by a dry-run reporter.
+type DryRunFormat int
+
+const (
+	// DryRunStatsd writes the lines a statsd reporter would send.
+	DryRunStatsd DryRunFormat = iota
+	// DryRunGraphite writes the lines a Graphite reporter would send.
+	DryRunGraphite
+	// DryRunInflux writes InfluxDB line protocol.
+	DryRunInflux
+)
+
+type dryRunReporter struct {
+	backendBase
+	w      io.Writer
+	format DryRunFormat
+}
+
+// NewDryRunReporter returns a reporter that writes exactly what would be
+// sent to a backend in the given format to w instead of the network. It's
+// meant for checking a reporter's configuration in tests and staging. Wrap
+// the reporter's backend with RenameBackend to add a prefix.
+func NewDryRunReporter(registry metrics.Registry, interval time.Duration, latched bool, w io.Writer, format DryRunFormat) *PeriodicReporter {
+	return NewPeriodicReporter(registry, interval, false, latched, &dryRunReporter{w: w, format: format})
+}
+
+func (r *dryRunReporter) lines(snapshot *metrics.RegistrySnapshot, now time.Time) []string {
+	snapshot = r.finite(snapshot)
+	switch r.format {
+	case DryRunGraphite:
+		return encoding.GraphiteLines(snapshot, "", now)
+	case DryRunInflux:
+		return encoding.InfluxLines(snapshot, now)
+	}
+	lines := encoding.StatsdLines(snapshot, "")
+	for i, line := range lines {
+		lines[i] = line + "\n"
+	}
+	return lines
+}
+
+func (r *dryRunReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	lines := r.lines(snapshot, time.Now())
+	for i, line := range lines {
+		if _, err := io.WriteString(r.w, line); err != nil {
+			r.errorf("", "dryrun: failed to write metrics: %w", err)
+			r.drop(len(lines) - i)
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestDryRunFormats(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("req/count", counter)
+	dist := metrics.NewDistribution()
+	dist.Update(1)
+	dist.Update(3)
+	registry.Add("req time", dist)
+	registry.Add("ratio", metrics.GaugeFunc(func() float64 { return math.NaN() }))
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	now := time.Unix(1400000000, 0)
+
+	cases := []struct {
+		format DryRunFormat
+		lines  []string
+	}{
+		{DryRunStatsd, []string{"req.count:3|c\n", "req time:2|ms\n"}},
+		{DryRunGraphite, []string{"req.count 3.000000 1400000000\n", "req time 2.000000 1400000000\n"}},
+		{DryRunInflux, []string{
+			"req/count value=3 1400000000000000000\n",
+			`req\ time count=2i,sum=4,min=1,max=3,mean=2 1400000000000000000` + "\n",
+		}},
+	}
+	for _, c := range cases {
+		r := &dryRunReporter{format: c.format}
+		if lines := r.lines(snapshot, now); !reflect.DeepEqual(lines, c.lines) {
+			t.Fatalf("Expected %q. Got %q", c.lines, lines)
+		}
+		if r.dropped != 1 {
+			t.Fatalf("Expected the NaN gauge to be dropped. Got %d dropped", r.dropped)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// elasticsearchIndexTemplate maps the fields of the metric documents so
+// Kibana sees names as keywords and values as numbers.
+const elasticsearchIndexTemplate = `{
+	"index_patterns": [%q],
+	"template": {
+		"mappings": {
+			"properties": {
+				"@timestamp": {"type": "date"},
+				"name": {"type": "keyword"},
+				"type": {"type": "keyword"},
+				"value": {"type": "double"},
+				"count": {"type": "long"},
+				"sum": {"type": "double"},
+				"min": {"type": "double"},
+				"max": {"type": "double"}
+			}
+		}
+	}
+}`
+
+type elasticsearchReporter struct {
+	backendBase
+	url         string
+	indexPrefix string
+	username    string
+	password    string
+	client      *http.Client
+	templated   bool
+}
+
+type elasticsearchDoc struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Value     float64   `json:"value"`
+	Count     *uint64   `json:"count,omitempty"`
+	Sum       *float64  `json:"sum,omitempty"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+}
+
+// NewElasticsearchReporter returns a reporter that indexes each metric as a
+// document using the Elasticsearch bulk API at url. Documents are written
+// to a daily index named indexPrefix-YYYY.MM.DD and an index template for
+// indexPrefix-* is installed on the first report. The username and password
+// are used for basic auth if not empty.
+func NewElasticsearchReporter(registry metrics.Registry, interval time.Duration, latched bool, url, indexPrefix, username, password string) *PeriodicReporter {
+	er := newElasticsearchReporter(url, indexPrefix, username, password)
+	return NewPeriodicReporter(registry, interval, true, latched, er)
+}
+
+func newElasticsearchReporter(url, indexPrefix, username, password string) *elasticsearchReporter {
+	return &elasticsearchReporter{
+		url:         strings.TrimRight(url, "/"),
+		indexPrefix: indexPrefix,
+		username:    username,
+		password:    password,
+		client:      &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+func (r *elasticsearchReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	if !r.templated {
+		body := fmt.Sprintf(elasticsearchIndexTemplate, r.indexPrefix+"-*")
+		if err := r.do("PUT", "/_index_template/"+r.indexPrefix, "application/json", []byte(body)); err != nil {
+			r.errorf("", "elasticsearch: failed to create index template: %w", err)
+		} else {
+			r.templated = true
+		}
+	}
+	now := time.Now().UTC()
+	body, err := elasticsearchBulkBody(snapshot, r.indexPrefix+"-"+now.Format("2006.01.02"), now)
+	if err != nil {
+		r.errorf("", "elasticsearch: failed to encode metrics: %w", err)
+		r.drop(snapshot.Len())
+		return
+	}
+	if len(body) == 0 {
+		return
+	}
+	if err := r.send(func() error { return r.do("POST", "/_bulk", "application/x-ndjson", body) }); err != nil {
+		r.errorf("", "elasticsearch: failed to index metrics: %w", err)
+		r.drop(snapshot.Len())
+	}
+}
+
+func elasticsearchBulkBody(snapshot *metrics.RegistrySnapshot, index string, ts time.Time) ([]byte, error) {
+	action, err := json.Marshal(map[string]map[string]string{"index": {"_index": index}})
+	if err != nil {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	add := func(doc *elasticsearchDoc) error {
+		d, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		b.Write(action)
+		b.WriteByte('\n')
+		b.Write(d)
+		b.WriteByte('\n')
+		return nil
+	}
+	for _, v := range snapshot.Values {
+		typ := "gauge"
+		if snapshot.IsCounter(v.Name) {
+			typ = "counter"
+		}
+		if err := add(&elasticsearchDoc{Timestamp: ts, Name: v.Name, Type: typ, Value: v.Value}); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range snapshot.Distributions {
+		d := v.Value
+		doc := &elasticsearchDoc{
+			Timestamp: ts,
+			Name:      v.Name,
+			Type:      "distribution",
+			Value:     d.Mean(),
+			Count:     &d.Count,
+			Sum:       &d.Sum,
+			Min:       &d.Min,
+			Max:       &d.Max,
+		}
+		if err := add(doc); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func (r *elasticsearchReporter) do(method, path, contentType string, body []byte) error {
+	req, err := http.NewRequest(method, r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if r.username != "" || r.password != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	msg, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	// The bulk API returns 200 even if some documents failed
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if json.Unmarshal(msg, &result) == nil && result.Errors {
+		return fmt.Errorf("some documents failed: %s", msg)
+	}
+	return nil
+}
+
+func (r *elasticsearchReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
@@ -0,0 +1,69 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestElasticsearchReporter(t *testing.T) {
+	var paths []string
+	var bulk []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.Method+" "+req.URL.Path)
+		if req.URL.Path == "/_bulk" {
+			bulk, _ = ioutil.ReadAll(req.Body)
+		}
+		w.Write([]byte(`{"errors":false}`))
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(7)
+	registry.Add("requests", counter)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	r := newElasticsearchReporter(server.URL, "metrics", "", "")
+	r.Report(snapshot)
+	r.Report(snapshot)
+
+	exp := []string{"PUT /_index_template/metrics", "POST /_bulk", "POST /_bulk"}
+	if strings.Join(paths, ",") != strings.Join(exp, ",") {
+		t.Fatalf("Expected %+v. Got %+v", exp, paths)
+	}
+	s := bufio.NewScanner(bytes.NewReader(bulk))
+	var action struct {
+		Index struct {
+			Index string `json:"_index"`
+		}
+	}
+	s.Scan()
+	if err := json.Unmarshal(s.Bytes(), &action); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "metrics-" + time.Now().UTC().Format("2006.01.02"); action.Index.Index != exp {
+		t.Fatalf("Expected index %s. Got %s", exp, action.Index.Index)
+	}
+	var doc elasticsearchDoc
+	s.Scan()
+	if err := json.Unmarshal(s.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "requests" || doc.Type != "counter" || doc.Value != 7 || doc.Count != nil {
+		t.Fatalf("Unexpected document %+v", doc)
+	}
+}
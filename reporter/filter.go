@@ -0,0 +1,74 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"path"
+	"regexp"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// MetricFilter reports whether a metric name matches.
+type MetricFilter func(name string) bool
+
+// GlobFilter returns a filter matching names against any of the shell
+// patterns as with path.Match, so '*' does not match the '/' separators
+// of scoped names ("http/*" matches "http/requests" but not
+// "http/requests/p99").
+func GlobFilter(patterns ...string) MetricFilter {
+	return func(name string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RegexpFilter returns a filter matching names against any of the regular
+// expressions.
+func RegexpFilter(res ...*regexp.Regexp) MetricFilter {
+	return func(name string) bool {
+		for _, re := range res {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type filterBackend struct {
+	backend Backend
+	include MetricFilter
+	exclude MetricFilter
+}
+
+// FilterBackend returns a Backend that passes only some metrics through to
+// backend. If include is not nil only metrics it matches are kept, then any
+// matched by exclude (if not nil) are dropped. Names are those in the
+// snapshot so derived values such as "latency/p99" or "requests/1m" can be
+// filtered separately from their metric.
+func FilterBackend(backend Backend, include, exclude MetricFilter) Backend {
+	return &filterBackend{
+		backend: backend,
+		include: include,
+		exclude: exclude,
+	}
+}
+
+func (b *filterBackend) unwrap() Backend {
+	return b.backend
+}
+
+func (b *filterBackend) keep(name string) bool {
+	return (b.include == nil || b.include(name)) && (b.exclude == nil || !b.exclude(name))
+}
+
+func (b *filterBackend) Report(snapshot *metrics.RegistrySnapshot) {
+	b.backend.Report(snapshot.Filter(b.keep))
+}
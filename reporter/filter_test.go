@@ -0,0 +1,45 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+type namesBackend struct {
+	names []string
+}
+
+func (b *namesBackend) Report(snapshot *metrics.RegistrySnapshot) {
+	b.names = snapshot.Names()
+	sort.Strings(b.names)
+}
+
+func TestFilterBackend(t *testing.T) {
+	registry := metrics.NewRegistry()
+	for _, name := range []string{"http/requests", "http/errors", "http/debug/conns", "db/queries", "debug/heap"} {
+		registry.Add(name, metrics.NewCounter())
+	}
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	b := &namesBackend{}
+	FilterBackend(b, GlobFilter("http/*", "db/*"), RegexpFilter(regexp.MustCompile("errors$"))).Report(snapshot)
+	exp := []string{"db/queries", "http/requests"}
+	if !reflect.DeepEqual(b.names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, b.names)
+	}
+
+	FilterBackend(b, nil, RegexpFilter(regexp.MustCompile("(^|/)debug/"))).Report(snapshot)
+	exp = []string{"db/queries", "http/errors", "http/requests"}
+	if !reflect.DeepEqual(b.names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, b.names)
+	}
+}
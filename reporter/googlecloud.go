@@ -0,0 +1,235 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const (
+	googleCloudEndpoint = "https://monitoring.googleapis.com/v3/projects/"
+
+	// googleCloudMetricPrefix is the namespace for user defined metrics.
+	googleCloudMetricPrefix = "custom.googleapis.com/"
+
+	// googleCloudMaxTimeSeries is the most time series per write request.
+	googleCloudMaxTimeSeries = 200
+
+	// googleCloudMinInterval is the fastest a time series may be written.
+	googleCloudMinInterval = time.Minute
+)
+
+// googleCloudNameReplacer replaces the characters that aren't allowed in
+// custom metric types, which may only hold letters, digits, underscores
+// and slashes.
+var googleCloudNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_/]`)
+
+type googleCloudReporter struct {
+	backendBase
+	endpoint       string
+	client         *http.Client
+	resourceType   string
+	resourceLabels map[string]string
+	descriptors    map[string]bool
+	written        map[string]time.Time // last write of each metric type
+	now            func() time.Time
+}
+
+type googleCloudMetric struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type googleCloudResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
+
+type googleCloudPoint struct {
+	Interval struct {
+		EndTime string `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue float64 `json:"doubleValue"`
+	} `json:"value"`
+}
+
+type googleCloudTimeSeries struct {
+	Metric     googleCloudMetric   `json:"metric"`
+	Resource   googleCloudResource `json:"resource"`
+	MetricKind string              `json:"metricKind"`
+	ValueType  string              `json:"valueType"`
+	Points     []googleCloudPoint  `json:"points"`
+}
+
+type googleCloudDescriptor struct {
+	Type        string `json:"type"`
+	MetricKind  string `json:"metricKind"`
+	ValueType   string `json:"valueType"`
+	Description string `json:"description"`
+}
+
+// NewGoogleCloudMonitoringReporter returns a reporter that writes metrics
+// as custom metrics (custom.googleapis.com/<name>) to Google Cloud
+// Monitoring for projectID. The client must add credentials to requests,
+// such as one returned by golang.org/x/oauth2/google.DefaultClient with the
+// monitoring.write scope. The monitored resource defaults to "global" if
+// resourceType is empty. Metric descriptors are created the first time each
+// metric is written. Since a time series may only be written once a minute
+// the interval is raised to a minute if shorter, and points that would be
+// written less than a minute after the previous point of their time series,
+// such as by Flush, are dropped. Names are turned into valid metric types by
+// replacing characters other than letters, digits, underscores and slashes
+// with underscores. All values are written as gauges and distributions as
+// their mean, count, min and max. If client is nil a client without
+// credentials is used.
+func NewGoogleCloudMonitoringReporter(registry metrics.Registry, interval time.Duration, latched bool, client *http.Client, projectID, resourceType string, resourceLabels map[string]string) *PeriodicReporter {
+	if interval < googleCloudMinInterval {
+		interval = googleCloudMinInterval
+	}
+	gr := newGoogleCloudReporter(googleCloudEndpoint+projectID, client, projectID, resourceType, resourceLabels)
+	return NewPeriodicReporter(registry, interval, true, latched, gr)
+}
+
+func newGoogleCloudReporter(endpoint string, client *http.Client, projectID, resourceType string, resourceLabels map[string]string) *googleCloudReporter {
+	if resourceType == "" {
+		resourceType = "global"
+		resourceLabels = map[string]string{"project_id": projectID}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return &googleCloudReporter{
+		endpoint:       endpoint,
+		client:         client,
+		resourceType:   resourceType,
+		resourceLabels: resourceLabels,
+		descriptors:    make(map[string]bool),
+		written:        make(map[string]time.Time),
+		now:            time.Now,
+	}
+}
+
+// googleCloudMetricType returns the custom metric type for a metric name.
+func googleCloudMetricType(name string) string {
+	parts := strings.Split(googleCloudNameReplacer.ReplaceAllString(name, "_"), "/")
+	segments := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			segments = append(segments, p)
+		}
+	}
+	return googleCloudMetricPrefix + strings.Join(segments, "/")
+}
+
+func (r *googleCloudReporter) timeSeries(name string, endTime string, value float64) googleCloudTimeSeries {
+	ts := googleCloudTimeSeries{
+		Metric:     googleCloudMetric{Type: googleCloudMetricType(name)},
+		Resource:   googleCloudResource{Type: r.resourceType, Labels: r.resourceLabels},
+		MetricKind: "GAUGE",
+		ValueType:  "DOUBLE",
+		Points:     make([]googleCloudPoint, 1),
+	}
+	ts.Points[0].Interval.EndTime = endTime
+	ts.Points[0].Value.DoubleValue = value
+	return ts
+}
+
+func (r *googleCloudReporter) allTimeSeries(snapshot *metrics.RegistrySnapshot, now time.Time) []googleCloudTimeSeries {
+	endTime := now.UTC().Format(time.RFC3339Nano)
+	series := make([]googleCloudTimeSeries, 0, len(snapshot.Values)+len(snapshot.Distributions)*4)
+	for _, v := range snapshot.Values {
+		series = append(series, r.timeSeries(v.Name, endTime, v.Value))
+	}
+	for _, v := range snapshot.Distributions {
+		series = append(series,
+			r.timeSeries(v.Name+"/mean", endTime, v.Value.Mean()),
+			r.timeSeries(v.Name+"/count", endTime, float64(v.Value.Count)),
+			r.timeSeries(v.Name+"/min", endTime, v.Value.Min),
+			r.timeSeries(v.Name+"/max", endTime, v.Value.Max),
+		)
+	}
+	return series
+}
+
+func (r *googleCloudReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	now := r.now()
+	series := r.allTimeSeries(snapshot, now)
+	// Drop points that come too soon after the previous one
+	due := series[:0]
+	for _, ts := range series {
+		if last, ok := r.written[ts.Metric.Type]; ok && now.Sub(last) < googleCloudMinInterval {
+			r.drop(1)
+			continue
+		}
+		due = append(due, ts)
+	}
+	series = due
+	for _, ts := range series {
+		if r.descriptors[ts.Metric.Type] {
+			continue
+		}
+		desc := &googleCloudDescriptor{
+			Type:        ts.Metric.Type,
+			MetricKind:  ts.MetricKind,
+			ValueType:   ts.ValueType,
+			Description: ts.Metric.Type[len(googleCloudMetricPrefix):],
+		}
+		if err := r.post("/metricDescriptors", desc); err != nil {
+			r.errorf(desc.Type, "googlecloud: failed to create metric descriptor %s: %w", desc.Type, err)
+			continue
+		}
+		r.descriptors[ts.Metric.Type] = true
+	}
+	for len(series) > 0 {
+		n := len(series)
+		if n > googleCloudMaxTimeSeries {
+			n = googleCloudMaxTimeSeries
+		}
+		req := struct {
+			TimeSeries []googleCloudTimeSeries `json:"timeSeries"`
+		}{series[:n]}
+		if err := r.send(func() error { return r.post("/timeSeries", &req) }); err != nil {
+			r.errorf("", "googlecloud: failed to write time series: %w", err)
+			r.drop(n)
+		} else {
+			for _, ts := range series[:n] {
+				r.written[ts.Metric.Type] = now
+			}
+		}
+		series = series[n:]
+	}
+}
+
+func (r *googleCloudReporter) post(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	res, err := r.client.Post(r.endpoint+path, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+func (r *googleCloudReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
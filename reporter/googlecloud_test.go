@@ -0,0 +1,74 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestGoogleCloudReporter(t *testing.T) {
+	var descriptors []string
+	var written []googleCloudTimeSeries
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/projects/p1/metricDescriptors":
+			var d googleCloudDescriptor
+			json.NewDecoder(req.Body).Decode(&d)
+			descriptors = append(descriptors, d.Type)
+		case "/projects/p1/timeSeries":
+			var body struct{ TimeSeries []googleCloudTimeSeries }
+			json.NewDecoder(req.Body).Decode(&body)
+			written = append(written, body.TimeSeries...)
+		default:
+			t.Errorf("Unexpected path %s", req.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(4)
+	registry.Add("queue/depth", gauge)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	now := time.Unix(1000, 0)
+	r := newGoogleCloudReporter(server.URL+"/projects/p1", server.Client(), "p1", "", nil)
+	r.now = func() time.Time { return now }
+	r.Report(snapshot)
+	now = now.Add(30 * time.Second)
+	r.Report(snapshot)
+	if len(written) != 1 || r.dropped != 1 {
+		t.Fatalf("Expected the early point to be dropped. Got %d writes and %d dropped", len(written), r.dropped)
+	}
+	now = now.Add(30 * time.Second)
+	r.Report(snapshot)
+
+	if len(descriptors) != 1 || descriptors[0] != "custom.googleapis.com/queue/depth" {
+		t.Fatalf("Expected one descriptor to be created. Got %+v", descriptors)
+	}
+	if len(written) != 2 {
+		t.Fatalf("Expected 2 writes. Got %d", len(written))
+	}
+	ts := written[0]
+	if ts.Resource.Type != "global" || ts.Resource.Labels["project_id"] != "p1" || ts.Points[0].Value.DoubleValue != 4 {
+		t.Fatalf("Unexpected time series %+v", ts)
+	}
+}
+
+func TestGoogleCloudMetricType(t *testing.T) {
+	if typ := googleCloudMetricType("http/GET /users{id}.latency"); typ != "custom.googleapis.com/http/GET_/users_id__latency" {
+		t.Fatalf("Expected a valid metric type. Got %s", typ)
+	}
+	if r := newGoogleCloudReporter("", nil, "p1", "", nil); r.client == nil {
+		t.Fatal("Expected a default client")
+	}
+}
@@ -5,20 +5,47 @@
 package reporter
 
 import (
+	"bufio"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"io"
 	"net"
-	"strings"
+	"os"
 	"time"
 
+	"github.com/samuel/go-metrics/encoding"
 	"github.com/samuel/go-metrics/metrics"
 )
 
+const (
+	graphiteDialTimeout  = 5 * time.Second
+	graphiteWriteTimeout = 10 * time.Second
+
+	// graphiteDefaultMaxBuffered is the size of the buffer when no
+	// positive maximum is given.
+	graphiteDefaultMaxBuffered = 100000
+)
+
+// graphiteReconnect is the backoff between attempts to connect to carbon.
+var graphiteReconnect = &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}
+
 type graphiteReporter struct {
-	addr   string
-	source string
+	backendBase
+	addr      string
+	source    string
+	tlsConfig *tls.Config
+	buffer    *graphiteBuffer
+
+	// The connection is kept open between reports.
+	conn     net.Conn
+	reused   bool // conn was used by a previous report
+	failures int
+	nextDial time.Time
 }
 
+// NewGraphiteReporter returns a reporter that sends to carbon using the
+// plaintext protocol. The connection is made on the first report and kept
+// open, reconnecting with backoff if it fails.
 func NewGraphiteReporter(registry metrics.Registry, interval time.Duration, latched bool, addr, source string) *PeriodicReporter {
 	gr := &graphiteReporter{
 		addr:   addr,
@@ -27,33 +54,201 @@ func NewGraphiteReporter(registry metrics.Registry, interval time.Duration, latc
 	return NewPeriodicReporter(registry, interval, false, latched, gr)
 }
 
-func (r *graphiteReporter) sourcedName(name string) string {
-	if r.source != "" {
-		return name + "." + r.source
+// NewGraphiteTLSReporter returns a Graphite reporter that connects to carbon
+// over TLS, such as through a TLS terminating relay.
+func NewGraphiteTLSReporter(registry metrics.Registry, interval time.Duration, latched bool, addr, source string, config *tls.Config) *PeriodicReporter {
+	gr := &graphiteReporter{
+		addr:      addr,
+		source:    source,
+		tlsConfig: config,
 	}
-	return name
+	return NewPeriodicReporter(registry, interval, false, latched, gr)
+}
+
+// NewBufferedGraphiteReporter returns a Graphite reporter that queues up to
+// maxBuffered datapoints while carbon is unreachable and replays them with
+// their original timestamps once it can connect again. The oldest datapoints
+// are dropped when the buffer is full. A maxBuffered of zero or less
+// buffers up to 100000 datapoints. If bufferPath is not empty the queue is
+// kept in that file instead of memory so it also survives restarts.
+func NewBufferedGraphiteReporter(registry metrics.Registry, interval time.Duration, latched bool, addr, source string, maxBuffered int, bufferPath string) *PeriodicReporter {
+	if maxBuffered <= 0 {
+		maxBuffered = graphiteDefaultMaxBuffered
+	}
+	gr := &graphiteReporter{
+		addr:   addr,
+		source: source,
+	}
+	gr.buffer = &graphiteBuffer{max: maxBuffered, path: bufferPath, errs: &gr.backendBase}
+	return NewPeriodicReporter(registry, interval, false, latched, gr)
 }
 
 func (r *graphiteReporter) Report(snapshot *metrics.RegistrySnapshot) {
-	conn, err := net.Dial("tcp", r.addr)
-	if err != nil {
-		log.Printf("Failed to connect to graphite/carbon: %+v", err)
+	lines := encoding.GraphiteLines(snapshot, r.source, time.Now())
+
+	if err := r.connect(); err != nil {
+		r.errorf("", "graphite: failed to connect to carbon: %w", err)
+		if r.buffer != nil {
+			r.buffer.add(lines)
+		} else {
+			r.drop(len(lines))
+		}
 		return
 	}
-	defer conn.Close()
 
-	ts := time.Now().UTC().Unix()
+	if r.buffer != nil {
+		lines = append(r.buffer.drain(), lines...)
+	}
+	for i := 0; i < len(lines); i++ {
+		err := r.write(lines[i])
+		if err != nil && i == 0 && r.reused {
+			// Carbon may have closed the idle connection since the last
+			// report so try again on a new one.
+			if err = r.connect(); err == nil {
+				err = r.write(lines[i])
+			}
+		}
+		if err != nil {
+			r.errorf("", "graphite: failed to post metrics: %w", err)
+			if r.buffer != nil {
+				r.buffer.add(lines[i:])
+			} else {
+				r.drop(len(lines) - i)
+			}
+			return
+		}
+		r.sent(len(lines[i]))
+	}
+	r.reused = true
+}
 
-	for _, v := range snapshot.Values {
-		name := strings.Replace(v.Name, "/", ".", -1)
-		if _, err := fmt.Fprintf(conn, "%s %f %d\n", r.sourcedName(name), v.Value, ts); err != nil {
-			log.Printf("graphite: failed to post metric %s: %s", name, err.Error())
+// connect opens a connection to carbon unless one is already open. After a
+// failure it waits an increasing time before dialing again so that an
+// unreachable carbon costs little.
+func (r *graphiteReporter) connect() error {
+	if r.conn != nil {
+		return nil
+	}
+	if wait := time.Until(r.nextDial); wait > 0 {
+		return fmt.Errorf("waiting %s before reconnecting", wait.Round(time.Millisecond))
+	}
+	dialer := &net.Dialer{Timeout: graphiteDialTimeout}
+	err := r.send(func() (err error) {
+		if r.tlsConfig != nil {
+			r.conn, err = tls.DialWithDialer(dialer, "tcp", r.addr, r.tlsConfig)
+		} else {
+			r.conn, err = dialer.Dial("tcp", r.addr)
 		}
+		return err
+	})
+	if err != nil {
+		r.conn = nil
+		r.failures++
+		r.nextDial = time.Now().Add(graphiteReconnect.backoff(r.failures))
+		return err
+	}
+	r.failures = 0
+	r.reused = false
+	return nil
+}
+
+// write sends a line, closing the connection if it fails.
+func (r *graphiteReporter) write(line string) error {
+	r.conn.SetWriteDeadline(time.Now().Add(graphiteWriteTimeout))
+	_, err := io.WriteString(r.conn, line)
+	if err != nil {
+		r.close()
 	}
-	for _, v := range snapshot.Distributions {
-		name := strings.Replace(v.Name, "/", ".", -1)
-		if _, err := fmt.Fprintf(conn, "%s %f %d\n", r.sourcedName(name), v.Value.Mean(), ts); err != nil {
-			log.Printf("graphite: failed to post metric %s: %s", name, err.Error())
+	return err
+}
+
+func (r *graphiteReporter) close() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// graphiteBuffer holds datapoint lines that could not be sent. The lines
+// are kept in a file if path is set and in memory otherwise.
+type graphiteBuffer struct {
+	errs  *backendBase
+	max   int
+	path  string
+	lines []string
+}
+
+func (b *graphiteBuffer) add(lines []string) {
+	if b.path == "" {
+		b.lines = b.trim(append(b.lines, lines...))
+		return
+	}
+	existing := b.read()
+	all := b.trim(append(existing, lines...))
+	if len(all) == len(existing)+len(lines) {
+		// Nothing dropped so the new lines can just be appended.
+		b.write(lines, os.O_APPEND)
+	} else {
+		b.write(all, os.O_TRUNC)
+	}
+}
+
+// drain returns all buffered lines, oldest first, and empties the buffer.
+func (b *graphiteBuffer) drain() []string {
+	if b.path == "" {
+		lines := b.lines
+		b.lines = nil
+		return lines
+	}
+	lines := b.read()
+	if len(lines) > 0 {
+		if err := os.Remove(b.path); err != nil {
+			b.errs.errorf("", "graphite: failed to remove buffer %s: %w", b.path, err)
 		}
 	}
+	return b.trim(lines)
+}
+
+// trim drops the oldest lines beyond the maximum.
+func (b *graphiteBuffer) trim(lines []string) []string {
+	if len(lines) > b.max {
+		dropped := len(lines) - b.max
+		b.errs.logf("graphite: buffer full, dropping %d datapoints", dropped)
+		b.errs.drop(dropped)
+		lines = append([]string(nil), lines[dropped:]...)
+	}
+	return lines
+}
+
+func (b *graphiteBuffer) read() []string {
+	f, err := os.Open(b.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			b.errs.errorf("", "graphite: failed to open buffer %s: %w", b.path, err)
+		}
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		lines = append(lines, s.Text()+"\n")
+	}
+	return lines
+}
+
+func (b *graphiteBuffer) write(lines []string, flag int) {
+	f, err := os.OpenFile(b.path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		b.errs.errorf("", "graphite: failed to open buffer %s: %w", b.path, err)
+		return
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range lines {
+		w.WriteString(line)
+	}
+	if err := w.Flush(); err != nil {
+		b.errs.errorf("", "graphite: failed to write buffer %s: %w", b.path, err)
+	}
+	f.Close()
 }
@@ -0,0 +1,145 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestGraphiteBuffering(t *testing.T) {
+	for _, path := range []string{"", filepath.Join(t.TempDir(), "carbon.buf")} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+
+		registry := metrics.NewRegistry()
+		gauge := metrics.NewIntegerGauge()
+		registry.Add("g", gauge)
+		snapshot := metrics.NewRegistrySnapshot(true)
+		r := &graphiteReporter{addr: addr}
+		r.buffer = &graphiteBuffer{max: 2, path: path, errs: &r.backendBase}
+
+		// Carbon is down so these are buffered and the first is dropped
+		for i := 1; i <= 3; i++ {
+			gauge.Set(int64(i))
+			snapshot.Snapshot(registry)
+			r.Report(snapshot)
+		}
+		if r.dropped != 1 {
+			t.Fatalf("%q: expected 1 dropped datapoint. Got %d", path, r.dropped)
+		}
+
+		ln, err = net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.nextDial = time.Time{} // skip the reconnect backoff
+		gauge.Set(4)
+		snapshot.Snapshot(registry)
+		go r.Report(snapshot)
+
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := readLines(t, conn, 3)
+		conn.Close()
+		ln.Close()
+
+		var values []string
+		for _, line := range b {
+			values = append(values, strings.Fields(line)[1])
+		}
+		if exp := "2.000000,3.000000,4.000000"; strings.Join(values, ",") != exp {
+			t.Fatalf("%q: expected %s. Got %s", path, exp, strings.Join(values, ","))
+		}
+	}
+}
+
+func readLines(t *testing.T, conn net.Conn, n int) []string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var lines []string
+	s := bufio.NewScanner(conn)
+	for len(lines) < n && s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if len(lines) != n {
+		t.Fatalf("Expected %d lines. Got %q (%v)", n, lines, s.Err())
+	}
+	return lines
+}
+
+func TestGraphiteReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	registry := metrics.NewRegistry()
+	registry.Add("g", metrics.NewIntegerGauge())
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	r := &graphiteReporter{addr: ln.Addr().String()}
+	r.handler = func(metric string, err error) {}
+
+	// The connection is made lazily and kept between reports
+	r.Report(snapshot)
+	r.Report(snapshot)
+	conn := <-accepted
+	readLines(t, conn, 2)
+
+	// Carbon closes the connection so the next report reconnects
+	conn.Close()
+	time.Sleep(time.Millisecond * 50)
+	r.Report(snapshot)
+	r.Report(snapshot)
+	select {
+	case conn = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a new connection")
+	}
+	readLines(t, conn, 1)
+	conn.Close()
+	if len(accepted) != 0 {
+		t.Fatal("Expected a single reconnect")
+	}
+
+	// Once carbon is down dialing backs off
+	r.close()
+	ln.Close()
+	r.Report(snapshot)
+	r.Report(snapshot)
+	if r.failures != 1 || r.nextDial.Before(time.Now()) {
+		t.Fatalf("Expected to back off after one failure. Got %d failures", r.failures)
+	}
+}
+
+func TestBufferedGraphiteDefaultMax(t *testing.T) {
+	r := NewBufferedGraphiteReporter(metrics.NewRegistry(), time.Minute, false, "127.0.0.1:0", "", 0, "")
+	if max := r.reporter.(*graphiteReporter).buffer.max; max != graphiteDefaultMaxBuffered {
+		t.Fatalf("Expected a buffer of %d. Got %d", graphiteDefaultMaxBuffered, max)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/metricspb"
+	"google.golang.org/grpc"
+)
+
+// grpcTimeout bounds sending a snapshot and closing the stream so that a
+// collector that stops reading can't block the reporter.
+var grpcTimeout = 10 * time.Second
+
+type grpcReporter struct {
+	backendBase
+	client metricspb.CollectorClient
+	source string
+	tags   map[string]string
+
+	// The stream is kept open between reports.
+	stream metricspb.Collector_StreamMetricsClient
+	cancel context.CancelFunc
+}
+
+// NewGRPCReporter returns a reporter that sends a snapshot every interval
+// over a long lived metricspb.Collector stream on conn. The stream is opened
+// on the first report and opened again after a failure. The source, such as
+// the hostname, identifies the process to the collector. The buckets of
+// histograms with fixed buckets are sent so they can be merged.
+func NewGRPCReporter(registry metrics.Registry, interval time.Duration, latched bool, conn grpc.ClientConnInterface, source string) *PeriodicReporter {
+	gr := &grpcReporter{
+		client: metricspb.NewCollectorClient(conn),
+		source: source,
+	}
+	r := NewPeriodicReporter(registry, interval, false, latched, gr)
+	r.snapshot.SetKeepBuckets(true)
+	return r
+}
+
+func (r *grpcReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	msg := metricspb.FromSnapshot(snapshot, r.source, time.Now())
+	msg.Tags = r.tags
+	if err := r.send(func() error { return r.sendSnapshot(msg) }); err != nil {
+		r.errorf("", "grpc: failed to send metrics: %w", err)
+		r.drop(snapshot.Len())
+	}
+}
+
+func (r *grpcReporter) sendSnapshot(msg *metricspb.Snapshot) error {
+	if r.stream == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := r.client.StreamMetrics(ctx)
+		if err != nil {
+			cancel()
+			return err
+		}
+		r.stream, r.cancel = stream, cancel
+	}
+	// Canceling the stream's context is the only way to interrupt a send
+	timer := time.AfterFunc(grpcTimeout, r.cancel)
+	err := r.stream.Send(msg)
+	if err == io.EOF {
+		// The stream was ended by the collector and the reason is
+		// only available from receiving.
+		_, err = r.stream.CloseAndRecv()
+		if err == nil {
+			err = io.EOF
+		}
+	}
+	timer.Stop()
+	if err != nil {
+		r.abort()
+	}
+	return err
+}
+
+// abort cancels the stream so the next report opens a new one.
+func (r *grpcReporter) abort() {
+	if r.stream != nil {
+		r.cancel()
+		r.stream, r.cancel = nil, nil
+	}
+}
+
+// close ends the stream, waiting for the collector to acknowledge it for
+// up to grpcTimeout.
+func (r *grpcReporter) close() {
+	if r.stream != nil {
+		timer := time.AfterFunc(grpcTimeout, r.cancel)
+		r.stream.CloseAndRecv()
+		timer.Stop()
+		r.abort()
+	}
+}
+
+func (r *grpcReporter) addTags(tags map[string]string) {
+	r.tags = mergeTags(r.tags, tags)
+}
@@ -0,0 +1,152 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/metricspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testCollector struct {
+	metricspb.UnimplementedCollectorServer
+	snapshots chan *metricspb.Snapshot
+	streams   chan struct{}
+}
+
+func (c *testCollector) StreamMetrics(stream metricspb.Collector_StreamMetricsServer) error {
+	c.streams <- struct{}{}
+	var n uint64
+	for {
+		s, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&metricspb.StreamMetricsResponse{Received: n})
+		} else if err != nil {
+			return err
+		}
+		n++
+		c.snapshots <- s
+	}
+}
+
+func TestGRPCReporter(t *testing.T) {
+	ln := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	collector := &testCollector{snapshots: make(chan *metricspb.Snapshot, 10), streams: make(chan struct{}, 10)}
+	metricspb.RegisterCollectorServer(server, collector)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	registry.Add("requests", counter)
+	r := NewGRPCReporter(registry, time.Minute, true, conn, "host1")
+	for i := uint64(1); i <= 2; i++ {
+		counter.Inc(i)
+		r.Flush()
+		select {
+		case s := <-collector.snapshots:
+			if s.Source != "host1" || len(s.Values) != 1 || s.Values[0].Value != float64(i) || !s.Values[0].Counter {
+				t.Fatalf("Expected requests counter of %d. Got %+v", i, s)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected a snapshot")
+		}
+	}
+	if len(collector.streams) != 1 {
+		t.Fatalf("Expected snapshots on a single stream. Got %d streams", len(collector.streams))
+	}
+
+	r.SetTags(map[string]string{"env": "prod"})
+	h := metrics.NewBucketedHistogram([]int64{10})
+	registry.Add("latency", h)
+	h.Update(5)
+	r.Flush()
+	select {
+	case s := <-collector.snapshots:
+		if s.Tags["env"] != "prod" || len(s.Distributions) != 1 || len(s.Distributions[0].Buckets) != 1 {
+			t.Fatalf("Expected tags and the latency buckets. Got %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a snapshot")
+	}
+	r.reporter.(*grpcReporter).close()
+	if r.reporter.(*grpcReporter).stream != nil {
+		t.Fatal("Expected stream to be closed")
+	}
+}
+
+type stalledCollector struct {
+	metricspb.UnimplementedCollectorServer
+	release chan struct{}
+}
+
+func (c *stalledCollector) StreamMetrics(stream metricspb.Collector_StreamMetricsServer) error {
+	<-c.release
+	return nil
+}
+
+func TestGRPCReporterStalledCollector(t *testing.T) {
+	defer func(d time.Duration) { grpcTimeout = d }(grpcTimeout)
+	grpcTimeout = 100 * time.Millisecond
+
+	ln := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	collector := &stalledCollector{release: make(chan struct{})}
+	metricspb.RegisterCollectorServer(server, collector)
+	go server.Serve(ln)
+	defer server.Stop()
+	defer close(collector.release)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Enough metrics to fill the stream's flow control window
+	registry := metrics.NewRegistry()
+	for i := 0; i < 5000; i++ {
+		registry.Add(fmt.Sprintf("a/long/metric/name/to/fill/the/window/%d", i), metrics.GaugeValue(1))
+	}
+	r := NewGRPCReporter(registry, time.Hour, false, conn, "host1")
+	gr := r.reporter.(*grpcReporter)
+	gr.handler = func(metric string, err error) {}
+	done := make(chan struct{})
+	go func() {
+		r.Flush()
+		r.Flush()
+		r.Flush()
+		gr.close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected sends and close to time out")
+	}
+	if gr.dropped == 0 {
+		t.Fatal("Expected the stalled snapshots to be dropped")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// JSONHandler returns an http.Handler that serves every metric in the
+// registry as a JSON object keyed by name, for mounting at a path such as
+// /debug/metrics. Counters and gauges are numbers, meters hold their rates
+// and histograms their distribution and percentiles. Reading the metrics
+// does not reset them. The query parameter "prefix" limits the response to
+// names with that prefix and "pretty" indents it.
+//
+// Like the pprof handlers, the query parameter "seconds" waits that long and
+// returns what happened in the meantime instead of the current totals:
+// counters are the change over the window, meters and histograms hold the
+// change in their count along with the rate or the mean and sum of the
+// values recorded, and everything else is its value at the end.
+func JSONHandler(registry metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reg := registry
+		if prefix := req.FormValue("prefix"); prefix != "" {
+			include := []*regexp.Regexp{regexp.MustCompile("^" + regexp.QuoteMeta(prefix))}
+			reg = metrics.NewFilterdRegistry(registry, include, nil)
+		}
+		var b []byte
+		var err error
+		if sec := req.FormValue("seconds"); sec != "" {
+			seconds, perr := strconv.ParseFloat(sec, 64)
+			if perr != nil || seconds <= 0 || seconds > maxDeltaSeconds {
+				http.Error(w, "invalid seconds", http.StatusBadRequest)
+				return
+			}
+			b, err = deltaJSON(req, reg, time.Duration(seconds*float64(time.Second)))
+			if err == errRequestDone {
+				return
+			}
+		} else {
+			b, err = json.Marshal(reg)
+		}
+		if err == nil && req.FormValue("pretty") != "" {
+			buf := &bytes.Buffer{}
+			if err = json.Indent(buf, b, "", "  "); err == nil {
+				b = buf.Bytes()
+			}
+		}
+		if err != nil {
+			log.Printf("reporter: failed to encode registry: %s", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+// maxDeltaSeconds limits how long a request for a delta can wait.
+const maxDeltaSeconds = 3600
+
+var errRequestDone = errors.New("reporter: request done")
+
+// deltaJSON waits for window and encodes how each metric of the registry
+// changed over it, as described by JSONHandler.
+func deltaJSON(req *http.Request, registry metrics.Registry, window time.Duration) ([]byte, error) {
+	type start struct {
+		count uint64
+		sum   float64
+	}
+	starts := make(map[string]start)
+	registry.Do(func(name string, metric interface{}) error {
+		switch m := metric.(type) {
+		case metrics.Histogram:
+			d := m.Distribution()
+			starts[name] = start{d.Count, d.Sum}
+		case metrics.DistributionMetric:
+			d := m.Value()
+			starts[name] = start{d.Count, d.Sum}
+		case metrics.CounterMetric:
+			// Also meters
+			starts[name] = start{count: m.Count()}
+		}
+		return nil
+	})
+
+	select {
+	case <-time.After(window):
+	case <-req.Context().Done():
+		return nil, errRequestDone
+	}
+
+	out := make(map[string]interface{})
+	err := registry.Do(func(name string, metric interface{}) error {
+		s := starts[name]
+		switch m := metric.(type) {
+		case metrics.Histogram:
+			out[name] = distributionDelta(m.Distribution(), s.count, s.sum)
+		case metrics.DistributionMetric:
+			out[name] = distributionDelta(m.Value(), s.count, s.sum)
+		case *metrics.Meter:
+			count := counterDelta(m.Count(), s.count)
+			out[name] = map[string]interface{}{"count": count, "rate": float64(count) / window.Seconds()}
+		case metrics.CounterMetric:
+			out[name] = counterDelta(m.Count(), s.count)
+		default:
+			b, err := json.Marshal(metric)
+			if err != nil {
+				return err
+			}
+			out[name] = json.RawMessage(b)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(out)
+}
+
+// counterDelta returns the change in a counter. A counter that went down
+// was reset (or added during the window) so its current value is the change.
+func counterDelta(count, start uint64) uint64 {
+	if count < start {
+		return count
+	}
+	return count - start
+}
+
+func distributionDelta(d metrics.DistributionValue, count uint64, sum float64) map[string]interface{} {
+	if d.Count < count {
+		count, sum = 0, 0
+	}
+	v := map[string]interface{}{"count": d.Count - count, "sum": d.Sum - sum, "mean": 0.0}
+	if n := d.Count - count; n > 0 {
+		v["mean"] = (d.Sum - sum) / float64(n)
+	}
+	return v
+}
+
+// fieldAliases maps names accepted by MetricHandler to the JSON keys of
+// meters, which use the number of minutes for their rates.
+var fieldAliases = map[string]string{"1m": "1", "5m": "5", "15m": "15"}
+
+// MetricHandler returns an http.Handler that serves a single metric as JSON.
+// The metric's name is the request path after prefix, so a handler mounted
+// with prefix "/debug/metrics/" serves "requests/latency" from
+// /debug/metrics/requests/latency. The query parameter "fields" is a comma
+// separated list of fields to return from a metric with several, such as
+// "p99" for a histogram or "1m" for a meter's one minute rate. Unknown
+// metrics are not found and unknown fields are a bad request.
+func MetricHandler(registry metrics.Registry, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, prefix)
+		metric := registry.Get(name)
+		if name == "" || metric == nil {
+			http.NotFound(w, req)
+			return
+		}
+		b, err := json.Marshal(metric)
+		if err != nil {
+			log.Printf("reporter: failed to encode metric %s: %s", name, err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if fields := req.FormValue("fields"); fields != "" {
+			var all map[string]json.RawMessage
+			if json.Unmarshal(b, &all) != nil {
+				http.Error(w, "metric "+name+" has no fields", http.StatusBadRequest)
+				return
+			}
+			selected := make(map[string]json.RawMessage)
+			for _, f := range strings.Split(fields, ",") {
+				key := f
+				if alias, ok := fieldAliases[f]; ok {
+					key = alias
+				}
+				v, ok := all[key]
+				if !ok {
+					http.Error(w, "metric "+name+" has no field "+f, http.StatusBadRequest)
+					return
+				}
+				selected[f] = v
+			}
+			if b, err = json.Marshal(selected); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestJSONHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("http/requests", counter)
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(7)
+	registry.Add("db/conns", gauge)
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(10)
+	registry.Add("http/latency", h)
+
+	handler := JSONHandler(registry)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected application/json. Got %s", ct)
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if all["http/requests"] != 3.0 || all["db/conns"] != 7.0 {
+		t.Fatalf("Expected counter and gauge values. Got %s", w.Body)
+	}
+	if latency, ok := all["http/latency"].(map[string]interface{}); !ok || latency["count"] != 1.0 || latency["p99"] != 10.0 {
+		t.Fatalf("Expected histogram with percentiles. Got %s", w.Body)
+	}
+
+	// Reading doesn't reset
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics?prefix=http/&pretty=1", nil))
+	var some map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &some); err != nil {
+		t.Fatal(err)
+	}
+	if len(some) != 2 || some["http/requests"] != 3.0 {
+		t.Fatalf("Expected only http metrics. Got %s", w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "\n  ") {
+		t.Fatalf("Expected indented JSON. Got %s", w.Body)
+	}
+}
+
+func TestMetricHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("http/requests", counter)
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(10)
+	registry.Add("http/latency", h)
+	meter := metrics.NewMeter()
+	defer meter.Stop()
+	registry.Add("http/bytes", meter)
+
+	handler := MetricHandler(registry, "/debug/metrics/")
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	if w := get("/debug/metrics/http/requests"); w.Code != 200 || w.Body.String() != "3" {
+		t.Fatalf("Expected 3. Got %d %s", w.Code, w.Body)
+	}
+	w := get("/debug/metrics/http/latency?fields=p99,count")
+	var latency map[string]float64
+	if err := json.Unmarshal(w.Body.Bytes(), &latency); err != nil {
+		t.Fatal(err)
+	}
+	if len(latency) != 2 || latency["p99"] != 10 || latency["count"] != 1 {
+		t.Fatalf("Expected p99 and count. Got %s", w.Body)
+	}
+	if w := get("/debug/metrics/http/bytes?fields=1m"); w.Code != 200 || !strings.HasPrefix(w.Body.String(), `{"1m":`) {
+		t.Fatalf("Expected the one minute rate. Got %d %s", w.Code, w.Body)
+	}
+
+	for url, code := range map[string]int{
+		"/debug/metrics/missing":                    404,
+		"/debug/metrics/":                           404,
+		"/debug/metrics/http/latency?fields=p12":    400,
+		"/debug/metrics/http/requests?fields=count": 400,
+	} {
+		if w := get(url); w.Code != code {
+			t.Errorf("Expected %d for %s. Got %d", code, url, w.Code)
+		}
+	}
+}
+
+func TestJSONHandlerSeconds(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(100)
+	registry.Add("requests", counter)
+	h := metrics.NewUnbiasedHistogram()
+	h.Update(1000)
+	registry.Add("latency", h)
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(7)
+	registry.Add("conns", gauge)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		counter.Inc(5)
+		h.Update(10)
+		h.Update(20)
+	}()
+	w := httptest.NewRecorder()
+	JSONHandler(registry).ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics?seconds=0.2", nil))
+	var delta map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &delta); err != nil {
+		t.Fatalf("%s: %s", err, w.Body)
+	}
+	if delta["requests"] != 5.0 || delta["conns"] != 7.0 {
+		t.Fatalf("Expected counter delta of 5 and gauge of 7. Got %s", w.Body)
+	}
+	if latency, ok := delta["latency"].(map[string]interface{}); !ok || latency["count"] != 2.0 || latency["mean"] != 15.0 {
+		t.Fatalf("Expected 2 latencies with a mean of 15. Got %s", w.Body)
+	}
+
+	for _, seconds := range []string{"0", "x", "-1", "100000"} {
+		w := httptest.NewRecorder()
+		JSONHandler(registry).ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics?seconds="+seconds, nil))
+		if w.Code != 400 {
+			t.Errorf("Expected 400 for seconds=%s. Got %d", seconds, w.Code)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// jsonFileTimeFormat is appended to the path of rotated files.
+const jsonFileTimeFormat = "20060102T150405"
+
+type jsonFileReporter struct {
+	backendBase
+
+	path     string
+	maxSize  int64
+	maxAge   time.Duration
+	compress bool
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+type jsonFileLine struct {
+	Timestamp int64                     `json:"timestamp"`
+	Metrics   *metrics.RegistrySnapshot `json:"metrics"`
+}
+
+// NewJSONFileReporter returns a reporter that appends each snapshot to the
+// file at path as a single line of JSON so it can be picked up by a log
+// shipper. The file is rotated when writing would take it past maxSize bytes
+// or once it has been open for maxAge (either may be 0 to disable). Rotated
+// files are renamed with a timestamp suffix and gzip compressed if compress
+// is true.
+func NewJSONFileReporter(registry metrics.Registry, interval time.Duration, latched bool, path string, maxSize int64, maxAge time.Duration, compress bool) *PeriodicReporter {
+	jr := &jsonFileReporter{
+		path:     path,
+		maxSize:  maxSize,
+		maxAge:   maxAge,
+		compress: compress,
+	}
+	return NewPeriodicReporter(registry, interval, false, latched, jr)
+}
+
+func (r *jsonFileReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	now := time.Now()
+	b, err := json.Marshal(&jsonFileLine{Timestamp: now.Unix(), Metrics: snapshot})
+	if err != nil {
+		r.errorf("", "jsonfile: failed to encode metrics: %w", err)
+		r.drop(snapshot.Len())
+		return
+	}
+	b = append(b, '\n')
+	if r.file != nil && r.shouldRotate(now, int64(len(b))) {
+		if err := r.rotate(now); err != nil {
+			r.errorf("", "jsonfile: failed to rotate %s: %w", r.path, err)
+		}
+	}
+	if r.file == nil {
+		if err := r.open(now); err != nil {
+			r.errorf("", "jsonfile: failed to open %s: %w", r.path, err)
+			r.drop(snapshot.Len())
+			return
+		}
+	}
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	if err != nil {
+		r.errorf("", "jsonfile: failed to write metrics: %w", err)
+		r.drop(snapshot.Len())
+	}
+}
+
+func (r *jsonFileReporter) close() {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			r.errorf("", "jsonfile: failed to close %s: %w", r.path, err)
+		}
+		r.file = nil
+	}
+}
+
+func (r *jsonFileReporter) shouldRotate(now time.Time, n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	return (r.maxSize > 0 && r.size+n > r.maxSize) || (r.maxAge > 0 && now.Sub(r.openedAt) >= r.maxAge)
+}
+
+func (r *jsonFileReporter) open(now time.Time) error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = fi.Size()
+	r.openedAt = now
+	return nil
+}
+
+func (r *jsonFileReporter) rotate(now time.Time) error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return err
+	}
+	rotated := r.path + "." + now.Format(jsonFileTimeFormat)
+	for i := 1; fileExists(rotated) || fileExists(rotated+".gz"); i++ {
+		rotated = r.path + "." + now.Format(jsonFileTimeFormat) + "." + strconv.Itoa(i)
+	}
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	if r.compress {
+		return gzipFile(rotated)
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipFile compresses path to path.gz and removes the original.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		zw.Close()
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestJSONFileReporter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.json")
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	registry.Add("requests", counter)
+	snapshot := metrics.NewRegistrySnapshot(true)
+
+	r := &jsonFileReporter{path: path, maxSize: 1, compress: true}
+	for i := 0; i < 2; i++ {
+		counter.Inc(uint64(i + 1))
+		snapshot.Snapshot(registry)
+		r.Report(snapshot)
+	}
+	r.close()
+	if r.file != nil {
+		t.Fatal("Expected the file to be closed")
+	}
+
+	// The second report rotated the file containing the first
+	var line struct {
+		Metrics map[string]float64
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Metrics["requests"] != 2 {
+		t.Fatalf("Expected 2 requests in current file. Got %+v", line)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*.gz")
+	if len(rotated) != 1 {
+		t.Fatalf("Expected one rotated file. Got %+v", rotated)
+	}
+	gf, err := os.Open(rotated[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gf.Close()
+	zr, err := gzip.NewReader(gf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := bufio.NewScanner(zr)
+	if !s.Scan() {
+		t.Fatal("Expected a line in the rotated file")
+	}
+	if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Metrics["requests"] != 1 {
+		t.Fatalf("Expected 1 request in rotated file. Got %+v", line)
+	}
+}
+
+func TestJSONFileReporterStop(t *testing.T) {
+	r := NewJSONFileReporter(metrics.NewRegistry(), time.Hour, false, filepath.Join(t.TempDir(), "metrics.json"), 0, 0, false)
+	r.Start()
+	r.Flush()
+	r.Stop()
+	if f := r.reporter.(*jsonFileReporter).file; f != nil {
+		t.Fatalf("Expected Stop to close the file. Got %v", f.Name())
+	}
+}
@@ -5,7 +5,11 @@
 package reporter
 
 import (
-	"log"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"strings"
 	"time"
 
@@ -13,11 +17,24 @@ import (
 	"github.com/samuel/go-metrics/metrics"
 )
 
+const (
+	appOpticsEndpoint = "https://api.appoptics.com/v1/measurements"
+
+	// appOpticsMaxMeasurements is the number of measurements sent per request.
+	appOpticsMaxMeasurements = 300
+)
+
 type libratoReporter struct {
+	backendBase
 	source string
 	client *librato.Client
 }
 
+// NewLibratoReporter returns a reporter that posts to the Librato metrics
+// API with all metrics attributed to source. Values (including counters,
+// which are sent as the change since the last report) are posted as gauges
+// and distributions as complex gauges with count, sum, min, max and
+// sum_squares so Librato can derive the mean and standard deviation.
 func NewLibratoReporter(registry metrics.Registry, interval time.Duration, latched bool, username, token, source string) *PeriodicReporter {
 	lr := &libratoReporter{
 		source: source,
@@ -27,6 +44,7 @@ func NewLibratoReporter(registry metrics.Registry, interval time.Duration, latch
 }
 
 func (r *libratoReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
 	mets := &librato.Metrics{Source: r.source}
 
 	for _, v := range snapshot.Values {
@@ -45,14 +63,173 @@ func (r *libratoReporter) Report(snapshot *metrics.RegistrySnapshot) {
 					Sum:        v.Value.Sum,
 					Min:        v.Value.Min,
 					Max:        v.Value.Max,
-					SumSquares: v.Value.Variance,
+					SumSquares: sumSquares(v.Value),
 				})
 		}
 	}
 
 	if len(mets.Gauges) > 0 {
-		if err := r.client.PostMetrics(mets); err != nil {
-			log.Printf("ERR librato.PostMetrics: %+v", err)
+		if err := r.send(func() error { return r.client.PostMetrics(mets) }); err != nil {
+			r.errorf("", "librato: failed to post metrics: %w", err)
+			r.drop(len(mets.Gauges))
+		}
+	}
+}
+
+// sumSquares returns the sum of the squared deviations from the mean
+// which is what Librato expects for sum_squares. The distribution holds
+// the sample variance.
+func sumSquares(v metrics.DistributionValue) float64 {
+	if v.Count < 2 {
+		return 0
+	}
+	return v.Variance * float64(v.Count-1)
+}
+
+type appOpticsReporter struct {
+	backendBase
+	endpoint string
+	token    string
+	tags     map[string]string
+	tagFunc  TagFunc
+	client   *http.Client
+}
+
+type appOpticsMeasurement struct {
+	Name  string   `json:"name"`
+	Value *float64 `json:"value,omitempty"`
+	*appOpticsSummary
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// appOpticsSummary holds the fields of a summary measurement which must be
+// sent together even when some are zero.
+type appOpticsSummary struct {
+	Count      uint64  `json:"count"`
+	Sum        float64 `json:"sum"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	SumSquares float64 `json:"sum_squares"`
+}
+
+type appOpticsPayload struct {
+	Time         int64                  `json:"time"`
+	Tags         map[string]string      `json:"tags,omitempty"`
+	Measurements []appOpticsMeasurement `json:"measurements"`
+}
+
+// NewAppOpticsReporter returns a reporter that posts tagged measurements
+// to the AppOptics (Librato) measurements API. The tags are applied to all
+// measurements. If tagFunc is not nil then any "key:value" tags it returns
+// for a metric name are added to that measurement. Values and distributions
+// are reported the same way as NewLibratoReporter.
+func NewAppOpticsReporter(registry metrics.Registry, interval time.Duration, latched bool, token string, tags map[string]string, tagFunc TagFunc) *PeriodicReporter {
+	ar := newAppOpticsReporter(appOpticsEndpoint, token, tags, tagFunc)
+	return NewPeriodicReporter(registry, interval, true, latched, ar)
+}
+
+func newAppOpticsReporter(endpoint, token string, tags map[string]string, tagFunc TagFunc) *appOpticsReporter {
+	return &appOpticsReporter{
+		endpoint: endpoint,
+		token:    token,
+		tags:     tags,
+		tagFunc:  tagFunc,
+		client:   &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+func (r *appOpticsReporter) measurement(name string) appOpticsMeasurement {
+	var tags map[string]string
+	if r.tagFunc != nil {
+		var extra []string
+		name, extra = r.tagFunc(name)
+		for _, t := range extra {
+			if tags == nil {
+				tags = make(map[string]string, len(r.tags)+len(extra))
+				for k, v := range r.tags {
+					tags[k] = v
+				}
+			}
+			if i := strings.IndexByte(t, ':'); i > 0 {
+				tags[t[:i]] = t[i+1:]
+			}
 		}
 	}
+	return appOpticsMeasurement{Name: strings.Replace(name, "/", ".", -1), Tags: tags}
+}
+
+func (r *appOpticsReporter) measurements(snapshot *metrics.RegistrySnapshot) []appOpticsMeasurement {
+	ms := make([]appOpticsMeasurement, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		m := r.measurement(v.Name)
+		value := v.Value
+		m.Value = &value
+		ms = append(ms, m)
+	}
+	for _, v := range snapshot.Distributions {
+		m := r.measurement(v.Name)
+		if v.Value.Count == 0 {
+			zero := 0.0
+			m.Value = &zero
+		} else {
+			m.appOpticsSummary = &appOpticsSummary{
+				Count:      v.Value.Count,
+				Sum:        v.Value.Sum,
+				Min:        v.Value.Min,
+				Max:        v.Value.Max,
+				SumSquares: sumSquares(v.Value),
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func (r *appOpticsReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	ms := r.measurements(snapshot)
+	now := time.Now().Unix()
+	for len(ms) > 0 {
+		n := len(ms)
+		if n > appOpticsMaxMeasurements {
+			n = appOpticsMaxMeasurements
+		}
+		payload := &appOpticsPayload{Time: now, Tags: r.tags, Measurements: ms[:n]}
+		if err := r.send(func() error { return r.post(payload) }); err != nil {
+			r.errorf("", "appoptics: failed to send metrics: %w", err)
+			r.drop(n)
+		}
+		ms = ms[n:]
+	}
+}
+
+func (r *appOpticsReporter) post(payload *appOpticsPayload) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(r.token, "")
+	req.Header.Set("Content-Type", "application/json")
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+func (r *appOpticsReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
+
+func (r *appOpticsReporter) addTags(tags map[string]string) {
+	r.tags = mergeTags(r.tags, tags)
 }
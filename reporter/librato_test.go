@@ -0,0 +1,121 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestSumSquares(t *testing.T) {
+	dist := metrics.NewDistribution()
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		dist.Update(v)
+	}
+	// Squared deviations from the mean of 5
+	if ss := sumSquares(dist.Value()); math.Abs(ss-32) > 1e-9 {
+		t.Fatalf("Expected sum_squares of 32. Got %f", ss)
+	}
+}
+
+func TestAppOpticsReporter(t *testing.T) {
+	var got struct {
+		Tags         map[string]string `json:"tags"`
+		Measurements []struct {
+			Name       string            `json:"name"`
+			Value      *float64          `json:"value"`
+			Count      uint64            `json:"count"`
+			Sum        float64           `json:"sum"`
+			SumSquares float64           `json:"sum_squares"`
+			Tags       map[string]string `json:"tags"`
+		} `json:"measurements"`
+	}
+	var user string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _, _ = req.BasicAuth()
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("requests/status:500", counter)
+	dist := metrics.NewDistribution()
+	dist.Update(1)
+	dist.Update(3)
+	registry.Add("latency", dist)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	tagFunc := func(name string) (string, []string) {
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			return name[:i], []string{name[i+1:]}
+		}
+		return name, nil
+	}
+	r := newAppOpticsReporter(server.URL, "token", map[string]string{"host": "web1"}, tagFunc)
+	r.Report(snapshot)
+
+	if user != "token" {
+		t.Fatalf("Expected basic auth user token. Got %s", user)
+	}
+	if got.Tags["host"] != "web1" || len(got.Measurements) != 2 {
+		t.Fatalf("Unexpected payload %+v", got)
+	}
+	m := got.Measurements[0]
+	if m.Name != "requests" || m.Value == nil || *m.Value != 3 || m.Tags["status"] != "500" || m.Tags["host"] != "web1" {
+		t.Fatalf("Unexpected measurement %+v", m)
+	}
+	m = got.Measurements[1]
+	if m.Name != "latency" || m.Count != 2 || m.Sum != 4 || m.SumSquares != 2 || m.Tags != nil {
+		t.Fatalf("Unexpected measurement %+v", m)
+	}
+}
+
+func TestAppOpticsZeroSummary(t *testing.T) {
+	var got []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload struct {
+			Measurements []map[string]interface{} `json:"measurements"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		got = payload.Measurements
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	dist := metrics.NewDistribution()
+	dist.Update(0)
+	dist.Update(0)
+	registry.Add("idle", dist)
+	registry.Add("gauge", metrics.GaugeValue(1))
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	newAppOpticsReporter(server.URL, "token", nil, nil).Report(snapshot)
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 measurements. Got %+v", got)
+	}
+	if _, ok := got[0]["count"]; ok {
+		t.Fatalf("Expected no summary fields for a value. Got %+v", got[0])
+	}
+	for _, field := range []string{"count", "sum", "min", "max", "sum_squares"} {
+		if _, ok := got[1][field]; !ok {
+			t.Fatalf("Expected %s in a summary with zero sum. Got %+v", field, got[1])
+		}
+	}
+}
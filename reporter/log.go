@@ -0,0 +1,69 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+type logReporter struct {
+	logger *log.Logger
+}
+
+// NewLogReporter returns a reporter that prints an aligned summary of all
+// metrics to logger every interval, one line per metric. If logger is nil
+// the standard logger is used. To write to an io.Writer without log
+// prefixes use log.New(w, "", 0).
+func NewLogReporter(registry metrics.Registry, interval time.Duration, latched bool, logger *log.Logger) *PeriodicReporter {
+	return NewPeriodicReporter(registry, interval, false, latched, &logReporter{logger})
+}
+
+func (r *logReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	b := &bytes.Buffer{}
+	formatSummary(b, snapshot)
+	s := bufio.NewScanner(b)
+	for s.Scan() {
+		if r.logger != nil {
+			r.logger.Print(s.Text())
+		} else {
+			log.Print(s.Text())
+		}
+	}
+}
+
+// formatSummary writes a table of the snapshot's metrics in sorted order.
+func formatSummary(b *bytes.Buffer, snapshot *metrics.RegistrySnapshot) {
+	tw := tabwriter.NewWriter(b, 0, 4, 2, ' ', 0)
+	snapshot.DoSorted(func(name string, metric interface{}) error {
+		switch m := metric.(type) {
+		case metrics.GaugeValue:
+			typ := "gauge"
+			if snapshot.IsCounter(name) {
+				typ = "counter"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, typ, formatFloat(float64(m)))
+		case metrics.NamedDistribution:
+			v := m.Value
+			fmt.Fprintf(tw, "%s\tdistribution\tcount=%d mean=%s min=%s max=%s stddev=%s\n",
+				name, v.Count, formatFloat(v.Mean()), formatFloat(v.Min), formatFloat(v.Max),
+				formatFloat(math.Sqrt(v.Variance)))
+		}
+		return nil
+	})
+	tw.Flush()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
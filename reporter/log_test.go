@@ -0,0 +1,40 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestLogReporter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(3)
+	registry.Add("requests", counter)
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(12)
+	registry.Add("pool/size", gauge)
+	dist := metrics.NewDistribution()
+	dist.Update(1)
+	dist.Update(3)
+	registry.Add("latency", dist)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	b := &bytes.Buffer{}
+	r := &logReporter{log.New(b, "", 0)}
+	r.Report(snapshot)
+
+	exp := "latency    distribution  count=2 mean=2 min=1 max=3 stddev=1.4142135623730951\n" +
+		"pool/size  gauge         12\n" +
+		"requests   counter       3\n"
+	if b.String() != exp {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", exp, b.String())
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Logger is the logging interface used by reporters. A *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// SlogLogger returns a Logger that writes messages to logger at level.
+func SlogLogger(logger *slog.Logger, level slog.Level) Logger {
+	return &slogLogger{logger: logger, level: level}
+}
+
+func (l *slogLogger) Printf(format string, v ...interface{}) {
+	l.logger.Log(context.Background(), l.level, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,37 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestSetLogger(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("requests", metrics.NewCounter())
+
+	b := &bytes.Buffer{}
+	r := NewPeriodicReporter(registry, time.Minute, false, true, &failingBackend{})
+	r.SetLogger(log.New(b, "", 0))
+	r.Flush()
+	if exp := "failing: failed to post metric requests: backend down\n"; b.String() != exp {
+		t.Fatalf("Expected %q. Got %q", exp, b.String())
+	}
+
+	b.Reset()
+	r = NewPeriodicReporter(registry, time.Minute, false, true, &testBackend{panics: true})
+	r.SetLogger(SlogLogger(slog.New(slog.NewTextHandler(b, nil)), slog.LevelError))
+	r.Flush()
+	if s := b.String(); !strings.Contains(s, "level=ERROR") || !strings.Contains(s, "backend failure") {
+		t.Fatalf("Unexpected log output %q", s)
+	}
+}
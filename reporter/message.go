@@ -0,0 +1,25 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// messageValue is the message published for a single value by the
+// message bus reporters.
+type messageValue struct {
+	Name      string  `json:"name"`
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Counter   bool    `json:"counter,omitempty"`
+}
+
+// messageDistribution is the message published for a distribution.
+type messageDistribution struct {
+	Name      string                    `json:"name"`
+	Timestamp int64                     `json:"timestamp"`
+	Value     metrics.DistributionValue `json:"value"`
+}
@@ -0,0 +1,143 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"crypto/tls"
+	"os"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const mqttTimeout = 10 * time.Second
+
+// mqttTopicReplacer replaces the wildcards and null character, which may
+// not appear in the topic of a published message. Slashes are kept as
+// topic level separators.
+var mqttTopicReplacer = strings.NewReplacer("+", "_", "#", "_", "\x00", "_")
+
+// MQTTConfig configures the broker connection and how metrics are
+// published by the MQTT reporter.
+type MQTTConfig struct {
+	// Broker is the broker URL such as "tcp://broker:1883" or
+	// "ssl://broker:8883" for TLS.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TLSConfig is used for "ssl://", "tls://" and "wss://" brokers.
+	TLSConfig *tls.Config
+
+	// Topic is a template for the topic of each metric. The placeholders
+	// {name} and {host} are replaced by the metric name (which keeps its
+	// '/' separators as topic levels, with the '+' and '#' wildcards
+	// replaced by '_') and the local hostname. It defaults to
+	// "metrics/{host}/{name}".
+	Topic    string
+	QoS      byte
+	Retained bool
+}
+
+// mqttClient is the subset of mqtt.Client used by the reporter.
+type mqttClient interface {
+	IsConnected() bool
+	Connect() mqtt.Token
+	Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token
+}
+
+type mqttReporter struct {
+	backendBase
+	messageCodec
+	client   mqttClient
+	topic    string
+	qos      byte
+	retained bool
+}
+
+// NewMQTTReporter returns a reporter that publishes each metric as a JSON
+// message to an MQTT broker. The connection is made on the first report
+// and the client reconnects automatically if it is lost. Messages are JSON
+// unless another codec is set with SetCodec.
+func NewMQTTReporter(registry metrics.Registry, interval time.Duration, latched bool, config MQTTConfig) *PeriodicReporter {
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(mqttTimeout)
+	if config.TLSConfig != nil {
+		opts.SetTLSConfig(config.TLSConfig)
+	}
+	mr := newMQTTReporter(mqtt.NewClient(opts), config)
+	return NewPeriodicReporter(registry, interval, false, latched, mr)
+}
+
+func newMQTTReporter(client mqttClient, config MQTTConfig) *mqttReporter {
+	template := config.Topic
+	if template == "" {
+		template = "metrics/{host}/{name}"
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &mqttReporter{
+		client:   client,
+		topic:    strings.Replace(template, "{host}", host, -1),
+		qos:      config.QoS,
+		retained: config.Retained,
+	}
+}
+
+func (r *mqttReporter) topicName(name string) string {
+	return strings.Replace(r.topic, "{name}", mqttTopicReplacer.Replace(name), -1)
+}
+
+func (r *mqttReporter) publish(name string, msg interface{}) {
+	b, err := r.marshal(msg)
+	if err != nil {
+		r.errorf(name, "mqtt: failed to encode metric %s: %w", name, err)
+		r.drop(1)
+		return
+	}
+	token := r.client.Publish(r.topicName(name), r.qos, r.retained, b)
+	if r.qos == 0 {
+		return
+	}
+	if !token.WaitTimeout(mqttTimeout) {
+		r.errorf(name, "mqtt: timed out publishing metric %s", name)
+		r.drop(1)
+	} else if err := token.Error(); err != nil {
+		r.errorf(name, "mqtt: failed to publish metric %s: %w", name, err)
+		r.drop(1)
+	}
+}
+
+func (r *mqttReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	if !r.client.IsConnected() {
+		token := r.client.Connect()
+		if !token.WaitTimeout(mqttTimeout) {
+			r.errorf("", "mqtt: timed out connecting to broker")
+			r.drop(snapshot.Len())
+			return
+		}
+		if err := token.Error(); err != nil {
+			r.errorf("", "mqtt: failed to connect: %w", err)
+			r.drop(snapshot.Len())
+			return
+		}
+	}
+	ts := time.Now().Unix()
+	for _, v := range snapshot.Values {
+		r.publish(v.Name, &messageValue{Name: v.Name, Timestamp: ts, Value: v.Value, Counter: snapshot.IsCounter(v.Name)})
+	}
+	for _, v := range snapshot.Distributions {
+		r.publish(v.Name, &messageDistribution{Name: v.Name, Timestamp: ts, Value: v.Value})
+	}
+}
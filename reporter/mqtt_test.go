@@ -0,0 +1,99 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+type testToken struct{}
+
+func (testToken) Wait() bool                     { return true }
+func (testToken) WaitTimeout(time.Duration) bool { return true }
+func (testToken) Done() <-chan struct{}          { ch := make(chan struct{}); close(ch); return ch }
+func (testToken) Error() error                   { return nil }
+
+// timeoutToken never completes.
+type timeoutToken struct{ testToken }
+
+func (timeoutToken) WaitTimeout(time.Duration) bool { return false }
+
+type testMQTTClient struct {
+	connected bool
+	retained  bool
+	qos       byte
+	messages  map[string][]byte
+	token     mqtt.Token
+}
+
+func (c *testMQTTClient) IsConnected() bool { return c.connected }
+
+func (c *testMQTTClient) Connect() mqtt.Token {
+	c.connected = true
+	return testToken{}
+}
+
+func (c *testMQTTClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.qos = qos
+	c.retained = retained
+	c.messages[topic] = payload.([]byte)
+	if c.token != nil {
+		return c.token
+	}
+	return testToken{}
+}
+
+func TestMQTTReporter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(21)
+	registry.Add("sensor/temp", gauge)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	client := &testMQTTClient{messages: make(map[string][]byte)}
+	r := newMQTTReporter(client, MQTTConfig{Topic: "site/{host}/{name}", QoS: 1, Retained: true})
+	r.Report(snapshot)
+
+	if !client.connected {
+		t.Fatal("Expected reporter to connect")
+	}
+	if client.qos != 1 || !client.retained {
+		t.Fatalf("Expected QoS 1 and retained. Got %d %t", client.qos, client.retained)
+	}
+	host, _ := os.Hostname()
+	var v messageValue
+	if err := json.Unmarshal(client.messages["site/"+host+"/sensor/temp"], &v); err != nil {
+		t.Fatalf("Missing message for topic: %s (%v)", err, client.messages)
+	}
+	if v.Value != 21 {
+		t.Fatalf("Expected 21. Got %f", v.Value)
+	}
+}
+
+func TestMQTTReporterTimeout(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("queue/#1+2", metrics.GaugeValue(1))
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	client := &testMQTTClient{connected: true, messages: make(map[string][]byte), token: timeoutToken{}}
+	r := newMQTTReporter(client, MQTTConfig{Topic: "metrics/{name}", QoS: 1})
+	r.handler = func(metric string, err error) {}
+	r.Report(snapshot)
+
+	if _, ok := client.messages["metrics/queue/_1_2"]; !ok {
+		t.Fatalf("Expected wildcards replaced in topic. Got %v", client.messages)
+	}
+	if r.errorCount() != 1 || r.dropped != 1 {
+		t.Fatalf("Expected a timed out publish to fail. Got %d errors and %d dropped", r.errorCount(), r.dropped)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// publisher is the subset of *nats.Conn used by the NATS reporter.
+type publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+type natsReporter struct {
+	backendBase
+	messageCodec
+	conn   publisher
+	prefix string
+}
+
+var natsSubjectReplacer = strings.NewReplacer("/", ".", " ", "_", "*", "_", ">", "_")
+
+// NewNATSReporter returns a reporter that publishes each metric as a JSON
+// message on the subject prefix.<name> (such as "metrics.api.requests")
+// using an existing connection. Slashes in metric names become subject
+// token separators so subscribers can use wildcards like "metrics.api.>".
+// Messages are JSON unless another codec is set with SetCodec. The
+// connection is not closed by the reporter.
+func NewNATSReporter(registry metrics.Registry, interval time.Duration, latched bool, conn *nats.Conn, prefix string) *PeriodicReporter {
+	nr := &natsReporter{
+		conn:   conn,
+		prefix: prefix,
+	}
+	return NewPeriodicReporter(registry, interval, false, latched, nr)
+}
+
+func (r *natsReporter) subject(name string) string {
+	name = natsSubjectReplacer.Replace(name)
+	if r.prefix != "" {
+		return r.prefix + "." + name
+	}
+	return name
+}
+
+func (r *natsReporter) publish(name string, msg interface{}) {
+	b, err := r.marshal(msg)
+	if err != nil {
+		r.errorf(name, "nats: failed to encode metric %s: %w", name, err)
+		r.drop(1)
+		return
+	}
+	if err := r.conn.Publish(r.subject(name), b); err != nil {
+		r.errorf(name, "nats: failed to publish metric %s: %w", name, err)
+		r.drop(1)
+	}
+}
+
+func (r *natsReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	ts := time.Now().Unix()
+	for _, v := range snapshot.Values {
+		r.publish(v.Name, &messageValue{Name: v.Name, Timestamp: ts, Value: v.Value, Counter: snapshot.IsCounter(v.Name)})
+	}
+	for _, v := range snapshot.Distributions {
+		r.publish(v.Name, &messageDistribution{Name: v.Name, Timestamp: ts, Value: v.Value})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+type testPublisher map[string][]byte
+
+func (p testPublisher) Publish(subject string, data []byte) error {
+	p[subject] = data
+	return nil
+}
+
+func TestNATSReporter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(2)
+	registry.Add("http/requests", counter)
+	dist := metrics.NewDistribution()
+	dist.Update(5)
+	registry.Add("latency", dist)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	pub := testPublisher{}
+	r := &natsReporter{conn: pub, prefix: "metrics.api"}
+	r.Report(snapshot)
+
+	var v messageValue
+	if err := json.Unmarshal(pub["metrics.api.http.requests"], &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "http/requests" || v.Value != 2 || !v.Counter {
+		t.Fatalf("Unexpected message %+v", v)
+	}
+	var d struct {
+		Value struct{ Count uint64 }
+	}
+	if err := json.Unmarshal(pub["metrics.api.latency"], &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Value.Count != 1 {
+		t.Fatalf("Unexpected distribution %+v", d)
+	}
+}
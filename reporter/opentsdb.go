@@ -0,0 +1,251 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const (
+	// openTSDBMaxDataPoints is the number of data points sent per HTTP
+	// request.
+	openTSDBMaxDataPoints = 50
+
+	openTSDBDialTimeout  = 5 * time.Second
+	openTSDBWriteTimeout = 10 * time.Second
+)
+
+type openTSDBReporter struct {
+	backendBase
+	url     string // HTTP /api/put endpoint if set
+	addr    string // telnet address otherwise
+	tags    map[string]string
+	tagFunc TagFunc
+	client  *http.Client
+	conn    net.Conn // telnet connection kept open between reports
+}
+
+type openTSDBDataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// NewOpenTSDBReporter returns a reporter that pushes metrics to OpenTSDB.
+// If addr is a URL such as "http://tsdb:4242" the HTTP /api/put endpoint is
+// used, otherwise addr is a host:port for the telnet protocol. OpenTSDB
+// requires every data point to have a tag so if tags is empty a host tag
+// is added using the local hostname. If tagFunc is not nil then any
+// "key:value" tags it returns for a metric name are added to its points.
+// Characters OpenTSDB does not allow in tags are replaced by '_'.
+// Distributions are reported as count, mean, min and max.
+func NewOpenTSDBReporter(registry metrics.Registry, interval time.Duration, latched bool, addr string, tags map[string]string, tagFunc TagFunc) *PeriodicReporter {
+	return NewPeriodicReporter(registry, interval, true, latched, newOpenTSDBReporter(addr, tags, tagFunc))
+}
+
+func newOpenTSDBReporter(addr string, tags map[string]string, tagFunc TagFunc) *openTSDBReporter {
+	if len(tags) == 0 {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		tags = map[string]string{"host": host}
+	}
+	sanitized := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sanitized[openTSDBSanitize(k)] = openTSDBSanitize(v)
+	}
+	tags = sanitized
+	r := &openTSDBReporter{
+		tags:    tags,
+		tagFunc: tagFunc,
+	}
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		r.url = strings.TrimRight(addr, "/") + "/api/put"
+		r.client = &http.Client{Timeout: defaultHTTPTimeout}
+	} else {
+		r.addr = addr
+	}
+	return r
+}
+
+func (r *openTSDBReporter) dataPoint(name string, ts int64, value float64) openTSDBDataPoint {
+	tags := r.tags
+	if r.tagFunc != nil {
+		var extra []string
+		name, extra = r.tagFunc(name)
+		if len(extra) > 0 {
+			tags = make(map[string]string, len(r.tags)+len(extra))
+			for k, v := range r.tags {
+				tags[k] = v
+			}
+			for _, t := range extra {
+				if i := strings.IndexByte(t, ':'); i > 0 {
+					tags[openTSDBSanitize(t[:i])] = openTSDBSanitize(t[i+1:])
+				}
+			}
+		}
+	}
+	return openTSDBDataPoint{
+		Metric:    openTSDBSanitize(strings.Replace(name, "/", ".", -1)),
+		Timestamp: ts,
+		Value:     value,
+		Tags:      tags,
+	}
+}
+
+func (r *openTSDBReporter) dataPoints(snapshot *metrics.RegistrySnapshot, ts int64) []openTSDBDataPoint {
+	points := make([]openTSDBDataPoint, 0, len(snapshot.Values)+len(snapshot.Distributions)*4)
+	for _, v := range snapshot.Values {
+		points = append(points, r.dataPoint(v.Name, ts, v.Value))
+	}
+	for _, v := range snapshot.Distributions {
+		points = append(points,
+			r.dataPoint(v.Name+"/count", ts, float64(v.Value.Count)),
+			r.dataPoint(v.Name+"/mean", ts, v.Value.Mean()),
+			r.dataPoint(v.Name+"/min", ts, v.Value.Min),
+			r.dataPoint(v.Name+"/max", ts, v.Value.Max),
+		)
+	}
+	return points
+}
+
+func (r *openTSDBReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	points := r.dataPoints(snapshot, time.Now().Unix())
+	if len(points) == 0 {
+		return
+	}
+	if r.url == "" {
+		if err := r.send(func() error { return r.putTelnet(points) }); err != nil {
+			r.errorf("", "opentsdb: failed to send metrics: %w", err)
+			r.drop(len(points))
+		}
+		return
+	}
+	for len(points) > 0 {
+		n := len(points)
+		if n > openTSDBMaxDataPoints {
+			n = openTSDBMaxDataPoints
+		}
+		batch := points[:n]
+		if err := r.send(func() error { return r.putHTTP(batch) }); err != nil {
+			r.errorf("", "opentsdb: failed to send metrics: %w", err)
+			r.drop(n)
+		}
+		points = points[n:]
+	}
+}
+
+func (r *openTSDBReporter) putHTTP(points []openTSDBDataPoint) error {
+	b, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	res, err := r.client.Post(r.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+func (r *openTSDBReporter) putTelnet(points []openTSDBDataPoint) error {
+	if r.conn == nil {
+		conn, err := net.DialTimeout("tcp", r.addr, openTSDBDialTimeout)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+	w := bufio.NewWriter(deadlineWriter{r.conn, openTSDBWriteTimeout})
+	for _, p := range points {
+		w.WriteString(openTSDBPutLine(p))
+	}
+	if err := w.Flush(); err != nil {
+		// Reconnect on the next attempt
+		r.close()
+		return err
+	}
+	return nil
+}
+
+func (r *openTSDBReporter) close() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// deadlineWriter sets a write deadline on a connection before each write
+// so a stalled server cannot block the reporter.
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w deadlineWriter) Write(p []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	return w.conn.Write(p)
+}
+
+// openTSDBPutLine formats a data point as a telnet put command with the
+// tags in sorted order.
+func openTSDBPutLine(p openTSDBDataPoint) string {
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "put %s %d %s", p.Metric, p.Timestamp, strconv.FormatFloat(p.Value, 'f', -1, 64))
+	for _, k := range keys {
+		fmt.Fprintf(b, " %s=%s", k, p.Tags[k])
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// openTSDBSanitize replaces characters OpenTSDB does not allow in metric
+// names and tags with '_'.
+func openTSDBSanitize(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '/') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func (r *openTSDBReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
+
+func (r *openTSDBReporter) addTags(tags map[string]string) {
+	sanitized := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sanitized[openTSDBSanitize(k)] = openTSDBSanitize(v)
+	}
+	r.tags = mergeTags(r.tags, sanitized)
+}
@@ -0,0 +1,93 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestOpenTSDBHTTP(t *testing.T) {
+	var requests [][]openTSDBDataPoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/put" {
+			t.Errorf("Unexpected path %s", req.URL.Path)
+		}
+		var points []openTSDBDataPoint
+		if err := json.NewDecoder(req.Body).Decode(&points); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, points)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	for i := 0; i < openTSDBMaxDataPoints+1; i++ {
+		registry.Add(string(rune('a'+i%26))+string(rune('a'+i/26)), metrics.NewCounter())
+	}
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	r := newOpenTSDBReporter(server.URL, map[string]string{"host": "web1"}, nil)
+	r.Report(snapshot)
+
+	if len(requests) != 2 || len(requests[0]) != openTSDBMaxDataPoints || len(requests[1]) != 1 {
+		t.Fatalf("Expected 2 chunked requests. Got %d", len(requests))
+	}
+	if requests[0][0].Tags["host"] != "web1" {
+		t.Fatalf("Expected host tag. Got %+v", requests[0][0])
+	}
+}
+
+func TestOpenTSDBTelnet(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	registry := metrics.NewRegistry()
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(12)
+	registry.Add("queue/depth name:jobs q", gauge)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	r := newOpenTSDBReporter(ln.Addr().String(), map[string]string{"host": "web1", "dc": "us east"}, nil)
+	defer r.close()
+	go func() {
+		r.Report(snapshot)
+		r.Report(snapshot)
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	br := bufio.NewReader(conn)
+	// Both reports are sent on the same connection
+	for i := 0; i < 2; i++ {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "put queue.depth_name_jobs_q ") || !strings.HasSuffix(line, " 12 dc=us_east host=web1\n") {
+			t.Fatalf("Unexpected line %q", line)
+		}
+	}
+	p := openTSDBDataPoint{Metric: "queue.depth_name_jobs_q", Value: 12, Tags: map[string]string{"host": "web1"}}
+	if exp := "put queue.depth_name_jobs_q 0 12 host=web1\n"; openTSDBPutLine(p) != exp {
+		t.Fatalf("Expected %q. Got %q", exp, openTSDBPutLine(p))
+	}
+}
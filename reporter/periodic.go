@@ -5,26 +5,63 @@
 package reporter
 
 import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/samuel/go-metrics/metrics"
 )
 
+// Reporter is implemented by reporters that periodically send metrics.
+type Reporter interface {
+	// Start begins reporting in the background.
+	Start()
+	// Stop ends reporting and makes one final report before returning so
+	// metrics from the last partial interval aren't lost.
+	Stop()
+	// Flush reports immediately.
+	Flush()
+}
+
+// Backend sends a snapshot of metrics somewhere. A PeriodicReporter takes
+// care of snapshotting the registry so backends only implement Report.
+type Backend interface {
+	Report(snapshot *metrics.RegistrySnapshot)
+}
+
+// PeriodicReporter is a Reporter that snapshots a registry every interval
+// and passes the snapshot to a Backend.
 type PeriodicReporter struct {
+	// Self metrics, accessed atomically so kept first for alignment.
+	reports    uint64
+	datapoints uint64
+	reportTime int64 // nanoseconds taken by the last report
+
 	registry      metrics.Registry
 	interval      time.Duration
 	alignInterval bool
-	ticker        *time.Ticker
-	closeChan     chan bool
-	reporter      Reporter
-	snapshot      *metrics.RegistrySnapshot
-}
+	randomPhase   bool
+	jitter        time.Duration
+	reportOnStart bool
+	prefix        string
+	reporter      Backend
+	logger        Logger
 
-type Reporter interface {
-	Report(snapshot *metrics.RegistrySnapshot)
+	mu       sync.Mutex // serializes reports and guards snapshot
+	snapshot *metrics.RegistrySnapshot
+
+	runMu     sync.Mutex // guards closeChan and doneChan
+	closeChan chan struct{}
+	doneChan  chan struct{}
 }
 
-func NewPeriodicReporter(registry metrics.Registry, interval time.Duration, alignInterval, latched bool, reporter Reporter) *PeriodicReporter {
+var _ Reporter = &PeriodicReporter{}
+
+func NewPeriodicReporter(registry metrics.Registry, interval time.Duration, alignInterval, latched bool, reporter Backend) *PeriodicReporter {
 	return &PeriodicReporter{
 		registry:      registry,
 		interval:      interval,
@@ -34,44 +71,302 @@ func NewPeriodicReporter(registry metrics.Registry, interval time.Duration, alig
 	}
 }
 
+// SetCounterMode sets whether counters are reported as the change since the
+// previous report (the default) or as cumulative totals, depending on what
+// the backend expects.
+func (r *PeriodicReporter) SetCounterMode(mode metrics.CounterMode) {
+	r.mu.Lock()
+	r.snapshot.SetCounterMode(mode)
+	r.mu.Unlock()
+}
+
+// SetPercentiles sets the percentiles reported for histograms in place of
+// metrics.DefaultPercentileConfig.
+func (r *PeriodicReporter) SetPercentiles(config metrics.PercentileConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshot.SetPercentiles(config)
+}
+
+// SetRateUnit sets the unit of time that meter and EWMA rates are reported
+// per. The default is per second.
+func (r *PeriodicReporter) SetRateUnit(unit time.Duration) {
+	r.mu.Lock()
+	r.snapshot.SetRateUnit(unit)
+	r.mu.Unlock()
+}
+
+// SetDurationUnit converts histogram values recorded in the recorded unit
+// to the reported unit, for example from time.Nanosecond to time.Millisecond.
+func (r *PeriodicReporter) SetDurationUnit(recorded, reported time.Duration) {
+	r.mu.Lock()
+	r.snapshot.SetDurationUnit(recorded, reported)
+	r.mu.Unlock()
+}
+
+// SetPrefix sets a prefix for the names of all reported metrics. Names are
+// joined to the prefix with a "/", which backends convert to their own
+// separator. It should be called before Start.
+func (r *PeriodicReporter) SetPrefix(prefix string) {
+	r.prefix = prefix
+}
+
+// SetMaxPacketSize sets the largest payload of a single datagram for the
+// statsd and DogStatsD reporters, which pack as many lines as fit into each.
+// The default is StatsdPacketSizeEthernet; StatsdPacketSizeJumbo suits
+// networks with jumbo frames and local agents. It should be called before
+// Start.
+func (r *PeriodicReporter) SetMaxPacketSize(size int) {
+	if s, ok := innermost(r.reporter).(packetSizer); ok && size > 0 {
+		s.setMaxPacketSize(size)
+	}
+}
+
+// SetTags adds tags to every datapoint for backends that support tags, such
+// as DogStatsD, Datadog, AppOptics, OpenTSDB and the gRPC and UDP snapshot
+// reporters. DefaultTags returns the
+// usual host, service, environment and version tags. It should be called
+// before Start.
+func (r *PeriodicReporter) SetTags(tags map[string]string) {
+	if s, ok := innermost(r.reporter).(tagSetter); ok {
+		s.addTags(tags)
+	}
+}
+
+// SetErrorHandler sets a function to call when the backend fails to send
+// metrics instead of logging the failure. It should be called before Start.
+func (r *PeriodicReporter) SetErrorHandler(handler ErrorHandler) {
+	if e, ok := innermost(r.reporter).(baser); ok {
+		e.base().handler = handler
+	}
+}
+
+// SetLogger sets the logger used by the reporter and its backend in place
+// of the standard logger. It should be called before Start.
+func (r *PeriodicReporter) SetLogger(logger Logger) {
+	r.logger = logger
+	if e, ok := innermost(r.reporter).(baser); ok {
+		e.base().logger = logger
+	}
+}
+
+// SetRetryPolicy sets how the backend retries failed sends. By default
+// failures are not retried. It should be called before Start.
+func (r *PeriodicReporter) SetRetryPolicy(policy *RetryPolicy) {
+	if e, ok := innermost(r.reporter).(baser); ok {
+		e.base().retry = policy
+	}
+}
+
+// SetCodec sets how the webhook, NATS and MQTT reporters encode messages,
+// such as MsgpackCodec for smaller messages than the default JSONCodec. It
+// should be called before Start.
+func (r *PeriodicReporter) SetCodec(codec Codec) {
+	if s, ok := innermost(r.reporter).(codecSetter); ok && codec != nil {
+		s.setCodec(codec)
+	}
+}
+
+// SetHTTPClient sets the client used by backends that make HTTP requests,
+// for example to configure a proxy, TLS or timeouts. Backends otherwise use
+// a client with a 15 second timeout. It should be called before Start.
+func (r *PeriodicReporter) SetHTTPClient(client *http.Client) {
+	if s, ok := innermost(r.reporter).(httpClientSetter); ok {
+		s.setHTTPClient(client)
+	}
+}
+
+// SetAlignInterval sets whether reports are aligned to wall-clock multiples
+// of the interval, for example at :00, :10, :20 seconds past the minute for
+// a 10 second interval, so that metrics from many hosts share timestamps.
+// Reporters for backends that aggregate by timestamp align by default. It
+// should be called before Start.
+func (r *PeriodicReporter) SetAlignInterval(enabled bool) {
+	r.alignInterval = enabled
+}
+
+// SetJitter spreads out reports from many processes that would otherwise
+// hit the backend at the same moment. With randomPhase the first report is
+// delayed by a random fraction of the interval, and every report is then
+// delayed by a further random duration up to jitter. Neither applies when
+// reports are aligned to the interval. It should be called before Start.
+func (r *PeriodicReporter) SetJitter(randomPhase bool, jitter time.Duration) {
+	r.randomPhase = randomPhase
+	r.jitter = jitter
+}
+
+// SetReportOnStart sets whether to report as soon as the reporter is started
+// rather than waiting a full interval for the first report. It should be
+// called before Start.
+func (r *PeriodicReporter) SetReportOnStart(enabled bool) {
+	r.reportOnStart = enabled
+}
+
+// ErrorCount returns the number of failures the backend has had sending
+// metrics.
+func (r *PeriodicReporter) ErrorCount() uint64 {
+	if e, ok := innermost(r.reporter).(baser); ok {
+		return e.base().errorCount()
+	}
+	return 0
+}
+
+// RegisterSelfMetrics adds metrics about the reporter itself to the registry
+// it reports, under prefix, so a failing metrics pipeline can be alerted on:
+//
+//	<prefix>/reports      number of reports
+//	<prefix>/datapoints   number of values and distributions reported
+//	<prefix>/bytes        bytes sent to the backend, where it tracks them
+//	<prefix>/errors       failures sending to the backend
+//	<prefix>/dropped      datapoints given up on after failures
+//	<prefix>/report_ms    time taken by the last report in milliseconds
+//
+// Since the registry is snapshotted before the backend is called, activity
+// of a report shows up in the next one.
+func (r *PeriodicReporter) RegisterSelfMetrics(prefix string) error {
+	b := &backendBase{}
+	if e, ok := innermost(r.reporter).(baser); ok {
+		b = e.base()
+	}
+	load := func(v *uint64) metrics.CounterFunc {
+		return func() uint64 { return atomic.LoadUint64(v) }
+	}
+	self := []struct {
+		name   string
+		metric interface{}
+	}{
+		{"reports", load(&r.reports)},
+		{"datapoints", load(&r.datapoints)},
+		{"bytes", load(&b.bytes)},
+		{"errors", load(&b.count)},
+		{"dropped", load(&b.dropped)},
+		{"report_ms", metrics.GaugeFunc(func() float64 {
+			return float64(atomic.LoadInt64(&r.reportTime)) / float64(time.Millisecond)
+		})},
+	}
+	for _, m := range self {
+		if err := r.registry.Add(prefix+"/"+m.name, m.metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Calculate nanoseconds to start of next interval
 func nsToNextInterval(t time.Time, i time.Duration) time.Duration {
 	return time.Duration(int64(i) - (int64(t.UnixNano()) % int64(i)))
 }
 
 func (r *PeriodicReporter) Start() {
-	if r.ticker == nil {
-		go func() {
-			if r.alignInterval {
-				// Wait until the beginning of the next even interval. This gives
-				// a better chance that different sources for the same metric
-				// will fall on the same timestamp.
-				time.Sleep(nsToNextInterval(time.Now(), r.interval))
-			}
-
-			r.closeChan = make(chan bool)
-			r.ticker = time.NewTicker(r.interval)
-			go r.loop(r.ticker.C)
-		}()
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	if r.closeChan == nil {
+		// Forget the state of counters as they're removed.
+		r.registry.AddListener(r.snapshot)
+		r.closeChan = make(chan struct{})
+		r.doneChan = make(chan struct{})
+		go r.loop(r.closeChan, r.doneChan)
 	}
 }
 
 func (r *PeriodicReporter) Stop() {
-	if r.ticker != nil {
-		r.ticker.Stop()
-		close(r.closeChan)
-		r.ticker = nil
+	r.runMu.Lock()
+	closeChan, doneChan := r.closeChan, r.doneChan
+	r.closeChan, r.doneChan = nil, nil
+	r.runMu.Unlock()
+	if closeChan != nil {
+		close(closeChan)
+		<-doneChan
+		r.report()
+		r.registry.RemoveListener(r.snapshot)
+		if c, ok := innermost(r.reporter).(closer); ok {
+			r.mu.Lock()
+			c.close()
+			r.mu.Unlock()
+		}
 	}
 }
 
-func (r *PeriodicReporter) loop(ch <-chan time.Time) {
+// Flush snapshots the registry and reports it now, independent of the
+// regular interval.
+func (r *PeriodicReporter) Flush() {
+	r.report()
+}
+
+func (r *PeriodicReporter) loop(closeChan <-chan struct{}, doneChan chan<- struct{}) {
+	defer close(doneChan)
+	if r.reportOnStart {
+		r.report()
+	}
+	now := time.Now()
+	next := now.Add(r.phase(now))
 	for {
+		next = next.Add(r.interval)
+		if now := time.Now(); next.Before(now) {
+			// Reporting took longer than the interval so skip the
+			// missed reports, as a ticker would.
+			next = next.Add(r.interval * (now.Sub(next)/r.interval + 1))
+		}
+		t := time.NewTimer(time.Until(next) + r.jitterDelay())
 		select {
-		case <-ch:
-		case <-r.closeChan:
+		case <-t.C:
+		case <-closeChan:
+			t.Stop()
 			return
 		}
-		r.snapshot.Snapshot(r.registry)
-		r.reporter.Report(r.snapshot)
+		r.report()
+	}
+}
+
+// phase returns how long to wait before starting to count intervals.
+func (r *PeriodicReporter) phase(now time.Time) time.Duration {
+	switch {
+	case r.alignInterval:
+		// Wait until the beginning of the next even interval. This gives
+		// a better chance that different sources for the same metric
+		// will fall on the same timestamp.
+		return nsToNextInterval(now, r.interval)
+	case r.randomPhase:
+		return time.Duration(rand.Int63n(int64(r.interval)))
+	}
+	return 0
+}
+
+// jitterDelay returns how long to delay a single report past its interval.
+func (r *PeriodicReporter) jitterDelay() time.Duration {
+	if r.jitter <= 0 || r.alignInterval {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(r.jitter)))
+}
+
+// report snapshots the registry and passes it to the backend. A panic in
+// the backend is logged rather than taking down the process.
+func (r *PeriodicReporter) report() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer func() {
+		if e := recover(); e != nil {
+			msg := fmt.Sprintf("reporter: panic in %T: %v", r.reporter, e)
+			if r.logger != nil {
+				r.logger.Printf("%s", msg)
+			} else {
+				log.Print(msg)
+			}
+		}
+	}()
+	start := time.Now()
+	defer func() {
+		atomic.AddUint64(&r.reports, 1)
+		atomic.StoreInt64(&r.reportTime, int64(time.Since(start)))
+	}()
+	r.snapshot.Snapshot(r.registry)
+	atomic.AddUint64(&r.datapoints, uint64(r.snapshot.Len()))
+	snapshot := r.snapshot
+	if r.prefix != "" {
+		snapshot = snapshot.Rename(func(name string) string {
+			return r.prefix + "/" + name
+		})
 	}
+	r.reporter.Report(snapshot)
 }
@@ -7,6 +7,8 @@ package reporter
 import (
 	"testing"
 	"time"
+
+	"github.com/samuel/go-metrics/metrics"
 )
 
 func TestNsToNextInterval(t *testing.T) {
@@ -17,3 +19,167 @@ func TestNsToNextInterval(t *testing.T) {
 		t.Fatalf("nsToNextInterval expected to return %+v instead of %+v", exp, ns)
 	}
 }
+
+type testBackend struct {
+	reports chan float64
+	panics  bool
+}
+
+func (b *testBackend) Report(snapshot *metrics.RegistrySnapshot) {
+	if b.panics {
+		panic("backend failure")
+	}
+	for _, v := range snapshot.Values {
+		b.reports <- v.Value
+	}
+}
+
+func TestPeriodicReporter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	registry.Add("c", counter)
+	b := &testBackend{reports: make(chan float64, 100)}
+	r := NewPeriodicReporter(registry, time.Millisecond*10, false, true, b)
+	r.Start()
+	r.Start()
+	counter.Inc(1)
+	select {
+	case <-b.reports:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a report")
+	}
+	for len(b.reports) > 0 {
+		<-b.reports
+	}
+	counter.Inc(2)
+	r.Stop()
+	// A tick may have reported part of it, but Stop reports the rest.
+	sum := 0.0
+	for len(b.reports) > 0 {
+		sum += <-b.reports
+	}
+	if sum != 2 {
+		t.Fatalf("Expected 2 to be reported by Stop. Got %f", sum)
+	}
+	r.Stop()
+	if len(b.reports) != 0 {
+		t.Fatal("Expected no report when stopping twice")
+	}
+
+	counter.Inc(3)
+	r.Flush()
+	if v := <-b.reports; v != 3 {
+		t.Fatalf("Expected flush to report 3. Got %f", v)
+	}
+	time.Sleep(time.Millisecond * 30)
+	if len(b.reports) != 0 {
+		t.Fatal("Expected no reports after Stop")
+	}
+}
+
+func TestPeriodicReporterPanic(t *testing.T) {
+	r := NewPeriodicReporter(metrics.NewRegistry(), time.Minute, false, true, &testBackend{panics: true})
+	r.Flush()
+}
+
+func TestPeriodicReporterJitter(t *testing.T) {
+	r := NewPeriodicReporter(metrics.NewRegistry(), time.Minute, false, true, &testBackend{})
+	now := time.Now()
+	if d := r.phase(now); d != 0 {
+		t.Fatalf("Expected no phase by default. Got %s", d)
+	}
+	if d := r.jitterDelay(); d != 0 {
+		t.Fatalf("Expected no jitter by default. Got %s", d)
+	}
+	r.SetJitter(true, time.Second)
+	for i := 0; i < 100; i++ {
+		if d := r.phase(now); d < 0 || d >= time.Minute {
+			t.Fatalf("Expected phase within the interval. Got %s", d)
+		}
+		if d := r.jitterDelay(); d < 0 || d >= time.Second {
+			t.Fatalf("Expected jitter below 1s. Got %s", d)
+		}
+	}
+
+	r.alignInterval = true
+	if d, exp := r.phase(now), nsToNextInterval(now, time.Minute); d != exp {
+		t.Fatalf("Expected aligned phase %s. Got %s", exp, d)
+	}
+	if d := r.jitterDelay(); d != 0 {
+		t.Fatalf("Expected no jitter when aligned. Got %s", d)
+	}
+}
+
+func TestPeriodicReporterReportOnStart(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	registry.Add("c", counter)
+	counter.Inc(4)
+	b := &testBackend{reports: make(chan float64, 100)}
+	r := NewPeriodicReporter(registry, time.Hour, false, true, b)
+	r.SetReportOnStart(true)
+	r.Start()
+	defer r.Stop()
+	select {
+	case v := <-b.reports:
+		if v != 4 {
+			t.Fatalf("Expected 4. Got %f", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a report on start")
+	}
+}
+
+type timeBackend chan time.Time
+
+func (b timeBackend) Report(snapshot *metrics.RegistrySnapshot) {
+	b <- time.Now()
+}
+
+func TestPeriodicReporterAlignInterval(t *testing.T) {
+	interval := time.Millisecond * 100
+	b := make(timeBackend, 100)
+	r := NewPeriodicReporter(metrics.NewRegistry(), interval, false, true, b)
+	r.SetAlignInterval(true)
+	r.Start()
+	var reports []time.Time
+	for len(reports) < 2 {
+		select {
+		case tm := <-b:
+			reports = append(reports, tm)
+		case <-time.After(time.Second):
+			t.Fatal("Expected a report")
+		}
+	}
+	r.Stop()
+	for _, tm := range reports {
+		if off := tm.Sub(tm.Truncate(interval)); off > interval/2 {
+			t.Fatalf("Expected report at a multiple of %s. Got %s past", interval, off)
+		}
+	}
+}
+
+func TestPeriodicReporterSelfMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	b := &failingBackend{}
+	r := NewPeriodicReporter(registry, time.Minute, false, true, b)
+	if err := r.RegisterSelfMetrics("reporter"); err != nil {
+		t.Fatal(err)
+	}
+	r.SetErrorHandler(func(metric string, err error) {})
+	r.Flush()
+	r.Flush()
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.SetCounterMode(metrics.CounterCumulative)
+	snapshot.Snapshot(registry)
+	for name, exp := range map[string]float64{
+		"reporter/reports":    2,
+		"reporter/datapoints": 12,
+		"reporter/errors":     12,
+	} {
+		if v := snapshot.Get(name); v != metrics.GaugeValue(exp) {
+			t.Fatalf("Expected %s to be %f. Got %+v", name, exp, v)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// RenameRule rewrites a metric name.
+type RenameRule func(name string) string
+
+// PrefixRule prepends prefix to names.
+func PrefixRule(prefix string) RenameRule {
+	return func(name string) string {
+		return prefix + name
+	}
+}
+
+// SuffixRule appends suffix to names.
+func SuffixRule(suffix string) RenameRule {
+	return func(name string) string {
+		return name + suffix
+	}
+}
+
+// RegexpRule replaces matches of re in names with repl which may refer to
+// submatches as with regexp.ReplaceAllString.
+func RegexpRule(re *regexp.Regexp, repl string) RenameRule {
+	return func(name string) string {
+		return re.ReplaceAllString(name, repl)
+	}
+}
+
+// TemplateRule builds names from a template in which {name} is replaced by
+// the metric name and {host} by the hostname. Environment variables
+// written as $VAR or ${VAR} are expanded once when the rule is created, so
+// "${ENV}/{host}/{name}" might give "prod/web1/requests".
+func TemplateRule(template string) RenameRule {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	template = strings.Replace(os.ExpandEnv(template), "{host}", host, -1)
+	return func(name string) string {
+		return strings.Replace(template, "{name}", name, -1)
+	}
+}
+
+type renameBackend struct {
+	backend Backend
+	rules   []RenameRule
+}
+
+// RenameBackend returns a Backend that applies the rules, in order, to the
+// name of every metric before passing the snapshot on to backend. Backends
+// still convert the '/' separators of the resulting names as they need.
+func RenameBackend(backend Backend, rules ...RenameRule) Backend {
+	return &renameBackend{
+		backend: backend,
+		rules:   rules,
+	}
+}
+
+func (b *renameBackend) unwrap() Backend {
+	return b.backend
+}
+
+func (b *renameBackend) rename(name string) string {
+	for _, rule := range b.rules {
+		name = rule(name)
+	}
+	return name
+}
+
+func (b *renameBackend) Report(snapshot *metrics.RegistrySnapshot) {
+	b.backend.Report(snapshot.Rename(b.rename))
+}
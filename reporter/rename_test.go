@@ -0,0 +1,41 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestRenameBackend(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("http/requests", metrics.NewCounter())
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	b := &namesBackend{}
+	RenameBackend(b,
+		RegexpRule(regexp.MustCompile(`^http/`), "web/"),
+		PrefixRule("app/"),
+		SuffixRule("/total"),
+	).Report(snapshot)
+	exp := []string{"app/web/requests/total"}
+	if !reflect.DeepEqual(b.names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, b.names)
+	}
+}
+
+func TestTemplateRule(t *testing.T) {
+	os.Setenv("METRICS_TEST_ENV", "prod")
+	defer os.Unsetenv("METRICS_TEST_ENV")
+	host, _ := os.Hostname()
+	if name := TemplateRule("${METRICS_TEST_ENV}/{host}/{name}")("requests"); name != "prod/"+host+"/requests" {
+		t.Fatalf("Unexpected name %s", name)
+	}
+}
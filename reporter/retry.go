@@ -0,0 +1,67 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// sleep is replaced by tests.
+var sleep = time.Sleep
+
+// RetryPolicy controls how failed sends are retried. The delay before each
+// retry doubles, starting at InitialBackoff and capped at MaxBackoff, with
+// random jitter of up to half the delay so many processes don't retry in
+// lockstep. Once MaxAttempts have failed the data is dropped and the error
+// passed to the error handler. Retries happen within a report so the total
+// backoff should be kept well under the reporting interval.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff defaults to 10s.
+	MaxBackoff time.Duration
+}
+
+// backoff returns the delay before the given retry (starting at 1).
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	initial, max := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := initial
+	for i := 1; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// do calls f until it succeeds or the attempts run out, returning the last
+// error. A nil policy calls f once.
+func (p *RetryPolicy) do(f func() error) error {
+	err := f()
+	if p == nil {
+		return err
+	}
+	for retry := 1; err != nil && retry < p.MaxAttempts; retry++ {
+		sleep(p.backoff(retry))
+		err = f()
+	}
+	return err
+}
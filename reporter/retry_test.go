@@ -0,0 +1,55 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	p := &RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	calls := 0
+	err := p.do(func() error {
+		calls++
+		return errors.New("fail")
+	})
+	if err == nil || calls != 4 {
+		t.Fatalf("Expected 4 failed attempts. Got %d (%v)", calls, err)
+	}
+	max := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	for i, d := range slept {
+		if d < max[i]/2 || d > max[i] {
+			t.Fatalf("Backoff %d of %s not within [%s, %s]", i, d, max[i]/2, max[i])
+		}
+	}
+
+	calls = 0
+	err = p.do(func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("Expected success on second attempt. Got %d (%v)", calls, err)
+	}
+
+	calls = 0
+	var nilPolicy *RetryPolicy
+	nilPolicy.do(func() error {
+		calls++
+		return errors.New("fail")
+	})
+	if calls != 1 {
+		t.Fatalf("Expected a nil policy to not retry. Got %d attempts", calls)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// sqlBatchSize is the number of rows inserted per statement.
+const sqlBatchSize = 100
+
+// SQLPlaceholder is the bind parameter style used by a database driver.
+type SQLPlaceholder int
+
+const (
+	// SQLQuestion uses ? placeholders (MySQL, SQLite).
+	SQLQuestion SQLPlaceholder = iota
+	// SQLDollar uses $1, $2, ... placeholders (PostgreSQL).
+	SQLDollar
+)
+
+const sqlColumns = 7
+
+type sqlReporter struct {
+	backendBase
+	db          *sql.DB
+	table       string
+	placeholder SQLPlaceholder
+	created     bool
+}
+
+// NewSQLReporter returns a reporter that inserts each snapshot into table
+// using db, creating the table if it does not exist. Each metric becomes a
+// row of (ts, name, value, count, sum, min, max). Distributions store their
+// mean as the value with the remaining columns filled in, other metrics
+// leave them NULL. The table name is used verbatim in statements so it must
+// not come from untrusted input.
+func NewSQLReporter(registry metrics.Registry, interval time.Duration, latched bool, db *sql.DB, table string, placeholder SQLPlaceholder) *PeriodicReporter {
+	sr := &sqlReporter{
+		db:          db,
+		table:       table,
+		placeholder: placeholder,
+	}
+	return NewPeriodicReporter(registry, interval, true, latched, sr)
+}
+
+func (r *sqlReporter) createTable() error {
+	_, err := r.db.Exec("CREATE TABLE IF NOT EXISTS " + r.table + ` (
+	ts TIMESTAMP NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	value DOUBLE PRECISION NOT NULL,
+	count BIGINT,
+	sum DOUBLE PRECISION,
+	min DOUBLE PRECISION,
+	max DOUBLE PRECISION
+)`)
+	return err
+}
+
+// insertStatement returns a multi-row insert for n rows.
+func (r *sqlReporter) insertStatement(n int) string {
+	b := &bytes.Buffer{}
+	b.WriteString("INSERT INTO " + r.table + " (ts, name, value, count, sum, min, max) VALUES ")
+	param := 0
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		for j := 0; j < sqlColumns; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			param++
+			if r.placeholder == SQLDollar {
+				b.WriteString("$" + strconv.Itoa(param))
+			} else {
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+func sqlRows(snapshot *metrics.RegistrySnapshot, ts time.Time) [][]interface{} {
+	rows := make([][]interface{}, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		rows = append(rows, []interface{}{ts, v.Name, v.Value, nil, nil, nil, nil})
+	}
+	for _, v := range snapshot.Distributions {
+		d := v.Value
+		rows = append(rows, []interface{}{ts, v.Name, d.Mean(), int64(d.Count), d.Sum, d.Min, d.Max})
+	}
+	return rows
+}
+
+func (r *sqlReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	if !r.created {
+		if err := r.createTable(); err != nil {
+			r.errorf("", "sql: failed to create table %s: %w", r.table, err)
+			r.drop(snapshot.Len())
+			return
+		}
+		r.created = true
+	}
+	rows := sqlRows(snapshot, time.Now().UTC())
+	if len(rows) == 0 {
+		return
+	}
+	if err := r.send(func() error { return r.insert(rows) }); err != nil {
+		r.errorf("", "sql: failed to insert metrics: %w", err)
+		r.drop(len(rows))
+	}
+}
+
+func (r *sqlReporter) insert(rows [][]interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > sqlBatchSize {
+			n = sqlBatchSize
+		}
+		args := make([]interface{}, 0, n*sqlColumns)
+		for _, row := range rows[:n] {
+			args = append(args, row...)
+		}
+		if _, err := tx.Exec(r.insertStatement(n), args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+		rows = rows[n:]
+	}
+	return tx.Commit()
+}
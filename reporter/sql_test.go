@@ -0,0 +1,97 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// testSQLDriver records the statements executed and their arguments.
+type testSQLDriver struct {
+	mu    sync.Mutex
+	execs []string
+	args  [][]driver.Value
+}
+
+func (d *testSQLDriver) Open(name string) (driver.Conn, error) { return testSQLConn{d}, nil }
+
+// Connect and Driver implement driver.Connector so each test can open its
+// own driver with sql.OpenDB.
+func (d *testSQLDriver) Connect(ctx context.Context) (driver.Conn, error) { return testSQLConn{d}, nil }
+func (d *testSQLDriver) Driver() driver.Driver                            { return d }
+
+type testSQLConn struct{ d *testSQLDriver }
+
+func (c testSQLConn) Prepare(query string) (driver.Stmt, error) { return testSQLStmt{c.d, query}, nil }
+func (c testSQLConn) Close() error                              { return nil }
+func (c testSQLConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c testSQLConn) Commit() error                             { return nil }
+func (c testSQLConn) Rollback() error                           { return nil }
+
+type testSQLStmt struct {
+	d     *testSQLDriver
+	query string
+}
+
+func (s testSQLStmt) Close() error  { return nil }
+func (s testSQLStmt) NumInput() int { return -1 }
+
+func (s testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	s.d.execs = append(s.d.execs, s.query)
+	s.d.args = append(s.d.args, args)
+	s.d.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+func (s testSQLStmt) Query(args []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func TestSQLReporter(t *testing.T) {
+	testDriver := &testSQLDriver{}
+	db := sql.OpenDB(testDriver)
+	defer db.Close()
+
+	registry := metrics.NewRegistry()
+	for i := 0; i < sqlBatchSize; i++ {
+		registry.Add("counter"+strings.Repeat("x", i), metrics.NewCounter())
+	}
+	registry.Add("nan", metrics.GaugeValue(math.NaN()))
+	dist := metrics.NewDistribution()
+	dist.Update(3)
+	registry.Add("latency", dist)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	r := &sqlReporter{db: db, table: "metrics", placeholder: SQLDollar}
+	r.Report(snapshot)
+
+	if len(testDriver.execs) != 3 {
+		t.Fatalf("Expected create and two inserts. Got %d statements", len(testDriver.execs))
+	}
+	if !strings.HasPrefix(testDriver.execs[0], "CREATE TABLE IF NOT EXISTS metrics ") {
+		t.Fatalf("Unexpected create statement %s", testDriver.execs[0])
+	}
+	if len(testDriver.args[1]) != sqlBatchSize*sqlColumns {
+		t.Fatalf("Expected a full batch. Got %d args", len(testDriver.args[1]))
+	}
+	exp := "INSERT INTO metrics (ts, name, value, count, sum, min, max) VALUES ($1,$2,$3,$4,$5,$6,$7)"
+	if testDriver.execs[2] != exp {
+		t.Fatalf("Expected %s. Got %s", exp, testDriver.execs[2])
+	}
+	if args := testDriver.args[2]; args[1] != "latency" || args[3] != int64(1) {
+		t.Fatalf("Unexpected distribution row %+v", args)
+	}
+	if r.dropped != 1 {
+		t.Fatalf("Expected the NaN gauge to be dropped. Got %d dropped", r.dropped)
+	}
+}
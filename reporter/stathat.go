@@ -5,38 +5,216 @@
 package reporter
 
 import (
-	"log"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/samuel/go-metrics/metrics"
-	"github.com/stathat/stathatgo"
 )
 
+const (
+	statHatEZEndpoint    = "https://api.stathat.com/ez"
+	statHatClassicPrefix = "https://api.stathat.com"
+
+	// statHatMaxBatch is the number of stats sent per request.
+	statHatMaxBatch = 100
+
+	// statHatConcurrency is the number of requests made at the same time.
+	statHatConcurrency = 4
+)
+
+// StatHatKeyFunc maps a metric name to the key of its stat for the classic
+// StatHat API. Metrics for which ok is false are not sent.
+type StatHatKeyFunc func(name string) (key string, ok bool)
+
 type statHatReporter struct {
-	source string
-	email  string
+	backendBase
+	source   string
+	email    string
+	endpoint string
+	client   *http.Client
+
+	// Classic API
+	userKey string
+	keyFunc StatHatKeyFunc
 }
 
+type statHatStat struct {
+	Stat  string  `json:"stat"`
+	Value float64 `json:"value"`
+	Time  int64   `json:"t,omitempty"`
+}
+
+// NewStatHatReporter returns a reporter that posts to StatHat using the EZ
+// API for the account email. Everything is posted as a value, counters
+// being the change since the last report, so existing stats keep their
+// type. All of an interval's stats are sent in a few batched requests.
+// StatHat stats have no source so source is not used.
 func NewStatHatReporter(registry metrics.Registry, interval time.Duration, latched bool, email, source string) *PeriodicReporter {
 	sr := &statHatReporter{
-		source: source,
-		email:  email,
+		source:   source,
+		email:    email,
+		endpoint: statHatEZEndpoint,
+		client:   &http.Client{Timeout: defaultHTTPTimeout},
 	}
 	return NewPeriodicReporter(registry, interval, false, latched, sr)
 }
 
+// NewStatHatClassicReporter returns a reporter that posts to StatHat using
+// the classic API, where each stat has its own key, for the account with
+// userKey. The keyFunc gives the key for each metric name. The classic API
+// takes one stat per request so requests are made a few at a time.
+func NewStatHatClassicReporter(registry metrics.Registry, interval time.Duration, latched bool, userKey string, keyFunc StatHatKeyFunc) *PeriodicReporter {
+	sr := &statHatReporter{
+		endpoint: statHatClassicPrefix,
+		client:   &http.Client{Timeout: defaultHTTPTimeout},
+		userKey:  userKey,
+		keyFunc:  keyFunc,
+	}
+	return NewPeriodicReporter(registry, interval, false, latched, sr)
+}
+
+func (r *statHatReporter) stats(snapshot *metrics.RegistrySnapshot, ts int64) []statHatStat {
+	stats := make([]statHatStat, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		stats = append(stats, statHatStat{Stat: strings.Replace(v.Name, "/", ".", -1), Value: v.Value, Time: ts})
+	}
+	for _, v := range snapshot.Distributions {
+		stats = append(stats, statHatStat{Stat: strings.Replace(v.Name, "/", ".", -1), Value: v.Value.Mean(), Time: ts})
+	}
+	return stats
+}
+
 func (r *statHatReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	if r.keyFunc != nil {
+		r.reportClassic(snapshot)
+		return
+	}
+	stats := r.stats(snapshot, time.Now().Unix())
+	var batches [][]statHatStat
+	for len(stats) > 0 {
+		n := len(stats)
+		if n > statHatMaxBatch {
+			n = statHatMaxBatch
+		}
+		batches = append(batches, stats[:n])
+		stats = stats[n:]
+	}
+	forEachConcurrently(len(batches), statHatConcurrency, func(i int) {
+		batch := batches[i]
+		if err := r.send(func() error { return r.post(batch) }); err != nil {
+			r.errorf("", "stathat: failed to post %d metrics: %w", len(batch), err)
+			r.drop(len(batch))
+		}
+	})
+}
+
+func (r *statHatReporter) post(stats []statHatStat) error {
+	b, err := json.Marshal(map[string]interface{}{"ezkey": r.email, "data": stats})
+	if err != nil {
+		return err
+	}
+	res, err := r.client.Post(r.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, body)
+	}
+	// Errors are also reported with a 200 response.
+	var result struct {
+		Status int    `json:"status"`
+		Msg    string `json:"msg"`
+	}
+	if json.Unmarshal(body, &result) == nil && result.Status != 0 && result.Status != http.StatusOK {
+		return fmt.Errorf("status %d: %s", result.Status, result.Msg)
+	}
+	r.sent(len(b))
+	return nil
+}
+
+func (r *statHatReporter) reportClassic(snapshot *metrics.RegistrySnapshot) {
+	type classicStat struct {
+		name   string
+		path   string
+		params url.Values
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	var stats []classicStat
+	add := func(name, path, field string, value float64) {
+		key, ok := r.keyFunc(name)
+		if !ok {
+			return
+		}
+		params := url.Values{
+			"ukey": {r.userKey},
+			"key":  {key},
+			"t":    {ts},
+			field:  {strconv.FormatFloat(value, 'f', -1, 64)},
+		}
+		stats = append(stats, classicStat{name, path, params})
+	}
 	for _, v := range snapshot.Values {
-		name := strings.Replace(v.Name, "/", ".", -1)
-		if err := stathat.PostEZValue(name, r.email, v.Value); err != nil {
-			log.Printf("stathat: failed to post metric %s: %s", name, err.Error())
+		if snapshot.IsCounter(v.Name) {
+			add(v.Name, "/c", "count", v.Value)
+		} else {
+			add(v.Name, "/v", "value", v.Value)
 		}
 	}
 	for _, v := range snapshot.Distributions {
-		name := strings.Replace(v.Name, "/", ".", -1)
-		if err := stathat.PostEZValue(name, r.email, v.Value.Mean()); err != nil {
-			log.Printf("stathat: failed to post metric %s: %s", name, err.Error())
+		add(v.Name, "/v", "value", v.Value.Mean())
+	}
+	forEachConcurrently(len(stats), statHatConcurrency, func(i int) {
+		s := stats[i]
+		if err := r.send(func() error { return r.postClassic(s.path, s.params) }); err != nil {
+			r.errorf(s.name, "stathat: failed to post metric %s: %w", s.name, err)
+			r.drop(1)
 		}
+	})
+}
+
+func (r *statHatReporter) postClassic(path string, params url.Values) error {
+	res, err := r.client.PostForm(r.endpoint+path, params)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, body)
 	}
+	return nil
+}
+
+// forEachConcurrently calls f for 0 to n-1 with at most limit calls running
+// at once and returns when all have finished.
+func forEachConcurrently(n, limit int, f func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (r *statHatReporter) setHTTPClient(client *http.Client) {
+	r.client = client
 }
@@ -0,0 +1,142 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestStatHatBatching(t *testing.T) {
+	var mu sync.Mutex
+	var batches []int
+	stats := map[string]statHatStat{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		if bytes.Contains(b, []byte(`"count"`)) {
+			t.Errorf("Expected only values. Got %s", b)
+		}
+		var body struct {
+			EZKey string `json:"ezkey"`
+			Data  []statHatStat
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Error(err)
+		}
+		if body.EZKey != "me@example.com" {
+			t.Errorf("Unexpected ezkey %s", body.EZKey)
+		}
+		mu.Lock()
+		batches = append(batches, len(body.Data))
+		for _, s := range body.Data {
+			stats[s.Stat] = s
+		}
+		mu.Unlock()
+		w.Write([]byte(`{"status":200,"msg":"ok"}`))
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	for i := 0; i < statHatMaxBatch; i++ {
+		c := metrics.NewCounter()
+		c.Inc(uint64(i))
+		registry.Add("counter/"+strconv.Itoa(i), c)
+	}
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(5)
+	registry.Add("gauge", gauge)
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	r := &statHatReporter{email: "me@example.com", endpoint: server.URL, client: server.Client()}
+	r.Report(snapshot)
+
+	if len(batches) != 2 || batches[0]+batches[1] != statHatMaxBatch+1 {
+		t.Fatalf("Expected 2 batches. Got %+v", batches)
+	}
+	if s := stats["counter.3"]; s.Value != 3 {
+		t.Fatalf("Expected counter to be sent as a value of 3. Got %+v", s)
+	}
+	if s := stats["gauge"]; s.Value != 5 {
+		t.Fatalf("Expected gauge to be sent as a value. Got %+v", s)
+	}
+}
+
+func TestStatHatClassic(t *testing.T) {
+	var mu sync.Mutex
+	posts := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.FormValue("ukey") != "user" {
+			t.Errorf("Unexpected ukey %s", req.FormValue("ukey"))
+		}
+		mu.Lock()
+		posts[req.URL.Path+" "+req.FormValue("key")] = req.FormValue("count") + req.FormValue("value")
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(2)
+	registry.Add("requests", counter)
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(9)
+	registry.Add("conns", gauge)
+	registry.Add("ignored", metrics.NewCounter())
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	keys := map[string]string{"requests": "k1", "conns": "k2"}
+	r := &statHatReporter{
+		endpoint: server.URL,
+		client:   server.Client(),
+		userKey:  "user",
+		keyFunc: func(name string) (string, bool) {
+			key, ok := keys[name]
+			return key, ok
+		},
+	}
+	r.Report(snapshot)
+
+	exp := map[string]string{"/c k1": "2", "/v k2": "9"}
+	if !reflect.DeepEqual(posts, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, posts)
+	}
+}
+
+func TestStatHatHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	registry.Add("counter", metrics.NewCounter())
+	r := NewStatHatReporter(registry, time.Minute, false, "me@example.com", "")
+	sr := r.reporter.(*statHatReporter)
+	sr.endpoint = server.URL
+	var errs []error
+	r.SetErrorHandler(func(metric string, err error) { errs = append(errs, err) })
+	r.SetHTTPClient(&http.Client{Timeout: 10 * time.Millisecond})
+
+	start := time.Now()
+	r.Flush()
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("Expected the client timeout to end the request. Took %s", d)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error. Got %+v", errs)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/encoding"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const (
+	// StatsdPacketSizeEthernet keeps datagrams within a typical 1500 byte
+	// MTU once IP and UDP headers are accounted for. It's the default.
+	StatsdPacketSizeEthernet = 1432
+	// StatsdPacketSizeJumbo suits networks with 9000 byte jumbo frames.
+	StatsdPacketSizeJumbo = 8932
+
+	statsdDialTimeout  = 5 * time.Second
+	statsdWriteTimeout = 5 * time.Second
+)
+
+type statsdReporter struct {
+	backendBase
+	network    string
+	addr       string
+	prefix     string
+	sampleRate float64
+	maxPacket  int // defaults to StatsdPacketSizeEthernet
+	conn       net.Conn
+}
+
+// NewStatsdReporter returns a reporter that sends metrics to a StatsD server.
+// The addr is a host:port for UDP or may include a scheme to choose another
+// transport: "tcp://host:port", "unix:///path/to/socket" for a stream socket
+// or "unixgram:///path/to/socket" for a datagram socket. Stream connections
+// are kept open between reports and re-established when a write fails.
+// Counters are sent as counts (|c), distributions as timers (|ms)
+// of their mean, and everything else as gauges (|g). If sampleRate is between
+// 0 and 1 then counters and timers are only sent with that probability and
+// are annotated with the rate so the server can scale them.
+func NewStatsdReporter(registry metrics.Registry, interval time.Duration, latched bool, addr, prefix string, sampleRate float64) *PeriodicReporter {
+	network, addr := parseStatsdAddr(addr)
+	sr := &statsdReporter{
+		network:    network,
+		addr:       addr,
+		prefix:     prefix,
+		sampleRate: sampleRate,
+	}
+	return NewPeriodicReporter(registry, interval, false, latched, sr)
+}
+
+func (r *statsdReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	lines := r.lines(snapshot)
+	if len(lines) == 0 {
+		return
+	}
+	r.sendLines("statsd", lines)
+}
+
+// parseStatsdAddr splits an optional scheme from addr, defaulting to UDP.
+func parseStatsdAddr(addr string) (network, address string) {
+	for _, network := range []string{"udp", "tcp", "unix", "unixgram"} {
+		if strings.HasPrefix(addr, network+"://") {
+			return network, addr[len(network)+3:]
+		}
+	}
+	return "udp", addr
+}
+
+func (r *statsdReporter) stream() bool {
+	return r.network == "tcp" || r.network == "unix"
+}
+
+// sendLines writes the lines in batches. Datagram connections are closed once
+// the report is sent while stream connections are reused for the next one.
+func (r *statsdReporter) sendLines(logName string, lines []string) {
+	stream := r.stream()
+	maxPacket := r.maxPacket
+	if maxPacket <= 0 {
+		maxPacket = StatsdPacketSizeEthernet
+	}
+	packets := batchLines(lines, maxPacket)
+	for i, packet := range packets {
+		if stream {
+			// Lines of consecutive batches must not run together on a stream.
+			packet = append(packet, '\n')
+		}
+		if err := r.send(func() error { return r.write(packet) }); err != nil {
+			r.errorf("", "%s: failed to send metrics: %w", logName, err)
+			for _, p := range packets[i:] {
+				r.drop(bytes.Count(bytes.TrimSuffix(p, []byte{'\n'}), []byte{'\n'}) + 1)
+			}
+			break
+		}
+		r.sent(len(packet))
+	}
+	if !stream {
+		r.close()
+	}
+}
+
+// write sends a packet, reconnecting and retrying once if the existing
+// connection has gone bad.
+func (r *statsdReporter) write(packet []byte) error {
+	for retried := false; ; retried = true {
+		if r.conn == nil {
+			conn, err := net.DialTimeout(r.network, r.addr, statsdDialTimeout)
+			if err != nil {
+				return err
+			}
+			r.conn = conn
+		}
+		r.conn.SetWriteDeadline(time.Now().Add(statsdWriteTimeout))
+		_, err := r.conn.Write(packet)
+		if err == nil {
+			return nil
+		}
+		r.close()
+		if retried || !r.stream() {
+			return err
+		}
+	}
+}
+
+func (r *statsdReporter) setMaxPacketSize(size int) {
+	r.maxPacket = size
+}
+
+func (r *statsdReporter) close() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+func (r *statsdReporter) sampled() bool {
+	return r.sampleRate <= 0 || r.sampleRate >= 1 || rand.Float64() < r.sampleRate
+}
+
+func (r *statsdReporter) line(name string, value float64, typ string, sampled bool) string {
+	rate := 0.0
+	if sampled {
+		rate = r.sampleRate
+	}
+	return encoding.StatsdLine(encoding.StatsdName(r.prefix, name), value, typ, rate)
+}
+
+func (r *statsdReporter) lines(snapshot *metrics.RegistrySnapshot) []string {
+	lines := make([]string, 0, len(snapshot.Values)+len(snapshot.Distributions))
+	for _, v := range snapshot.Values {
+		if snapshot.IsCounter(v.Name) {
+			if r.sampled() {
+				lines = append(lines, r.line(v.Name, v.Value, "c", true))
+			}
+		} else {
+			lines = append(lines, r.line(v.Name, v.Value, "g", false))
+		}
+	}
+	for _, v := range snapshot.Distributions {
+		if r.sampled() {
+			lines = append(lines, r.line(v.Name, v.Value.Mean(), "ms", true))
+		}
+	}
+	return lines
+}
+
+// batchLines joins newline separated lines into packets no larger than
+// maxSize bytes. A single line larger than maxSize is sent on its own.
+func batchLines(lines []string, maxSize int) [][]byte {
+	var packets [][]byte
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > maxSize {
+			packets = append(packets, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		packets = append(packets, buf.Bytes())
+	}
+	return packets
+}
@@ -0,0 +1,179 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestStatsdReporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(5)
+	registry.Add("requests", counter)
+	gauge := metrics.NewIntegerGauge()
+	gauge.Set(3)
+	registry.Scope("pool").Add("size", gauge)
+	dist := metrics.NewDistribution()
+	dist.Update(10)
+	dist.Update(20)
+	registry.Add("latency", dist)
+
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	r := &statsdReporter{network: "udp", addr: conn.LocalAddr().String(), prefix: "app"}
+	r.Report(snapshot)
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	sort.Strings(lines)
+	exp := []string{"app.latency:15|ms", "app.pool.size:3|g", "app.requests:5|c"}
+	if !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, lines)
+	}
+}
+
+func TestStatsdReporterStream(t *testing.T) {
+	dir := t.TempDir()
+	for _, network := range []string{"tcp", "unix"} {
+		addr := "127.0.0.1:0"
+		if network == "unix" {
+			addr = filepath.Join(dir, "statsd.sock")
+		}
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		registry := metrics.NewRegistry()
+		counter := metrics.NewCounter()
+		registry.Add("requests", counter)
+		snapshot := metrics.NewRegistrySnapshot(true)
+
+		r := &statsdReporter{network: network, addr: ln.Addr().String()}
+		defer r.close()
+		for i := 1; i <= 2; i++ {
+			counter.Inc(1)
+			snapshot.Snapshot(registry)
+			r.Report(snapshot)
+		}
+
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		rd := bufio.NewReader(conn)
+		for _, exp := range []string{"requests:1|c\n", "requests:1|c\n"} {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%s: %s", network, err)
+			}
+			if line != exp {
+				t.Fatalf("%s: expected %q. Got %q", network, exp, line)
+			}
+		}
+		conn.Close()
+	}
+}
+
+func TestParseStatsdAddr(t *testing.T) {
+	cases := []struct{ addr, network, address string }{
+		{"localhost:8125", "udp", "localhost:8125"},
+		{"tcp://localhost:8125", "tcp", "localhost:8125"},
+		{"unix:///tmp/statsd.sock", "unix", "/tmp/statsd.sock"},
+		{"unixgram:///tmp/dsd.sock", "unixgram", "/tmp/dsd.sock"},
+	}
+	for _, c := range cases {
+		network, address := parseStatsdAddr(c.addr)
+		if network != c.network || address != c.address {
+			t.Fatalf("Expected %s %s for %s. Got %s %s", c.network, c.address, c.addr, network, address)
+		}
+	}
+}
+
+func TestStatsdSampleRate(t *testing.T) {
+	r := &statsdReporter{sampleRate: 0.5}
+	if line := r.line("a/b", 1, "c", true); line != "a.b:1|c|@0.5" {
+		t.Fatalf("Unexpected line %s", line)
+	}
+	if line := r.line("a/b", 1, "g", false); line != "a.b:1|g" {
+		t.Fatalf("Unexpected line %s", line)
+	}
+}
+
+func TestBatchLines(t *testing.T) {
+	packets := batchLines([]string{"aaaa", "bbbb", "cccc", "dddddddddddd"}, 10)
+	exp := []string{"aaaa\nbbbb", "cccc", "dddddddddddd"}
+	var out []string
+	for _, p := range packets {
+		out = append(out, string(p))
+	}
+	if !reflect.DeepEqual(out, exp) {
+		t.Fatalf("Expected %q. Got %q", exp, out)
+	}
+}
+
+func TestStatsdMaxPacketSize(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	registry := metrics.NewRegistry()
+	for i := 0; i < 200; i++ {
+		registry.Add(fmt.Sprintf("gauge/%03d", i), metrics.NewIntegerGauge())
+	}
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+
+	r := NewStatsdReporter(registry, time.Minute, true, conn.LocalAddr().String(), "", 0)
+	for _, c := range []struct {
+		size    int
+		packets int
+	}{{0, 2}, {StatsdPacketSizeJumbo, 1}} {
+		r.SetMaxPacketSize(c.size)
+		r.reporter.Report(snapshot)
+		buf := make([]byte, 65536)
+		lines := 0
+		for i := 0; i < c.packets; i++ {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n > StatsdPacketSizeEthernet && c.size == 0 {
+				t.Fatalf("Expected packets of at most %d bytes. Got %d", StatsdPacketSizeEthernet, n)
+			}
+			lines += strings.Count(string(buf[:n]), "\n") + 1
+		}
+		if lines != 200 {
+			t.Fatalf("Expected 200 lines in %d packets. Got %d", c.packets, lines)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"os"
+	"sort"
+)
+
+// tagSetter is implemented by backends that support tags.
+type tagSetter interface {
+	addTags(tags map[string]string)
+}
+
+// DefaultTags returns the tags commonly applied to every metric of a
+// service: host, service, env and version. Empty values are left out and
+// host is the hostname.
+func DefaultTags(service, environment, version string) map[string]string {
+	tags := make(map[string]string, 4)
+	if host, err := os.Hostname(); err == nil && host != "" {
+		tags["host"] = host
+	}
+	for k, v := range map[string]string{"service": service, "env": environment, "version": version} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// tagList returns tags as key:value strings sorted by key.
+func tagList(tags map[string]string) []string {
+	list := make([]string, 0, len(tags))
+	for k, v := range tags {
+		list = append(list, k+":"+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// mergeTags returns a copy of tags with extra added, overriding any tags
+// with the same keys.
+func mergeTags(tags, extra map[string]string) map[string]string {
+	out := make(map[string]string, len(tags)+len(extra))
+	for k, v := range tags {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
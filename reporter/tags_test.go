@@ -0,0 +1,51 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestDefaultTags(t *testing.T) {
+	tags := DefaultTags("api", "prod", "")
+	if tags["service"] != "api" || tags["env"] != "prod" || tags["host"] == "" {
+		t.Fatalf("Expected service, env and host tags. Got %+v", tags)
+	}
+	if _, ok := tags["version"]; ok {
+		t.Fatalf("Expected empty version to be left out. Got %+v", tags)
+	}
+}
+
+func TestPeriodicReporterPrefixAndTags(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("requests", metrics.NewCounter())
+
+	b := &namesBackend{}
+	r := NewPeriodicReporter(registry, time.Minute, false, true, b)
+	r.SetPrefix("api")
+	r.Flush()
+	if exp := []string{"api/requests"}; !reflect.DeepEqual(b.names, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, b.names)
+	}
+
+	r = NewDogStatsdReporter(registry, time.Minute, true, "127.0.0.1:8125", "", []string{"team:x"}, nil)
+	r.SetTags(map[string]string{"service": "api", "env": "prod"})
+	snapshot := metrics.NewRegistrySnapshot(true)
+	snapshot.Snapshot(registry)
+	lines := r.reporter.(*dogStatsdReporter).lines(snapshot)
+	if exp := []string{"requests:0|c|#team:x,env:prod,service:api"}; !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Expected %+v. Got %+v", exp, lines)
+	}
+
+	r = NewOpenTSDBReporter(registry, time.Minute, true, "localhost:4242", map[string]string{"host": "a"}, nil)
+	r.SetTags(map[string]string{"host": "b", "env": "prod"})
+	if tags := r.reporter.(*openTSDBReporter).tags; !reflect.DeepEqual(tags, map[string]string{"host": "b", "env": "prod"}) {
+		t.Fatalf("Expected global tags to override. Got %+v", tags)
+	}
+}
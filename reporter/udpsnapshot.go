@@ -0,0 +1,149 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+	"github.com/samuel/go-metrics/metricspb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+type udpSnapshotReporter struct {
+	backendBase
+	addr      string
+	source    string
+	tags      map[string]string
+	maxPacket int // defaults to StatsdPacketSizeEthernet
+}
+
+// NewUDPSnapshotReporter returns a reporter that sends each snapshot to addr
+// as marshaled metricspb.Snapshot datagrams, such as to an aggregator.Server
+// merging the metrics of several processes. Snapshots that don't fit in one
+// packet are split across several, each with the same source, timestamp
+// and tags. The buckets of histograms with fixed buckets are sent so they
+// can be merged.
+func NewUDPSnapshotReporter(registry metrics.Registry, interval time.Duration, latched bool, addr, source string) *PeriodicReporter {
+	ur := &udpSnapshotReporter{
+		addr:   addr,
+		source: source,
+	}
+	r := NewPeriodicReporter(registry, interval, false, latched, ur)
+	r.snapshot.SetKeepBuckets(true)
+	return r
+}
+
+func (r *udpSnapshotReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	maxPacket := r.maxPacket
+	if maxPacket <= 0 {
+		maxPacket = StatsdPacketSizeEthernet
+	}
+	msg := metricspb.FromSnapshot(snapshot, r.source, time.Now())
+	msg.Tags = r.tags
+	packets := splitSnapshot(msg, maxPacket)
+	if len(packets) == 0 {
+		return
+	}
+	conn, err := net.DialTimeout("udp", r.addr, statsdDialTimeout)
+	if err != nil {
+		r.errorf("", "udp: failed to send metrics: %w", err)
+		r.drop(snapshot.Len())
+		return
+	}
+	defer conn.Close()
+	for i, p := range packets {
+		b, err := proto.Marshal(p)
+		if err == nil {
+			conn.SetWriteDeadline(time.Now().Add(statsdWriteTimeout))
+			_, err = conn.Write(b)
+		}
+		if err != nil {
+			r.errorf("", "udp: failed to send metrics: %w", err)
+			for _, p := range packets[i:] {
+				r.drop(len(p.Values) + len(p.Distributions))
+			}
+			return
+		}
+		r.sent(len(b))
+	}
+}
+
+// splitSnapshot splits msg into messages that marshal to at most maxSize
+// bytes. A distribution and the values derived from it, such as its
+// percentiles, are kept in the same message since the aggregator recognises
+// those values by the distributions they're sent with. A value or
+// distribution larger than maxSize is sent on its own.
+func splitSnapshot(msg *metricspb.Snapshot, maxSize int) []*metricspb.Snapshot {
+	if len(msg.Values) == 0 && len(msg.Distributions) == 0 {
+		return nil
+	}
+	header := proto.Size(&metricspb.Snapshot{Timestamp: msg.Timestamp, Source: msg.Source, Tags: msg.Tags})
+	var packets []*metricspb.Snapshot
+	var cur *metricspb.Snapshot
+	size := 0
+	// next returns the message to add n bytes to, starting a new one when full.
+	next := func(n int) *metricspb.Snapshot {
+		if cur == nil || (size > header && size+n > maxSize) {
+			cur = &metricspb.Snapshot{Timestamp: msg.Timestamp, Source: msg.Source, Tags: msg.Tags}
+			packets = append(packets, cur)
+			size = header
+		}
+		size += n
+		return cur
+	}
+	fieldSize := func(m proto.Message) int {
+		return protowire.SizeTag(1) + protowire.SizeBytes(proto.Size(m))
+	}
+
+	type group struct {
+		distributions []*metricspb.Distribution
+		values        []*metricspb.Value
+	}
+	groups := make(map[string]*group, len(msg.Distributions))
+	var order []*group
+	for _, d := range msg.Distributions {
+		g := groups[d.Name]
+		if g == nil {
+			g = &group{}
+			groups[d.Name] = g
+			order = append(order, g)
+		}
+		g.distributions = append(g.distributions, d)
+	}
+	for _, v := range msg.Values {
+		if i := strings.LastIndexByte(v.Name, '/'); i > 0 && groups[v.Name[:i]] != nil {
+			g := groups[v.Name[:i]]
+			g.values = append(g.values, v)
+			continue
+		}
+		s := next(fieldSize(v))
+		s.Values = append(s.Values, v)
+	}
+	for _, g := range order {
+		n := 0
+		for _, v := range g.values {
+			n += fieldSize(v)
+		}
+		for _, d := range g.distributions {
+			n += fieldSize(d)
+		}
+		s := next(n)
+		s.Values = append(s.Values, g.values...)
+		s.Distributions = append(s.Distributions, g.distributions...)
+	}
+	return packets
+}
+
+func (r *udpSnapshotReporter) setMaxPacketSize(size int) {
+	r.maxPacket = size
+}
+
+func (r *udpSnapshotReporter) addTags(tags map[string]string) {
+	r.tags = mergeTags(r.tags, tags)
+}
@@ -0,0 +1,70 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-metrics/metricspb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSplitSnapshot(t *testing.T) {
+	msg := &metricspb.Snapshot{Timestamp: 1, Source: "host1"}
+	for i := 0; i < 100; i++ {
+		msg.Values = append(msg.Values, &metricspb.Value{Name: "value/" + strconv.Itoa(i), Value: float64(i)})
+		msg.Distributions = append(msg.Distributions, &metricspb.Distribution{Name: "dist/" + strconv.Itoa(i), Count: 1})
+	}
+	packets := splitSnapshot(msg, 512)
+	if len(packets) < 2 {
+		t.Fatalf("Expected the snapshot to be split. Got %d packets", len(packets))
+	}
+	values, dists := 0, 0
+	for _, p := range packets {
+		if n := proto.Size(p); n > 512 {
+			t.Fatalf("Expected packets of at most 512 bytes. Got %d", n)
+		}
+		if p.Source != "host1" || p.Timestamp != 1 {
+			t.Fatalf("Expected source and timestamp on every packet. Got %+v", p)
+		}
+		values += len(p.Values)
+		dists += len(p.Distributions)
+	}
+	if values != 100 || dists != 100 {
+		t.Fatalf("Expected 100 values and distributions. Got %d and %d", values, dists)
+	}
+	if packets := splitSnapshot(&metricspb.Snapshot{}, 512); len(packets) != 0 {
+		t.Fatalf("Expected no packets for an empty snapshot. Got %d", len(packets))
+	}
+}
+
+func TestSplitSnapshotKeepsPercentiles(t *testing.T) {
+	msg := &metricspb.Snapshot{Timestamp: 1, Source: "host1"}
+	for i := 0; i < 20; i++ {
+		name := "latency" + strconv.Itoa(i)
+		msg.Values = append(msg.Values,
+			&metricspb.Value{Name: name + "/p50", Value: 1},
+			&metricspb.Value{Name: name + "/p99", Value: 2})
+		msg.Distributions = append(msg.Distributions, &metricspb.Distribution{Name: name, Count: 1,
+			Buckets: []*metricspb.Bucket{{UpperBound: 1, Count: 1}, {UpperBound: 10}, {UpperBound: 100}}})
+	}
+	packets := splitSnapshot(msg, 256)
+	if len(packets) < 2 {
+		t.Fatalf("Expected the snapshot to be split. Got %d packets", len(packets))
+	}
+	for _, p := range packets {
+		dists := make(map[string]bool)
+		for _, d := range p.Distributions {
+			dists[d.Name] = true
+		}
+		for _, v := range p.Values {
+			if name := v.Name[:strings.LastIndexByte(v.Name, '/')]; !dists[name] {
+				t.Fatalf("Expected %s in the same packet as %s", v.Name, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// WebhookConfig configures where and how the webhook reporter posts.
+type WebhookConfig struct {
+	URL string
+	// Header is added to every request, for example for an API key.
+	Header http.Header
+	// Username and Password set basic auth if Username is not empty.
+	Username string
+	Password string
+	// BearerToken sets an "Authorization: Bearer" header if not empty.
+	BearerToken string
+	// Gzip compresses the body.
+	Gzip bool
+
+	// Template, if not nil, renders the body from a WebhookData in place of
+	// the default JSON. The function "json" is available to templates to
+	// encode a value as JSON. ContentType defaults to that of the reporter's
+	// codec, application/json unless set with SetCodec.
+	Template    *template.Template
+	ContentType string
+}
+
+// WebhookData is the body posted by the webhook reporter, encoded by its
+// codec, and the data passed to a body template.
+type WebhookData struct {
+	Timestamp     int64                 `json:"timestamp"`
+	Host          string                `json:"host"`
+	Values        []messageValue        `json:"values"`
+	Distributions []messageDistribution `json:"distributions"`
+}
+
+// WebhookFuncs are the functions available to webhook body templates.
+var WebhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type webhookReporter struct {
+	backendBase
+	messageCodec
+	config WebhookConfig
+	host   string
+	client *http.Client
+}
+
+// NewWebhookReporter returns a reporter that posts each interval's snapshot
+// to an arbitrary URL. Parse templates with WebhookFuncs to be able to use
+// the json function.
+func NewWebhookReporter(registry metrics.Registry, interval time.Duration, latched bool, config WebhookConfig) *PeriodicReporter {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	wr := &webhookReporter{
+		config: config,
+		host:   host,
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+	return NewPeriodicReporter(registry, interval, false, latched, wr)
+}
+
+func (r *webhookReporter) data(snapshot *metrics.RegistrySnapshot, ts int64) *WebhookData {
+	d := &WebhookData{
+		Timestamp:     ts,
+		Host:          r.host,
+		Values:        make([]messageValue, 0, len(snapshot.Values)),
+		Distributions: make([]messageDistribution, 0, len(snapshot.Distributions)),
+	}
+	for _, v := range snapshot.Values {
+		d.Values = append(d.Values, messageValue{Name: v.Name, Timestamp: ts, Value: v.Value, Counter: snapshot.IsCounter(v.Name)})
+	}
+	for _, v := range snapshot.Distributions {
+		d.Distributions = append(d.Distributions, messageDistribution{Name: v.Name, Timestamp: ts, Value: v.Value})
+	}
+	return d
+}
+
+func (r *webhookReporter) body(d *WebhookData) ([]byte, error) {
+	b := &bytes.Buffer{}
+	if r.config.Template != nil {
+		if err := r.config.Template.Execute(b, d); err != nil {
+			return nil, err
+		}
+	} else {
+		body, err := r.marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(body)
+	}
+	if !r.config.Gzip {
+		return b.Bytes(), nil
+	}
+	z := &bytes.Buffer{}
+	zw := gzip.NewWriter(z)
+	zw.Write(b.Bytes())
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return z.Bytes(), nil
+}
+
+func (r *webhookReporter) Report(snapshot *metrics.RegistrySnapshot) {
+	snapshot = r.finite(snapshot)
+	body, err := r.body(r.data(snapshot, time.Now().Unix()))
+	if err != nil {
+		r.errorf("", "webhook: failed to render body: %w", err)
+		r.drop(snapshot.Len())
+		return
+	}
+	if err := r.send(func() error { return r.post(body) }); err != nil {
+		r.errorf("", "webhook: failed to post metrics: %w", err)
+		r.drop(snapshot.Len())
+	}
+}
+
+func (r *webhookReporter) post(body []byte) error {
+	req, err := http.NewRequest("POST", r.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range r.config.Header {
+		req.Header[k] = v
+	}
+	contentType := r.config.ContentType
+	if contentType == "" {
+		contentType = r.currentCodec().ContentType()
+	}
+	req.Header.Set("Content-Type", contentType)
+	if r.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if r.config.Username != "" {
+		req.SetBasicAuth(r.config.Username, r.config.Password)
+	}
+	if r.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.config.BearerToken)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, msg)
+	}
+	r.sent(len(body))
+	return nil
+}
+
+func (r *webhookReporter) setHTTPClient(client *http.Client) {
+	r.client = client
+}
@@ -0,0 +1,71 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestWebhookReporter(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ = ioutil.ReadAll(zr)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(2)
+	registry.Add("requests", counter)
+
+	r := NewWebhookReporter(registry, time.Minute, true, WebhookConfig{
+		URL:         server.URL,
+		Header:      http.Header{"X-Api-Key": {"secret"}},
+		BearerToken: "token",
+		Gzip:        true,
+	})
+	r.Flush()
+	if header.Get("X-Api-Key") != "secret" || header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("Expected configured headers. Got %+v", header)
+	}
+	var d WebhookData
+	if err := json.Unmarshal(body, &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Values) != 1 || d.Values[0].Name != "requests" || d.Values[0].Value != 2 || !d.Values[0].Counter {
+		t.Fatalf("Expected requests counter. Got %s", body)
+	}
+
+	tmpl := template.Must(template.New("body").Funcs(WebhookFuncs).Parse(
+		`{{range .Values}}{{.Name}}={{.Value}} {{end}}{{json .Host}}`))
+	r = NewWebhookReporter(registry, time.Minute, true, WebhookConfig{URL: server.URL, Template: tmpl, ContentType: "text/plain", Gzip: true})
+	counter.Inc(3)
+	r.Flush()
+	host := r.reporter.(*webhookReporter).host
+	if exp := "requests=3 " + `"` + host + `"`; string(body) != exp {
+		t.Fatalf("Expected %q. Got %q", exp, body)
+	}
+	if header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("Expected text/plain. Got %s", header.Get("Content-Type"))
+	}
+}
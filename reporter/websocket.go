@@ -0,0 +1,93 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+const (
+	defaultWebSocketInterval = 5 * time.Second
+	minWebSocketInterval     = 100 * time.Millisecond
+	webSocketWriteTimeout    = 10 * time.Second
+)
+
+var webSocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// webSocketMessage is pushed to WebSocket clients every interval.
+type webSocketMessage struct {
+	Timestamp int64           `json:"timestamp"`
+	Metrics   json.RawMessage `json:"metrics"`
+}
+
+// WebSocketHandler returns an http.Handler that upgrades requests to a
+// WebSocket and pushes the registry, encoded as by JSONHandler, every
+// interval until the client goes away. Each message is a JSON object with
+// "timestamp" in Unix milliseconds and "metrics". The query parameter
+// "interval" sets the interval as a duration such as "1s", defaulting to 5
+// seconds with a minimum of 100ms. Reading the metrics does not reset them.
+func WebSocketHandler(registry metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		interval := defaultWebSocketInterval
+		if s := req.FormValue("interval"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				http.Error(w, "invalid interval: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			interval = d
+		}
+		if interval < minWebSocketInterval {
+			interval = minWebSocketInterval
+		}
+		conn, err := webSocketUpgrader.Upgrade(w, req, nil)
+		if err != nil {
+			// Upgrade has already replied to the client.
+			return
+		}
+		defer conn.Close()
+
+		// Messages from the client are ignored but reading is needed to
+		// notice when it closes the connection.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			b, err := json.Marshal(registry)
+			if err != nil {
+				log.Printf("reporter: failed to encode registry: %s", err.Error())
+				return
+			}
+			msg := webSocketMessage{Timestamp: time.Now().UnixNano() / int64(time.Millisecond), Metrics: b}
+			conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-closed:
+				return
+			}
+		}
+	})
+}
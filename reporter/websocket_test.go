@@ -0,0 +1,50 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package reporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	registry.Add("requests", counter)
+	counter.Inc(1)
+	server := httptest.NewServer(WebSocketHandler(registry))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/?interval=100ms"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 1; i <= 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var msg struct {
+			Timestamp int64
+			Metrics   map[string]float64
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Timestamp == 0 || msg.Metrics["requests"] != float64(i) {
+			t.Fatalf("Expected %d requests. Got %+v", i, msg)
+		}
+		counter.Inc(1)
+	}
+
+	if _, _, err := websocket.DefaultDialer.Dial(strings.Replace(url, "100ms", "soon", 1), nil); err != websocket.ErrBadHandshake {
+		t.Fatalf("Expected a bad interval to be rejected. Got %v", err)
+	}
+}
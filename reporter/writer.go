@@ -7,30 +7,34 @@ package reporter
 import (
 	"fmt"
 	"io"
-	"log"
 	"time"
 
 	"github.com/samuel/go-metrics/metrics"
 )
 
 type writerReporter struct {
+	backendBase
 	w io.Writer
 }
 
 func NewWriterReporter(registry metrics.Registry, interval time.Duration, latched bool, w io.Writer) *PeriodicReporter {
-	return NewPeriodicReporter(registry, interval, false, latched, &writerReporter{w})
+	return NewPeriodicReporter(registry, interval, false, latched, &writerReporter{w: w})
 }
 
 func (r *writerReporter) Report(snapshot *metrics.RegistrySnapshot) {
 	fmt.Fprintf(r.w, "%+v\n", time.Now())
-	for _, v := range snapshot.Values {
-		if _, err := fmt.Fprintf(r.w, "%s: %f\n", v.Name, v.Value); err != nil {
-			log.Printf("metricswriter: failed to post %s: %s", v.Name, err.Error())
+	snapshot.DoSorted(func(name string, metric interface{}) error {
+		var err error
+		switch m := metric.(type) {
+		case metrics.GaugeValue:
+			_, err = fmt.Fprintf(r.w, "%s: %f\n", name, float64(m))
+		case metrics.NamedDistribution:
+			_, err = fmt.Fprintf(r.w, "%s: %+v\n", name, m.Value)
 		}
-	}
-	for _, v := range snapshot.Distributions {
-		if _, err := fmt.Fprintf(r.w, "%s: %+v\n", v.Name, v.Value); err != nil {
-			log.Printf("metricswriter: failed to post %s: %s", v.Name, err.Error())
+		if err != nil {
+			r.errorf(name, "metricswriter: failed to post %s: %w", name, err)
+			r.drop(1)
 		}
-	}
+		return nil
+	})
 }
@@ -0,0 +1,166 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+// Package statsd receives metrics in the statsd protocol and records them in
+// a registry so they can be exported by any of the reporters.
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/samuel/go-metrics/internal/listen"
+	"github.com/samuel/go-metrics/metrics"
+)
+
+// ErrListenerClosed is returned by Serve and ServePacket after Close.
+var ErrListenerClosed = errors.New("statsd: listener closed")
+
+// Listener receives statsd lines of the form name:value|type[|@rate][|#tags]
+// and records them in a registry. Counters (c) are recorded in a
+// *metrics.Counter scaled up by their sample rate, gauges (g) in a
+// *metrics.FloatGauge where a leading + or - adjusts the value, and timers
+// (ms), histograms (h) and distributions (d) in a histogram with their value
+// rounded to an integer. Tags are ignored and sets (s) are not supported.
+type Listener struct {
+	registry metrics.Registry
+
+	// NewHistogram returns the histogram for a new timer. It defaults to
+	// metrics.NewUnbiasedHistogram.
+	NewHistogram func() metrics.Histogram
+	// ErrorLog logs malformed lines. If nil the standard logger is used.
+	ErrorLog *log.Logger
+
+	server listen.Server
+}
+
+// NewListener returns a Listener recording metrics in registry.
+func NewListener(registry metrics.Registry) *Listener {
+	return &Listener{
+		registry: registry,
+		server:   listen.Server{ErrClosed: ErrListenerClosed},
+	}
+}
+
+// ServePacket reads datagrams, each holding one or more newline separated
+// lines, from conn until it's closed.
+func (l *Listener) ServePacket(conn net.PacketConn) error {
+	return l.server.ServePacket(conn, func(packet []byte, addr net.Addr) {
+		l.HandlePacket(packet)
+	})
+}
+
+// Serve accepts stream connections on ln, such as TCP, and reads newline
+// separated lines from each until ln is closed.
+func (l *Listener) Serve(ln net.Listener) error {
+	return l.server.Serve(ln, l.handleLine)
+}
+
+// Close stops serving and closes all connections.
+func (l *Listener) Close() error {
+	return l.server.Close()
+}
+
+// HandlePacket records the newline separated lines in a packet.
+func (l *Listener) HandlePacket(packet []byte) {
+	for len(packet) > 0 {
+		line := packet
+		if i := bytes.IndexByte(packet, '\n'); i >= 0 {
+			line, packet = packet[:i], packet[i+1:]
+		} else {
+			packet = nil
+		}
+		l.handleLine(line)
+	}
+}
+
+func (l *Listener) handleLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	if err := l.HandleLine(string(line)); err != nil {
+		l.logf("statsd: %s", err.Error())
+	}
+}
+
+func (l *Listener) logf(format string, args ...interface{}) {
+	if l.ErrorLog != nil {
+		l.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// HandleLine records a single statsd line.
+func (l *Listener) HandleLine(line string) error {
+	fields := strings.Split(line, "|")
+	if len(fields) < 2 {
+		return fmt.Errorf("missing type in %q", line)
+	}
+	// Tags follow the value so only the first field holds the name.
+	i := strings.LastIndexByte(fields[0], ':')
+	if i <= 0 {
+		return fmt.Errorf("missing value in %q", line)
+	}
+	name, rawValue, typ := fields[0][:i], fields[0][i+1:], fields[1]
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid value in %q", line)
+	}
+	rate := 1.0
+	for _, f := range fields[2:] {
+		if strings.HasPrefix(f, "@") {
+			rate, err = strconv.ParseFloat(f[1:], 64)
+			if err != nil || !(rate > 0 && rate <= 1) {
+				return fmt.Errorf("invalid sample rate in %q", line)
+			}
+		}
+	}
+
+	switch typ {
+	case "c":
+		if value < 0 {
+			return fmt.Errorf("negative count in %q", line)
+		}
+		c, ok := l.registry.GetOrAdd(name, func() interface{} { return metrics.NewCounter() }).(*metrics.Counter)
+		if !ok {
+			return l.conflict(name, "counter")
+		}
+		c.Inc(uint64(math.Round(value / rate)))
+	case "g":
+		g, ok := l.registry.GetOrAdd(name, func() interface{} { return metrics.NewFloatGauge() }).(*metrics.FloatGauge)
+		if !ok {
+			return l.conflict(name, "gauge")
+		}
+		if rawValue[0] == '+' || rawValue[0] == '-' {
+			g.Add(value)
+		} else {
+			g.Set(value)
+		}
+	case "ms", "h", "d":
+		newHistogram := l.NewHistogram
+		if newHistogram == nil {
+			newHistogram = metrics.NewUnbiasedHistogram
+		}
+		h, ok := l.registry.GetOrAdd(name, func() interface{} { return newHistogram() }).(metrics.Histogram)
+		if !ok {
+			return l.conflict(name, "histogram")
+		}
+		h.Update(int64(math.Round(value)))
+	default:
+		return fmt.Errorf("unsupported type %q in %q", typ, line)
+	}
+	return nil
+}
+
+func (l *Listener) conflict(name, typ string) error {
+	return fmt.Errorf("%s is already registered as %T not a %s", name, l.registry.Get(name), typ)
+}
@@ -0,0 +1,112 @@
+// Copyright 2012 Samuel Stauffer. All rights reserved.
+// Use of this source code is governed by a 3-clause BSD
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-metrics/metrics"
+)
+
+func TestListenerHandlePacket(t *testing.T) {
+	registry := metrics.NewRegistry()
+	l := NewListener(registry)
+	l.HandlePacket([]byte("requests:2|c\nrequests:1|c|@0.5\ntemp:20|g\ntemp:-2.5|g\nlatency:12.4|ms|#host:a\nlatency:30|h\n"))
+
+	if c, ok := registry.Get("requests").(*metrics.Counter); !ok || c.Count() != 4 {
+		t.Fatalf("Expected requests counter of 4. Got %+v", registry.Get("requests"))
+	}
+	if g, ok := registry.Get("temp").(*metrics.FloatGauge); !ok || g.Value() != 17.5 {
+		t.Fatalf("Expected temp gauge of 17.5. Got %+v", registry.Get("temp"))
+	}
+	h, ok := registry.Get("latency").(metrics.Histogram)
+	if !ok {
+		t.Fatalf("Expected latency histogram. Got %+v", registry.Get("latency"))
+	}
+	if d := h.Distribution(); d.Count != 2 || d.Min != 12 || d.Max != 30 {
+		t.Fatalf("Expected 2 timings from 12 to 30. Got %+v", d)
+	}
+}
+
+func TestListenerHandleLineErrors(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Add("gauge", metrics.NewIntegerGauge())
+	l := NewListener(registry)
+	for _, line := range []string{
+		"novalue",
+		"notype:1",
+		"novalue|c",
+		"bad:x|c",
+		"neg:-1|c",
+		"rate:1|c|@2",
+		"rate:1|c|@NaN",
+		"nan:NaN|g",
+		"inf:+Inf|c",
+		"inf:-Inf|ms",
+		"set:1|s",
+		"gauge:1|c",
+	} {
+		if err := l.HandleLine(line); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestListenerServe(t *testing.T) {
+	registry := metrics.NewRegistry()
+	l := NewListener(registry)
+	l.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- l.ServePacket(pc) }()
+	go func() { errc <- l.Serve(ln) }()
+
+	uc, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uc.Close()
+	if _, err := uc.Write([]byte("udp:1|c\nudp:2|c")); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tc.Write([]byte("tcp:3|g\n")); err != nil {
+		t.Fatal(err)
+	}
+	tc.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for registry.Get("udp") == nil || registry.Get("tcp") == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected udp and tcp metrics. Got %+v", registry)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := registry.Get("udp").(*metrics.Counter); c.Count() != 3 {
+		t.Fatalf("Expected udp counter of 3. Got %d", c.Count())
+	}
+
+	l.Close()
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != ErrListenerClosed {
+			t.Fatalf("Expected %v. Got %v", ErrListenerClosed, err)
+		}
+	}
+}